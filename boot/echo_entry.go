@@ -14,32 +14,35 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rookie-ninja/rk-echo/middleware/audit"
 	"github.com/rookie-ninja/rk-echo/middleware/auth"
+	"github.com/rookie-ninja/rk-echo/middleware/authz"
+	"github.com/rookie-ninja/rk-echo/middleware/cache"
+	"github.com/rookie-ninja/rk-echo/middleware/coalesce"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-echo/middleware/cors"
 	"github.com/rookie-ninja/rk-echo/middleware/csrf"
 	"github.com/rookie-ninja/rk-echo/middleware/gzip"
+	"github.com/rookie-ninja/rk-echo/middleware/idempotency"
+	"github.com/rookie-ninja/rk-echo/middleware/introspect"
+	"github.com/rookie-ninja/rk-echo/middleware/ipfilter"
 	"github.com/rookie-ninja/rk-echo/middleware/jwt"
 	"github.com/rookie-ninja/rk-echo/middleware/log"
 	"github.com/rookie-ninja/rk-echo/middleware/meta"
 	"github.com/rookie-ninja/rk-echo/middleware/panic"
 	rkechoprom "github.com/rookie-ninja/rk-echo/middleware/prom"
+	"github.com/rookie-ninja/rk-echo/middleware/proxy"
 	"github.com/rookie-ninja/rk-echo/middleware/ratelimit"
 	"github.com/rookie-ninja/rk-echo/middleware/secure"
+	"github.com/rookie-ninja/rk-echo/middleware/session"
+	"github.com/rookie-ninja/rk-echo/middleware/signature"
 	"github.com/rookie-ninja/rk-echo/middleware/timeout"
 	"github.com/rookie-ninja/rk-echo/middleware/tracing"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
 	rkerror "github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/auth"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/cors"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/csrf"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/jwt"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/log"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/meta"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/panic"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/ratelimit"
-	"github.com/rookie-ninja/rk-entry/v2/middleware/secure"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/timeout"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/tracing"
 	"github.com/rookie-ninja/rk-query"
@@ -80,21 +83,31 @@ type BootEcho struct {
 		EventEntry    string                        `yaml:"eventEntry" json:"eventEntry"`
 		Static        rkentry.BootStaticFileHandler `yaml:"static" json:"static"`
 		PProf         rkentry.BootPProf             `yaml:"pprof" json:"pprof"`
+		Proxy         rkechoproxy.BootConfig        `yaml:"proxy" json:"proxy"`
 		Middleware    struct {
-			Ignore     []string                `yaml:"ignore" json:"ignore"`
-			ErrorModel string                  `yaml:"errorModel" json:"errorModel"`
-			Logging    rkmidlog.BootConfig     `yaml:"logging" json:"logging"`
-			Prom       rkmidprom.BootConfig    `yaml:"prom" json:"prom"`
-			Auth       rkmidauth.BootConfig    `yaml:"auth" json:"auth"`
-			Cors       rkmidcors.BootConfig    `yaml:"cors" json:"cors"`
-			Meta       rkmidmeta.BootConfig    `yaml:"meta" json:"meta"`
-			Jwt        rkmidjwt.BootConfig     `yaml:"jwt" json:"jwt"`
-			Secure     rkmidsec.BootConfig     `yaml:"secure" json:"secure"`
-			RateLimit  rkmidlimit.BootConfig   `yaml:"rateLimit" json:"rateLimit"`
-			Csrf       rkmidcsrf.BootConfig    `yaml:"csrf" yaml:"csrf"`
-			Timeout    rkmidtimeout.BootConfig `yaml:"timeout" json:"timeout"`
-			Trace      rkmidtrace.BootConfig   `yaml:"trace" json:"trace"`
-			Gzip       struct {
+			Ignore      []string                    `yaml:"ignore" json:"ignore"`
+			ErrorModel  string                      `yaml:"errorModel" json:"errorModel"`
+			Logging     rkecholog.BootConfig        `yaml:"logging" json:"logging"`
+			Prom        rkmidprom.BootConfig        `yaml:"prom" json:"prom"`
+			Auth        rkechoauth.BootConfig       `yaml:"auth" json:"auth"`
+			Cors        rkechocors.BootConfig       `yaml:"cors" json:"cors"`
+			Meta        rkechometa.BootConfig       `yaml:"meta" json:"meta"`
+			Jwt         rkechojwt.BootConfig        `yaml:"jwt" json:"jwt"`
+			Introspect  rkechointrospect.BootConfig `yaml:"introspect" json:"introspect"`
+			Authz       rkechoauthz.BootConfig      `yaml:"authz" json:"authz"`
+			Secure      rkechosec.BootConfig        `yaml:"secure" json:"secure"`
+			RateLimit   rkecholimit.BootConfig      `yaml:"rateLimit" json:"rateLimit"`
+			Session     rkechosession.BootConfig    `yaml:"session" json:"session"`
+			Csrf        rkechocsrf.BootConfig       `yaml:"csrf" yaml:"csrf"`
+			Timeout     rkmidtimeout.BootConfig     `yaml:"timeout" json:"timeout"`
+			Trace       rkmidtrace.BootConfig       `yaml:"trace" json:"trace"`
+			Idempotency rkechoidem.BootConfig       `yaml:"idempotency" json:"idempotency"`
+			Cache       rkechocache.BootConfig      `yaml:"cache" json:"cache"`
+			Coalesce    rkechocoalesce.BootConfig   `yaml:"coalesce" json:"coalesce"`
+			Signature   rkechosig.BootConfig        `yaml:"signature" json:"signature"`
+			Audit       rkechoaudit.BootConfig      `yaml:"audit" json:"audit"`
+			IpFilter    rkechoipfilter.BootConfig   `yaml:"ipFilter" json:"ipFilter"`
+			Gzip        struct {
 				Enabled bool     `yaml:"enabled" json:"enabled"`
 				Ignore  []string `yaml:"ignore" json:"ignore"`
 				Level   string   `yaml:"level" json:"level"`
@@ -119,6 +132,8 @@ type EchoEntry struct {
 	StaticFileEntry    *rkentry.StaticFileHandlerEntry `json:"-" yaml:"-"`
 	CertEntry          *rkentry.CertEntry              `json:"-" yaml:"-"`
 	PProfEntry         *rkentry.PProfEntry             `json:"-" yaml:"-"`
+	JwtRefresher       *rkechojwt.TokenRefresher       `json:"-" yaml:"-"`
+	CspReporter        *rkechosec.CspReporter          `json:"-" yaml:"-"`
 	bootstrapLogOnce   sync.Once                       `json:"-" yaml:"-"`
 }
 
@@ -181,6 +196,9 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 		promRegistry := prometheus.NewRegistry()
 		promEntry := rkentry.RegisterPromEntry(&element.Prom, rkentry.WithRegistryPromEntry(promRegistry))
 
+		// metrics of outbound requests sent with rkechoctx.HTTPClient()
+		rkechoctx.SetClientRegisterer(element.Name, promRegistry)
+
 		// Register common service entry
 		commonServiceEntry := rkentry.RegisterCommonServiceEntry(&element.CommonService)
 
@@ -203,11 +221,17 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 			rkmid.SetErrorBuilder(rkerror.NewErrorBuilderAMZN())
 		}
 
+		// proxy middleware, client ip should be resolved before logging
+		proxyOpts := rkechoproxy.ToOptions(&element.Proxy, element.Name, EchoEntryType)
+		if element.Proxy.Enabled {
+			inters = append(inters, rkechoproxy.Middleware(proxyOpts...))
+		}
+
 		// logging middlewares
 		if element.Middleware.Logging.Enabled {
-			inters = append(inters, rkecholog.Middleware(
-				rkmidlog.ToOptions(&element.Middleware.Logging, element.Name, EchoEntryType,
-					loggerEntry, eventEntry)...))
+			inters = append(inters, rkecholog.MiddlewareWithOptions(
+				rkecholog.ToOptions(&element.Middleware.Logging, element.Name, EchoEntryType,
+					loggerEntry, eventEntry, promRegistry)...))
 		}
 
 		// insert panic interceptor
@@ -227,28 +251,82 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 				rkmidtrace.ToOptions(&element.Middleware.Trace, element.Name, EchoEntryType)...))
 		}
 
+		// ip filter middleware
+		if element.Middleware.IpFilter.Enabled {
+			inters = append(inters, rkechoipfilter.Middleware(
+				rkechoipfilter.ToOptions(&element.Middleware.IpFilter, element.Name, EchoEntryType, promRegistry)...))
+		}
+
+		// audit middleware
+		if element.Middleware.Audit.Enabled {
+			inters = append(inters, rkechoaudit.Middleware(
+				rkechoaudit.ToOptions(&element.Middleware.Audit, element.Name, EchoEntryType, eventEntry)...))
+		}
+
 		// cors middleware
 		if element.Middleware.Cors.Enabled {
-			inters = append(inters, rkechocors.Middleware(
-				rkmidcors.ToOptions(&element.Middleware.Cors, element.Name, EchoEntryType)...))
+			inters = append(inters, rkechocors.MiddlewareWithOptions(
+				rkechocors.ToOptions(&element.Middleware.Cors, element.Name, EchoEntryType)...))
 		}
 
 		// jwt middleware
+		var jwtRefresher *rkechojwt.TokenRefresher
 		if element.Middleware.Jwt.Enabled {
 			inters = append(inters, rkechojwt.Middleware(
-				rkmidjwt.ToOptions(&element.Middleware.Jwt, element.Name, EchoEntryType)...))
+				rkechojwt.ToOptions(&element.Middleware.Jwt, element.Name, EchoEntryType)...))
+
+			if element.Middleware.Jwt.Refresh.Enabled {
+				jwtRefresher = rkechojwt.GetTokenRefresher(element.Name)
+			}
+		}
+
+		// introspect middleware
+		if element.Middleware.Introspect.Enabled {
+			inters = append(inters, rkechointrospect.Middleware(
+				rkechointrospect.ToOptions(&element.Middleware.Introspect, element.Name, EchoEntryType)...))
 		}
 
 		// secure middleware
+		var cspReporter *rkechosec.CspReporter
 		if element.Middleware.Secure.Enabled {
-			inters = append(inters, rkechosec.Middleware(
-				rkmidsec.ToOptions(&element.Middleware.Secure, element.Name, EchoEntryType)...))
+			opts := rkechosec.ToOptions(&element.Middleware.Secure, element.Name, EchoEntryType, promRegistry)
+
+			// swagger and docs pages rely on inline scripts, relax them so that API could stay strict
+			if swEntry != nil {
+				opts = append(opts, rkechosec.WithRelaxedPath(swEntry.Path))
+			}
+			if docsEntry != nil {
+				opts = append(opts, rkechosec.WithRelaxedPath(docsEntry.Path))
+			}
+
+			inters = append(inters, rkechosec.MiddlewareWithOptions(opts...))
+
+			if element.Middleware.Secure.CspReport.Enabled {
+				cspReporter = rkechosec.GetCspReporter(element.Name)
+			}
+		}
+
+		// session middleware
+		if element.Middleware.Session.Enabled {
+			inters = append(inters, rkechosession.Middleware(
+				rkechosession.ToOptions(&element.Middleware.Session, element.Name, EchoEntryType)...))
 		}
 
 		// csrf middleware
 		if element.Middleware.Csrf.Enabled {
-			inters = append(inters, rkechocsrf.Middleware(
-				rkmidcsrf.ToOptions(&element.Middleware.Csrf, element.Name, EchoEntryType)...))
+			// browsers send CSP reports without csrf token
+			if cspReporter != nil {
+				element.Middleware.Csrf.Exempt = append(element.Middleware.Csrf.Exempt, cspReporter.Path())
+			}
+
+			inters = append(inters, rkechocsrf.MiddlewareWithOptions(
+				rkechocsrf.ToOptions(&element.Middleware.Csrf, element.Name, EchoEntryType)...))
+		}
+
+		// signature middleware
+		if element.Middleware.Signature.Enabled {
+			inters = append(inters, rkechosig.Middleware(
+				rkechosig.ToOptions(&element.Middleware.Signature, element.Name, EchoEntryType)...))
 		}
 
 		// gzip middleware
@@ -263,14 +341,20 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 
 		// meta middleware
 		if element.Middleware.Meta.Enabled {
-			inters = append(inters, rkechometa.Middleware(
-				rkmidmeta.ToOptions(&element.Middleware.Meta, element.Name, EchoEntryType)...))
+			inters = append(inters, rkechometa.MiddlewareWithOptions(
+				rkechometa.ToOptions(&element.Middleware.Meta, element.Name, EchoEntryType)...))
 		}
 
 		// auth middlewares
 		if element.Middleware.Auth.Enabled {
-			inters = append(inters, rkechoauth.Middleware(
-				rkmidauth.ToOptions(&element.Middleware.Auth, element.Name, EchoEntryType)...))
+			inters = append(inters, rkechoauth.MiddlewareWithOptions(
+				rkechoauth.ToOptions(&element.Middleware.Auth, element.Name, EchoEntryType, promRegistry)...))
+		}
+
+		// authz middleware, after jwt, introspect and auth so that scopes and principal are resolved
+		if element.Middleware.Authz.Enabled {
+			inters = append(inters, rkechoauthz.Middleware(
+				rkechoauthz.ToOptions(&element.Middleware.Authz, element.Name, EchoEntryType)...))
 		}
 
 		// timeout middlewares
@@ -281,8 +365,26 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 
 		// rate limit middleware
 		if element.Middleware.RateLimit.Enabled {
-			inters = append(inters, rkecholimit.Middleware(
-				rkmidlimit.ToOptions(&element.Middleware.RateLimit, element.Name, EchoEntryType)...))
+			inters = append(inters, rkecholimit.MiddlewareWithOptions(
+				rkecholimit.ToOptions(&element.Middleware.RateLimit, element.Name, EchoEntryType)...))
+		}
+
+		// idempotency middleware
+		if element.Middleware.Idempotency.Enabled {
+			inters = append(inters, rkechoidem.Middleware(
+				rkechoidem.ToOptions(&element.Middleware.Idempotency, element.Name, EchoEntryType)...))
+		}
+
+		// cache middleware
+		if element.Middleware.Cache.Enabled {
+			inters = append(inters, rkechocache.Middleware(
+				rkechocache.ToOptions(&element.Middleware.Cache, element.Name, EchoEntryType, promRegistry)...))
+		}
+
+		// coalesce middleware
+		if element.Middleware.Coalesce.Enabled {
+			inters = append(inters, rkechocoalesce.Middleware(
+				rkechocoalesce.ToOptions(&element.Middleware.Coalesce, element.Name, EchoEntryType, promRegistry)...))
 		}
 
 		entry := RegisterEchoEntry(
@@ -297,7 +399,13 @@ func RegisterEchoEntryYAML(raw []byte) map[string]rkentry.Entry {
 			WithCommonServiceEntry(commonServiceEntry),
 			WithCertEntry(certEntry),
 			WithPProfEntry(pprofEntry),
-			WithStaticFileHandlerEntry(staticEntry))
+			WithStaticFileHandlerEntry(staticEntry),
+			WithJwtRefresher(jwtRefresher),
+			WithCspReporter(cspReporter))
+
+		if element.Proxy.Enabled {
+			entry.Echo.IPExtractor = rkechoproxy.IPExtractor(proxyOpts...)
+		}
 
 		entry.AddMiddleware(inters...)
 
@@ -431,6 +539,16 @@ func (entry *EchoEntry) Bootstrap(ctx context.Context) {
 		entry.Echo.GET(path.Join(entry.PProfEntry.Path, "threadcreate"), echo.WrapHandler(http.HandlerFunc(pprof.Handler("threadcreate").ServeHTTP)))
 	}
 
+	// Is jwt refresh enabled?
+	if entry.IsJwtRefreshEnabled() {
+		entry.Echo.POST(entry.JwtRefresher.Path(), entry.JwtRefresher.Handler())
+	}
+
+	// Is CSP report enabled?
+	if entry.IsCspReportEnabled() {
+		entry.Echo.POST(entry.CspReporter.Path(), entry.CspReporter.Handler(entry.EventEntry))
+	}
+
 	// Start echo server
 	go entry.startServer(event, logger)
 
@@ -465,6 +583,12 @@ func (entry *EchoEntry) Bootstrap(ctx context.Context) {
 		if entry.IsPProfEnabled() {
 			entry.LoggerEntry.Info(fmt.Sprintf("PProfEntry: %s://localhost:%d%s", scheme, entry.Port, entry.PProfEntry.Path))
 		}
+		if entry.IsJwtRefreshEnabled() {
+			entry.LoggerEntry.Info(fmt.Sprintf("JwtRefresh: %s://localhost:%d%s", scheme, entry.Port, entry.JwtRefresher.Path()))
+		}
+		if entry.IsCspReportEnabled() {
+			entry.LoggerEntry.Info(fmt.Sprintf("CspReport: %s://localhost:%d%s", scheme, entry.Port, entry.CspReporter.Path()))
+		}
 		entry.EventEntry.Finish(event)
 	})
 }
@@ -600,6 +724,16 @@ func (entry *EchoEntry) IsPProfEnabled() bool {
 	return entry.PProfEntry != nil
 }
 
+// IsJwtRefreshEnabled Is jwt refresh endpoint enabled?
+func (entry *EchoEntry) IsJwtRefreshEnabled() bool {
+	return entry.JwtRefresher != nil
+}
+
+// IsCspReportEnabled Is CSP report endpoint enabled?
+func (entry *EchoEntry) IsCspReportEnabled() bool {
+	return entry.CspReporter != nil
+}
+
 // IsStaticFileHandlerEnabled Is static file handler entry enabled?
 func (entry *EchoEntry) IsStaticFileHandlerEnabled() bool {
 	return entry.StaticFileEntry != nil
@@ -807,3 +941,17 @@ func WithDocsEntry(docs *rkentry.DocsEntry) EchoEntryOption {
 		entry.DocsEntry = docs
 	}
 }
+
+// WithJwtRefresher provide rkechojwt.TokenRefresher, refresh endpoint would be registered.
+func WithJwtRefresher(refresher *rkechojwt.TokenRefresher) EchoEntryOption {
+	return func(entry *EchoEntry) {
+		entry.JwtRefresher = refresher
+	}
+}
+
+// WithCspReporter provide rkechosec.CspReporter, CSP report endpoint would be registered.
+func WithCspReporter(reporter *rkechosec.CspReporter) EchoEntryOption {
+	return func(entry *EchoEntry) {
+		entry.CspReporter = reporter
+	}
+}
@@ -42,33 +42,109 @@ echo:
      enabled: true
      pusher:
        enabled: false
+   proxy:
+     enabled: true
+     trustedCidrs: ["10.0.0.0/8", "127.0.0.1"]
+     header: forwarded
+     rewriteScheme: true
+     rewriteHost: true
    middleware:
      logging:
        enabled: true
+       body:
+         enabled: true
+         maxBytes: 1024
+         paths: ["/v1/partner"]
+         redactFields: ["password", "card.number"]
+         redactHeaders: ["X-Partner-Secret"]
+       sampling:
+         enabled: true
+         rate: 0.5
+         slowThresholdMs: 500
+         paths:
+           - path: "/rk/v1/healthy"
+             rate: 0
+       accessLog:
+         enabled: true
+         format: ecs
      prom:
        enabled: true
      auth:
        enabled: true
        basic:
          - "user:pass"
+       apiKeys:
+         - id: "ut-key"
+           hash: "sha256:2a93ecccd58cfc223e3a4db82703b9b7:6fc667866f181d5cfbab9e3dc21d05bc8639c4e2d277187b4cbdc3c3c1416d84"
+           owner: "ut-owner"
+           scopes: ["read"]
      meta:
        enabled: true
+       requestId:
+         trustedCidrs: ["10.0.0.0/8"]
+         generator: ulid
      trace:
        enabled: true
      ratelimit:
        enabled: true
+       perClient:
+         enabled: true
+         reqPerSec: 1000
      timeout:
        enabled: true
      cors:
        enabled: true
+       allowOrigins: ["https://*.example.com"]
+       paths:
+         - path: "/v1/public"
+           allowPrivateNetwork: true
+     introspect:
+       enabled: true
+       endpoint: "http://127.0.0.1:0"
      jwt:
        enabled: true
+       symmetric:
+         algorithm: HS256
+         token: "ut-key"
+       refresh:
+         enabled: true
      secure:
        enabled: true
+       preset: api
+       contentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'"
+       paths:
+         - path: "/v1/ui"
+           preset: spa
+           headers:
+             Cross-Origin-Resource-Policy: "cross-origin"
+       cspReport:
+         enabled: true
+     session:
+       enabled: true
      csrf:
        enabled: true
+       mode: origin
+       exempt: ["/v1/hook/:id"]
+     signature:
+       enabled: true
+     audit:
+       enabled: true
+     ipFilter:
+       enabled: true
+       deny: ["203.0.113.0/24"]
+       paths:
+         - path: "/v1/admin"
+           allow: ["10.0.0.0/8"]
      gzip:
        enabled: true
+     idempotency:
+       enabled: true
+     cache:
+       enabled: true
+     coalesce:
+       enabled: true
+     authz:
+       enabled: true
  - name: greeter2
    port: 2008
    enabled: true
@@ -267,8 +343,15 @@ func TestRegisterEchoEntryYAML(t *testing.T) {
 	greeter := entries["greeter"].(*EchoEntry)
 	assert.NotNil(t, greeter)
 
+	assert.True(t, greeter.IsJwtRefreshEnabled())
+	assert.True(t, greeter.IsCspReportEnabled())
+	assert.NotNil(t, greeter.Echo.IPExtractor)
+
 	greeter2 := entries["greeter2"].(*EchoEntry)
 	assert.NotNil(t, greeter2)
+	assert.False(t, greeter2.IsJwtRefreshEnabled())
+	assert.False(t, greeter2.IsCspReportEnabled())
+	assert.Nil(t, greeter2.Echo.IPExtractor)
 
 	greeter3 := entries["greeter3"]
 	assert.Nil(t, greeter3)
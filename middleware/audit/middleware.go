@@ -0,0 +1,149 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechoaudit is a middleware for echo framework which records tamper-evident audit trail of mutating requests
+package rkechoaudit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// DiffKey is the key of diff set by handler with SetDiff()
+const DiffKey = "auditDiffKeyRk"
+
+// SetDiff provide changes made by request, like before and after of resource, which would be recorded in audit record.
+func SetDiff(ctx echo.Context, diff interface{}) {
+	if ctx != nil {
+		ctx.Set(DiffKey, diff)
+	}
+}
+
+// Middleware records audit of POST, PUT, PATCH and DELETE requests.
+//
+// 1: Who: subject of jwt token, subject of introspected token or principal matched by auth middleware.
+// 2: What: method, route pattern, path and resource ids from path params.
+// 3: When, source IP, request id, status, outcome and optional diff.
+//
+// Records are chained with SHA-256 hash and written into sinks, event sink is used by default.
+// Failure of writing records would be logged and would not affect response.
+//
+// Panic of handler would be recorded with outcome error and re-panicked to panic middleware.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			startTime := time.Now()
+			defer func() {
+				if recv := recover(); recv != nil {
+					record := set.newRecord(ctx, startTime, fmt.Errorf("panic: %v", recv))
+					record.Outcome = OutcomeError
+					set.write(ctx, record)
+
+					panic(recv)
+				}
+			}()
+
+			err := next(ctx)
+
+			set.write(ctx, set.newRecord(ctx, startTime, err))
+
+			return err
+		}
+	}
+}
+
+// write appends record to chain, failure would be logged
+func (set *optionSet) write(ctx echo.Context, record *Record) {
+	if err := set.chain.append(record); err != nil {
+		rkechoctx.GetLogger(ctx).Error("Failed to write audit record",
+			zap.String("auditId", record.Id),
+			zap.Error(err))
+	}
+}
+
+// newRecord collects fields of request after handler returned
+func (set *optionSet) newRecord(ctx echo.Context, startTime time.Time, err error) *Record {
+	req := ctx.Request()
+	record := &Record{
+		Id:        xid.New().String(),
+		Time:      startTime.UTC(),
+		EntryName: set.EntryName,
+		Method:    req.Method,
+		Route:     ctx.Path(),
+		SourceIp:  rkechoctx.GetClientIP(ctx),
+		RequestId: rkechoctx.GetRequestId(ctx),
+		Status:    ctx.Response().Status,
+		Outcome:   OutcomeSuccess,
+	}
+
+	if req.URL != nil {
+		record.Path = req.URL.Path
+	}
+
+	record.Actor, record.ActorType = actorOf(ctx)
+
+	if names := ctx.ParamNames(); len(names) > 0 {
+		record.Resource = make(map[string]string, len(names))
+		for _, name := range names {
+			record.Resource[name] = ctx.Param(name)
+		}
+	}
+
+	// response is written by echo error handler after middleware returned
+	if err != nil {
+		record.Error = err.Error()
+		if !ctx.Response().Committed {
+			record.Status = http.StatusInternalServerError
+			httpErr := &echo.HTTPError{}
+			if errors.As(err, &httpErr) {
+				record.Status = httpErr.Code
+			}
+		}
+	}
+
+	if err != nil || record.Status >= http.StatusBadRequest {
+		record.Outcome = OutcomeFailure
+	}
+
+	var diff interface{}
+	if set.DiffHook != nil {
+		diff = set.DiffHook(ctx)
+	} else {
+		diff = ctx.Get(DiffKey)
+	}
+
+	if diff != nil {
+		if bytes, marshalErr := json.Marshal(diff); marshalErr == nil {
+			record.Diff = bytes
+		}
+	}
+
+	return record
+}
+
+// actorOf returns identity of request and type of it
+func actorOf(ctx echo.Context) (string, string) {
+	if id, principalType := rkechoctx.GetPrincipal(ctx); len(id) > 0 {
+		return id, principalType
+	}
+
+	return "", ActorTypeAnonymous
+}
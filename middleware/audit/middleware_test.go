@@ -0,0 +1,162 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer assertNotPanic(t)
+
+	sink := NewMemorySink()
+	e := echo.New()
+	e.Use(Middleware(WithEntryNameAndType("ut-entry", "ut-type"), WithSink(sink)))
+
+	e.GET("/v1/users/:id", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "")
+	})
+	e.PUT("/v1/users/:id", func(ctx echo.Context) error {
+		ctx.Set(rkmid.JwtTokenKey.String(), jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "ut-subject"}))
+		SetDiff(ctx, map[string]interface{}{"name": []string{"old", "new"}})
+		return ctx.String(http.StatusOK, "")
+	})
+	e.DELETE("/v1/users/:id", func(ctx echo.Context) error {
+		ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Type: "X-API-Key", Id: "ut-key"})
+		return echo.ErrForbidden
+	})
+	e.POST("/v1/users", func(ctx echo.Context) error {
+		return ctx.String(http.StatusCreated, "")
+	})
+
+	serve := func(method, p string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, p, strings.NewReader("{}"))
+		req.RemoteAddr = "1.2.3.4:5678"
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// GET is not audited
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/v1/users/1").Code)
+	assert.Empty(t, sink.Records())
+
+	// PUT with jwt and diff
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/v1/users/1").Code)
+	// DELETE with api key, failed
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/v1/users/2").Code)
+	// POST anonymous
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/v1/users").Code)
+
+	records := sink.Records()
+	assert.Len(t, records, 3)
+	assert.Nil(t, Verify(records))
+
+	r := records[0]
+	assert.NotEmpty(t, r.Id)
+	assert.Equal(t, "ut-entry", r.EntryName)
+	assert.Equal(t, "ut-subject", r.Actor)
+	assert.Equal(t, ActorTypeJwt, r.ActorType)
+	assert.Equal(t, http.MethodPut, r.Method)
+	assert.Equal(t, "/v1/users/:id", r.Route)
+	assert.Equal(t, "/v1/users/1", r.Path)
+	assert.Equal(t, map[string]string{"id": "1"}, r.Resource)
+	assert.Equal(t, "1.2.3.4", r.SourceIp)
+	assert.Equal(t, http.StatusOK, r.Status)
+	assert.Equal(t, OutcomeSuccess, r.Outcome)
+	assert.JSONEq(t, `{"name":["old","new"]}`, string(r.Diff))
+
+	r = records[1]
+	assert.Equal(t, "ut-key", r.Actor)
+	assert.Equal(t, "X-API-Key", r.ActorType)
+	assert.Equal(t, http.StatusForbidden, r.Status)
+	assert.Equal(t, OutcomeFailure, r.Outcome)
+	assert.NotEmpty(t, r.Error)
+	assert.Equal(t, map[string]string{"id": "2"}, r.Resource)
+
+	r = records[2]
+	assert.Empty(t, r.Actor)
+	assert.Equal(t, ActorTypeAnonymous, r.ActorType)
+	assert.Equal(t, http.StatusCreated, r.Status)
+	assert.Nil(t, r.Resource)
+	assert.Nil(t, r.Diff)
+}
+
+func TestMiddleware_DiffHook(t *testing.T) {
+	defer assertNotPanic(t)
+
+	sink := NewMemorySink()
+	inter := Middleware(
+		WithSink(sink),
+		WithDiffHook(func(ctx echo.Context) interface{} {
+			return map[string]string{"hook": ctx.Path()}
+		}))
+
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/ut-path", nil), httptest.NewRecorder())
+	ctx.SetPath("/ut-path")
+	ctx.Set(rkechoctx.IntrospectionKey, &rkechoctx.Introspection{Active: true, Username: "ut-user"})
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		SetDiff(ctx, "ignored")
+		return ctx.NoContent(http.StatusNoContent)
+	})(ctx))
+
+	records := sink.Records()
+	assert.Len(t, records, 1)
+	assert.JSONEq(t, `{"hook":"/ut-path"}`, string(records[0].Diff))
+	assert.Equal(t, "ut-user", records[0].Actor)
+	assert.Equal(t, ActorTypeIntrospect, records[0].ActorType)
+}
+
+func TestMiddleware_WithPanic(t *testing.T) {
+	sink := NewMemorySink()
+	inter := Middleware(WithSink(sink))
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/ut-path", nil), httptest.NewRecorder())
+
+	assert.PanicsWithValue(t, "ut-panic", func() {
+		inter(func(ctx echo.Context) error {
+			panic("ut-panic")
+		})(ctx)
+	})
+
+	records := sink.Records()
+	assert.Len(t, records, 1)
+	assert.Equal(t, OutcomeError, records[0].Outcome)
+	assert.Equal(t, http.StatusInternalServerError, records[0].Status)
+	assert.Contains(t, records[0].Error, "ut-panic")
+}
+
+func TestMiddleware_SinkError(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := Middleware(WithSink(&errSink{}))
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/ut-path", nil), httptest.NewRecorder())
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusNoContent)
+	})(ctx))
+}
+
+func assertNotPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// Expect panic to be called with non nil error
+		assert.True(t, false)
+	} else {
+		// This should never be called in case of a bug
+		assert.True(t, true)
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName: xid.New().String(),
+		EntryType: "",
+		Skipper:   defaultSkipper,
+		methods:   make(map[string]bool),
+		chain:     &chain{},
+	}
+
+	for _, m := range defaultMethods {
+		set.methods[m] = true
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if len(set.chain.sinks) < 1 {
+		set.chain.sinks = append(set.chain.sinks, NewEventSink(set.eventEntry))
+	}
+
+	if err := set.chain.resume(); err != nil {
+		rkentry.ShutdownWithError(err)
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	DiffHook     DiffHook
+	methods      map[string]bool
+	eventEntry   *rkentry.EventEntry
+	chain        *chain
+	ignorePrefix []string
+}
+
+// ShouldIgnore determine whether audit should be ignored based on method and path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if !set.methods[ctx.Request().Method] {
+		return true
+	}
+
+	if ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	Ignore     []string `yaml:"ignore" json:"ignore"`
+	Methods    []string `yaml:"methods" json:"methods"`
+	EventEntry string   `yaml:"eventEntry" json:"eventEntry"`
+	File       struct {
+		Enabled bool   `yaml:"enabled" json:"enabled"`
+		Path    string `yaml:"path" json:"path"`
+	} `yaml:"file" json:"file"`
+}
+
+// ToOptions convert BootConfig into Option list.
+//
+// Records would be logged into EventEntry named in config, or eventEntry provided if not found.
+func ToOptions(config *BootConfig, entryName, entryType string, eventEntry *rkentry.EventEntry) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		if len(config.EventEntry) > 0 {
+			if v := rkentry.GlobalAppCtx.GetEventEntry(config.EventEntry); v != nil {
+				eventEntry = v
+			}
+		}
+
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithSink(NewEventSink(eventEntry)),
+			WithPathToIgnore(config.Ignore...))
+
+		if len(config.Methods) > 0 {
+			opts = append(opts, WithMethods(config.Methods...))
+		}
+
+		if config.File.Enabled {
+			filePath := config.File.Path
+			if len(filePath) < 1 {
+				filePath = "logs/audit.log"
+			}
+
+			sink, err := NewFileSink(filePath)
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+
+			opts = append(opts, WithSink(sink))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithMethods provide http methods which would be audited, default: POST, PUT, PATCH and DELETE.
+func WithMethods(methods ...string) Option {
+	return func(opt *optionSet) {
+		opt.methods = make(map[string]bool)
+		for i := range methods {
+			opt.methods[strings.ToUpper(methods[i])] = true
+		}
+	}
+}
+
+// WithEventEntry provide rkentry.EventEntry used by default sink if no sink provided.
+func WithEventEntry(eventEntry *rkentry.EventEntry) Option {
+	return func(opt *optionSet) {
+		opt.eventEntry = eventEntry
+	}
+}
+
+// WithSink provide Sink, records would be written into every sink in order, default: event sink.
+//
+// Chain would be resumed from the first sink implements Tail.
+func WithSink(sink Sink) Option {
+	return func(opt *optionSet) {
+		if sink != nil {
+			opt.chain.sinks = append(opt.chain.sinks, sink)
+		}
+	}
+}
+
+// WithDiffHook provide DiffHook which returns changes made by request, diff set with SetDiff() would be used if not provided.
+func WithDiffHook(hook DiffHook) Option {
+	return func(opt *optionSet) {
+		opt.DiffHook = hook
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
+
+// DiffHook returns changes made by request which would be marshaled into Record.Diff, called after handler returned
+type DiffHook func(ctx echo.Context) interface{}
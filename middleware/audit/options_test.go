@@ -0,0 +1,88 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Len(t, set.chain.sinks, 1)
+	assert.True(t, set.methods[http.MethodPost])
+	assert.True(t, set.methods[http.MethodDelete])
+	assert.False(t, set.methods[http.MethodGet])
+	assert.Nil(t, set.DiffHook)
+
+	// with options
+	sink := NewMemorySink()
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithMethods("get"),
+		WithSink(sink),
+		WithSink(nil),
+		WithDiffHook(func(echo.Context) interface{} {
+			return nil
+		}),
+		WithSkipper(func(echo.Context) bool {
+			return true
+		}))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Equal(t, []Sink{sink}, set.chain.sinks)
+	assert.True(t, set.methods[http.MethodGet])
+	assert.False(t, set.methods[http.MethodPost])
+	assert.NotNil(t, set.DiffHook)
+	assert.True(t, set.Skipper(echo.New().NewContext(nil, nil)))
+}
+
+func TestOptionSet_ShouldIgnore(t *testing.T) {
+	newCtxWithMethod := func(method, p string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(method, p, nil), httptest.NewRecorder())
+	}
+
+	set := newOptionSet(WithPathToIgnore("/ut-ignore"))
+	assert.False(t, set.ShouldIgnore(newCtxWithMethod(http.MethodPost, "/ut-path")))
+	assert.True(t, set.ShouldIgnore(newCtxWithMethod(http.MethodGet, "/ut-path")))
+	assert.True(t, set.ShouldIgnore(newCtxWithMethod(http.MethodPost, "/ut-ignore")))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled:    false,
+		Methods:    []string{http.MethodDelete},
+		EventEntry: "ut-not-exist",
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", "", nil))
+
+	// with enabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type", rkentry.EventEntryNoop)...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Len(t, set.chain.sinks, 1)
+	assert.Equal(t, rkentry.EventEntryNoop, set.chain.sinks[0].(*eventSink).eventEntry)
+	assert.True(t, set.methods[http.MethodDelete])
+	assert.False(t, set.methods[http.MethodPost])
+
+	// with file
+	config.File.Enabled = true
+	config.File.Path = path.Join(t.TempDir(), "audit.log")
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type", rkentry.EventEntryNoop)...)
+	assert.Len(t, set.chain.sinks, 2)
+	assert.IsType(t, &FileSink{}, set.chain.sinks[1])
+	set.chain.sinks[1].(*FileSink).Close()
+}
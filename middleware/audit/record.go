@@ -0,0 +1,145 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"sync"
+	"time"
+)
+
+const (
+	// OutcomeSuccess means handler returned without error and status < 400
+	OutcomeSuccess = "success"
+	// OutcomeFailure means handler returned error or status >= 400
+	OutcomeFailure = "failure"
+	// OutcomeError means handler panicked
+	OutcomeError = "error"
+
+	// ActorTypeAnonymous is type of actor if request was not authenticated
+	ActorTypeAnonymous = "anonymous"
+	// ActorTypeJwt is type of actor identified by subject of jwt token
+	ActorTypeJwt = rkechoctx.PrincipalTypeJwt
+	// ActorTypeIntrospect is type of actor identified by subject of introspected token
+	ActorTypeIntrospect = rkechoctx.PrincipalTypeIntrospect
+)
+
+// Record is an audit record of mutating request.
+//
+// Records are chained by hash, Hash is SHA-256 of JSON of record without Hash, which contains PrevHash,
+// so that any modification or removal of records could be detected with Verify().
+type Record struct {
+	Id        string            `json:"id" yaml:"id"`
+	Seq       uint64            `json:"seq" yaml:"seq"`
+	Time      time.Time         `json:"time" yaml:"time"`
+	EntryName string            `json:"entryName" yaml:"entryName"`
+	Actor     string            `json:"actor" yaml:"actor"`
+	ActorType string            `json:"actorType" yaml:"actorType"`
+	Method    string            `json:"method" yaml:"method"`
+	Route     string            `json:"route" yaml:"route"`
+	Path      string            `json:"path" yaml:"path"`
+	Resource  map[string]string `json:"resource,omitempty" yaml:"resource"`
+	SourceIp  string            `json:"sourceIp" yaml:"sourceIp"`
+	RequestId string            `json:"requestId,omitempty" yaml:"requestId"`
+	Status    int               `json:"status" yaml:"status"`
+	Outcome   string            `json:"outcome" yaml:"outcome"`
+	Error     string            `json:"error,omitempty" yaml:"error"`
+	Diff      json.RawMessage   `json:"diff,omitempty" yaml:"diff"`
+	PrevHash  string            `json:"prevHash" yaml:"prevHash"`
+	Hash      string            `json:"hash,omitempty" yaml:"hash"`
+}
+
+// ComputeHash returns SHA-256 of record without Hash in hex
+func (r *Record) ComputeHash() string {
+	copied := *r
+	copied.Hash = ""
+
+	bytes, _ := json.Marshal(&copied)
+	sum := sha256.Sum256(bytes)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks hash of each record and links between records in order.
+//
+// Records should be continuous part of chain, the first record is trusted as start of chain.
+func Verify(records []*Record) error {
+	for i, r := range records {
+		if r.Hash != r.ComputeHash() {
+			return fmt.Errorf("audit record %d has been modified", r.Seq)
+		}
+
+		if i < 1 {
+			continue
+		}
+
+		prev := records[i-1]
+		if r.PrevHash != prev.Hash || r.Seq != prev.Seq+1 {
+			return fmt.Errorf("audit chain broken between record %d and %d", prev.Seq, r.Seq)
+		}
+	}
+
+	return nil
+}
+
+// chain assigns sequence and hash to records and writes them into sinks in order
+type chain struct {
+	lock     sync.Mutex
+	seq      uint64
+	lastHash string
+	sinks    []Sink
+}
+
+// resume continues chain from last record of first sink which implements Tail
+func (c *chain) resume() error {
+	for _, sink := range c.sinks {
+		tail, ok := sink.(Tail)
+		if !ok {
+			continue
+		}
+
+		last, err := tail.Last()
+		if err != nil {
+			return err
+		}
+
+		if last != nil {
+			c.seq, c.lastHash = last.Seq, last.Hash
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// append links record to chain and writes it into all sinks, the first error would be returned.
+//
+// Sinks are written while lock is held so that records are stored in order of chain,
+// a slow sink like FileSink which syncs every record serializes audited requests.
+func (c *chain) append(r *Record) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	r.Seq = c.seq + 1
+	r.PrevHash = c.lastHash
+	r.Hash = r.ComputeHash()
+
+	c.seq, c.lastHash = r.Seq, r.Hash
+
+	var res error
+	for _, sink := range c.sinks {
+		if err := sink.Write(r); err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type errSink struct{}
+
+func (s *errSink) Write(*Record) error {
+	return errors.New("ut-error")
+}
+
+func TestRecord_ComputeHash(t *testing.T) {
+	r := &Record{Id: "ut-id", Time: time.Unix(0, 0).UTC(), Diff: []byte(`{"k":"v"}`)}
+	hash := r.ComputeHash()
+	assert.Len(t, hash, 64)
+
+	// hash itself is excluded
+	r.Hash = "ut-hash"
+	assert.Equal(t, hash, r.ComputeHash())
+
+	// any field changed
+	r.Actor = "ut-actor"
+	assert.NotEqual(t, hash, r.ComputeHash())
+}
+
+func TestChain(t *testing.T) {
+	sink := NewMemorySink()
+	c := &chain{sinks: []Sink{sink}}
+
+	assert.Nil(t, c.append(&Record{Id: "1"}))
+	assert.Nil(t, c.append(&Record{Id: "2"}))
+
+	records := sink.Records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, uint64(1), records[0].Seq)
+	assert.Empty(t, records[0].PrevHash)
+	assert.Equal(t, uint64(2), records[1].Seq)
+	assert.Equal(t, records[0].Hash, records[1].PrevHash)
+	assert.Nil(t, Verify(records))
+
+	// resume from tail
+	c = &chain{sinks: []Sink{&errSink{}, sink}}
+	assert.Nil(t, c.resume())
+	assert.Equal(t, uint64(2), c.seq)
+	assert.Equal(t, records[1].Hash, c.lastHash)
+
+	// error of sink returned while others still written
+	assert.NotNil(t, c.append(&Record{Id: "3"}))
+	assert.Len(t, sink.Records(), 3)
+	assert.Nil(t, Verify(sink.Records()))
+}
+
+func TestVerify(t *testing.T) {
+	sink := NewMemorySink()
+	c := &chain{sinks: []Sink{sink}}
+	for i := 0; i < 3; i++ {
+		c.append(&Record{Actor: "ut-actor"})
+	}
+
+	// empty
+	assert.Nil(t, Verify(nil))
+
+	// modified
+	records := sink.Records()
+	records[1].Actor = "ut-evil"
+	assert.NotNil(t, Verify(records))
+	records[1].Actor = "ut-actor"
+
+	// removed
+	records = sink.Records()
+	assert.NotNil(t, Verify([]*Record{records[0], records[2]}))
+
+	// part of chain
+	assert.Nil(t, Verify(records[1:]))
+}
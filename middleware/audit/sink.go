@@ -0,0 +1,213 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-query"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sink is a pluggable destination of audit records.
+//
+// Records are written one by one in order of chain, implementation does not need to be thread safe.
+type Sink interface {
+	// Write persists record
+	Write(record *Record) error
+}
+
+// Tail is implemented by Sink which could return last record written,
+// chain would be resumed from it after restart.
+type Tail interface {
+	// Last returns last record, nil if no record written
+	Last() (*Record, error)
+}
+
+// NewEventSink create Sink which logs records as events in EventEntry, default: rkentry.EventEntryStdout.
+func NewEventSink(eventEntry *rkentry.EventEntry) Sink {
+	if eventEntry == nil {
+		eventEntry = rkentry.EventEntryStdout
+	}
+
+	return &eventSink{
+		eventEntry: eventEntry,
+	}
+}
+
+// eventSink logs records as events
+type eventSink struct {
+	eventEntry *rkentry.EventEntry
+}
+
+// Write logs record as event with operation of audit
+func (s *eventSink) Write(r *Record) error {
+	event := s.eventEntry.Start("audit",
+		rkquery.WithEntryName(r.EntryName),
+		rkquery.WithEntryType("audit"))
+
+	event.SetEventId(r.Id)
+	event.SetRequestId(r.RequestId)
+	event.SetRemoteAddr(r.SourceIp)
+	event.SetResCode(r.Outcome)
+
+	event.AddPayloads(
+		zap.Uint64("seq", r.Seq),
+		zap.Time("time", r.Time),
+		zap.String("actor", r.Actor),
+		zap.String("actorType", r.ActorType),
+		zap.String("method", r.Method),
+		zap.String("route", r.Route),
+		zap.String("path", r.Path),
+		zap.Any("resource", r.Resource),
+		zap.Int("status", r.Status),
+		zap.String("outcome", r.Outcome),
+		zap.String("error", r.Error),
+		zap.ByteString("diff", r.Diff),
+		zap.String("prevHash", r.PrevHash),
+		zap.String("hash", r.Hash))
+
+	s.eventEntry.Finish(event)
+
+	return nil
+}
+
+// NewFileSink create FileSink which appends records as JSON lines into file.
+func NewFileSink(filePath string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		path: filePath,
+		file: file,
+	}, nil
+}
+
+// FileSink is a Sink appends records as JSON lines into file, records are synced to disk once written.
+//
+// Sync runs while lock of chain is held, so throughput of audited requests is bounded by latency of fsync.
+// Implement Sink with buffered writes if durability of every single record is not required.
+type FileSink struct {
+	path string
+	lock sync.Mutex
+	file *os.File
+}
+
+// Write appends record as one line of JSON
+func (s *FileSink) Write(r *Record) error {
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.file.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+// Last returns last record in file, nil if file is empty
+func (s *FileSink) Last() (*Record, error) {
+	records, err := ReadFile(s.path)
+	if err != nil || len(records) < 1 {
+		return nil, err
+	}
+
+	return records[len(records)-1], nil
+}
+
+// Close closes file
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.file.Close()
+}
+
+// ReadFile reads records written by FileSink, which could be verified with Verify().
+func ReadFile(filePath string) ([]*Record, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	res := make([]*Record, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) < 1 {
+			continue
+		}
+
+		r := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, scanner.Err()
+}
+
+// NewMemorySink create MemorySink which keeps records in memory, mostly used in tests.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		records: make([]*Record, 0),
+	}
+}
+
+// MemorySink is a Sink keeps records in memory
+type MemorySink struct {
+	lock    sync.Mutex
+	records []*Record
+}
+
+// Write keeps copy of record
+func (s *MemorySink) Write(r *Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	copied := *r
+	s.records = append(s.records, &copied)
+
+	return nil
+}
+
+// Last returns last record, nil if no record written
+func (s *MemorySink) Last() (*Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.records) < 1 {
+		return nil, nil
+	}
+
+	return s.records[len(s.records)-1], nil
+}
+
+// Records returns records written
+func (s *MemorySink) Records() []*Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]*Record{}, s.records...)
+}
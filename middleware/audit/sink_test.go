@@ -0,0 +1,83 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoaudit
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestEventSink(t *testing.T) {
+	defer assertNotPanic(t)
+
+	sink := NewEventSink(nil)
+	assert.Nil(t, sink.Write(&Record{Id: "ut-id", Resource: map[string]string{"id": "1"}}))
+
+	sink = NewEventSink(rkentry.EventEntryNoop)
+	assert.Nil(t, sink.Write(&Record{Id: "ut-id"}))
+}
+
+func TestFileSink(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "audit", "audit.log")
+
+	sink, err := NewFileSink(filePath)
+	assert.Nil(t, err)
+
+	// empty file
+	last, err := sink.Last()
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+
+	c := &chain{sinks: []Sink{sink}}
+	assert.Nil(t, c.append(&Record{Time: time.Now().UTC(), Diff: []byte(`{"b":1,"a":2}`)}))
+	assert.Nil(t, c.append(&Record{Time: time.Now().UTC(), Resource: map[string]string{"id": "1"}}))
+	assert.Nil(t, sink.Close())
+
+	// chain resumed after reopen
+	sink, err = NewFileSink(filePath)
+	assert.Nil(t, err)
+	c = &chain{sinks: []Sink{sink}}
+	assert.Nil(t, c.resume())
+	assert.Equal(t, uint64(2), c.seq)
+	assert.Nil(t, c.append(&Record{Time: time.Now().UTC()}))
+	assert.Nil(t, sink.Close())
+
+	records, err := ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	assert.Nil(t, Verify(records))
+
+	// tampered file
+	raw, _ := os.ReadFile(filePath)
+	os.WriteFile(filePath, []byte(string(raw[:len(raw)-1])+"\n{\"seq\":4}\n"), 0600)
+	records, err = ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.NotNil(t, Verify(records))
+
+	// file not exists
+	_, err = ReadFile(path.Join(t.TempDir(), "ut-not-exist"))
+	assert.NotNil(t, err)
+}
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+
+	last, err := sink.Last()
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+
+	r := &Record{Id: "ut-id"}
+	assert.Nil(t, sink.Write(r))
+	r.Id = "ut-changed"
+
+	last, _ = sink.Last()
+	assert.Equal(t, "ut-id", last.Id)
+	assert.Len(t, sink.Records(), 1)
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rs/xid"
+	"golang.org/x/crypto/bcrypt"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserStore verifies username and password presented in Basic Auth header.
+type UserStore interface {
+	// Verify returns true if user exists and password matches
+	Verify(user, password string) (bool, error)
+}
+
+// NewHtpasswdStore create UserStore which loads users from htpasswd file.
+//
+// Supported hash formats are bcrypt ($2y$, $2a$, $2b$), SHA-256 crypt ($5$), SHA-512 crypt ($6$) and {SHA}.
+// Modification time of file would be checked at most once per reload interval, file would be reloaded if changed.
+// Previously loaded users would be kept if reloading failed.
+func NewHtpasswdStore(path string, reloadInterval time.Duration) (*HtpasswdStore, error) {
+	res := &HtpasswdStore{
+		users: make(map[string]string),
+	}
+	res.watcher = rkechointernal.NewFileWatcher(path, reloadInterval, res.load)
+
+	if err := res.Reload(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// HtpasswdStore is a UserStore backed by htpasswd file
+type HtpasswdStore struct {
+	lock    sync.RWMutex
+	users   map[string]string
+	watcher *rkechointernal.FileWatcher
+}
+
+// Verify returns true if password matches hash of user, file would be reloaded if changed.
+//
+// Password of unknown user would be compared with a dummy bcrypt hash,
+// so that existence of user could not be told by response time.
+func (s *HtpasswdStore) Verify(user, password string) (bool, error) {
+	s.watcher.ReloadIfChanged()
+
+	s.lock.RLock()
+	hashed, ok := s.users[user]
+	s.lock.RUnlock()
+
+	if !ok {
+		VerifyHtpasswd(dummyHash(), password)
+		return false, nil
+	}
+
+	return VerifyHtpasswd(hashed, password), nil
+}
+
+// Reload loads users from file
+func (s *HtpasswdStore) Reload() error {
+	return s.watcher.Reload()
+}
+
+// load parses user:hash lines, empty lines and lines start with # are skipped
+func (s *HtpasswdStore) load(content []byte) error {
+	users := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) < 1 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		tokens := strings.SplitN(text, ":", 2)
+		if len(tokens) != 2 || len(tokens[0]) < 1 {
+			return fmt.Errorf("invalid htpasswd entry at line %d", line)
+		}
+
+		if !isSupportedHtpasswd(tokens[1]) {
+			return fmt.Errorf("unsupported htpasswd hash of user %s", tokens[0])
+		}
+
+		users[tokens[0]] = tokens[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.users = users
+
+	return nil
+}
+
+var (
+	dummyHashOnce  sync.Once
+	dummyHashValue string
+)
+
+// dummyHash returns bcrypt hash of random password generated once with default cost
+func dummyHash() string {
+	dummyHashOnce.Do(func() {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(xid.New().String()), bcrypt.DefaultCost)
+		if err == nil {
+			dummyHashValue = string(hashed)
+		}
+	})
+
+	return dummyHashValue
+}
+
+// isSupportedHtpasswd returns true if format of hash is supported by VerifyHtpasswd
+func isSupportedHtpasswd(hashed string) bool {
+	for _, prefix := range []string{"$2y$", "$2a$", "$2b$", "$5$", "$6$", "{SHA}"} {
+		if strings.HasPrefix(hashed, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyHtpasswd returns true if password matches htpasswd hash
+func VerifyHtpasswd(hashed, password string) bool {
+	switch {
+	case strings.HasPrefix(hashed, "$2y$"), strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+	case strings.HasPrefix(hashed, "$5$"):
+		return constantTimeEqual(hashed, shaCrypt(sha256.New, "$5$", password, hashed))
+	case strings.HasPrefix(hashed, "$6$"):
+		return constantTimeEqual(hashed, shaCrypt(sha512.New, "$6$", password, hashed))
+	case strings.HasPrefix(hashed, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return constantTimeEqual(hashed, "{SHA}"+base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	return false
+}
+
+// constantTimeEqual compares two strings in constant time
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+const (
+	shaCryptAlphabet      = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	shaCryptRoundsPrefix  = "rounds="
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSalt       = 16
+)
+
+var (
+	// byte permutation of final digest while encoding
+	sha256CryptOrder = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	sha512CryptOrder = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+		{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+		{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+		{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+		{62, 20, 41},
+	}
+)
+
+// shaCrypt computes SHA-crypt of password with salt and rounds parsed from setting, see https://www.akkadia.org/drepper/SHA-crypt.txt
+func shaCrypt(newHash func() hash.Hash, magic, password, setting string) string {
+	setting = strings.TrimPrefix(setting, magic)
+
+	rounds, customRounds := shaCryptDefaultRounds, false
+	if strings.HasPrefix(setting, shaCryptRoundsPrefix) {
+		tokens := strings.SplitN(strings.TrimPrefix(setting, shaCryptRoundsPrefix), "$", 2)
+		if len(tokens) != 2 {
+			return ""
+		}
+
+		v, err := strconv.Atoi(tokens[0])
+		if err != nil {
+			return ""
+		}
+
+		rounds, customRounds, setting = v, true, tokens[1]
+		if rounds < shaCryptMinRounds {
+			rounds = shaCryptMinRounds
+		}
+		if rounds > shaCryptMaxRounds {
+			rounds = shaCryptMaxRounds
+		}
+	}
+
+	salt := strings.SplitN(setting, "$", 2)[0]
+	if len(salt) > shaCryptMaxSalt {
+		salt = salt[:shaCryptMaxSalt]
+	}
+
+	p, s := []byte(password), []byte(salt)
+
+	// digest B
+	h := newHash()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+	size := len(b)
+
+	// digest A
+	h = newHash()
+	h.Write(p)
+	h.Write(s)
+	h.Write(repeat(b, len(p)))
+	for i := len(p); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	// byte sequence P
+	h = newHash()
+	for i := 0; i < len(p); i++ {
+		h.Write(p)
+	}
+	pSeq := repeat(h.Sum(nil), len(p))
+
+	// byte sequence S
+	h = newHash()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	sSeq := repeat(h.Sum(nil), len(s))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h = newHash()
+		if i&1 != 0 {
+			h.Write(pSeq)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(sSeq)
+		}
+		if i%7 != 0 {
+			h.Write(pSeq)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pSeq)
+		}
+		c = h.Sum(nil)
+	}
+
+	res := &strings.Builder{}
+	res.WriteString(magic)
+	if customRounds {
+		res.WriteString(fmt.Sprintf("%s%d$", shaCryptRoundsPrefix, rounds))
+	}
+	res.WriteString(salt)
+	res.WriteString("$")
+
+	if size == sha256.Size {
+		for _, o := range sha256CryptOrder {
+			encode24(res, c[o[0]], c[o[1]], c[o[2]], 4)
+		}
+		encode24(res, 0, c[31], c[30], 3)
+	} else {
+		for _, o := range sha512CryptOrder {
+			encode24(res, c[o[0]], c[o[1]], c[o[2]], 4)
+		}
+		encode24(res, 0, 0, c[63], 2)
+	}
+
+	return res.String()
+}
+
+// repeat returns first n bytes of src repeated
+func repeat(src []byte, n int) []byte {
+	res := make([]byte, 0, n)
+	for len(res) < n {
+		size := n - len(res)
+		if size > len(src) {
+			size = len(src)
+		}
+		res = append(res, src[:size]...)
+	}
+
+	return res
+}
+
+// encode24 encodes three bytes into n characters of crypt base64
+func encode24(res *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for i := 0; i < n; i++ {
+		res.WriteByte(shaCryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShaCrypt(t *testing.T) {
+	// vectors from https://www.akkadia.org/drepper/SHA-crypt.txt
+	assert.Equal(t,
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		shaCrypt(sha256.New, "$5$", "Hello world!", "$5$saltstring"))
+	assert.Equal(t,
+		"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+		shaCrypt(sha256.New, "$5$", "Hello world!", "$5$rounds=10000$saltstringsaltstring"))
+	assert.Equal(t,
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		shaCrypt(sha512.New, "$6$", "Hello world!", "$6$saltstring"))
+
+	// with invalid rounds
+	assert.Empty(t, shaCrypt(sha256.New, "$5$", "Hello world!", "$5$rounds=ut$salt$hash"))
+	assert.Empty(t, shaCrypt(sha256.New, "$5$", "Hello world!", "$5$rounds=1000"))
+}
+
+func TestVerifyHtpasswd(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+
+	hashes := []string{
+		string(bcryptHash),
+		strings.Replace(string(bcryptHash), "$2a$", "$2y$", 1),
+		"$5$ut-salt$1eOj8osUMffhs8LXj1ygG85tE8v6T4ERzDjO3Nm/K49",
+		"$6$rounds=1000$ut-salt$Upb93z3mENBGuAr46J9AIKDl8h5/3OvYO0oamer/O5JK9TxHVLlzMA3N7Rt4YFHu28Xh/rkTwSeWFRl74rHkB.",
+		"{SHA}nU4eI71bcnBGqeO0t9tXvY1u5oQ=",
+	}
+
+	for _, hash := range hashes {
+		assert.True(t, VerifyHtpasswd(hash, "pass"), hash)
+		assert.False(t, VerifyHtpasswd(hash, "invalid"), hash)
+	}
+
+	// with unsupported hash
+	assert.False(t, VerifyHtpasswd("pass", "pass"))
+}
+
+func TestHtpasswdStore(t *testing.T) {
+	file := path.Join(t.TempDir(), ".htpasswd")
+
+	// with missing file
+	_, err := NewHtpasswdStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// with invalid entry
+	assert.Nil(t, os.WriteFile(file, []byte("user"), 0644))
+	_, err = NewHtpasswdStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// with unsupported hash
+	assert.Nil(t, os.WriteFile(file, []byte("user:pass"), 0644))
+	_, err = NewHtpasswdStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// happy case
+	assert.Nil(t, os.WriteFile(file, []byte("# comment\n\nuser:{SHA}nU4eI71bcnBGqeO0t9tXvY1u5oQ=\n"), 0644))
+	store, err := NewHtpasswdStore(file, time.Millisecond)
+	assert.Nil(t, err)
+
+	ok, err := store.Verify("user", "pass")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = store.Verify("user", "invalid")
+	assert.False(t, ok)
+	ok, _ = store.Verify("unknown", "pass")
+	assert.False(t, ok)
+	assert.True(t, strings.HasPrefix(dummyHash(), "$2a$"))
+
+	// reload on change
+	assert.Nil(t, os.WriteFile(file, []byte("another:$5$ut-salt$1eOj8osUMffhs8LXj1ygG85tE8v6T4ERzDjO3Nm/K49\n"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(2 * time.Millisecond)
+
+	ok, _ = store.Verify("another", "pass")
+	assert.True(t, ok)
+	ok, _ = store.Verify("user", "pass")
+	assert.False(t, ok)
+}
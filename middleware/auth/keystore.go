@@ -0,0 +1,262 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hashAlgorithmSha256 = "sha256"
+	saltSize            = 16
+	secretSize          = 32
+	// keyIdSeparator separates public id and secret of API key formed as <id>.<secret>
+	keyIdSeparator = "."
+)
+
+// GenerateApiKey returns random API key formed as <id>.<secret> and its hash generated by HashApiKey.
+//
+// Key should be handed to client, only id and hash should be stored.
+func GenerateApiKey(id string) (string, string, error) {
+	if err := validateKeyId(id); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	key := id + keyIdSeparator + base64.RawURLEncoding.EncodeToString(secret)
+	hash, err := HashApiKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, hash, nil
+}
+
+// validateKeyId returns error if id is empty or contains separator
+func validateKeyId(id string) error {
+	if len(id) < 1 || strings.Contains(id, keyIdSeparator) {
+		return fmt.Errorf("invalid id of API key %s, non-empty id without %s is expected", id, keyIdSeparator)
+	}
+
+	return nil
+}
+
+// HashApiKey returns salted hash of API key formed as sha256:<salt>:<digest> which could be stored in YAML or key file.
+//
+// API keys are expected to be random strings with high entropy, so a fast hash is sufficient.
+// Keys looked up by MemoryKeyStore should be formed as <id>.<secret> with the same id of ApiKey.
+func HashApiKey(key string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%s:%s", hashAlgorithmSha256, hex.EncodeToString(salt), hex.EncodeToString(digest(salt, key))), nil
+}
+
+// VerifyApiKey returns true if key matches hash generated by HashApiKey
+func VerifyApiKey(hash, key string) bool {
+	tokens := strings.Split(hash, ":")
+	if len(tokens) != 3 || tokens[0] != hashAlgorithmSha256 {
+		return false
+	}
+
+	salt, err := hex.DecodeString(tokens[1])
+	if err != nil {
+		return false
+	}
+
+	expected, err := hex.DecodeString(tokens[2])
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(expected, digest(salt, key)) == 1
+}
+
+// digest returns sha256 of salt and key
+func digest(salt []byte, key string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(key))
+	return h.Sum(nil)
+}
+
+// ApiKey is a hashed API key with metadata
+type ApiKey struct {
+	Id        string
+	Hash      string
+	Owner     string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Expired returns true if key has expiry and is expired at now
+func (k *ApiKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeyStore looks up API key presented in X-API-Key header.
+//
+// Keys in MemoryKeyStore and FileKeyStore are formed as <id>.<secret>, key is located by id
+// and only hash of located key would be compared, see GenerateApiKey().
+type KeyStore interface {
+	// Lookup returns ApiKey matches raw key, nil if not found
+	Lookup(key string) (*ApiKey, error)
+}
+
+// NewMemoryKeyStore create in-memory KeyStore with hashed keys.
+func NewMemoryKeyStore(keys ...*ApiKey) *MemoryKeyStore {
+	res := &MemoryKeyStore{}
+	res.Set(keys...)
+	return res
+}
+
+// MemoryKeyStore is an in-memory KeyStore, keys are indexed by id
+type MemoryKeyStore struct {
+	lock sync.RWMutex
+	keys map[string]*ApiKey
+}
+
+// Set replaces keys in store, the latter one would be kept if ids are duplicated
+func (s *MemoryKeyStore) Set(keys ...*ApiKey) {
+	res := make(map[string]*ApiKey, len(keys))
+	for i := range keys {
+		if keys[i] != nil {
+			res[keys[i].Id] = keys[i]
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keys = res
+}
+
+// Lookup returns ApiKey located by id of raw key formed as <id>.<secret> whose hash matches raw key
+func (s *MemoryKeyStore) Lookup(key string) (*ApiKey, error) {
+	id, _, ok := strings.Cut(key, keyIdSeparator)
+	if !ok {
+		return nil, nil
+	}
+
+	s.lock.RLock()
+	v, ok := s.keys[id]
+	s.lock.RUnlock()
+
+	if !ok || !VerifyApiKey(v.Hash, key) {
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// NewFileKeyStore create KeyStore which loads keys from YAML or JSON file, a list of ApiKeyConfig is expected.
+//
+// Modification time of file would be checked at most once per reload interval, file would be reloaded if changed.
+// Previously loaded keys would be kept if reloading failed.
+func NewFileKeyStore(path string, reloadInterval time.Duration) (*FileKeyStore, error) {
+	res := &FileKeyStore{
+		store: NewMemoryKeyStore(),
+	}
+	res.watcher = rkechointernal.NewFileWatcher(path, reloadInterval, res.load)
+
+	if err := res.Reload(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// FileKeyStore is a KeyStore backed by file
+type FileKeyStore struct {
+	store   *MemoryKeyStore
+	watcher *rkechointernal.FileWatcher
+}
+
+// Lookup returns ApiKey whose hash matches raw key, file would be reloaded if changed
+func (s *FileKeyStore) Lookup(key string) (*ApiKey, error) {
+	s.watcher.ReloadIfChanged()
+	return s.store.Lookup(key)
+}
+
+// Reload loads keys from file
+func (s *FileKeyStore) Reload() error {
+	return s.watcher.Reload()
+}
+
+// load parses keys from file content
+func (s *FileKeyStore) load(bytes []byte) error {
+	configs := make([]ApiKeyConfig, 0)
+	if err := yaml.Unmarshal(bytes, &configs); err != nil {
+		return err
+	}
+
+	keys, err := toApiKeys(configs)
+	if err != nil {
+		return err
+	}
+
+	s.store.Set(keys...)
+	return nil
+}
+
+// toApiKeys converts ApiKeyConfig list into ApiKey list
+func toApiKeys(configs []ApiKeyConfig) ([]*ApiKey, error) {
+	res := make([]*ApiKey, 0, len(configs))
+
+	for i := range configs {
+		key, err := configs[i].toApiKey()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+
+	return res, nil
+}
+
+// toApiKey converts ApiKeyConfig into ApiKey, expiresAt should be in RFC3339 format
+func (c *ApiKeyConfig) toApiKey() (*ApiKey, error) {
+	if err := validateKeyId(c.Id); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(c.Hash, hashAlgorithmSha256+":") {
+		return nil, errors.New("invalid hash of API key " + c.Id + ", sha256:<salt>:<digest> is expected")
+	}
+
+	res := &ApiKey{
+		Id:     c.Id,
+		Hash:   c.Hash,
+		Owner:  c.Owner,
+		Scopes: c.Scopes,
+	}
+
+	if len(c.ExpiresAt) > 0 {
+		expiresAt, err := time.Parse(time.RFC3339, c.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiresAt of API key %s, %v", c.Id, err)
+		}
+		res.ExpiresAt = expiresAt
+	}
+
+	return res, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashApiKey(t *testing.T) {
+	hash, err := HashApiKey("ut-key")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "sha256:"))
+
+	// salted
+	another, _ := HashApiKey("ut-key")
+	assert.NotEqual(t, hash, another)
+
+	assert.True(t, VerifyApiKey(hash, "ut-key"))
+	assert.True(t, VerifyApiKey(another, "ut-key"))
+	assert.False(t, VerifyApiKey(hash, "ut-invalid"))
+	assert.False(t, VerifyApiKey("ut-key", "ut-key"))
+	assert.False(t, VerifyApiKey("sha256:xx:00", "ut-key"))
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, hash, err := GenerateApiKey("ut-id")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(key, "ut-id."))
+	assert.True(t, VerifyApiKey(hash, key))
+
+	another, _, _ := GenerateApiKey("ut-id")
+	assert.NotEqual(t, key, another)
+
+	// with invalid id
+	_, _, err = GenerateApiKey("")
+	assert.NotNil(t, err)
+	_, _, err = GenerateApiKey("ut.id")
+	assert.NotNil(t, err)
+}
+
+func TestApiKey_Expired(t *testing.T) {
+	now := time.Now()
+
+	assert.False(t, (&ApiKey{}).Expired(now))
+	assert.False(t, (&ApiKey{ExpiresAt: now.Add(time.Minute)}).Expired(now))
+	assert.True(t, (&ApiKey{ExpiresAt: now}).Expired(now))
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	hash, _ := HashApiKey("ut-id.ut-key")
+	otherHash, _ := HashApiKey("ut-other-id.ut-key")
+	store := NewMemoryKeyStore(&ApiKey{Id: "ut-id", Hash: hash}, &ApiKey{Id: "ut-other-id", Hash: otherHash}, nil)
+
+	key, err := store.Lookup("ut-id.ut-key")
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-id", key.Id)
+
+	// with invalid secret
+	key, err = store.Lookup("ut-id.ut-invalid")
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+
+	// with secret of another id
+	key, _ = store.Lookup("ut-other-id.ut-invalid")
+	assert.Nil(t, key)
+
+	// without id
+	key, _ = store.Lookup("ut-key")
+	assert.Nil(t, key)
+
+	// with unknown id
+	key, _ = store.Lookup("ut-unknown.ut-key")
+	assert.Nil(t, key)
+
+	// replace keys
+	store.Set()
+	key, _ = store.Lookup("ut-id.ut-key")
+	assert.Nil(t, key)
+}
+
+func TestFileKeyStore(t *testing.T) {
+	hash, _ := HashApiKey("ut-id.ut-key")
+	file := path.Join(t.TempDir(), "keys.yaml")
+
+	// with missing file
+	_, err := NewFileKeyStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// with invalid hash
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"id": "ut-id", "hash": "ut-key"}]`), 0644))
+	_, err = NewFileKeyStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// with invalid id
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"id": "ut.id", "hash": "`+hash+`"}]`), 0644))
+	_, err = NewFileKeyStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// with invalid expiresAt
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"id": "ut-id", "hash": "`+hash+`", "expiresAt": "ut"}]`), 0644))
+	_, err = NewFileKeyStore(file, time.Millisecond)
+	assert.NotNil(t, err)
+
+	// happy case with json
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"id": "ut-id", "hash": "`+hash+`", "owner": "ut-owner", "scopes": ["read"], "expiresAt": "2030-01-01T00:00:00Z"}]`), 0644))
+	store, err := NewFileKeyStore(file, time.Millisecond)
+	assert.Nil(t, err)
+
+	key, err := store.Lookup("ut-id.ut-key")
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-id", key.Id)
+	assert.Equal(t, "ut-owner", key.Owner)
+	assert.Equal(t, []string{"read"}, key.Scopes)
+	assert.Equal(t, 2030, key.ExpiresAt.Year())
+
+	// reload on change with yaml
+	newHash, _ := HashApiKey("ut-new-id.ut-new-key")
+	assert.Nil(t, os.WriteFile(file, []byte("- id: ut-new-id\n  hash: "+newHash+"\n"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(2 * time.Millisecond)
+
+	key, _ = store.Lookup("ut-new-id.ut-new-key")
+	assert.Equal(t, "ut-new-id", key.Id)
+	key, _ = store.Lookup("ut-id.ut-key")
+	assert.Nil(t, key)
+
+	// keep previous keys if reload failed
+	assert.Nil(t, os.WriteFile(file, []byte("ut-invalid"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(2 * time.Millisecond)
+
+	key, _ = store.Lookup("ut-new-id.ut-new-key")
+	assert.Equal(t, "ut-new-id", key.Id)
+}
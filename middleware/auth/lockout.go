@@ -0,0 +1,98 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"sync"
+	"time"
+)
+
+var defaultLockoutDuration = 5 * time.Minute
+
+// newLockout create lockout which locks user for duration after maxAttempts consecutive failures
+func newLockout(maxAttempts int, duration time.Duration) *lockout {
+	if duration <= 0 {
+		duration = defaultLockoutDuration
+	}
+
+	return &lockout{
+		maxAttempts: maxAttempts,
+		duration:    duration,
+		users:       make(map[string]*attempts),
+	}
+}
+
+// lockout tracks failed login attempts per user
+type lockout struct {
+	maxAttempts int
+	duration    time.Duration
+	lock        sync.Mutex
+	users       map[string]*attempts
+	lastPurge   time.Time
+}
+
+// attempts of a user, failures older than lockout duration are forgotten
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// lockedFor returns remaining duration of lockout, zero if user is not locked
+func (l *lockout) lockedFor(user string, now time.Time) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if v, ok := l.users[user]; ok && now.Before(v.lockedUntil) {
+		return v.lockedUntil.Sub(now)
+	}
+
+	return 0
+}
+
+// fail records a failed attempt of user
+func (l *lockout) fail(user string, now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.purge(now)
+
+	v, ok := l.users[user]
+	if !ok || now.Sub(v.lastFailure) > l.duration {
+		v = &attempts{}
+		l.users[user] = v
+	}
+
+	v.failures++
+	v.lastFailure = now
+
+	if v.failures >= l.maxAttempts {
+		v.failures = 0
+		v.lockedUntil = now.Add(l.duration)
+	}
+}
+
+// succeed resets failed attempts of user
+func (l *lockout) succeed(user string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.users, user)
+}
+
+// purge removes outdated attempts at most once per minute, lock should be held by caller
+func (l *lockout) purge(now time.Time) {
+	if now.Sub(l.lastPurge) < time.Minute {
+		return
+	}
+	l.lastPurge = now
+
+	for user, v := range l.users {
+		if now.Sub(v.lastFailure) > l.duration && !now.Before(v.lockedUntil) {
+			delete(l.users, user)
+		}
+	}
+}
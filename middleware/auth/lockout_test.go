@@ -0,0 +1,47 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	l := newLockout(2, time.Minute)
+	now := time.Now()
+
+	// first failure
+	l.fail("user", now)
+	assert.Zero(t, l.lockedFor("user", now))
+
+	// success resets failures
+	l.succeed("user")
+	l.fail("user", now)
+	assert.Zero(t, l.lockedFor("user", now))
+
+	// locked after max attempts
+	l.fail("user", now)
+	assert.Equal(t, time.Minute, l.lockedFor("user", now))
+	assert.Zero(t, l.lockedFor("another", now))
+
+	// unlocked after duration
+	assert.Zero(t, l.lockedFor("user", now.Add(time.Minute)))
+
+	// outdated failures are forgotten
+	l.fail("another", now)
+	l.fail("another", now.Add(2*time.Minute))
+	assert.Zero(t, l.lockedFor("another", now.Add(2*time.Minute)))
+
+	// purge outdated attempts
+	l.fail("user", now.Add(time.Hour))
+	assert.Len(t, l.users, 1)
+}
+
+func TestNewLockout(t *testing.T) {
+	assert.Equal(t, defaultLockoutDuration, newLockout(1, 0).duration)
+}
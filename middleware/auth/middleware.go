@@ -7,9 +7,23 @@
 package rkechoauth
 
 import (
+	"encoding/base64"
+	"fmt"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/auth"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	authTypeBasic  = "Basic"
+	authTypeApiKey = "X-API-Key"
 )
 
 // Middleware validate bellow authorization.
@@ -18,24 +32,229 @@ import (
 // 2: Bearer Token: Commonly known as token authentication. It is an HTTP authentication scheme that involves security tokens called bearer tokens.
 // 3: API key: An API key is a token that a client provides when making API calls. With API key auth, you send a key-value pair to the API in the request headers.
 func Middleware(opts ...rkmidauth.Option) echo.MiddlewareFunc {
-	set := rkmidauth.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithAuthOptions(opts...))
+}
+
+// MiddlewareWithOptions validate Basic Auth and X-API-Key same as Middleware,
+// API keys could be looked up from KeyStore which holds hashed keys with metadata,
+// basic auth credentials could be verified with UserStore like htpasswd file.
+//
+// User would be locked after configured consecutive failed basic auth attempts if lockout enabled.
+//
+// Matched identity would be assigned to context which could be read with rkechoctx.GetAuthPrincipal().
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
-			// case 1: return to user if error occur
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
 
-			if beforeCtx.Output.ErrResp != nil {
-				for k, v := range beforeCtx.Output.HeadersToReturn {
-					ctx.Response().Header().Set(k, v)
+			// case 0: credentials provided with rkmidauth only
+			if !set.hasBasic() && !set.hasApiKey() {
+				beforeCtx := set.beforeWithAuthSet(ctx)
+				if beforeCtx.Output.ErrResp != nil {
+					for k, v := range beforeCtx.Output.HeadersToReturn {
+						ctx.Response().Header().Set(k, v)
+					}
+					return ctx.JSON(beforeCtx.Output.ErrResp.Code(), beforeCtx.Output.ErrResp)
 				}
-				return ctx.JSON(beforeCtx.Output.ErrResp.Code(), beforeCtx.Output.ErrResp)
+				return next(ctx)
+			}
+
+			basicHeader := ctx.Request().Header.Get(rkmid.HeaderAuthorization)
+			apiKeyHeader := ctx.Request().Header.Get(rkmid.HeaderApiKey)
+
+			// case 1: basic auth passed
+			principal, errBasic := set.authBasic(ctx, basicHeader)
+			if errBasic == nil {
+				return set.authorized(ctx, next, principal)
 			}
 
-			return next(ctx)
+			// case 2: X-API-Key passed
+			principal, errApiKey := set.authApiKey(apiKeyHeader)
+			if errApiKey == nil {
+				return set.authorized(ctx, next, principal)
+			}
+
+			// case 3: credentials provided with rkmidauth passed
+			if set.authSet != nil && !set.authSet.ShouldIgnore(ctx.Request().URL.Path) &&
+				set.beforeWithAuthSet(ctx).Output.ErrResp == nil {
+				return next(ctx)
+			}
+
+			var errResp rkerror.ErrorInterface
+			switch {
+			case len(basicHeader) > 0:
+				// case 4: basic auth provided, then return code and response related to basic auth
+				errResp = errBasic
+			case len(apiKeyHeader) > 0:
+				// case 5: X-API-Key provided, then return code and response related to X-API-Key
+				errResp = errApiKey
+			default:
+				// case 6: no auth provided
+				tmp := make([]string, 0)
+				if set.hasBasic() {
+					set.setAuthenticateHeader(ctx)
+					tmp = append(tmp, "Basic Auth")
+				}
+				if set.hasApiKey() {
+					tmp = append(tmp, authTypeApiKey)
+				}
+				errResp = rkmid.GetErrorBuilder().New(http.StatusUnauthorized,
+					fmt.Sprintf("Missing authorization, provide one of bellow auth header:[%s]", strings.Join(tmp, ",")))
+			}
+
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+	}
+}
+
+// beforeWithAuthSet validates credentials with rkmidauth
+func (set *optionSet) beforeWithAuthSet(ctx echo.Context) *rkmidauth.BeforeCtx {
+	beforeCtx := set.authSet.BeforeCtx(ctx.Request())
+	set.authSet.Before(beforeCtx)
+
+	return beforeCtx
+}
+
+// authorized assigns principal into context and calls next handler
+func (set *optionSet) authorized(ctx echo.Context, next echo.HandlerFunc, principal *rkechoctx.AuthPrincipal) error {
+	if principal != nil {
+		ctx.Set(rkechoctx.AuthPrincipalKey, principal)
+	}
+
+	return next(ctx)
+}
+
+// setAuthenticateHeader set WWW-Authenticate header with realm
+func (set *optionSet) setAuthenticateHeader(ctx echo.Context) {
+	ctx.Response().Header().Set("WWW-Authenticate", fmt.Sprintf(`%s realm="%s"`, authTypeBasic, set.basicRealm))
+}
+
+// authBasic validates basic auth header with plain accounts and user stores
+func (set *optionSet) authBasic(ctx echo.Context, header string) (*rkechoctx.AuthPrincipal, rkerror.ErrorInterface) {
+	if len(header) < 1 {
+		return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Missing authorization header")
+	}
+
+	// other schemes like Bearer and malformed credentials are not login attempts, skip metrics and lockout
+	tokens := strings.SplitN(header, " ", 2)
+	if len(tokens) != 2 || !strings.EqualFold(tokens[0], authTypeBasic) {
+		return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid Basic Auth format")
+	}
+
+	user, password := "", ""
+	if decoded, err := base64.StdEncoding.DecodeString(tokens[1]); err == nil {
+		if cred := strings.SplitN(string(decoded), ":", 2); len(cred) == 2 {
+			user, password = cred[0], cred[1]
 		}
 	}
+
+	if len(user) < 1 {
+		return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid Basic Auth format")
+	}
+
+	now := time.Now()
+	if set.lockout != nil {
+		if lockedFor := set.lockout.lockedFor(user, now); lockedFor > 0 {
+			set.failed(authTypeBasic)
+			ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+			return nil, rkmid.GetErrorBuilder().New(http.StatusTooManyRequests, "Too many failed login attempts")
+		}
+	}
+
+	ok, err := set.verifyBasic(user, password)
+	if err != nil {
+		return nil, rkmid.GetErrorBuilder().New(http.StatusServiceUnavailable, "Failed to verify credential", err.Error())
+	}
+
+	if ok {
+		if set.lockout != nil {
+			set.lockout.succeed(user)
+		}
+
+		return &rkechoctx.AuthPrincipal{
+			Type: authTypeBasic,
+			Id:   user,
+		}, nil
+	}
+
+	set.failed(authTypeBasic)
+	if set.lockout != nil {
+		set.lockout.fail(user, now)
+	}
+
+	set.setAuthenticateHeader(ctx)
+
+	return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid credential")
+}
+
+// verifyBasic returns true if user and password matches any of plain accounts or user stores
+func (set *optionSet) verifyBasic(user, password string) (bool, error) {
+	if expected, ok := set.basicAccounts[user]; ok && constantTimeEqual(expected, password) {
+		return true, nil
+	}
+
+	for _, store := range set.userStores {
+		ok, err := store.Verify(user, password)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// failed increases failed login counter
+func (set *optionSet) failed(authType string) {
+	if vec := set.metricsSet.GetCounter(MetricsNameFailedLogin); vec != nil {
+		vec.WithLabelValues(set.EntryName, set.EntryType, authType).Inc()
+	}
+}
+
+// authApiKey validates X-API-Key header with plain keys and key stores
+func (set *optionSet) authApiKey(header string) (*rkechoctx.AuthPrincipal, rkerror.ErrorInterface) {
+	if len(header) < 1 {
+		return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Missing authorization header")
+	}
+
+	for key, id := range set.apiKeys {
+		if constantTimeEqual(key, header) {
+			return &rkechoctx.AuthPrincipal{
+				Type: authTypeApiKey,
+				Id:   id,
+			}, nil
+		}
+	}
+
+	for _, store := range set.keyStores {
+		key, err := store.Lookup(header)
+		if err != nil {
+			return nil, rkmid.GetErrorBuilder().New(http.StatusServiceUnavailable, "Failed to lookup X-API-Key", err.Error())
+		}
+
+		if key == nil {
+			continue
+		}
+
+		if key.Expired(time.Now()) {
+			set.failed(authTypeApiKey)
+			return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Expired X-API-Key")
+		}
+
+		return &rkechoctx.AuthPrincipal{
+			Type:      authTypeApiKey,
+			Id:        key.Id,
+			Owner:     key.Owner,
+			Scopes:    key.Scopes,
+			ExpiresAt: key.ExpiresAt,
+		}, nil
+	}
+
+	set.failed(authTypeApiKey)
+	return nil, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid X-API-Key")
 }
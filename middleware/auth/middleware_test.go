@@ -7,14 +7,21 @@ package rkechoauth
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/auth"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path"
 	"testing"
+	"time"
 )
 
 var userFunc = func(context echo.Context) error {
@@ -49,6 +56,186 @@ func TestMiddleware(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestMiddlewareWithOptions(t *testing.T) {
+	hash, _ := HashApiKey("ut-id.ut-key")
+	expiredHash, _ := HashApiKey("ut-expired-id.ut-expired-key")
+	store := NewMemoryKeyStore(
+		&ApiKey{Id: "ut-id", Hash: hash, Owner: "ut-owner", Scopes: []string{"read"}},
+		&ApiKey{Id: "ut-expired-id", Hash: expiredHash, ExpiresAt: time.Now().Add(-time.Minute)})
+
+	var principal *rkechoctx.AuthPrincipal
+	handler := func(ctx echo.Context) error {
+		principal = rkechoctx.GetAuthPrincipal(ctx)
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	inter := MiddlewareWithOptions(
+		WithBasicAuth("ut-realm", "user:pass"),
+		WithApiKeyAuth("ut-plain-key"),
+		WithKeyStore(store))
+
+	// case 1: without credential
+	ctx, w := newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Basic Auth,X-API-Key")
+	assert.Equal(t, `Basic realm="ut-realm"`, w.Header().Get("WWW-Authenticate"))
+
+	// case 2: with hashed key
+	principal = nil
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-id.ut-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ut-id", principal.Id)
+	assert.Equal(t, "ut-owner", principal.Owner)
+	assert.Equal(t, "X-API-Key", principal.Type)
+	assert.True(t, rkechoctx.HasScope(ctx, "read"))
+
+	// case 3: with expired key
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-expired-id.ut-expired-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Expired X-API-Key")
+
+	// case 4: with invalid key
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-invalid-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid X-API-Key")
+
+	// case 5: with plain key
+	principal = nil
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-plain-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, plainKeyId("ut-plain-key"), principal.Id)
+	assert.Equal(t, "X-API-Key", principal.Type)
+	assert.NotContains(t, principal.Id, "ut-plain-key")
+
+	// case 6: with basic auth
+	ctx, w = newCtx()
+	ctx.Request().SetBasicAuth("user", "pass")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user", principal.Id)
+	assert.Equal(t, "Basic", principal.Type)
+
+	// case 7: with invalid basic auth
+	ctx, w = newCtx()
+	ctx.Request().SetBasicAuth("user", "invalid")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid credential")
+
+	// case 8: with failed key store
+	inter = MiddlewareWithOptions(WithKeyStore(&failedKeyStore{}))
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// case 9: with skipper
+	inter = MiddlewareWithOptions(
+		WithKeyStore(store),
+		WithSkipper(func(echo.Context) bool { return true }))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 10: credentials provided with rkmidauth along with key store
+	inter = MiddlewareWithOptions(
+		WithKeyStore(store),
+		WithAuthOptions(rkmidauth.WithApiKeyAuth("ut-legacy-key")))
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-legacy-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-id.ut-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderApiKey, "ut-invalid-key")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// case 11: credentials provided with rkmidauth only
+	inter = MiddlewareWithOptions(WithAuthOptions(rkmidauth.WithApiKeyAuth("ut-legacy-key")))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMiddlewareWithOptions_Htpasswd(t *testing.T) {
+	file := path.Join(t.TempDir(), ".htpasswd")
+	assert.Nil(t, os.WriteFile(file, []byte("user:{SHA}nU4eI71bcnBGqeO0t9tXvY1u5oQ="), 0644))
+	store, _ := NewHtpasswdStore(file, time.Minute)
+
+	registry := prometheus.NewRegistry()
+	inter := MiddlewareWithOptions(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithUserStore(store),
+		WithLockout(2, time.Minute),
+		WithRegisterer(registry))
+
+	// case 1: with htpasswd user
+	ctx, w := newCtx()
+	ctx.Request().SetBasicAuth("user", "pass")
+	assert.Nil(t, inter(userFunc)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 2: with invalid password
+	for i := 0; i < 2; i++ {
+		ctx, w = newCtx()
+		ctx.Request().SetBasicAuth("user", "invalid")
+		assert.Nil(t, inter(userFunc)(ctx))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// case 3: locked even with valid password
+	ctx, w = newCtx()
+	ctx.Request().SetBasicAuth("user", "pass")
+	assert.Nil(t, inter(userFunc)(ctx))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get(echo.HeaderRetryAfter))
+
+	// case 4: other schemes and malformed credentials are not login attempts
+	for _, header := range []string{"Bearer ut-token", "Basic " + base64.StdEncoding.EncodeToString([]byte(":pass")), "ut-invalid"} {
+		ctx, w = newCtx()
+		ctx.Request().Header.Set(echo.HeaderAuthorization, header)
+		assert.Nil(t, inter(userFunc)(ctx))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// case 5: failed login metrics
+	assert.Equal(t, 1, testutil.CollectAndCount(registry))
+	assert.Equal(t, float64(3), testutil.ToFloat64(optionsMap["ut-entry"].metricsSet.GetCounter(MetricsNameFailedLogin)))
+	assert.NotContains(t, optionsMap["ut-entry"].lockout.users, "")
+
+	// case 6: with failed user store
+	inter = MiddlewareWithOptions(WithUserStore(&failedUserStore{}))
+	ctx, w = newCtx()
+	ctx.Request().SetBasicAuth("user", "pass")
+	assert.Nil(t, inter(userFunc)(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+type failedUserStore struct{}
+
+func (s *failedUserStore) Verify(string, string) (bool, error) {
+	return false, errors.New("ut-error")
+}
+
+type failedKeyStore struct{}
+
+func (s *failedKeyStore) Lookup(string) (*ApiKey, error) {
+	return nil, errors.New("ut-error")
+}
+
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
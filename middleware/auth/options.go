@@ -0,0 +1,301 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/auth"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rs/xid"
+	"strings"
+	"time"
+)
+
+const (
+	// MetricsNameFailedLogin records requests rejected with invalid or locked credentials
+	MetricsNameFailedLogin = "failedLogin"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	labelKeys = []string{"entryName", "entryType", "authType"}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:     "",
+		EntryType:     "",
+		Skipper:       defaultSkipper,
+		basicAccounts: make(map[string]string),
+		apiKeys:       make(map[string]string),
+		keyStores:     make([]KeyStore, 0),
+		userStores:    make([]UserStore, 0),
+		authOpts:      make([]rkmidauth.Option, 0),
+		registerer:    prometheus.DefaultRegisterer,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidauth
+	switch {
+	case len(set.authOpts) > 0 && len(set.EntryName) > 0:
+		set.authSet = rkmidauth.NewOptionSet(append(set.authOpts,
+			rkmidauth.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	case len(set.authOpts) > 0:
+		set.authSet = rkmidauth.NewOptionSet(append([]rkmidauth.Option{
+			rkmidauth.WithEntryNameAndType(xid.New().String(), "")}, set.authOpts...)...)
+		set.EntryName = set.authSet.GetEntryName()
+		set.EntryType = set.authSet.GetEntryType()
+	case len(set.EntryName) < 1:
+		set.EntryName = xid.New().String()
+	}
+
+	set.metricsSet = rkmidprom.NewMetricsSet("rk", "auth", set.registerer)
+	set.metricsSet.RegisterCounter(MetricsNameFailedLogin, labelKeys...)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName     string
+	EntryType     string
+	Skipper       Skipper
+	basicRealm    string
+	basicAccounts map[string]string
+	apiKeys       map[string]string
+	keyStores     []KeyStore
+	userStores    []UserStore
+	lockout       *lockout
+	ignorePrefix  []string
+	authOpts      []rkmidauth.Option
+	authSet       rkmidauth.OptionSetInterface
+	registerer    prometheus.Registerer
+	metricsSet    *rkmidprom.MetricsSet
+}
+
+// ShouldIgnore determine whether auth should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if !set.hasBasic() && !set.hasApiKey() && set.authSet == nil {
+		return true
+	}
+
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// hasBasic returns true if any of basic accounts or user stores provided
+func (set *optionSet) hasBasic() bool {
+	return len(set.basicAccounts) > 0 || len(set.userStores) > 0
+}
+
+// hasApiKey returns true if any of plain API keys or key stores provided
+func (set *optionSet) hasApiKey() bool {
+	return len(set.apiKeys) > 0 || len(set.keyStores) > 0
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidauth.BootConfig with hashed API keys and htpasswd file
+type BootConfig struct {
+	rkmidauth.BootConfig `yaml:",inline" mapstructure:",squash"`
+	ApiKeys              []ApiKeyConfig `yaml:"apiKeys" json:"apiKeys"`
+	ApiKeyFile           string         `yaml:"apiKeyFile" json:"apiKeyFile"`
+	Htpasswd             string         `yaml:"htpasswd" json:"htpasswd"`
+	ReloadIntervalMs     int            `yaml:"reloadIntervalMs" json:"reloadIntervalMs"`
+	Lockout              LockoutConfig  `yaml:"lockout" json:"lockout"`
+}
+
+// LockoutConfig for YAML, user would be locked after maxAttempts consecutive failed basic auth attempts
+type LockoutConfig struct {
+	MaxAttempts int `yaml:"maxAttempts" json:"maxAttempts"`
+	DurationMs  int `yaml:"durationMs" json:"durationMs"`
+}
+
+// ApiKeyConfig is hashed API key with metadata, key and hash could be generated with GenerateApiKey()
+type ApiKeyConfig struct {
+	Id        string   `yaml:"id" json:"id"`
+	Hash      string   `yaml:"hash" json:"hash"`
+	Owner     string   `yaml:"owner" json:"owner"`
+	Scopes    []string `yaml:"scopes" json:"scopes"`
+	ExpiresAt string   `yaml:"expiresAt" json:"expiresAt"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string, registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		reloadInterval := time.Duration(config.ReloadIntervalMs) * time.Millisecond
+
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithRegisterer(registerer),
+			WithLockout(config.Lockout.MaxAttempts, time.Duration(config.Lockout.DurationMs)*time.Millisecond),
+			WithBasicAuth(entryName, config.Basic...),
+			WithApiKeyAuth(config.ApiKey...),
+			WithPathToIgnore(config.Ignore...))
+
+		if len(config.ApiKeys) > 0 {
+			keys, err := toApiKeys(config.ApiKeys)
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opts = append(opts, WithKeyStore(NewMemoryKeyStore(keys...)))
+		}
+
+		if len(config.ApiKeyFile) > 0 {
+			store, err := NewFileKeyStore(config.ApiKeyFile, reloadInterval)
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opts = append(opts, WithKeyStore(store))
+		}
+
+		if len(config.Htpasswd) > 0 {
+			store, err := NewHtpasswdStore(config.Htpasswd, reloadInterval)
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opts = append(opts, WithUserStore(store))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithAuthOptions provide options of rkmidauth, credentials of it would be validated
+// if none of credentials provided with other options matched.
+func WithAuthOptions(opts ...rkmidauth.Option) Option {
+	return func(opt *optionSet) {
+		opt.authOpts = append(opt.authOpts, opts...)
+	}
+}
+
+// WithBasicAuth provide basic auth credentials formed as user:pass.
+func WithBasicAuth(realm string, cred ...string) Option {
+	return func(opt *optionSet) {
+		for i := range cred {
+			tokens := strings.SplitN(cred[i], ":", 2)
+			if len(tokens) == 2 {
+				opt.basicAccounts[tokens[0]] = tokens[1]
+			}
+		}
+
+		opt.basicRealm = realm
+	}
+}
+
+// WithApiKeyAuth provide plain text API keys which would be compared with X-API-Key header.
+//
+// Principal of plain key is identified by plainKeyId(), which is derived from key and is not secret.
+func WithApiKeyAuth(key ...string) Option {
+	return func(opt *optionSet) {
+		for i := range key {
+			opt.apiKeys[key[i]] = plainKeyId(key[i])
+		}
+	}
+}
+
+// plainKeyId returns stable id of plain text API key formed as plain-<first 12 hex chars of sha256>
+func plainKeyId(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "plain-" + hex.EncodeToString(sum[:6])
+}
+
+// WithKeyStore provide KeyStore which looks up hashed API keys with metadata.
+func WithKeyStore(store KeyStore) Option {
+	return func(opt *optionSet) {
+		if store != nil {
+			opt.keyStores = append(opt.keyStores, store)
+		}
+	}
+}
+
+// WithUserStore provide UserStore which verifies basic auth credentials, for example htpasswd file.
+func WithUserStore(store UserStore) Option {
+	return func(opt *optionSet) {
+		if store != nil {
+			opt.userStores = append(opt.userStores, store)
+		}
+	}
+}
+
+// WithLockout lock user for duration after maxAttempts consecutive failed basic auth attempts, default duration: 5 minutes.
+func WithLockout(maxAttempts int, duration time.Duration) Option {
+	return func(opt *optionSet) {
+		if maxAttempts > 0 {
+			opt.lockout = newLockout(maxAttempts, duration)
+		}
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for failed login metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
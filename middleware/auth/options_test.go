@@ -0,0 +1,125 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauth
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/auth"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestUnmarshalBootConfig(t *testing.T) {
+	raw := `
+enabled: true
+ignore: ["/ut-ignore"]
+apiKey: ["ut-plain"]
+apiKeys:
+  - id: "ut-id"
+    hash: "sha256:00:00"
+    owner: "ut-owner"
+    scopes: ["read", "write"]
+    expiresAt: "2030-01-01T00:00:00Z"
+apiKeyFile: "ut-file"
+htpasswd: "ut-htpasswd"
+reloadIntervalMs: 1000
+lockout:
+  maxAttempts: 5
+  durationMs: 60000
+`
+	config := &BootConfig{}
+	rkentry.UnmarshalBootYAML([]byte(raw), config)
+
+	assert.True(t, config.Enabled)
+	assert.Equal(t, []string{"/ut-ignore"}, config.Ignore)
+	assert.Equal(t, []string{"ut-plain"}, config.ApiKey)
+	assert.Len(t, config.ApiKeys, 1)
+	assert.Equal(t, "ut-owner", config.ApiKeys[0].Owner)
+	assert.Equal(t, []string{"read", "write"}, config.ApiKeys[0].Scopes)
+	assert.Equal(t, "2030-01-01T00:00:00Z", config.ApiKeys[0].ExpiresAt)
+	assert.Equal(t, "ut-file", config.ApiKeyFile)
+	assert.Equal(t, "ut-htpasswd", config.Htpasswd)
+	assert.Equal(t, 1000, config.ReloadIntervalMs)
+	assert.Equal(t, 5, config.Lockout.MaxAttempts)
+	assert.Equal(t, 60000, config.Lockout.DurationMs)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", "", nil))
+
+	// with enabled
+	config.Enabled = true
+	opts := ToOptions(config, "ut-entry", "ut-type", nil)
+	assert.NotEmpty(t, opts)
+
+	// with hashed keys
+	hash, _ := HashApiKey("ut-id.ut-key")
+	config.ApiKeys = []ApiKeyConfig{
+		{Id: "ut-id", Hash: hash},
+	}
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type", nil), len(opts)+1)
+
+	// with key file
+	file := path.Join(t.TempDir(), "keys.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("- id: ut-id\n  hash: "+hash+"\n"), 0644))
+	config.ApiKeyFile = file
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type", nil), len(opts)+2)
+
+	// with htpasswd
+	htpasswd := path.Join(t.TempDir(), ".htpasswd")
+	assert.Nil(t, os.WriteFile(htpasswd, []byte("user:{SHA}nU4eI71bcnBGqeO0t9tXvY1u5oQ="), 0644))
+	config.Htpasswd = htpasswd
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type", nil), len(opts)+3)
+}
+
+func TestOptionSet_ShouldIgnore(t *testing.T) {
+	// without credentials
+	set := newOptionSet()
+	ctx, _ := newCtx()
+	assert.True(t, set.ShouldIgnore(ctx))
+
+	// with path ignored
+	set = newOptionSet(
+		WithApiKeyAuth("ut-key"),
+		WithPathToIgnore("/ut-path"))
+	assert.True(t, set.ShouldIgnore(ctx))
+
+	// with key store
+	set = newOptionSet(WithKeyStore(NewMemoryKeyStore()))
+	assert.False(t, set.ShouldIgnore(ctx))
+
+	// with user store
+	set = newOptionSet(WithUserStore(&failedUserStore{}))
+	assert.False(t, set.ShouldIgnore(ctx))
+
+	// with options of rkmidauth
+	set = newOptionSet(WithAuthOptions(rkmidauth.WithApiKeyAuth("ut-key")))
+	assert.False(t, set.ShouldIgnore(ctx))
+}
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.Nil(t, set.authSet)
+
+	// with entry name of rkmidauth
+	set = newOptionSet(WithAuthOptions(rkmidauth.WithEntryNameAndType("ut-auth-name", "ut-auth-type")))
+	assert.Equal(t, "ut-auth-name", set.EntryName)
+	assert.Equal(t, "ut-auth-type", set.EntryType)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithAuthOptions(rkmidauth.WithEntryNameAndType("ut-auth-name", "ut-auth-type")))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-name", set.authSet.GetEntryName())
+}
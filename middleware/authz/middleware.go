@@ -0,0 +1,137 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechoauthz is a middleware for echo framework which authorizes requests with roles and scopes
+package rkechoauthz
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"strings"
+)
+
+// Middleware authorizes requests with roles and scopes granted by authentication middlewares,
+// it should be placed after jwt, introspect and auth middlewares.
+//
+// Roles and scopes of jwt token would be read from configured claim paths and stored into context,
+// scopes of introspected token and principal of auth middleware would be read from context.
+// Both could be checked with rkechoctx.HasScope() and rkechoctx.HasRole() in handlers.
+//
+// 1: Request without any credential on a path protected by rules would be rejected with 401.
+// 2: Request without required roles or scopes would be rejected with 403.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			rules := set.matchedRules(ctx)
+
+			if !authenticated(ctx) {
+				if len(rules) > 0 {
+					errResp := rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Missing credential")
+					return ctx.JSON(errResp.Code(), errResp)
+				}
+
+				return next(ctx)
+			}
+
+			if token := rkechoctx.GetJwtToken(ctx); token != nil {
+				claims := claimsToMap(token.Claims)
+				ctx.Set(rkechoctx.RolesKey, rkechoctx.ClaimStrings(lookup(claims, set.RolesClaim)))
+				ctx.Set(rkechoctx.ScopesKey, rkechoctx.ClaimStrings(lookup(claims, set.ScopesClaim)))
+			}
+
+			roles, _ := ctx.Get(rkechoctx.RolesKey).([]string)
+
+			for i := range rules {
+				rule := rules[i]
+
+				if len(rule.Roles) > 0 && !containsAny(roles, rule.Roles) {
+					errResp := rkmid.GetErrorBuilder().New(http.StatusForbidden,
+						fmt.Sprintf("Missing any of roles:[%s]", strings.Join(rule.Roles, ",")))
+					return ctx.JSON(errResp.Code(), errResp)
+				}
+
+				if !rkechoctx.HasScope(ctx, rule.Scopes...) {
+					errResp := rkmid.GetErrorBuilder().New(http.StatusForbidden,
+						fmt.Sprintf("Missing scopes:[%s]", strings.Join(rule.Scopes, ",")))
+					return ctx.JSON(errResp.Code(), errResp)
+				}
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// authenticated returns true if request was authenticated by jwt, introspect or auth middleware
+func authenticated(ctx echo.Context) bool {
+	if rkechoctx.GetJwtToken(ctx) != nil || rkechoctx.GetIntrospection(ctx) != nil {
+		return true
+	}
+
+	id, _ := rkechoctx.GetPrincipal(ctx)
+	return len(id) > 0
+}
+
+// claimsToMap converts jwt.Claims into map, custom claims would be converted via json
+func claimsToMap(claims jwt.Claims) map[string]interface{} {
+	if claims == nil {
+		return map[string]interface{}{}
+	}
+
+	if v, ok := claims.(jwt.MapClaims); ok {
+		return v
+	}
+
+	res := make(map[string]interface{})
+	if bytes, err := json.Marshal(claims); err == nil {
+		json.Unmarshal(bytes, &res)
+	}
+
+	return res
+}
+
+// lookup returns value of dot separated claim path, for example realm_access.roles
+func lookup(claims map[string]interface{}, claimPath string) interface{} {
+	var current interface{} = claims
+
+	for _, key := range strings.Split(claimPath, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		if current, ok = m[key]; !ok {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// containsAny returns true if any of expected elements exists in granted
+func containsAny(granted, expected []string) bool {
+	for i := range expected {
+		for j := range granted {
+			if expected[i] == granted[j] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauthz
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/auth"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newEcho(claims jwt.Claims, opts ...Option) *echo.Echo {
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if claims != nil {
+				ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{Claims: claims})
+			}
+			return next(ctx)
+		}
+	})
+	e.Use(Middleware(opts...))
+
+	handler := func(ctx echo.Context) error {
+		if rkechoctx.HasScope(ctx, "orders:read") {
+			return ctx.String(http.StatusOK, "with-scope")
+		}
+		return ctx.String(http.StatusOK, "without-scope")
+	}
+	e.GET("/v1/orders/:id", handler)
+	e.POST("/v1/orders/:id", handler)
+	e.GET("/public", handler)
+
+	return e
+}
+
+func serve(e *echo.Echo, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	opts := []Option{
+		WithRolesClaim("realm_access.roles"),
+		WithRule(Rule{
+			Path:    "/v1/orders/:id",
+			Methods: []string{"post"},
+			Roles:   []string{"admin", "operator"},
+			Scopes:  []string{"orders:write"},
+		}),
+		WithRule(Rule{
+			Path:   "/v1/orders/**",
+			Scopes: []string{"orders:read"},
+		}),
+	}
+
+	// case 1: without token
+	e := newEcho(nil, opts...)
+	assert.Equal(t, http.StatusUnauthorized, serve(e, http.MethodGet, "/v1/orders/1").Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/public").Code)
+
+	// case 2: with scope
+	e = newEcho(jwt.MapClaims{
+		"scope": "orders:read",
+	}, opts...)
+	w := serve(e, http.MethodGet, "/v1/orders/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "with-scope", w.Body.String())
+
+	// case 3: missing role
+	w = serve(e, http.MethodPost, "/v1/orders/1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "admin")
+
+	// case 4: with role but missing scope
+	e = newEcho(jwt.MapClaims{
+		"scope":        "orders:read",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"operator"}},
+	}, opts...)
+	assert.Equal(t, http.StatusForbidden, serve(e, http.MethodPost, "/v1/orders/1").Code)
+
+	// case 5: with role and scopes
+	e = newEcho(jwt.MapClaims{
+		"scope":        "orders:read orders:write",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"operator"}},
+	}, opts...)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/v1/orders/1").Code)
+
+	// case 6: scopes resolved from custom claims
+	e = newEcho(&jwt.RegisteredClaims{Subject: "ut"}, opts...)
+	w = serve(e, http.MethodGet, "/public")
+	assert.Equal(t, "without-scope", w.Body.String())
+}
+
+func TestMiddleware_WithoutJwt(t *testing.T) {
+	newEchoWith := func(key string, value interface{}) *echo.Echo {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(ctx echo.Context) error {
+				ctx.Set(key, value)
+				if introspection, ok := value.(*rkechoctx.Introspection); ok {
+					ctx.Set(rkechoctx.ScopesKey, introspection.Scopes())
+				}
+				return next(ctx)
+			}
+		})
+		e.Use(Middleware(WithRule(Rule{
+			Path:   "/v1/orders/**",
+			Scopes: []string{"orders:read"},
+		})))
+		e.GET("/v1/orders/:id", func(ctx echo.Context) error {
+			return ctx.NoContent(http.StatusOK)
+		})
+		return e
+	}
+
+	// case 1: with scopes of introspected opaque token
+	e := newEchoWith(rkechoctx.IntrospectionKey, &rkechoctx.Introspection{Active: true, Scope: "orders:read"})
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/v1/orders/1").Code)
+
+	e = newEchoWith(rkechoctx.IntrospectionKey, &rkechoctx.Introspection{Active: true, Scope: "orders:write"})
+	assert.Equal(t, http.StatusForbidden, serve(e, http.MethodGet, "/v1/orders/1").Code)
+
+	// case 2: with scopes of API key principal
+	e = newEchoWith(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Type: "X-API-Key", Id: "ut-key", Scopes: []string{"orders:read"}})
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/v1/orders/1").Code)
+
+	e = newEchoWith(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Type: "X-API-Key", Id: "ut-key"})
+	assert.Equal(t, http.StatusForbidden, serve(e, http.MethodGet, "/v1/orders/1").Code)
+}
+
+func TestMiddleware_WithAuth(t *testing.T) {
+	e := echo.New()
+	e.Use(rkechoauth.MiddlewareWithOptions(rkechoauth.WithApiKeyAuth("ut-plain-key")))
+	e.Use(Middleware(WithRule(Rule{
+		Path: "/v1/orders/**",
+	})))
+	e.GET("/v1/orders/:id", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+
+	// case 1: authenticated with plain API key
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/1", nil)
+	req.Header.Set(rkmid.HeaderApiKey, "ut-plain-key")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 2: without API key
+	assert.Equal(t, http.StatusUnauthorized, serve(e, http.MethodGet, "/v1/orders/1").Code)
+}
+
+func TestLookup(t *testing.T) {
+	claims := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{"c"},
+		},
+		"scope": "x y",
+	}
+
+	assert.Equal(t, []string{"c"}, rkechoctx.ClaimStrings(lookup(claims, "a.b")))
+	assert.Equal(t, []string{"x", "y"}, rkechoctx.ClaimStrings(lookup(claims, "scope")))
+	assert.Nil(t, lookup(claims, "a.b.c"))
+	assert.Nil(t, lookup(claims, "missing"))
+	assert.Empty(t, rkechoctx.ClaimStrings(nil))
+}
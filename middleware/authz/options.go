@@ -0,0 +1,229 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauthz
+
+import (
+	"github.com/labstack/echo/v4"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"path"
+	"strings"
+)
+
+const (
+	// DefaultRolesClaim is the default claim path of roles
+	DefaultRolesClaim = "roles"
+	// DefaultScopesClaim is the default claim path of scopes
+	DefaultScopesClaim = "scope"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+)
+
+// Rule maps route pattern and methods to required roles and scopes.
+//
+// Path is matched against both echo route pattern (for example /v1/user/:id) and request path.
+// Wildcard * matches a single path segment and a trailing /** matches any suffix.
+//
+// Request should have any of Roles and all of Scopes.
+// Rule applies to all methods if Methods is empty.
+type Rule struct {
+	Path    string   `yaml:"path" json:"path"`
+	Methods []string `yaml:"methods" json:"methods"`
+	Roles   []string `yaml:"roles" json:"roles"`
+	Scopes  []string `yaml:"scopes" json:"scopes"`
+}
+
+// matches returns true if rule applies to method, route pattern or request path
+func (r *Rule) matches(method, route, urlPath string) bool {
+	if len(r.Methods) > 0 {
+		matched := false
+		for i := range r.Methods {
+			if strings.EqualFold(r.Methods[i], method) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if r.Path == route {
+		return true
+	}
+
+	if strings.HasSuffix(r.Path, "/**") {
+		prefix := strings.TrimSuffix(r.Path, "/**")
+		return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+	}
+
+	matched, err := path.Match(r.Path, urlPath)
+	return err == nil && matched
+}
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:   xid.New().String(),
+		EntryType:   "",
+		Skipper:     defaultSkipper,
+		RolesClaim:  DefaultRolesClaim,
+		ScopesClaim: DefaultScopesClaim,
+		Rules:       make([]*Rule, 0),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	RolesClaim   string
+	ScopesClaim  string
+	Rules        []*Rule
+	ignorePrefix []string
+}
+
+// ShouldIgnore determine whether authz should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// matchedRules returns rules which apply to request
+func (set *optionSet) matchedRules(ctx echo.Context) []*Rule {
+	res := make([]*Rule, 0)
+
+	for i := range set.Rules {
+		if set.Rules[i].matches(ctx.Request().Method, ctx.Path(), ctx.Request().URL.Path) {
+			res = append(res, set.Rules[i])
+		}
+	}
+
+	return res
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	Ignore      []string `yaml:"ignore" json:"ignore"`
+	RolesClaim  string   `yaml:"rolesClaim" json:"rolesClaim"`
+	ScopesClaim string   `yaml:"scopesClaim" json:"scopesClaim"`
+	Rules       []Rule   `yaml:"rules" json:"rules"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithRolesClaim(config.RolesClaim),
+			WithScopesClaim(config.ScopesClaim),
+			WithPathToIgnore(config.Ignore...))
+
+		for i := range config.Rules {
+			opts = append(opts, WithRule(config.Rules[i]))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithRolesClaim provide dot separated claim path of roles, for example realm_access.roles, default: roles.
+func WithRolesClaim(claimPath string) Option {
+	return func(opt *optionSet) {
+		if len(claimPath) > 0 {
+			opt.RolesClaim = claimPath
+		}
+	}
+}
+
+// WithScopesClaim provide dot separated claim path of scopes, default: scope.
+func WithScopesClaim(claimPath string) Option {
+	return func(opt *optionSet) {
+		if len(claimPath) > 0 {
+			opt.ScopesClaim = claimPath
+		}
+	}
+}
+
+// WithRule provide authorization rule.
+func WithRule(rule Rule) Option {
+	return func(opt *optionSet) {
+		if len(rule.Path) < 1 {
+			return
+		}
+
+		if !strings.HasPrefix(rule.Path, "/") {
+			rule.Path = "/" + rule.Path
+		}
+
+		opt.Rules = append(opt.Rules, &rule)
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
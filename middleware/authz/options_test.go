@@ -0,0 +1,68 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoauthz
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Equal(t, DefaultRolesClaim, set.RolesClaim)
+	assert.Equal(t, DefaultScopesClaim, set.ScopesClaim)
+	assert.Empty(t, set.Rules)
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithRolesClaim("realm_access.roles"),
+		WithScopesClaim("scp"),
+		WithRule(Rule{Path: "ut-path"}),
+		WithRule(Rule{}),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "realm_access.roles", set.RolesClaim)
+	assert.Equal(t, "scp", set.ScopesClaim)
+	assert.Len(t, set.Rules, 1)
+	assert.Equal(t, "/ut-path", set.Rules[0].Path)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+}
+
+func TestRule_Matches(t *testing.T) {
+	rule := &Rule{Path: "/v1/user/:id"}
+	assert.True(t, rule.matches("GET", "/v1/user/:id", "/v1/user/1"))
+	assert.False(t, rule.matches("GET", "/v1/order/:id", "/v1/order/1"))
+
+	rule = &Rule{Path: "/v1/*/detail", Methods: []string{"GET"}}
+	assert.True(t, rule.matches("GET", "", "/v1/user/detail"))
+	assert.False(t, rule.matches("POST", "", "/v1/user/detail"))
+
+	rule = &Rule{Path: "/admin/**"}
+	assert.True(t, rule.matches("GET", "", "/admin"))
+	assert.True(t, rule.matches("GET", "", "/admin/a/b"))
+	assert.False(t, rule.matches("GET", "", "/administrator"))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.Rules = []Rule{{Path: "/ut-path", Roles: []string{"admin"}}}
+	assert.NotEmpty(t, ToOptions(config, "", ""))
+
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Len(t, set.Rules, 1)
+}
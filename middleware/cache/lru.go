@@ -0,0 +1,129 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// entry is a cached response, or request headers listed in Vary of responses of a primary key if varies is not nil
+type entry struct {
+	key      string
+	status   int
+	header   http.Header
+	body     []byte
+	varies   []string
+	expireAt time.Time
+}
+
+// size returns approximate bytes of entry
+func (e *entry) size() int {
+	res := len(e.key) + len(e.body)
+	for i := range e.varies {
+		res += len(e.varies[i])
+	}
+	for k, v := range e.header {
+		res += len(k)
+		for i := range v {
+			res += len(v[i])
+		}
+	}
+
+	return res
+}
+
+// lru is a thread safe LRU cache with total size cap in bytes
+type lru struct {
+	lock     sync.Mutex
+	maxBytes int
+	curBytes int
+	ll       *list.List
+	items    map[string]*list.Element
+	onEvict  func(*entry)
+}
+
+// newLru create lru with size cap and eviction callback
+func newLru(maxBytes int, onEvict func(*entry)) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+}
+
+// Get returns entry which is not expired
+func (c *lru) Get(key string) (*entry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ele, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := ele.Value.(*entry)
+	if !time.Now().Before(e.expireAt) {
+		c.removeElement(ele)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(ele)
+	return e, true
+}
+
+// Add adds entry into cache and evicts least recently used entries while exceeding size cap.
+// Entry larger than size cap would be ignored.
+func (c *lru) Add(e *entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	size := e.size()
+	if size > c.maxBytes {
+		return
+	}
+
+	if ele, ok := c.items[e.key]; ok {
+		c.curBytes -= ele.Value.(*entry).size()
+		ele.Value = e
+		c.curBytes += size
+		c.ll.MoveToFront(ele)
+	} else {
+		c.items[e.key] = c.ll.PushFront(e)
+		c.curBytes += size
+	}
+
+	for c.curBytes > c.maxBytes {
+		ele := c.ll.Back()
+		if ele == nil {
+			break
+		}
+
+		c.removeElement(ele)
+		if c.onEvict != nil {
+			c.onEvict(ele.Value.(*entry))
+		}
+	}
+}
+
+// Len returns number of entries
+func (c *lru) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.ll.Len()
+}
+
+// removeElement removes element, caller should hold the lock
+func (c *lru) removeElement(ele *list.Element) {
+	c.ll.Remove(ele)
+	e := ele.Value.(*entry)
+	delete(c.items, e.key)
+	c.curBytes -= e.size()
+}
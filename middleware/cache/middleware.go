@@ -0,0 +1,334 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechocache is a middleware for echo framework which answers conditional requests and caches responses
+package rkechocache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Middleware computes strong ETag for GET/HEAD responses and answers If-None-Match/If-Modified-Since with 304.
+//
+// If TTL was configured globally or on path, the whole response would be cached in memory with LRU policy.
+// Cache key is composed of method, path, selected query params and request headers listed in Vary.
+// Response larger than max body bytes would be written through without ETag and would not be cached.
+//
+// Cache-Control directives are honored as bellow:
+// 1: Request with no-store would bypass cache.
+// 2: Request with no-cache or max-age=0 would skip cached response and refresh it.
+// 3: Response with no-store, no-cache or private would not be cached.
+// 4: Response with s-maxage or max-age would override TTL.
+// 5: Response to request with Authorization, X-API-Key or Cookie would not be cached unless it is public or has s-maxage.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			req := ctx.Request()
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next(ctx)
+			}
+
+			reqDirectives := parseCacheControl(req.Header.Get(echo.HeaderCacheControl))
+			_, noStore := reqDirectives["no-store"]
+			ttl := set.ttlFor(req)
+			useCache := ttl > 0 && !noStore
+			primaryKey := set.primaryKey(req)
+
+			if useCache {
+				_, noCache := reqDirectives["no-cache"]
+				if maxAge, ok := reqDirectives["max-age"]; !noCache && (!ok || maxAge != "0") {
+					if e, ok := set.cache.Get(set.keyOf(primaryKey, req)); ok {
+						set.incCounter(MetricsNameHit)
+						header := ctx.Response().Header()
+						for k, v := range e.header {
+							header[k] = append([]string(nil), v...)
+						}
+						header.Set(HeaderXCache, "HIT")
+
+						return write(ctx, ctx.Response(), e.status, e.body)
+					}
+				}
+
+				set.incCounter(MetricsNameMiss)
+			}
+
+			// snapshot headers set by previous middlewares, they should not be cached
+			headerBefore := ctx.Response().Header().Clone()
+
+			originalWriter := ctx.Response().Writer
+			bufWriter := newBufferWriter(originalWriter, set.MaxBodyBytes)
+			ctx.Response().Writer = bufWriter
+
+			err := next(ctx)
+
+			ctx.Response().Writer = originalWriter
+
+			// response was flushed, exceeded max body bytes or nothing was written
+			if bufWriter.passThrough || bufWriter.code == 0 {
+				return err
+			}
+
+			header := ctx.Response().Header()
+			body := bufWriter.body.Bytes()
+
+			if bufWriter.code == http.StatusOK {
+				if len(header.Get(HeaderETag)) < 1 && (len(body) > 0 || req.Method == http.MethodGet) {
+					header.Set(HeaderETag, etagOf(body))
+				}
+
+				if useCache {
+					if storeTtl, ok := storable(req, header, ttl); ok {
+						set.store(primaryKey, req, bufWriter.code,
+							rkechointernal.DiffHeader(headerBefore, header, HeaderXCache), body, storeTtl)
+					}
+					header.Set(HeaderXCache, "MISS")
+				}
+			}
+
+			// size was counted while writing into buffer
+			ctx.Response().Size = 0
+			if writeErr := write(ctx, originalWriter, bufWriter.code, body); err == nil {
+				err = writeErr
+			}
+
+			return err
+		}
+	}
+}
+
+// primaryKey returns method, path and selected query params of request
+func (set *optionSet) primaryKey(req *http.Request) string {
+	query := req.URL.Query()
+	if len(set.QueryParams) > 0 {
+		selected := url.Values{}
+		for i := range set.QueryParams {
+			if v, ok := query[set.QueryParams[i]]; ok {
+				selected[set.QueryParams[i]] = v
+			}
+		}
+		query = selected
+	}
+
+	// url.Values.Encode() sorts by key
+	return req.Method + " " + req.URL.Path + "?" + query.Encode()
+}
+
+// varyKeyOf returns key of entry which keeps request headers in Vary of responses of primary key.
+// Method of request could not contain colon, so that it never conflicts with keys of responses.
+func varyKeyOf(primaryKey string) string {
+	return "vary:" + primaryKey
+}
+
+// keyOf returns cache key with values of vary headers appended
+func (set *optionSet) keyOf(primaryKey string, req *http.Request) string {
+	headers := set.VaryHeaders
+	if e, ok := set.cache.Get(varyKeyOf(primaryKey)); ok {
+		headers = e.varies
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(primaryKey)
+	for i := range headers {
+		builder.WriteString("\n")
+		builder.WriteString(headers[i])
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(req.Header.Values(headers[i]), ","))
+	}
+
+	return builder.String()
+}
+
+// store adds response into cache, request headers in Vary of response would be remembered for the primary key.
+//
+// Vary headers are kept as LRU entry as well, so that they are bounded by size cap and evicted like responses.
+func (set *optionSet) store(primaryKey string, req *http.Request, status int, header http.Header, body []byte, ttl time.Duration) {
+	varies := make(map[string]bool)
+	for i := range set.VaryHeaders {
+		varies[set.VaryHeaders[i]] = true
+	}
+	for _, v := range header.Values(echo.HeaderVary) {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				varies[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	headers := make([]string, 0, len(varies))
+	for k := range varies {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+
+	expireAt := time.Now().Add(ttl)
+	set.cache.Add(&entry{
+		key:      varyKeyOf(primaryKey),
+		varies:   headers,
+		expireAt: expireAt,
+	})
+
+	cached := make([]byte, len(body))
+	copy(cached, body)
+
+	set.cache.Add(&entry{
+		key:      set.keyOf(primaryKey, req),
+		status:   status,
+		header:   header,
+		body:     cached,
+		expireAt: expireAt,
+	})
+}
+
+// write writes response or 304 if request precondition matches
+func write(ctx echo.Context, w http.ResponseWriter, status int, body []byte) error {
+	header := ctx.Response().Header()
+
+	if status == http.StatusOK && notModified(ctx.Request(), header) {
+		header.Del(echo.HeaderContentType)
+		header.Del(echo.HeaderContentLength)
+		ctx.Response().Status = http.StatusNotModified
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	ctx.Response().Status = status
+	w.WriteHeader(status)
+
+	if ctx.Request().Method == http.MethodHead {
+		return nil
+	}
+
+	n, err := w.Write(body)
+	if w != ctx.Response() {
+		ctx.Response().Size += int64(n)
+	}
+
+	return err
+}
+
+// notModified checks If-None-Match and If-Modified-Since of request
+func notModified(req *http.Request, header http.Header) bool {
+	if inm := req.Header.Get(HeaderIfNoneMatch); len(inm) > 0 {
+		etag := header.Get(HeaderETag)
+		if len(etag) < 1 {
+			return false
+		}
+
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ims, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(header.Get(echo.HeaderLastModified))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(ims)
+}
+
+// hasCredential returns true if request carries Authorization, X-API-Key or Cookie header
+func hasCredential(req *http.Request) bool {
+	for _, header := range []string{echo.HeaderAuthorization, rkmid.HeaderApiKey, "Cookie"} {
+		if len(req.Header.Get(header)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storable checks Cache-Control of response and returns TTL
+func storable(req *http.Request, header http.Header, ttl time.Duration) (time.Duration, bool) {
+	if len(header.Values(echo.HeaderSetCookie)) > 0 || strings.Contains(header.Get(echo.HeaderVary), "*") {
+		return 0, false
+	}
+
+	directives := parseCacheControl(header.Get(echo.HeaderCacheControl))
+	for _, v := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[v]; ok {
+			return 0, false
+		}
+	}
+
+	_, public := directives["public"]
+	sMaxAge, shared := directives["s-maxage"]
+
+	// response to request with credentials should not be shared unless response allows it
+	if hasCredential(req) && !public && !shared {
+		return 0, false
+	}
+
+	maxAge, ok := directives["max-age"]
+	if shared {
+		maxAge, ok = sMaxAge, true
+	}
+
+	if ok {
+		sec, err := strconv.Atoi(maxAge)
+		if err != nil || sec <= 0 {
+			return 0, false
+		}
+		ttl = time.Duration(sec) * time.Second
+	}
+
+	return ttl, true
+}
+
+// parseCacheControl parse Cache-Control header into map of directive and value
+func parseCacheControl(raw string) map[string]string {
+	res := make(map[string]string)
+
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) < 1 {
+			continue
+		}
+
+		tokens := strings.SplitN(v, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(tokens[0]))
+		if len(tokens) > 1 {
+			res[key] = strings.Trim(strings.TrimSpace(tokens[1]), `"`)
+		} else {
+			res[key] = ""
+		}
+	}
+
+	return res
+}
+
+// etagOf returns strong ETag of body
+func etagOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocache
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serve(e *echo.Echo, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_Etag(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(WithRegisterer(prometheus.NewRegistry())))
+	e.GET("/ut-path", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+	e.POST("/ut-path", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+
+	// case 1: etag assigned
+	w := serve(e, http.MethodGet, "/ut-path", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ut-body", w.Body.String())
+	etag := w.Header().Get(HeaderETag)
+	assert.Equal(t, etagOf([]byte("ut-body")), etag)
+	assert.Empty(t, w.Header().Get(HeaderXCache))
+
+	// case 2: matched If-None-Match
+	w = serve(e, http.MethodGet, "/ut-path", map[string]string{HeaderIfNoneMatch: `"other", ` + etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// case 3: unmatched If-None-Match
+	w = serve(e, http.MethodGet, "/ut-path", map[string]string{HeaderIfNoneMatch: `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 4: POST is not handled
+	w = serve(e, http.MethodPost, "/ut-path", nil)
+	assert.Empty(t, w.Header().Get(HeaderETag))
+}
+
+func TestMiddleware_IfModifiedSince(t *testing.T) {
+	lastModified := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e := echo.New()
+	e.Use(Middleware(WithRegisterer(prometheus.NewRegistry())))
+	e.GET("/ut-path", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+
+	w := serve(e, http.MethodGet, "/ut-path", map[string]string{
+		echo.HeaderIfModifiedSince: lastModified.Add(time.Hour).Format(http.TimeFormat),
+	})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = serve(e, http.MethodGet, "/ut-path", map[string]string{
+		echo.HeaderIfModifiedSince: lastModified.Add(-time.Hour).Format(http.TimeFormat),
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMiddleware_Cache(t *testing.T) {
+	var counter int32
+	registry := prometheus.NewRegistry()
+
+	e := echo.New()
+	e.Use(Middleware(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithRegisterer(registry),
+		WithTtlByPath("/cached", time.Minute),
+		WithQueryParams("page")))
+	handler := func(ctx echo.Context) error {
+		ctx.Response().Header().Set("X-Counter", fmt.Sprintf("%d", atomic.AddInt32(&counter, 1)))
+		return ctx.String(http.StatusOK, "ut-body")
+	}
+	e.GET("/cached", handler)
+	e.GET("/not-cached", handler)
+
+	// case 1: miss
+	w := serve(e, http.MethodGet, "/cached?page=1&ignored=1", nil)
+	assert.Equal(t, "MISS", w.Header().Get(HeaderXCache))
+	assert.Equal(t, "1", w.Header().Get("X-Counter"))
+
+	// case 2: hit, ignored query param is not part of key
+	w = serve(e, http.MethodGet, "/cached?ignored=2&page=1", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderXCache))
+	assert.Equal(t, "1", w.Header().Get("X-Counter"))
+	assert.Equal(t, "ut-body", w.Body.String())
+
+	// case 3: hit with If-None-Match
+	w = serve(e, http.MethodGet, "/cached?page=1", map[string]string{HeaderIfNoneMatch: etagOf([]byte("ut-body"))})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// case 4: request with no-cache would refresh
+	w = serve(e, http.MethodGet, "/cached?page=1", map[string]string{echo.HeaderCacheControl: "no-cache"})
+	assert.Equal(t, "MISS", w.Header().Get(HeaderXCache))
+	assert.Equal(t, "2", w.Header().Get("X-Counter"))
+
+	// case 5: path without TTL
+	serve(e, http.MethodGet, "/not-cached", nil)
+	w = serve(e, http.MethodGet, "/not-cached", nil)
+	assert.Empty(t, w.Header().Get(HeaderXCache))
+	assert.Equal(t, "4", w.Header().Get("X-Counter"))
+
+	set := optionsMap["ut-entry"]
+	assert.Equal(t, float64(2), testutil.ToFloat64(set.metricsSet.GetCounter(MetricsNameHit)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(set.metricsSet.GetCounter(MetricsNameMiss)))
+}
+
+func TestMiddleware_CacheControl(t *testing.T) {
+	var counter int32
+
+	e := echo.New()
+	e.Use(Middleware(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithTtl(time.Minute)))
+	e.GET("/no-store", func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+	e.GET("/vary", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderVary, "X-Tenant")
+		return ctx.String(http.StatusOK, ctx.Request().Header.Get("X-Tenant"))
+	})
+
+	// response with no-store
+	serve(e, http.MethodGet, "/no-store", nil)
+	serve(e, http.MethodGet, "/no-store", nil)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+
+	// response with Vary
+	w := serve(e, http.MethodGet, "/vary", map[string]string{"X-Tenant": "a"})
+	assert.Equal(t, "a", w.Body.String())
+	w = serve(e, http.MethodGet, "/vary", map[string]string{"X-Tenant": "b"})
+	assert.Equal(t, "MISS", w.Header().Get(HeaderXCache))
+	assert.Equal(t, "b", w.Body.String())
+	w = serve(e, http.MethodGet, "/vary", map[string]string{"X-Tenant": "a"})
+	assert.Equal(t, "HIT", w.Header().Get(HeaderXCache))
+	assert.Equal(t, "a", w.Body.String())
+}
+
+func TestMiddleware_Stream(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(WithRegisterer(prometheus.NewRegistry())))
+	e.GET("/stream", func(ctx echo.Context) error {
+		ctx.Response().WriteHeader(http.StatusOK)
+		ctx.Response().Write([]byte("part-1"))
+		ctx.Response().Flush()
+		ctx.Response().Write([]byte("part-2"))
+		return nil
+	})
+
+	w := serve(e, http.MethodGet, "/stream", nil)
+	assert.Equal(t, "part-1part-2", w.Body.String())
+	assert.Empty(t, w.Header().Get(HeaderETag))
+}
+
+func TestMiddleware_MaxBodyBytes(t *testing.T) {
+	var counter int32
+
+	e := echo.New()
+	e.Use(Middleware(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithTtl(time.Minute),
+		WithMaxBodyBytes(8)))
+	e.GET("/large", func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		ctx.Response().WriteHeader(http.StatusOK)
+		ctx.Response().Write([]byte("part-1"))
+		ctx.Response().Write([]byte("part-2"))
+		return nil
+	})
+
+	// written through without ETag and not cached
+	w := serve(e, http.MethodGet, "/large", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "part-1part-2", w.Body.String())
+	assert.Empty(t, w.Header().Get(HeaderETag))
+	serve(e, http.MethodGet, "/large", nil)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+}
+
+func TestMiddleware_VaryBounded(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(
+		WithEntryNameAndType("ut-vary-bounded", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithTtl(time.Minute),
+		WithMaxBytes(1024)))
+	e.GET("/vary", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderVary, "X-Tenant")
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+	set := optionsMap["ut-vary-bounded"]
+
+	// vary headers of every primary key are kept in LRU, which is bounded by size cap
+	for i := 0; i < 1000; i++ {
+		serve(e, http.MethodGet, fmt.Sprintf("/vary?i=%d", i), nil)
+	}
+	assert.LessOrEqual(t, set.cache.curBytes, 1024)
+	assert.Less(t, set.cache.Len(), 1000)
+
+	// vary headers are still honored
+	w := serve(e, http.MethodGet, "/vary?i=999", map[string]string{"X-Tenant": "a"})
+	assert.Equal(t, "MISS", w.Header().Get(HeaderXCache))
+	w = serve(e, http.MethodGet, "/vary?i=999", map[string]string{"X-Tenant": "a"})
+	assert.Equal(t, "HIT", w.Header().Get(HeaderXCache))
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocache
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderXCache would be set to response as HIT or MISS if response cache is enabled on path
+	HeaderXCache = "X-Cache"
+	// HeaderETag is response header of entity tag
+	HeaderETag = "ETag"
+	// HeaderIfNoneMatch is request header of conditional request with entity tags
+	HeaderIfNoneMatch = "If-None-Match"
+
+	// MetricsNameHit records cache hit
+	MetricsNameHit = "hit"
+	// MetricsNameMiss records cache miss
+	MetricsNameMiss = "miss"
+	// MetricsNameEviction records entries evicted because of size cap
+	MetricsNameEviction = "eviction"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultMaxBytes = 64 * 1024 * 1024
+	// defaultMaxBodyBytes is max size of response body which would be buffered for ETag and cache
+	defaultMaxBodyBytes = 1024 * 1024
+	labelKeys           = []string{"entryName", "entryType"}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:    xid.New().String(),
+		EntryType:    "",
+		Skipper:      defaultSkipper,
+		MaxBytes:     defaultMaxBytes,
+		MaxBodyBytes: defaultMaxBodyBytes,
+		registerer:   prometheus.DefaultRegisterer,
+		paths:        make(map[string]time.Duration),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	set.metricsSet = rkmidprom.NewMetricsSet("rk", "cache", set.registerer)
+	set.metricsSet.RegisterCounter(MetricsNameHit, labelKeys...)
+	set.metricsSet.RegisterCounter(MetricsNameMiss, labelKeys...)
+	set.metricsSet.RegisterCounter(MetricsNameEviction, labelKeys...)
+
+	set.cache = newLru(set.MaxBytes, func(e *entry) {
+		if e.varies == nil {
+			set.incCounter(MetricsNameEviction)
+		}
+	})
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	MaxBytes     int
+	MaxBodyBytes int
+	Ttl          time.Duration
+	QueryParams  []string
+	VaryHeaders  []string
+	paths        map[string]time.Duration
+	ignorePrefix []string
+	registerer   prometheus.Registerer
+	metricsSet   *rkmidprom.MetricsSet
+	cache        *lru
+}
+
+// ShouldIgnore determine whether cache should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ttlFor returns TTL of in-memory response cache, zero means response would not be cached.
+// TTL of the longest matching path prefix would be returned, otherwise, default TTL would be returned.
+func (set *optionSet) ttlFor(req *http.Request) time.Duration {
+	matched, ttl := "", set.Ttl
+	for prefix, v := range set.paths {
+		if strings.HasPrefix(req.URL.Path, prefix) && len(prefix) > len(matched) {
+			matched, ttl = prefix, v
+		}
+	}
+
+	return ttl
+}
+
+// incCounter increase counter with entry name and type as labels
+func (set *optionSet) incCounter(name string) {
+	if vec := set.metricsSet.GetCounter(name); vec != nil {
+		vec.WithLabelValues(set.EntryName, set.EntryType).Inc()
+	}
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Ignore       []string `yaml:"ignore" json:"ignore"`
+	MaxBytes     int      `yaml:"maxBytes" json:"maxBytes"`
+	MaxBodyBytes int      `yaml:"maxBodyBytes" json:"maxBodyBytes"`
+	TtlMs        int      `yaml:"ttlMs" json:"ttlMs"`
+	QueryParams  []string `yaml:"queryParams" json:"queryParams"`
+	VaryHeaders  []string `yaml:"varyHeaders" json:"varyHeaders"`
+	Paths        []struct {
+		Path  string `yaml:"path" json:"path"`
+		TtlMs int    `yaml:"ttlMs" json:"ttlMs"`
+	} `yaml:"paths" json:"paths"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string, registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithRegisterer(registerer),
+			WithMaxBytes(config.MaxBytes),
+			WithMaxBodyBytes(config.MaxBodyBytes),
+			WithTtl(time.Duration(config.TtlMs)*time.Millisecond),
+			WithQueryParams(config.QueryParams...),
+			WithVaryHeaders(config.VaryHeaders...),
+			WithPathToIgnore(config.Ignore...))
+
+		for i := range config.Paths {
+			e := config.Paths[i]
+			opts = append(opts, WithTtlByPath(e.Path, time.Duration(e.TtlMs)*time.Millisecond))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for hit, miss and eviction metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithMaxBytes provide size cap of in-memory response cache, default: 64MB.
+func WithMaxBytes(maxBytes int) Option {
+	return func(opt *optionSet) {
+		if maxBytes > 0 {
+			opt.MaxBytes = maxBytes
+		}
+	}
+}
+
+// WithMaxBodyBytes provide max size of response body buffered for ETag and cache, default: 1MB.
+// Larger response would be written through without ETag and would not be cached.
+func WithMaxBodyBytes(maxBytes int) Option {
+	return func(opt *optionSet) {
+		if maxBytes > 0 {
+			opt.MaxBodyBytes = maxBytes
+		}
+	}
+}
+
+// WithTtl provide default TTL of in-memory response cache, default: 0, which means only ETag is enabled.
+func WithTtl(ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if ttl > 0 {
+			opt.Ttl = ttl
+		}
+	}
+}
+
+// WithTtlByPath provide TTL of in-memory response cache by path prefix.
+// Zero TTL would disable response cache on path.
+func WithTtlByPath(path string, ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if len(path) < 1 {
+			return
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		opt.paths[path] = ttl
+	}
+}
+
+// WithQueryParams provide query params which would be part of cache key.
+// All query params would be used if nothing provided.
+func WithQueryParams(params ...string) Option {
+	return func(opt *optionSet) {
+		opt.QueryParams = append(opt.QueryParams, params...)
+	}
+}
+
+// WithVaryHeaders provide request headers which would be part of cache key.
+// Headers listed in Vary header of response would be used either.
+func WithVaryHeaders(headers ...string) Option {
+	return func(opt *optionSet) {
+		for i := range headers {
+			opt.VaryHeaders = append(opt.VaryHeaders, http.CanonicalHeaderKey(headers[i]))
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
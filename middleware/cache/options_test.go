@@ -0,0 +1,135 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocache
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet(WithRegisterer(prometheus.NewRegistry()))
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Equal(t, defaultMaxBytes, set.MaxBytes)
+	assert.Equal(t, defaultMaxBodyBytes, set.MaxBodyBytes)
+	assert.Zero(t, set.Ttl)
+	assert.NotNil(t, set.cache)
+	assert.NotNil(t, set.metricsSet.GetCounter(MetricsNameHit))
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithMaxBytes(1024),
+		WithTtl(time.Minute),
+		WithTtlByPath("ut-path", time.Second),
+		WithQueryParams("page"),
+		WithVaryHeaders("x-tenant"),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, 1024, set.MaxBytes)
+	assert.Equal(t, time.Minute, set.Ttl)
+	assert.Equal(t, time.Second, set.paths["/ut-path"])
+	assert.Equal(t, []string{"page"}, set.QueryParams)
+	assert.Equal(t, []string{"X-Tenant"}, set.VaryHeaders)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+
+	assert.Equal(t, time.Second, set.ttlFor(httptest.NewRequest(http.MethodGet, "/ut-path/1", nil)))
+	assert.Equal(t, time.Minute, set.ttlFor(httptest.NewRequest(http.MethodGet, "/other", nil)))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", "", nil))
+
+	// with enabled
+	config.Enabled = true
+	config.TtlMs = 1000
+	assert.NotEmpty(t, ToOptions(config, "", "", nil))
+
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type", prometheus.NewRegistry())...)
+	assert.Equal(t, time.Second, set.Ttl)
+}
+
+func TestStorable(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// default ttl
+	ttl, ok := storable(req, http.Header{}, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, ttl)
+
+	// max-age and s-maxage
+	ttl, ok = storable(req, http.Header{echo.HeaderCacheControl: []string{"max-age=10"}}, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, ttl)
+	ttl, _ = storable(req, http.Header{echo.HeaderCacheControl: []string{"max-age=10, s-maxage=20"}}, time.Minute)
+	assert.Equal(t, 20*time.Second, ttl)
+
+	// not storable
+	_, ok = storable(req, http.Header{echo.HeaderCacheControl: []string{"private"}}, time.Minute)
+	assert.False(t, ok)
+	_, ok = storable(req, http.Header{echo.HeaderSetCookie: []string{"a=b"}}, time.Minute)
+	assert.False(t, ok)
+	_, ok = storable(req, http.Header{echo.HeaderCacheControl: []string{"max-age=0"}}, time.Minute)
+	assert.False(t, ok)
+
+	// authorized request
+	req.Header.Set(echo.HeaderAuthorization, "Bearer ut")
+	_, ok = storable(req, http.Header{}, time.Minute)
+	assert.False(t, ok)
+	_, ok = storable(req, http.Header{echo.HeaderCacheControl: []string{"public"}}, time.Minute)
+	assert.True(t, ok)
+
+	// request authenticated by cookie or X-API-Key
+	for _, header := range []string{"Cookie", "X-API-Key"} {
+		req = httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+		req.Header.Set(header, "ut")
+		_, ok = storable(req, http.Header{}, time.Minute)
+		assert.False(t, ok, header)
+		_, ok = storable(req, http.Header{echo.HeaderCacheControl: []string{"s-maxage=60"}}, time.Minute)
+		assert.True(t, ok, header)
+	}
+}
+
+func TestLru(t *testing.T) {
+	evicted := 0
+	cache := newLru(20, func(*entry) {
+		evicted++
+	})
+
+	cache.Add(&entry{key: "a", body: []byte("12345"), expireAt: time.Now().Add(time.Minute)})
+	cache.Add(&entry{key: "b", body: []byte("12345"), expireAt: time.Now().Add(time.Minute)})
+	assert.Equal(t, 2, cache.Len())
+
+	// touch a, then b would be evicted
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	cache.Add(&entry{key: "c", body: []byte("12345678"), expireAt: time.Now().Add(time.Minute)})
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 1, evicted)
+
+	// entry larger than cap is ignored
+	cache.Add(&entry{key: "d", body: make([]byte, 100), expireAt: time.Now().Add(time.Minute)})
+	_, ok = cache.Get("d")
+	assert.False(t, ok)
+
+	// expired
+	cache.Add(&entry{key: "a", body: []byte("1"), expireAt: time.Now().Add(-time.Second)})
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocache
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// bufferWriter keeps status code and body in memory until flushed by middleware.
+//
+// If user code flushes response, which is usually a streaming response, or body exceeds limit,
+// bufferWriter would write buffered contents and switch to pass through mode.
+type bufferWriter struct {
+	http.ResponseWriter
+	body        *bytes.Buffer
+	limit       int
+	code        int
+	passThrough bool
+}
+
+func newBufferWriter(w http.ResponseWriter, limit int) *bufferWriter {
+	return &bufferWriter{
+		ResponseWriter: w,
+		body:           new(bytes.Buffer),
+		limit:          limit,
+	}
+}
+
+// WriteHeader keeps status code in memory
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.passThrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.code = code
+}
+
+// Write writes bytes into buffer
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	if w.body.Len()+len(b) > w.limit {
+		w.switchToPassThrough()
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.body.Write(b)
+}
+
+// Flush writes buffered contents and switch to pass through mode
+func (w *bufferWriter) Flush() {
+	w.switchToPassThrough()
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// switchToPassThrough writes buffered status code and contents and switch to pass through mode
+func (w *bufferWriter) switchToPassThrough() {
+	if w.passThrough {
+		return
+	}
+
+	w.passThrough = true
+	if w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+	w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+}
+
+// Hijack hijack http.ResponseWriter
+func (w *bufferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.passThrough = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
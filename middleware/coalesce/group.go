@@ -0,0 +1,65 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocoalesce
+
+import (
+	"net/http"
+	"sync"
+)
+
+// result is the buffered response of leader request
+type result struct {
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// call is an in-flight or completed leader request, done would be closed after res assigned
+type call struct {
+	done chan struct{}
+	res  *result
+	dup  int
+}
+
+// group deduplicates concurrent calls with the same key, similar to golang.org/x/sync/singleflight
+type group struct {
+	lock  sync.Mutex
+	calls map[string]*call
+}
+
+func newGroup() *group {
+	return &group{
+		calls: make(map[string]*call),
+	}
+}
+
+// join returns in-flight call of key and false if exists,
+// otherwise a new call would be created and caller becomes the leader which must call done().
+func (g *group) join(key string) (*call, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if c, ok := g.calls[key]; ok {
+		c.dup++
+		return c, false
+	}
+
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+
+	return c, true
+}
+
+// done publishes result of leader and wakes up followers
+func (g *group) done(key string, c *call, res *result) {
+	g.lock.Lock()
+	delete(g.calls, key)
+	g.lock.Unlock()
+
+	c.res = res
+	close(c.done)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechocoalesce is a middleware for echo framework which deduplicates concurrent identical requests
+package rkechocoalesce
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"strings"
+)
+
+// Middleware deduplicates concurrent identical GET/HEAD requests.
+//
+// Only requests matches one of configured paths would be coalesced.
+// Requests with the same method, path, query, credential headers and selected headers are identical.
+// Set-Cookie of leader would never be copied to others.
+// Only the first request runs the handler, the others would wait and receive a copy of the buffered response.
+// Request which was canceled while waiting returns error of its context without waiting for the first one.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) || !set.ShouldCoalesce(ctx.Request()) {
+				return next(ctx)
+			}
+
+			key := set.keyOf(ctx.Request())
+			c, leader := set.group.join(key)
+
+			// case 1: follower, wait for leader and copy response
+			if !leader {
+				select {
+				case <-c.done:
+				case <-ctx.Request().Context().Done():
+					return ctx.Request().Context().Err()
+				}
+
+				if vec := set.metricsSet.GetCounter(MetricsNameCoalesced); vec != nil {
+					vec.WithLabelValues(set.EntryName, set.EntryType, ctx.Path()).Inc()
+				}
+
+				return replay(ctx, c.res)
+			}
+
+			// case 2: leader, run handler and record response
+			res := &result{
+				err: rkmid.GetErrorBuilder().New(http.StatusInternalServerError, "Coalesced request panicked"),
+			}
+			defer func() {
+				set.group.done(key, c, res)
+			}()
+
+			headerBefore := ctx.Response().Header().Clone()
+
+			originalWriter := ctx.Response().Writer
+			recorder := newRecordWriter(originalWriter)
+			ctx.Response().Writer = recorder
+
+			err := next(ctx)
+
+			ctx.Response().Writer = originalWriter
+
+			res.err = err
+			if ctx.Response().Committed {
+				res.status = ctx.Response().Status
+				res.header = diffHeader(headerBefore, ctx.Response().Header())
+				res.body = recorder.body.Bytes()
+			}
+
+			return err
+		}
+	}
+}
+
+// replay writes response of leader, error of leader would be returned if leader did not write response
+func replay(ctx echo.Context, res *result) error {
+	if res.status == 0 {
+		if res.err == nil {
+			return fmt.Errorf("coalesced request returned without response")
+		}
+		return res.err
+	}
+
+	header := ctx.Response().Header()
+	for k, v := range res.header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	ctx.Response().WriteHeader(res.status)
+	if ctx.Request().Method == http.MethodHead {
+		return nil
+	}
+
+	_, err := ctx.Response().Write(res.body)
+	return err
+}
+
+// diffHeader returns headers which were added or changed after snapshot, Set-Cookie is excluded
+func diffHeader(before, after http.Header) http.Header {
+	res := make(http.Header)
+
+	for k, v := range after {
+		if k == echo.HeaderSetCookie {
+			continue
+		}
+
+		if old, ok := before[k]; ok && strings.Join(old, ",") == strings.Join(v, ",") {
+			continue
+		}
+
+		res[k] = append([]string(nil), v...)
+	}
+
+	return res
+}
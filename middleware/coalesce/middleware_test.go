@@ -0,0 +1,166 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocoalesce
+
+import (
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var counter int32
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.Use(Middleware(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithPaths("/ut-path")))
+	e.GET("/ut-path", func(ctx echo.Context) error {
+		<-release
+		n := atomic.AddInt32(&counter, 1)
+		ctx.Response().Header().Set("X-Counter", fmt.Sprintf("%d", n))
+		ctx.SetCookie(&http.Cookie{Name: "session", Value: "ut-session"})
+		return ctx.String(http.StatusOK, "ut-body")
+	})
+
+	set := optionsMap["ut-entry"]
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	wg := sync.WaitGroup{}
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ut-path?a=1", nil))
+		}(recorders[i])
+	}
+
+	// wait for all followers to join
+	assert.Eventually(t, func() bool {
+		set.group.lock.Lock()
+		defer set.group.lock.Unlock()
+		c, ok := set.group.calls["GET /ut-path?a=1"]
+		return ok && c.dup == len(recorders)-1
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&counter))
+	for i := range recorders {
+		assert.Equal(t, http.StatusOK, recorders[i].Code)
+		assert.Equal(t, "ut-body", recorders[i].Body.String())
+		assert.Equal(t, "1", recorders[i].Header().Get("X-Counter"))
+	}
+
+	// only leader receives Set-Cookie
+	cookies := 0
+	for i := range recorders {
+		if len(recorders[i].Header().Get(echo.HeaderSetCookie)) > 0 {
+			cookies++
+		}
+	}
+	assert.Equal(t, 1, cookies)
+
+	assert.Equal(t, float64(4), testutil.ToFloat64(set.metricsSet.GetCounter(MetricsNameCoalesced)))
+}
+
+func TestMiddleware_FollowerCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	inter := Middleware(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithPaths("/ut-path"))
+	handler := inter(func(ctx echo.Context) error {
+		<-release
+		return ctx.NoContent(http.StatusOK)
+	})
+
+	// leader blocks until released
+	go handler(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ut-path", nil), httptest.NewRecorder()))
+	time.Sleep(10 * time.Millisecond)
+
+	// follower returns as soon as its context canceled
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil).WithContext(reqCtx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- handler(echo.New().NewContext(req, httptest.NewRecorder()))
+	}()
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "follower still waiting for leader after canceled")
+	}
+}
+
+func TestMiddleware_NotCoalesced(t *testing.T) {
+	var counter int32
+
+	e := echo.New()
+	e.Use(Middleware(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithPaths("/coalesced")))
+	handler := func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return ctx.NoContent(http.StatusOK)
+	}
+	e.GET("/other", handler)
+	e.POST("/coalesced", handler)
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other", nil))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/coalesced", nil))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+
+	// without paths, nothing is coalesced
+	set := newOptionSet(WithRegisterer(prometheus.NewRegistry()))
+	assert.False(t, set.ShouldCoalesce(httptest.NewRequest(http.MethodGet, "/coalesced", nil)))
+}
+
+func TestReplay(t *testing.T) {
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// leader returned error without response
+	assert.NotNil(t, replay(ctx, &result{err: fmt.Errorf("ut-error")}))
+	assert.NotNil(t, replay(ctx, &result{}))
+
+	// leader wrote response
+	assert.Nil(t, replay(ctx, &result{
+		status: http.StatusAccepted,
+		header: http.Header{"X-Ut": []string{"ut"}},
+		body:   []byte("ut-body"),
+	}))
+	w := ctx.Response().Writer.(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "ut", w.Header().Get("X-Ut"))
+	assert.Equal(t, "ut-body", w.Body.String())
+}
+
+func TestDiffHeader(t *testing.T) {
+	before := http.Header{"X-Same": []string{"ut"}}
+	after := http.Header{
+		"X-Same":             []string{"ut"},
+		"X-New":              []string{"ut"},
+		echo.HeaderSetCookie: []string{"session=ut"},
+	}
+
+	assert.Equal(t, http.Header{"X-New": []string{"ut"}}, diffHeader(before, after))
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocoalesce
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MetricsNameCoalesced records requests which received response of another identical request
+	MetricsNameCoalesced = "coalesced"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	labelKeys = []string{"entryName", "entryType", "path"}
+
+	// credentialHeaders are always part of key so that responses are never shared across callers
+	credentialHeaders = []string{rkmid.HeaderAuthorization, rkmid.HeaderApiKey, "Cookie"}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:  xid.New().String(),
+		EntryType:  "",
+		Skipper:    defaultSkipper,
+		registerer: prometheus.DefaultRegisterer,
+		group:      newGroup(),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	set.metricsSet = rkmidprom.NewMetricsSet("rk", "coalesce", set.registerer)
+	set.metricsSet.RegisterCounter(MetricsNameCoalesced, labelKeys...)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	Headers      []string
+	paths        []string
+	ignorePrefix []string
+	registerer   prometheus.Registerer
+	metricsSet   *rkmidprom.MetricsSet
+	group        *group
+}
+
+// ShouldIgnore determine whether coalescing should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ShouldCoalesce determine whether request is GET/HEAD and matches one of paths.
+// Nothing would be coalesced if no path was provided.
+func (set *optionSet) ShouldCoalesce(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	for i := range set.paths {
+		if strings.HasPrefix(req.URL.Path, set.paths[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyOf returns method, path, query, credential headers and selected headers of request
+func (set *optionSet) keyOf(req *http.Request) string {
+	builder := strings.Builder{}
+	builder.WriteString(req.Method)
+	builder.WriteString(" ")
+	builder.WriteString(req.URL.Path)
+	builder.WriteString("?")
+	builder.WriteString(req.URL.Query().Encode())
+	for i := range credentialHeaders {
+		if values := req.Header.Values(credentialHeaders[i]); len(values) > 0 {
+			builder.WriteString("\n")
+			builder.WriteString(credentialHeaders[i])
+			builder.WriteString(":")
+			builder.WriteString(strings.Join(values, ","))
+		}
+	}
+	for i := range set.Headers {
+		builder.WriteString("\n")
+		builder.WriteString(set.Headers[i])
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(req.Header.Values(set.Headers[i]), ","))
+	}
+
+	return builder.String()
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled bool     `yaml:"enabled" json:"enabled"`
+	Ignore  []string `yaml:"ignore" json:"ignore"`
+	Headers []string `yaml:"headers" json:"headers"`
+	Paths   []string `yaml:"paths" json:"paths"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string, registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithRegisterer(registerer),
+			WithHeaders(config.Headers...),
+			WithPaths(config.Paths...),
+			WithPathToIgnore(config.Ignore...))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for coalesced metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithHeaders provide request headers which distinguish identical requests, for example Accept-Language.
+// Authorization, X-API-Key and Cookie are always distinguished.
+func WithHeaders(headers ...string) Option {
+	return func(opt *optionSet) {
+		for i := range headers {
+			opt.Headers = append(opt.Headers, http.CanonicalHeaderKey(headers[i]))
+		}
+	}
+}
+
+// WithPaths provide path prefix which would be coalesced, nothing would be coalesced if no path provided.
+func WithPaths(paths ...string) Option {
+	return func(opt *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				opt.paths = append(opt.paths, paths[i])
+			}
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
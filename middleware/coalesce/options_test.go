@@ -0,0 +1,77 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocoalesce
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet(WithRegisterer(prometheus.NewRegistry()))
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.NotNil(t, set.group)
+	assert.NotNil(t, set.metricsSet.GetCounter(MetricsNameCoalesced))
+	assert.False(t, set.ShouldCoalesce(httptest.NewRequest(http.MethodGet, "/any", nil)))
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithHeaders("authorization"),
+		WithPaths("/ut-path"),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, []string{"Authorization"}, set.Headers)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+	assert.True(t, set.ShouldCoalesce(httptest.NewRequest(http.MethodHead, "/ut-path/1", nil)))
+	assert.False(t, set.ShouldCoalesce(httptest.NewRequest(http.MethodGet, "/other", nil)))
+	assert.False(t, set.ShouldCoalesce(httptest.NewRequest(http.MethodPost, "/ut-path", nil)))
+}
+
+func TestOptionSet_KeyOf(t *testing.T) {
+	set := newOptionSet(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithHeaders("Accept-Language"))
+
+	req1 := httptest.NewRequest(http.MethodGet, "/ut-path?b=2&a=1", nil)
+	req2 := httptest.NewRequest(http.MethodGet, "/ut-path?a=1&b=2", nil)
+	assert.Equal(t, set.keyOf(req1), set.keyOf(req2))
+
+	req2.Header.Set("Accept-Language", "en")
+	assert.NotEqual(t, set.keyOf(req1), set.keyOf(req2))
+
+	// credential headers are always distinguished
+	for _, header := range []string{"Authorization", "X-API-Key", "Cookie"} {
+		req1 = httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+		req2 = httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+		req1.Header.Set(header, "ut-user-1")
+		req2.Header.Set(header, "ut-user-2")
+		assert.NotEqual(t, set.keyOf(req1), set.keyOf(req2), header)
+	}
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", "", nil))
+
+	// with enabled
+	config.Enabled = true
+	config.Paths = []string{"/ut-path"}
+	assert.NotEmpty(t, ToOptions(config, "", "", nil))
+
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type", prometheus.NewRegistry())...)
+	assert.Equal(t, []string{"/ut-path"}, set.paths)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocoalesce
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// recordWriter writes to original http.ResponseWriter and keeps a copy of body
+type recordWriter struct {
+	http.ResponseWriter
+	body *bytes.Buffer
+}
+
+func newRecordWriter(w http.ResponseWriter) *recordWriter {
+	return &recordWriter{
+		ResponseWriter: w,
+		body:           new(bytes.Buffer),
+	}
+}
+
+// Write writes bytes into both buffer and http.ResponseWriter
+func (w *recordWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes contents in http.ResponseWriter.
+func (w *recordWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijack http.ResponseWriter
+func (w *recordWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoctx
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderRequestTimeout carries remaining deadline of incoming request in milliseconds to downstream services
+	HeaderRequestTimeout = "X-Request-Timeout"
+	// MetricsNameClientElapsedNano records elapsed time of outbound http requests
+	MetricsNameClientElapsedNano = "clientElapsedNano"
+	// MetricsNameClientResCode records response code of outbound http requests, error if no response received
+	MetricsNameClientResCode = "clientResCode"
+)
+
+var (
+	clientLabelKeys   = []string{"entryName", "method", "host", "resCode"}
+	clientMetricsLock sync.Mutex
+	// clientMetricsMap maps entry name to metrics set of its registerer
+	clientMetricsMap = make(map[string]*rkmidprom.MetricsSet)
+	// clientRegistererMap holds one metrics set per registerer, entries sharing registerer are distinguished by entryName label
+	clientRegistererMap = make(map[prometheus.Registerer]*rkmidprom.MetricsSet)
+
+	// defaultPropagator is used while tracing middleware is not enabled
+	defaultPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// SetClientRegisterer provide prometheus.Registerer of entry, metrics of outbound requests sent with HTTPClient()
+// in handlers of entry would be recorded into it.
+//
+// It is called by EchoEntry while bootstrapping with registry of PromEntry.
+// Metrics are registered once per registerer, so entries could share the same registry.
+func SetClientRegisterer(entryName string, registerer prometheus.Registerer) {
+	clientMetricsLock.Lock()
+	defer clientMetricsLock.Unlock()
+
+	if registerer == nil {
+		delete(clientMetricsMap, entryName)
+		return
+	}
+
+	set, ok := clientRegistererMap[registerer]
+	if !ok {
+		set = rkmidprom.NewMetricsSet("rk", "client", registerer)
+		set.RegisterSummary(MetricsNameClientElapsedNano, rkmidprom.SummaryObjectives, clientLabelKeys...)
+		set.RegisterCounter(MetricsNameClientResCode, clientLabelKeys...)
+		clientRegistererMap[registerer] = set
+	}
+
+	clientMetricsMap[entryName] = set
+}
+
+// getClientMetrics returns metrics set of entry, nil if not registered
+func getClientMetrics(entryName string) *rkmidprom.MetricsSet {
+	clientMetricsLock.Lock()
+	defer clientMetricsLock.Unlock()
+
+	return clientMetricsMap[entryName]
+}
+
+// HTTPClient returns http.Client for outbound requests sent while handling request of ctx.
+//
+// See NewRoundTripper for details, the client should not be shared across requests.
+func HTTPClient(ctx echo.Context) *http.Client {
+	return &http.Client{
+		Transport: NewRoundTripper(ctx, nil),
+	}
+}
+
+// NewRoundTripper wraps base http.RoundTripper, http.DefaultTransport would be used if base is nil.
+//
+// 1: Request id, trace context and baggage of incoming request are propagated with headers.
+// 2: Remaining deadline of incoming request is applied to outbound request and sent with X-Request-Timeout header.
+// 3: Client span is created as child of span of incoming request.
+// 4: Outbound requests are logged with call-scoped logger from GetLogger().
+// 5: Elapsed time and response code are recorded in registry of entry.
+func NewRoundTripper(ctx echo.Context, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &roundTripper{
+		echoCtx: ctx,
+		base:    base,
+	}
+}
+
+// roundTripper propagates rk context of incoming request to outbound requests
+type roundTripper struct {
+	echoCtx echo.Context
+	base    http.RoundTripper
+}
+
+// RoundTrip sends request with cloned request, original request would not be modified
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.echoCtx == nil || rt.echoCtx.Request() == nil {
+		return rt.base.RoundTrip(req)
+	}
+
+	startTime := time.Now()
+	incoming := rt.echoCtx.Request()
+	outCtx := req.Context()
+
+	// remaining deadline of incoming request
+	var cancel context.CancelFunc
+	if deadline, ok := incoming.Context().Deadline(); ok {
+		if current, ok := outCtx.Deadline(); !ok || deadline.Before(current) {
+			outCtx, cancel = context.WithDeadline(outCtx, deadline)
+		}
+	}
+
+	// baggage of incoming request
+	if baggage.FromContext(outCtx).Len() < 1 {
+		bag := baggage.FromContext(incoming.Context())
+		if bag.Len() < 1 {
+			bag = baggage.FromContext(propagation.Baggage{}.Extract(context.Background(), propagation.HeaderCarrier(incoming.Header)))
+		}
+		if bag.Len() > 0 {
+			outCtx = baggage.ContextWithBaggage(outCtx, bag)
+		}
+	}
+
+	// client span as child of span of incoming request
+	if !trace.SpanContextFromContext(outCtx).IsValid() {
+		outCtx = trace.ContextWithSpan(outCtx, GetTraceSpan(rt.echoCtx))
+	}
+	outCtx, span := GetTracer(rt.echoCtx).Start(outCtx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", urlOf(req)),
+			attribute.String("net.peer.name", req.URL.Host)))
+
+	out := req.Clone(outCtx)
+
+	if requestId := GetRequestId(rt.echoCtx); len(requestId) > 0 && len(out.Header.Get(rkmid.HeaderRequestId)) < 1 {
+		out.Header.Set(rkmid.HeaderRequestId, requestId)
+	}
+
+	if deadline, ok := outCtx.Deadline(); ok {
+		out.Header.Set(HeaderRequestTimeout, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
+	propagator := GetTracerPropagator(rt.echoCtx)
+	if propagator == nil {
+		propagator = defaultPropagator
+	}
+	propagator.Inject(outCtx, propagation.HeaderCarrier(out.Header))
+
+	resp, err := rt.base.RoundTrip(out)
+	elapsed := time.Now().Sub(startTime)
+
+	resCode := "error"
+	if err == nil {
+		resCode = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	}
+
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(otelcodes.Error, resCode)
+	} else {
+		span.SetStatus(otelcodes.Ok, resCode)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+
+	rt.record(req, resCode, elapsed, err)
+
+	// release deadline after body closed
+	if cancel != nil {
+		if err != nil {
+			cancel()
+		} else {
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		}
+	}
+
+	return resp, err
+}
+
+// record logs outbound request and records metrics
+func (rt *roundTripper) record(req *http.Request, resCode string, elapsed time.Duration, err error) {
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("url", urlOf(req)),
+		zap.String("resCode", resCode),
+		zap.Duration("elapsed", elapsed),
+	}
+
+	logger := GetLogger(rt.echoCtx)
+	if err != nil {
+		logger.Warn("Outbound request failed", append(fields, zap.Error(err))...)
+	} else {
+		logger.Info("Outbound request", fields...)
+	}
+
+	set := getClientMetrics(GetEntryName(rt.echoCtx))
+	if set == nil {
+		return
+	}
+
+	values := []string{GetEntryName(rt.echoCtx), req.Method, req.URL.Host, resCode}
+	if vec := set.GetSummary(MetricsNameClientElapsedNano); vec != nil {
+		vec.WithLabelValues(values...).Observe(float64(elapsed.Nanoseconds()))
+	}
+	if vec := set.GetCounter(MetricsNameClientResCode); vec != nil {
+		vec.WithLabelValues(values...).Inc()
+	}
+}
+
+// urlOf returns url without query and user info which may contain credentials
+func urlOf(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+
+	return req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+}
+
+// cancelBody cancels context of outbound request once body closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes body and cancels context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoctx
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type errRoundTripper struct{}
+
+func (rt *errRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("ut-error")
+}
+
+func TestHTTPClient(t *testing.T) {
+	defer assertNotPanic(t)
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	SetClientRegisterer("ut-entry", registry)
+	defer SetClientRegisterer("ut-entry", nil)
+
+	// incoming request with request id, span, baggage and deadline
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	})
+	_, span := noopTracerProvider.Tracer("ut").Start(trace.ContextWithSpanContext(context.Background(), spanCtx), "ut-span")
+
+	reqCtx, cancel := context.WithTimeout(WithRequestId(context.Background(), "ut-request-id"), time.Minute)
+	defer cancel()
+	incoming := httptest.NewRequest(http.MethodGet, "/ut-path", nil).WithContext(reqCtx)
+	incoming.Header.Set("baggage", "tenant=ut-tenant")
+
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := echo.New().NewContext(incoming, httptest.NewRecorder())
+	ctx.Set(rkmid.EntryNameKey.String(), "ut-entry")
+	ctx.Set(rkmid.SpanKey.String(), span)
+	ctx.Set(rkmid.LoggerKey.String(), zap.New(core))
+
+	resp, err := HTTPClient(ctx).Get(server.URL + "/ut-remote?token=ut-secret")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Nil(t, resp.Body.Close())
+
+	// propagated headers
+	assert.Equal(t, "ut-request-id", received.Get(rkmid.HeaderRequestId))
+	assert.Contains(t, received.Get("traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, "tenant=ut-tenant", received.Get("baggage"))
+	timeout, err := strconv.Atoi(received.Get(HeaderRequestTimeout))
+	assert.Nil(t, err)
+	assert.True(t, timeout > 0 && timeout <= 60000)
+
+	// logged without query
+	assert.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, server.URL+"/ut-remote", fields["url"])
+	assert.Equal(t, "202", fields["resCode"])
+	assert.Equal(t, "ut-request-id", fields["requestId"])
+
+	// metrics
+	host := resp.Request.URL.Host
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		getClientMetrics("ut-entry").GetCounter(MetricsNameClientResCode).WithLabelValues("ut-entry", http.MethodGet, host, "202")))
+}
+
+func TestNewRoundTripper(t *testing.T) {
+	defer assertNotPanic(t)
+
+	registry := prometheus.NewRegistry()
+	SetClientRegisterer("ut-entry", registry)
+	defer SetClientRegisterer("ut-entry", nil)
+
+	ctx := newCtx()
+	ctx.Set(rkmid.EntryNameKey.String(), "ut-entry")
+
+	// with error
+	req := httptest.NewRequest(http.MethodPost, "http://ut-host/ut-path", nil)
+	req.Header.Set(rkmid.HeaderRequestId, "ut-existing")
+	_, err := NewRoundTripper(ctx, &errRoundTripper{}).RoundTrip(req)
+	assert.NotNil(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		getClientMetrics("ut-entry").GetCounter(MetricsNameClientResCode).WithLabelValues("ut-entry", http.MethodPost, "ut-host", "error")))
+
+	// original request is not modified
+	assert.Equal(t, "ut-existing", req.Header.Get(rkmid.HeaderRequestId))
+	assert.Empty(t, req.Header.Get("traceparent"))
+
+	// with nil context
+	_, err = NewRoundTripper(nil, &errRoundTripper{}).RoundTrip(req)
+	assert.NotNil(t, err)
+
+	// with default transport
+	assert.Equal(t, http.DefaultTransport, NewRoundTripper(ctx, nil).(*roundTripper).base)
+}
+
+func TestSetClientRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	SetClientRegisterer("ut-entry-a", registry)
+	SetClientRegisterer("ut-entry-b", registry)
+	defer SetClientRegisterer("ut-entry-a", nil)
+	defer SetClientRegisterer("ut-entry-b", nil)
+
+	// entries sharing registry share metrics set
+	assert.NotNil(t, getClientMetrics("ut-entry-a"))
+	assert.Equal(t, getClientMetrics("ut-entry-a"), getClientMetrics("ut-entry-b"))
+
+	for _, entryName := range []string{"ut-entry-a", "ut-entry-b"} {
+		ctx := newCtx()
+		ctx.Set(rkmid.EntryNameKey.String(), entryName)
+		NewRoundTripper(ctx, &errRoundTripper{}).RoundTrip(httptest.NewRequest(http.MethodGet, "http://ut-host/ut-path", nil))
+	}
+
+	vec := getClientMetrics("ut-entry-a").GetCounter(MetricsNameClientResCode)
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry-a", http.MethodGet, "ut-host", "error")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry-b", http.MethodGet, "ut-host", "error")))
+
+	// unregistered entry
+	SetClientRegisterer("ut-entry-b", nil)
+	assert.Nil(t, getClientMetrics("ut-entry-b"))
+	assert.NotNil(t, getClientMetrics("ut-entry-a"))
+}
@@ -8,6 +8,7 @@ package rkechoctx
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 	rkcursor "github.com/rookie-ninja/rk-entry/v2/cursor"
@@ -18,9 +19,91 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
+const (
+	// ScopesKey is the key of scopes granted to request, assigned by authz or introspect middleware
+	ScopesKey = "scopesKeyRk"
+	// RolesKey is the key of roles granted to request, assigned by authz middleware
+	RolesKey = "rolesKeyRk"
+	// IntrospectionKey is the key of token introspection result, assigned by introspect middleware
+	IntrospectionKey = "introspectionKeyRk"
+	// AuthPrincipalKey is the key of authenticated principal, assigned by auth middleware
+	AuthPrincipalKey = "authPrincipalKeyRk"
+	// SessionKey is the key of session, assigned by session middleware
+	SessionKey = "sessionKeyRk"
+	// CspNonceKey is the key of Content-Security-Policy nonce, assigned by secure middleware
+	CspNonceKey = "cspNonceKeyRk"
+	// ClientIPKey is the key of client ip resolved behind trusted proxies, assigned by proxy middleware
+	ClientIPKey = "clientIpKeyRk"
+
+	// PrincipalTypeJwt is type of principal identified by subject of jwt token
+	PrincipalTypeJwt = "jwt"
+	// PrincipalTypeIntrospect is type of principal identified by subject or username of introspected token
+	PrincipalTypeIntrospect = "introspect"
+)
+
+// Session is the session of request, values would be serialized as JSON while storing
+type Session interface {
+	// Id returns id of session
+	Id() string
+
+	// IsNew returns true if session was created by current request
+	IsNew() bool
+
+	// Get returns value with key, nil if not exists
+	Get(key string) interface{}
+
+	// Set value with key
+	Set(key string, value interface{})
+
+	// Delete value with key
+	Delete(key string)
+
+	// Keys returns keys of values
+	Keys() []string
+
+	// Regenerate assigns new id to session and keeps values, should be called after login to prevent session fixation
+	Regenerate()
+
+	// Invalidate clears values and expires session
+	Invalidate()
+}
+
+// AuthPrincipal is the identity of credential matched by auth middleware
+type AuthPrincipal struct {
+	// Type is the type of credential, one of Basic and X-API-Key
+	Type      string    `json:"type" yaml:"type"`
+	Id        string    `json:"id" yaml:"id"`
+	Owner     string    `json:"owner,omitempty" yaml:"owner"`
+	Scopes    []string  `json:"scopes,omitempty" yaml:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty" yaml:"expiresAt"`
+}
+
+// Introspection is the result of OAuth2 token introspection, see RFC 7662
+type Introspection struct {
+	Active    bool   `json:"active" yaml:"active"`
+	Scope     string `json:"scope,omitempty" yaml:"scope"`
+	ClientId  string `json:"client_id,omitempty" yaml:"client_id"`
+	Username  string `json:"username,omitempty" yaml:"username"`
+	TokenType string `json:"token_type,omitempty" yaml:"token_type"`
+	Exp       int64  `json:"exp,omitempty" yaml:"exp"`
+	Iat       int64  `json:"iat,omitempty" yaml:"iat"`
+	Nbf       int64  `json:"nbf,omitempty" yaml:"nbf"`
+	Sub       string `json:"sub,omitempty" yaml:"sub"`
+	Iss       string `json:"iss,omitempty" yaml:"iss"`
+	Jti       string `json:"jti,omitempty" yaml:"jti"`
+}
+
+// Scopes returns space separated scopes as list
+func (i *Introspection) Scopes() []string {
+	return strings.Fields(i.Scope)
+}
+
 var (
 	noopTracerProvider = trace.NewNoopTracerProvider()
 	noopEvent          = rkquery.NewEventFactory().CreateEventNoop()
@@ -112,14 +195,41 @@ func GormCtx(ctx echo.Context) context.Context {
 	res := context.Background()
 	res = context.WithValue(res, rkmid.LoggerKey.String(), GetLogger(ctx))
 	res = context.WithValue(res, rkmid.EventKey.String(), GetEvent(ctx))
+	res = WithRequestId(res, GetRequestId(ctx))
+	res = WithTraceId(res, GetTraceId(ctx))
 	return res
 }
 
+// ctxKey is the type of keys of request-scoped values stored in context.Context of request,
+// which prevents collisions with keys defined in other packages.
+type ctxKey int
+
+const (
+	// requestIdCtxKey is the key of request id, assigned by meta middleware
+	requestIdCtxKey ctxKey = iota
+	// traceIdCtxKey is the key of trace id, assigned by tracing middleware
+	traceIdCtxKey
+)
+
 // GetRequestId extract request id from context.
-// If user enabled meta interceptor, then a random request Id would e assigned and set to context as value.
-// If user called AddHeaderToClient() with key of RequestIdKey, then a new request id would be updated.
+// If user enabled meta interceptor, then a random request Id would e assigned and set to context of request as value.
+//
+// Response header is read as fallback for request id assigned by middleware which only sets header.
+// Request id in context of request takes precedence, so calling AddHeaderToClient() or SetHeaderToClient()
+// with X-Request-Id no longer changes returned request id once meta middleware assigned one.
+// To override it, replace request with ctx.SetRequest(req.WithContext(WithRequestId(req.Context(), id))).
 func GetRequestId(ctx echo.Context) string {
-	if ctx == nil || ctx.Response().Writer == nil {
+	if ctx == nil {
+		return ""
+	}
+
+	if ctx.Request() != nil {
+		if requestId := GetRequestIdFromContext(ctx.Request().Context()); len(requestId) > 0 {
+			return requestId
+		}
+	}
+
+	if ctx.Response() == nil || ctx.Response().Writer == nil {
 		return ""
 	}
 
@@ -127,14 +237,104 @@ func GetRequestId(ctx echo.Context) string {
 }
 
 // GetTraceId extract trace id from context.
+// If user enabled tracing interceptor, then trace id of span would be set to context of request as value.
+//
+// Response header is read as fallback for trace id assigned by middleware which only sets header.
+// Trace id in context of request takes precedence over X-Trace-Id header added by AddHeaderToClient()
+// or SetHeaderToClient(), use WithTraceId() to override it.
 func GetTraceId(ctx echo.Context) string {
-	if ctx == nil || ctx.Response().Writer == nil {
+	if ctx == nil {
+		return ""
+	}
+
+	if ctx.Request() != nil {
+		if traceId := GetTraceIdFromContext(ctx.Request().Context()); len(traceId) > 0 {
+			return traceId
+		}
+	}
+
+	if ctx.Response() == nil || ctx.Response().Writer == nil {
 		return ""
 	}
 
 	return ctx.Response().Writer.Header().Get(rkmid.HeaderTraceId)
 }
 
+// WithRequestId returns copy of context.Context carries request id.
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, requestIdCtxKey, requestId)
+}
+
+// WithTraceId returns copy of context.Context carries trace id.
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, traceIdCtxKey, traceId)
+}
+
+// GetRequestIdFromContext extract request id from context.Context of request.
+// Service and repository layers without echo.Context could use it with ctx.Request().Context() passed from handler.
+func GetRequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if raw, ok := ctx.Value(requestIdCtxKey).(string); ok {
+		return raw
+	}
+
+	return ""
+}
+
+// GetTraceIdFromContext extract trace id from context.Context of request.
+// Trace id of span in context would be returned if not assigned by tracing middleware.
+func GetTraceIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if raw, ok := ctx.Value(traceIdCtxKey).(string); ok {
+		return raw
+	}
+
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		return spanCtx.TraceID().String()
+	}
+
+	return ""
+}
+
+// GetClientIP extract ip of client from context.
+//
+// Client ip resolved by proxy middleware would be returned, then ip extracted by echo.IPExtractor if configured,
+// otherwise address of connection. Forwarding headers are never trusted without proxy middleware or echo.IPExtractor.
+func GetClientIP(ctx echo.Context) string {
+	if ctx == nil || ctx.Request() == nil {
+		return ""
+	}
+
+	if raw, ok := ctx.Get(ClientIPKey).(string); ok && len(raw) > 0 {
+		return raw
+	}
+
+	if ctx.Echo() != nil && ctx.Echo().IPExtractor != nil {
+		return ctx.Echo().IPExtractor(ctx.Request())
+	}
+
+	host, _, err := net.SplitHostPort(ctx.Request().RemoteAddr)
+	if err != nil {
+		return ctx.Request().RemoteAddr
+	}
+
+	return host
+}
+
 // GetEntryName extract entry name from context.
 func GetEntryName(ctx echo.Context) string {
 	if ctx == nil {
@@ -253,6 +453,131 @@ func GetJwtToken(ctx echo.Context) *jwt.Token {
 	return nil
 }
 
+// GetJwtClaims returns claims of jwt token assigned by jwt middleware as T.
+//
+// Claims parsed by rkechojwt.ClaimsSigner would be returned directly if it is T,
+// otherwise, claims would be converted into T via json and validated if T implements jwt.Claims.
+// Returned error is rkerror.ErrorInterface.
+func GetJwtClaims[T any](ctx echo.Context) (T, error) {
+	var res T
+
+	token := GetJwtToken(ctx)
+	if token == nil || token.Claims == nil {
+		return res, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Missing jwt token")
+	}
+
+	if v, ok := token.Claims.(T); ok {
+		return v, nil
+	}
+
+	bytes, err := json.Marshal(token.Claims)
+	if err == nil {
+		err = json.Unmarshal(bytes, &res)
+	}
+	if err != nil {
+		return res, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid jwt claims", err.Error())
+	}
+
+	var claims jwt.Claims
+	if v, ok := any(res).(jwt.Claims); ok {
+		claims = v
+	} else if v, ok := any(&res).(jwt.Claims); ok {
+		claims = v
+	}
+
+	if claims != nil {
+		if err := claims.Valid(); err != nil {
+			return res, rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid jwt claims", err.Error())
+		}
+	}
+
+	return res, nil
+}
+
+// GetIntrospection returns token introspection result assigned by introspect middleware, nil if not exists
+func GetIntrospection(ctx echo.Context) *Introspection {
+	if ctx == nil {
+		return nil
+	}
+
+	if res, ok := ctx.Get(IntrospectionKey).(*Introspection); ok {
+		return res
+	}
+
+	return nil
+}
+
+// GetAuthPrincipal returns principal authenticated by auth middleware, nil if not exists
+func GetAuthPrincipal(ctx echo.Context) *AuthPrincipal {
+	if ctx == nil {
+		return nil
+	}
+
+	if res, ok := ctx.Get(AuthPrincipalKey).(*AuthPrincipal); ok {
+		return res
+	}
+
+	return nil
+}
+
+// GetPrincipal returns id and type of authenticated caller, empty strings if request was not authenticated.
+//
+// Subject of jwt token, introspected token and principal of auth middleware are checked in order,
+// type of principal of auth middleware is AuthPrincipal.Type.
+func GetPrincipal(ctx echo.Context) (string, string) {
+	if GetJwtToken(ctx) != nil {
+		claims, err := GetJwtClaims[struct {
+			Subject string `json:"sub"`
+		}](ctx)
+		if err == nil && len(claims.Subject) > 0 {
+			return claims.Subject, PrincipalTypeJwt
+		}
+	}
+
+	if introspection := GetIntrospection(ctx); introspection != nil {
+		if len(introspection.Sub) > 0 {
+			return introspection.Sub, PrincipalTypeIntrospect
+		}
+		if len(introspection.Username) > 0 {
+			return introspection.Username, PrincipalTypeIntrospect
+		}
+	}
+
+	if principal := GetAuthPrincipal(ctx); principal != nil && len(principal.Id) > 0 {
+		return principal.Id, principal.Type
+	}
+
+	return "", ""
+}
+
+// GetSession returns session assigned by session middleware, nil if not exists
+func GetSession(ctx echo.Context) Session {
+	if ctx == nil {
+		return nil
+	}
+
+	if res, ok := ctx.Get(SessionKey).(Session); ok {
+		return res
+	}
+
+	return nil
+}
+
+// GetCspNonce returns Content-Security-Policy nonce of request generated by secure middleware, empty if not exists.
+//
+// Nonce could be rendered in templates as <script nonce="{{ .nonce }}">
+func GetCspNonce(ctx echo.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if res, ok := ctx.Get(CspNonceKey).(string); ok {
+		return res
+	}
+
+	return ""
+}
+
 // GetCsrfToken return csrf token if exists
 func GetCsrfToken(ctx echo.Context) string {
 	if ctx == nil {
@@ -267,3 +592,85 @@ func GetCsrfToken(ctx echo.Context) string {
 
 	return ""
 }
+
+// HasScope returns true if all of scopes were granted to request.
+//
+// Scopes resolved by authz middleware would be used, then scopes of principal authenticated by auth middleware,
+// otherwise, scope or scp claim of jwt token would be used.
+func HasScope(ctx echo.Context, scopes ...string) bool {
+	if ctx == nil {
+		return false
+	}
+
+	granted, ok := ctx.Get(ScopesKey).([]string)
+	if !ok {
+		if principal := GetAuthPrincipal(ctx); principal != nil && len(principal.Scopes) > 0 {
+			return containsAll(principal.Scopes, scopes)
+		}
+
+		if token := GetJwtToken(ctx); token != nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				granted = append(ClaimStrings(claims["scope"]), ClaimStrings(claims["scp"])...)
+			}
+		}
+	}
+
+	return containsAll(granted, scopes)
+}
+
+// HasRole returns true if all of roles were granted to request.
+//
+// Roles resolved by authz middleware would be used,
+// otherwise, roles claim of jwt token would be used.
+func HasRole(ctx echo.Context, roles ...string) bool {
+	if ctx == nil {
+		return false
+	}
+
+	granted, ok := ctx.Get(RolesKey).([]string)
+	if !ok {
+		if token := GetJwtToken(ctx); token != nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				granted = ClaimStrings(claims["roles"])
+			}
+		}
+	}
+
+	return containsAll(granted, roles)
+}
+
+// ClaimStrings converts claim value of space separated string or list of strings into list
+func ClaimStrings(raw interface{}) []string {
+	res := make([]string, 0)
+
+	switch v := raw.(type) {
+	case string:
+		res = append(res, strings.Fields(v)...)
+	case []string:
+		res = append(res, v...)
+	case []interface{}:
+		for i := range v {
+			if str, ok := v[i].(string); ok {
+				res = append(res, str)
+			}
+		}
+	}
+
+	return res
+}
+
+// containsAll returns true if all of expected elements exist in granted
+func containsAll(granted, expected []string) bool {
+	set := make(map[string]bool)
+	for i := range granted {
+		set[granted[i]] = true
+	}
+
+	for i := range expected {
+		if !set[expected[i]] {
+			return false
+		}
+	}
+
+	return true
+}
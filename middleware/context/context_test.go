@@ -7,9 +7,11 @@ package rkechoctx
 
 import (
 	"context"
+	"errors"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 	rkcursor "github.com/rookie-ninja/rk-entry/v2/cursor"
+	"github.com/rookie-ninja/rk-entry/v2/error"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-logger"
 	"github.com/rookie-ninja/rk-query"
@@ -38,6 +40,12 @@ func TestGetIncomingHeaders(t *testing.T) {
 func TestGormCtx(t *testing.T) {
 	ctx := newCtx()
 	assert.NotNil(t, GormCtx(ctx))
+
+	// request id and trace id are carried
+	ctx.SetRequest(ctx.Request().WithContext(WithTraceId(WithRequestId(ctx.Request().Context(), "ut-request-id"), "ut-trace-id")))
+	gormCtx := GormCtx(ctx)
+	assert.Equal(t, "ut-request-id", GetRequestIdFromContext(gormCtx))
+	assert.Equal(t, "ut-trace-id", GetTraceIdFromContext(gormCtx))
 }
 
 func TestAddHeaderToClient(t *testing.T) {
@@ -116,6 +124,26 @@ func TestGetRequestId(t *testing.T) {
 	// Happy case
 	ctx.Response().Writer.Header().Set(rkmid.HeaderRequestId, "ut-request-id")
 	assert.Equal(t, "ut-request-id", GetRequestId(ctx))
+
+	// With requestId in context of request, which is kept after header removed or writer swapped
+	ctx.SetRequest(ctx.Request().WithContext(WithRequestId(ctx.Request().Context(), "ut-ctx-request-id")))
+	assert.Equal(t, "ut-ctx-request-id", GetRequestId(ctx))
+	SetHeaderToClient(ctx, rkmid.HeaderRequestId, "ut-overridden-request-id")
+	assert.Equal(t, "ut-ctx-request-id", GetRequestId(ctx))
+	ctx.Response().Writer = nil
+	assert.Equal(t, "ut-ctx-request-id", GetRequestId(ctx))
+}
+
+func TestGetRequestIdFromContext(t *testing.T) {
+	// With nil context
+	assert.Empty(t, GetRequestIdFromContext(nil))
+
+	// With no requestId in context
+	assert.Empty(t, GetRequestIdFromContext(context.Background()))
+
+	// Happy case
+	assert.Equal(t, "ut-request-id", GetRequestIdFromContext(WithRequestId(nil, "ut-request-id")))
+	assert.Equal(t, "ut-request-id", GetRequestIdFromContext(WithRequestId(context.Background(), "ut-request-id")))
 }
 
 func TestGetTraceId(t *testing.T) {
@@ -130,6 +158,56 @@ func TestGetTraceId(t *testing.T) {
 	// Happy case
 	ctx.Response().Writer.Header().Set(rkmid.HeaderTraceId, "ut-trace-id")
 	assert.Equal(t, "ut-trace-id", GetTraceId(ctx))
+
+	// With traceId in context of request, which is kept after header removed or writer swapped
+	ctx.SetRequest(ctx.Request().WithContext(WithTraceId(ctx.Request().Context(), "ut-ctx-trace-id")))
+	assert.Equal(t, "ut-ctx-trace-id", GetTraceId(ctx))
+	ctx.Response().Writer = nil
+	assert.Equal(t, "ut-ctx-trace-id", GetTraceId(ctx))
+}
+
+func TestGetTraceIdFromContext(t *testing.T) {
+	// With nil context
+	assert.Empty(t, GetTraceIdFromContext(nil))
+
+	// With no traceId in context
+	assert.Empty(t, GetTraceIdFromContext(context.Background()))
+
+	// With span in context
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", GetTraceIdFromContext(spanCtx))
+
+	// Happy case
+	assert.Equal(t, "ut-trace-id", GetTraceIdFromContext(WithTraceId(nil, "ut-trace-id")))
+	assert.Equal(t, "ut-trace-id", GetTraceIdFromContext(WithTraceId(spanCtx, "ut-trace-id")))
+}
+
+func TestGetClientIP(t *testing.T) {
+	// With nil context
+	assert.Empty(t, GetClientIP(nil))
+
+	// With address of connection, forwarding headers are not trusted
+	ctx := newCtx()
+	ctx.Request().RemoteAddr = "1.1.1.1:1234"
+	ctx.Request().Header.Set(echo.HeaderXForwardedFor, "2.2.2.2")
+	assert.Equal(t, "1.1.1.1", GetClientIP(ctx))
+
+	ctx.Request().RemoteAddr = "ut-addr"
+	assert.Equal(t, "ut-addr", GetClientIP(ctx))
+
+	// With echo.IPExtractor which trusts loopback by default
+	ctx.Request().RemoteAddr = "127.0.0.1:1234"
+	ctx.Echo().IPExtractor = echo.ExtractIPFromXFFHeader()
+	assert.Equal(t, "2.2.2.2", GetClientIP(ctx))
+
+	// With client ip in context
+	ctx.Set(ClientIPKey, "3.3.3.3")
+	assert.Equal(t, "3.3.3.3", GetClientIP(ctx))
 }
 
 func TestGetEntryName(t *testing.T) {
@@ -264,6 +342,142 @@ func TestGetJwtToken(t *testing.T) {
 	assert.NotNil(t, GetJwtToken(ctx))
 }
 
+type utClaims struct {
+	jwt.RegisteredClaims
+	Tenant string `json:"tenant"`
+}
+
+func (c *utClaims) Valid() error {
+	if len(c.Tenant) < 1 {
+		return errors.New("missing tenant")
+	}
+	return c.RegisteredClaims.Valid()
+}
+
+func TestGetJwtClaims(t *testing.T) {
+	// without token
+	ctx := newCtx()
+	_, err := GetJwtClaims[jwt.MapClaims](ctx)
+	assert.NotNil(t, err)
+	assert.Implements(t, (*rkerror.ErrorInterface)(nil), err)
+
+	// with claims of the same type
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: &utClaims{Tenant: "ut-tenant"},
+	})
+	claims, err := GetJwtClaims[*utClaims](ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-tenant", claims.Tenant)
+
+	// with map claims converted into struct
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: jwt.MapClaims{"sub": "ut-sub", "tenant": "ut-tenant"},
+	})
+	value, err := GetJwtClaims[utClaims](ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-sub", value.Subject)
+	claims, err = GetJwtClaims[*utClaims](ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-tenant", claims.Tenant)
+
+	// with invalid claims
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: jwt.MapClaims{"sub": "ut-sub"},
+	})
+	_, err = GetJwtClaims[*utClaims](ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, err.(rkerror.ErrorInterface).Code())
+
+	// with mismatched type
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: jwt.MapClaims{"tenant": 1},
+	})
+	_, err = GetJwtClaims[utClaims](ctx)
+	assert.NotNil(t, err)
+}
+
+func TestGetIntrospection(t *testing.T) {
+	// with nil
+	assert.Nil(t, GetIntrospection(nil))
+
+	// without introspection
+	ctx := newCtx()
+	assert.Nil(t, GetIntrospection(ctx))
+
+	// happy case
+	ctx.Set(IntrospectionKey, &Introspection{Active: true, Scope: "read write"})
+	assert.True(t, GetIntrospection(ctx).Active)
+	assert.Equal(t, []string{"read", "write"}, GetIntrospection(ctx).Scopes())
+}
+
+func TestGetAuthPrincipal(t *testing.T) {
+	// with nil
+	assert.Nil(t, GetAuthPrincipal(nil))
+
+	// without principal
+	ctx := newCtx()
+	assert.Nil(t, GetAuthPrincipal(ctx))
+	assert.False(t, HasScope(ctx, "read"))
+
+	// happy case
+	ctx.Set(AuthPrincipalKey, &AuthPrincipal{Id: "ut-id", Scopes: []string{"read"}})
+	assert.Equal(t, "ut-id", GetAuthPrincipal(ctx).Id)
+	assert.True(t, HasScope(ctx, "read"))
+	assert.False(t, HasScope(ctx, "write"))
+}
+
+func TestGetPrincipal(t *testing.T) {
+	// without principal
+	ctx := newCtx()
+	id, principalType := GetPrincipal(ctx)
+	assert.Empty(t, id)
+	assert.Empty(t, principalType)
+
+	// with auth principal
+	ctx.Set(AuthPrincipalKey, &AuthPrincipal{Type: "X-API-Key", Id: "ut-key"})
+	id, principalType = GetPrincipal(ctx)
+	assert.Equal(t, "ut-key", id)
+	assert.Equal(t, "X-API-Key", principalType)
+
+	// with introspection
+	ctx.Set(IntrospectionKey, &Introspection{Active: true, Username: "ut-user"})
+	id, principalType = GetPrincipal(ctx)
+	assert.Equal(t, "ut-user", id)
+	assert.Equal(t, PrincipalTypeIntrospect, principalType)
+
+	// with jwt token
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{Claims: jwt.MapClaims{"sub": "ut-sub"}})
+	id, principalType = GetPrincipal(ctx)
+	assert.Equal(t, "ut-sub", id)
+	assert.Equal(t, PrincipalTypeJwt, principalType)
+}
+
+func TestGetSession(t *testing.T) {
+	// with nil
+	assert.Nil(t, GetSession(nil))
+
+	// without session
+	ctx := newCtx()
+	assert.Nil(t, GetSession(ctx))
+
+	// with invalid type
+	ctx.Set(SessionKey, "ut-session")
+	assert.Nil(t, GetSession(ctx))
+}
+
+func TestGetCspNonce(t *testing.T) {
+	// with nil
+	assert.Empty(t, GetCspNonce(nil))
+
+	// without nonce
+	ctx := newCtx()
+	assert.Empty(t, GetCspNonce(ctx))
+
+	// with nonce
+	ctx.Set(CspNonceKey, "ut-nonce")
+	assert.Equal(t, "ut-nonce", GetCspNonce(ctx))
+}
+
 func TestGetCsrfToken(t *testing.T) {
 	defer assertNotPanic(t)
 
@@ -279,6 +493,42 @@ func TestGetCsrfToken(t *testing.T) {
 	assert.Equal(t, "value", GetCsrfToken(ctx))
 }
 
+func TestHasScope(t *testing.T) {
+	// with nil
+	assert.False(t, HasScope(nil, "read"))
+
+	// with jwt token
+	ctx := newCtx()
+	assert.False(t, HasScope(ctx, "read"))
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: jwt.MapClaims{"scope": "read write"},
+	})
+	assert.True(t, HasScope(ctx, "read", "write"))
+	assert.False(t, HasScope(ctx, "read", "admin"))
+
+	// with scopes assigned by middleware
+	ctx.Set(ScopesKey, []string{"admin"})
+	assert.True(t, HasScope(ctx, "admin"))
+	assert.False(t, HasScope(ctx, "read"))
+}
+
+func TestHasRole(t *testing.T) {
+	// with nil
+	assert.False(t, HasRole(nil, "admin"))
+
+	// with jwt token
+	ctx := newCtx()
+	ctx.Set(rkmid.JwtTokenKey.String(), &jwt.Token{
+		Claims: jwt.MapClaims{"roles": []interface{}{"admin", "user"}},
+	})
+	assert.True(t, HasRole(ctx, "admin"))
+	assert.False(t, HasRole(ctx, "root"))
+
+	// with roles assigned by middleware
+	ctx.Set(RolesKey, []string{"root"})
+	assert.True(t, HasRole(ctx, "root"))
+}
+
 func TestSetPointerCreator(t *testing.T) {
 	assert.Nil(t, pointerCreator)
 
@@ -18,30 +18,111 @@ import (
 // Mainly copied and modified from bellow.
 // https://github.com/labstack/echo/blob/master/middleware/cors.go
 func Middleware(opts ...rkmidcors.Option) echo.MiddlewareFunc {
-	set := rkmidcors.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithCorsOptions(opts...))
+}
+
+// MiddlewareWithOptions validate CORS same as Middleware with bellow extensions.
+//
+// 1: Allowed origins could be wildcard like https://*.example.com or regular expression with regex: prefix.
+// 2: Origin could be decided programmatically with WithAllowOriginFunc().
+// 3: Policy could be provided per path prefix with WithPolicyByPath().
+// 4: Private Network Access preflight requests would be allowed with WithAllowPrivateNetwork().
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
 
-			for k, v := range beforeCtx.Output.HeadersToReturn {
-				ctx.Response().Header().Set(k, v)
+			// case 0: entry policy decided by rkmidcors
+			policy := set.policyOf(ctx)
+			if policy == nil {
+				return set.beforeWithCorsSet(ctx, next)
 			}
 
-			for _, v := range beforeCtx.Output.HeaderVary {
-				ctx.Response().Header().Add(rkmid.HeaderVary, v)
+			req := ctx.Request()
+			header := ctx.Response().Header()
+			origin := req.Header.Get(rkmid.HeaderOrigin)
+			isPreflight := req.Method == http.MethodOptions
+
+			// case 1: not a CORS request
+			if len(origin) < 1 {
+				if isPreflight {
+					return ctx.NoContent(http.StatusNoContent)
+				}
+				return next(ctx)
 			}
 
-			// case 1: with abort
-			if beforeCtx.Output.Abort {
+			// response differs by origin, caches should be aware of it
+			header.Add(rkmid.HeaderVary, rkmid.HeaderOrigin)
+
+			// case 2: origin not allowed
+			if !policy.isOriginAllowed(ctx, origin) {
 				return ctx.NoContent(http.StatusNoContent)
 			}
 
-			// case 2: call next
-			return next(ctx)
+			header.Set(rkmid.HeaderAccessControlAllowOrigin, origin)
+			if policy.allowCredentials {
+				header.Set(rkmid.HeaderAccessControlAllowCredentials, "true")
+			}
+
+			// case 3: actual request
+			if !isPreflight {
+				if len(policy.exposeHeaders) > 0 {
+					header.Set(rkmid.HeaderAccessControlExposeHeaders, policy.exposeHeaders)
+				}
+				return next(ctx)
+			}
+
+			// case 4: preflight request
+			header.Add(rkmid.HeaderVary, rkmid.HeaderAccessControlRequestMethod)
+			header.Add(rkmid.HeaderVary, rkmid.HeaderAccessControlRequestHeaders)
+			header.Set(rkmid.HeaderAccessControlAllowMethods, policy.allowMethods)
+
+			if len(policy.allowHeaders) > 0 {
+				header.Set(rkmid.HeaderAccessControlAllowHeaders, policy.allowHeaders)
+			} else if v := req.Header.Get(rkmid.HeaderAccessControlRequestHeaders); len(v) > 0 {
+				header.Set(rkmid.HeaderAccessControlAllowHeaders, v)
+			}
+
+			if len(policy.maxAge) > 0 {
+				header.Set(rkmid.HeaderAccessControlMaxAge, policy.maxAge)
+			}
+
+			if req.Header.Get(HeaderAccessControlRequestPrivateNetwork) == "true" {
+				header.Add(rkmid.HeaderVary, HeaderAccessControlRequestPrivateNetwork)
+				if policy.allowPrivateNetwork {
+					header.Set(HeaderAccessControlAllowPrivateNetwork, "true")
+				}
+			}
+
+			return ctx.NoContent(http.StatusNoContent)
 		}
 	}
 }
+
+// beforeWithCorsSet validate CORS with rkmidcors
+func (set *optionSet) beforeWithCorsSet(ctx echo.Context, next echo.HandlerFunc) error {
+	beforeCtx := set.corsSet.BeforeCtx(ctx.Request())
+	set.corsSet.Before(beforeCtx)
+
+	for k, v := range beforeCtx.Output.HeadersToReturn {
+		ctx.Response().Header().Set(k, v)
+	}
+
+	for _, v := range beforeCtx.Output.HeaderVary {
+		ctx.Response().Header().Add(rkmid.HeaderVary, v)
+	}
+
+	// case 1: with abort
+	if beforeCtx.Output.Abort {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+
+	// case 2: call next
+	return next(ctx)
+}
@@ -44,6 +44,106 @@ func TestMiddleware(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestMiddlewareWithOptions(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := MiddlewareWithOptions(
+		WithAllowOrigins("https://*.example.com", `regex:https://ut-[0-9]+\.test\.com`),
+		WithAllowOriginFunc(func(ctx echo.Context, origin string) bool {
+			return origin == "https://func.com"
+		}),
+		WithAllowCredentials(true),
+		WithExposeHeaders("X-Ut-Expose"),
+		WithMaxAge(10),
+		WithAllowPrivateNetwork(true),
+		WithPolicyByPath("/public", &Policy{}))
+
+	// case 1: without origin
+	ctx, w := newCtxWithOrigin(http.MethodGet, "/ut-path", "")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+
+	// case 2: actual request with wildcard origin
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://api.example.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://api.example.com", w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", w.Header().Get(rkmid.HeaderAccessControlAllowCredentials))
+	assert.Equal(t, "X-Ut-Expose", w.Header().Get(rkmid.HeaderAccessControlExposeHeaders))
+	assert.Equal(t, rkmid.HeaderOrigin, w.Header().Get(rkmid.HeaderVary))
+
+	// case 3: regex and func origins
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://ut-1.test.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://func.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 4: origin not allowed
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://evil.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+
+	// case 5: path policy allows any origin
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/public/ut", "https://evil.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://evil.com", w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, w.Header().Get(rkmid.HeaderAccessControlAllowCredentials))
+
+	// case 6: preflight with private network access
+	ctx, w = newCtxWithOrigin(http.MethodOptions, "/ut-path", "https://api.example.com")
+	ctx.Request().Header.Set(rkmid.HeaderAccessControlRequestHeaders, "X-Ut")
+	ctx.Request().Header.Set(HeaderAccessControlRequestPrivateNetwork, "true")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://api.example.com", w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "GET,HEAD,PUT,PATCH,POST,DELETE", w.Header().Get(rkmid.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "X-Ut", w.Header().Get(rkmid.HeaderAccessControlAllowHeaders))
+	assert.Equal(t, "10", w.Header().Get(rkmid.HeaderAccessControlMaxAge))
+	assert.Equal(t, "true", w.Header().Get(HeaderAccessControlAllowPrivateNetwork))
+	assert.Contains(t, w.Header().Values(rkmid.HeaderVary), HeaderAccessControlRequestPrivateNetwork)
+
+	// case 7: preflight with private network access on path which does not allow it
+	ctx, w = newCtxWithOrigin(http.MethodOptions, "/public", "https://api.example.com")
+	ctx.Request().Header.Set(HeaderAccessControlRequestPrivateNetwork, "true")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get(HeaderAccessControlAllowPrivateNetwork))
+
+	// case 8: ignored path
+	inter = MiddlewareWithOptions(WithAllowOrigins("https://www.example.com"), WithPathToIgnore("/ut-ignore"))
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-ignore", "https://evil.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 9: entry policy decided by rkmidcors, path policy still applies
+	inter = MiddlewareWithOptions(
+		WithCorsOptions(rkmidcors.WithAllowOrigins("https://www.example.com")),
+		WithPolicyByPath("/public", &Policy{}))
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://www.example.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, "https://www.example.com", w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/ut-path", "https://evil.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Empty(t, w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+	ctx, w = newCtxWithOrigin(http.MethodGet, "/public", "https://evil.com")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, "https://evil.com", w.Header().Get(rkmid.HeaderAccessControlAllowOrigin))
+}
+
+func newCtxWithOrigin(method, path, origin string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, path, nil)
+	if len(origin) > 0 {
+		req.Header.Set(rkmid.HeaderOrigin, origin)
+	}
+	resp := httptest.NewRecorder()
+	return echo.New().NewContext(req, resp), resp
+}
+
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
 	var buf bytes.Buffer
 	req := httptest.NewRequest(http.MethodGet, "/ut-path", &buf)
@@ -0,0 +1,414 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocors
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/cors"
+	"github.com/rs/xid"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OriginAny allows any of origins
+	OriginAny = rkechointernal.OriginAny
+	// OriginRegexPrefix marks origin as regular expression, for example regex:^https://[a-z]+\.example\.com$
+	OriginRegexPrefix = rkechointernal.OriginRegexPrefix
+
+	// HeaderAccessControlRequestPrivateNetwork is sent with preflight request by browser
+	// if public website is requesting server in private network
+	HeaderAccessControlRequestPrivateNetwork = "Access-Control-Request-Private-Network"
+	// HeaderAccessControlAllowPrivateNetwork is returned if private network access is allowed
+	HeaderAccessControlAllowPrivateNetwork = "Access-Control-Allow-Private-Network"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultAllowMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodPost,
+		http.MethodDelete,
+	}
+)
+
+// Policy is CORS policy which applies to whole entry or to path prefix.
+type Policy struct {
+	// AllowOrigins could be exact origin, * or wildcard like https://*.example.com,
+	// regular expression could be provided with regex: prefix.
+	// Any of origins would be allowed if both AllowOrigins and AllowOriginFunc are empty.
+	AllowOrigins []string
+	// AllowOriginFunc decides whether origin is allowed, would be called if none of AllowOrigins matched
+	AllowOriginFunc func(ctx echo.Context, origin string) bool
+	// AllowMethods returned in preflight response, default: GET, HEAD, PUT, PATCH, POST, DELETE
+	AllowMethods []string
+	// AllowHeaders returned in preflight response, request headers would be echoed if empty
+	AllowHeaders []string
+	// AllowCredentials returns Access-Control-Allow-Credentials if true
+	AllowCredentials bool
+	// ExposeHeaders returned in actual response
+	ExposeHeaders []string
+	// MaxAge in seconds of preflight response
+	MaxAge int
+	// AllowPrivateNetwork returns Access-Control-Allow-Private-Network in preflight response
+	// if Access-Control-Request-Private-Network was sent
+	AllowPrivateNetwork bool
+}
+
+// policy is compiled Policy
+type policy struct {
+	matchers            []rkechointernal.OriginMatcher
+	allowOriginFunc     func(ctx echo.Context, origin string) bool
+	allowMethods        string
+	allowHeaders        string
+	allowCredentials    bool
+	exposeHeaders       string
+	maxAge              string
+	allowPrivateNetwork bool
+}
+
+// pathPolicy is policy applies to path prefix
+type pathPolicy struct {
+	path   string
+	policy *policy
+}
+
+// compile Policy, error would be returned if any of regex origins is invalid
+func (p *Policy) compile() (*policy, error) {
+	res := &policy{
+		allowOriginFunc:     p.AllowOriginFunc,
+		allowMethods:        strings.Join(p.AllowMethods, ","),
+		allowHeaders:        strings.Join(p.AllowHeaders, ","),
+		allowCredentials:    p.AllowCredentials,
+		exposeHeaders:       strings.Join(p.ExposeHeaders, ","),
+		allowPrivateNetwork: p.AllowPrivateNetwork,
+	}
+
+	if len(p.AllowMethods) < 1 {
+		res.allowMethods = strings.Join(defaultAllowMethods, ",")
+	}
+
+	if p.MaxAge > 0 {
+		res.maxAge = strconv.Itoa(p.MaxAge)
+	}
+
+	origins := p.AllowOrigins
+	if len(origins) < 1 && p.AllowOriginFunc == nil {
+		origins = []string{OriginAny}
+	}
+
+	for i := range origins {
+		if len(origins[i]) < 1 {
+			continue
+		}
+
+		matcher, err := rkechointernal.NewOriginMatcher(origins[i])
+		if err != nil {
+			return nil, err
+		}
+		res.matchers = append(res.matchers, matcher)
+	}
+
+	return res, nil
+}
+
+// isOriginAllowed returns true if any of origin matchers matched or allowOriginFunc returns true
+func (p *policy) isOriginAllowed(ctx echo.Context, origin string) bool {
+	for i := range p.matchers {
+		if p.matchers[i].Match(origin) {
+			return true
+		}
+	}
+
+	if p.allowOriginFunc != nil {
+		return p.allowOriginFunc(ctx, origin)
+	}
+
+	return false
+}
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:    "",
+		EntryType:    "",
+		Skipper:      defaultSkipper,
+		policy:       &Policy{},
+		pathPolicies: make(map[string]*Policy),
+		corsOpts:     make([]rkmidcors.Option, 0),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry policy would be decided by rkmidcors if options of it provided,
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidcors
+	switch {
+	case len(set.corsOpts) > 0 && len(set.EntryName) > 0:
+		set.corsSet = rkmidcors.NewOptionSet(append(set.corsOpts,
+			rkmidcors.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	case len(set.corsOpts) > 0:
+		set.corsSet = rkmidcors.NewOptionSet(append([]rkmidcors.Option{
+			rkmidcors.WithEntryNameAndType(xid.New().String(), "")}, set.corsOpts...)...)
+		set.EntryName = set.corsSet.GetEntryName()
+		set.EntryType = set.corsSet.GetEntryType()
+	default:
+		var err error
+		if set.compiled, err = set.policy.compile(); err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+	}
+
+	if len(set.EntryName) < 1 {
+		set.EntryName = xid.New().String()
+	}
+
+	for path, p := range set.pathPolicies {
+		compiled, err := p.compile()
+		if err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+		set.compiledPaths = append(set.compiledPaths, &pathPolicy{
+			path:   path,
+			policy: compiled,
+		})
+	}
+
+	// longest path prefix wins
+	sort.Slice(set.compiledPaths, func(i, j int) bool {
+		return len(set.compiledPaths[i].path) > len(set.compiledPaths[j].path)
+	})
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName     string
+	EntryType     string
+	Skipper       Skipper
+	policy        *Policy
+	pathPolicies  map[string]*Policy
+	compiled      *policy
+	compiledPaths []*pathPolicy
+	ignorePrefix  []string
+	corsOpts      []rkmidcors.Option
+	corsSet       rkmidcors.OptionSetInterface
+}
+
+// ShouldIgnore determine whether cors should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// policyOf returns policy of longest matching path prefix, entry policy would be returned if none matched,
+// nil would be returned if entry policy is decided by rkmidcors
+func (set *optionSet) policyOf(ctx echo.Context) *policy {
+	if ctx != nil && ctx.Request().URL != nil {
+		for _, v := range set.compiledPaths {
+			if strings.HasPrefix(ctx.Request().URL.Path, v.path) {
+				return v.policy
+			}
+		}
+	}
+
+	return set.compiled
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidcors.BootConfig with private network access and path policies
+type BootConfig struct {
+	rkmidcors.BootConfig `yaml:",inline" mapstructure:",squash"`
+	AllowPrivateNetwork  bool           `yaml:"allowPrivateNetwork" json:"allowPrivateNetwork"`
+	Paths                []PolicyConfig `yaml:"paths" json:"paths"`
+}
+
+// PolicyConfig for YAML, policy applies to path prefix and overrides entry policy as a whole
+type PolicyConfig struct {
+	Path                string   `yaml:"path" json:"path"`
+	AllowOrigins        []string `yaml:"allowOrigins" json:"allowOrigins"`
+	AllowCredentials    bool     `yaml:"allowCredentials" json:"allowCredentials"`
+	AllowHeaders        []string `yaml:"allowHeaders" json:"allowHeaders"`
+	AllowMethods        []string `yaml:"allowMethods" json:"allowMethods"`
+	ExposeHeaders       []string `yaml:"exposeHeaders" json:"exposeHeaders"`
+	MaxAge              int      `yaml:"maxAge" json:"maxAge"`
+	AllowPrivateNetwork bool     `yaml:"allowPrivateNetwork" json:"allowPrivateNetwork"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithAllowOrigins(config.AllowOrigins...),
+			WithAllowCredentials(config.AllowCredentials),
+			WithExposeHeaders(config.ExposeHeaders...),
+			WithMaxAge(config.MaxAge),
+			WithAllowHeaders(config.AllowHeaders...),
+			WithAllowMethods(config.AllowMethods...),
+			WithAllowPrivateNetwork(config.AllowPrivateNetwork),
+			WithPathToIgnore(config.Ignore...))
+
+		for i := range config.Paths {
+			e := config.Paths[i]
+			opts = append(opts, WithPolicyByPath(e.Path, &Policy{
+				AllowOrigins:        e.AllowOrigins,
+				AllowMethods:        e.AllowMethods,
+				AllowHeaders:        e.AllowHeaders,
+				AllowCredentials:    e.AllowCredentials,
+				ExposeHeaders:       e.ExposeHeaders,
+				MaxAge:              e.MaxAge,
+				AllowPrivateNetwork: e.AllowPrivateNetwork,
+			}))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithCorsOptions provide options of rkmidcors which decides entry policy instead of WithAllowOrigins and others,
+// policies provided with WithPolicyByPath still apply to matched path prefixes.
+func WithCorsOptions(opts ...rkmidcors.Option) Option {
+	return func(opt *optionSet) {
+		opt.corsOpts = append(opt.corsOpts, opts...)
+	}
+}
+
+// WithAllowOrigins provide allowed origins, exact origin, * and wildcard like https://*.example.com are supported,
+// regular expression could be provided with regex: prefix.
+func WithAllowOrigins(origins ...string) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowOrigins = append(opt.policy.AllowOrigins, origins...)
+	}
+}
+
+// WithAllowOriginFunc provide function which decides whether origin is allowed if none of allowed origins matched.
+func WithAllowOriginFunc(f func(ctx echo.Context, origin string) bool) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowOriginFunc = f
+	}
+}
+
+// WithAllowMethods provide allowed http methods, default: GET, HEAD, PUT, PATCH, POST, DELETE.
+func WithAllowMethods(methods ...string) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowMethods = append(opt.policy.AllowMethods, methods...)
+	}
+}
+
+// WithAllowHeaders provide allowed request headers, requested headers would be echoed if empty.
+func WithAllowHeaders(headers ...string) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowHeaders = append(opt.policy.AllowHeaders, headers...)
+	}
+}
+
+// WithAllowCredentials return Access-Control-Allow-Credentials if true.
+func WithAllowCredentials(allow bool) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowCredentials = allow
+	}
+}
+
+// WithExposeHeaders provide headers which could be read by client.
+func WithExposeHeaders(headers ...string) Option {
+	return func(opt *optionSet) {
+		opt.policy.ExposeHeaders = append(opt.policy.ExposeHeaders, headers...)
+	}
+}
+
+// WithMaxAge provide max age in seconds of preflight response.
+func WithMaxAge(age int) Option {
+	return func(opt *optionSet) {
+		opt.policy.MaxAge = age
+	}
+}
+
+// WithAllowPrivateNetwork allow Private Network Access preflight requests.
+func WithAllowPrivateNetwork(allow bool) Option {
+	return func(opt *optionSet) {
+		opt.policy.AllowPrivateNetwork = allow
+	}
+}
+
+// WithPolicyByPath provide policy for requests whose path starts with prefix,
+// longest prefix wins and entry policy would be used if none of prefixes matched.
+func WithPolicyByPath(prefix string, policy *Policy) Option {
+	return func(opt *optionSet) {
+		if len(prefix) < 1 || policy == nil {
+			return
+		}
+
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+
+		opt.pathPolicies[prefix] = policy
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
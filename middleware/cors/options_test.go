@@ -0,0 +1,129 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocors
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/cors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.True(t, set.compiled.isOriginAllowed(nil, "https://ut.example.com"))
+	assert.Equal(t, "GET,HEAD,PUT,PATCH,POST,DELETE", set.compiled.allowMethods)
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithAllowOrigins("https://*.example.com"),
+		WithAllowMethods(http.MethodGet),
+		WithAllowHeaders("X-Ut"),
+		WithAllowCredentials(true),
+		WithExposeHeaders("X-Ut-Expose"),
+		WithMaxAge(10),
+		WithAllowPrivateNetwork(true),
+		WithPolicyByPath("ut-path", &Policy{}),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.True(t, set.compiled.isOriginAllowed(nil, "https://ut.example.com"))
+	assert.False(t, set.compiled.isOriginAllowed(nil, "https://ut.evil.com"))
+	assert.Equal(t, http.MethodGet, set.compiled.allowMethods)
+	assert.Equal(t, "X-Ut", set.compiled.allowHeaders)
+	assert.True(t, set.compiled.allowCredentials)
+	assert.Equal(t, "X-Ut-Expose", set.compiled.exposeHeaders)
+	assert.Equal(t, "10", set.compiled.maxAge)
+	assert.True(t, set.compiled.allowPrivateNetwork)
+	assert.Len(t, set.compiledPaths, 1)
+	assert.Equal(t, "/ut-path", set.compiledPaths[0].path)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+
+	// with origin func only, no origin allowed by default
+	set = newOptionSet(WithAllowOriginFunc(func(ctx echo.Context, origin string) bool {
+		return origin == "https://ut.example.com"
+	}))
+	assert.True(t, set.compiled.isOriginAllowed(nil, "https://ut.example.com"))
+	assert.False(t, set.compiled.isOriginAllowed(nil, "https://ut.evil.com"))
+
+	// with options of rkmidcors, entry policy decided by rkmidcors
+	set = newOptionSet(
+		WithCorsOptions(rkmidcors.WithEntryNameAndType("ut-cors-name", "ut-cors-type")),
+		WithPolicyByPath("/ut-path", &Policy{}))
+	assert.Equal(t, "ut-cors-name", set.EntryName)
+	assert.Equal(t, "ut-cors-type", set.EntryType)
+	assert.NotNil(t, set.corsSet)
+	assert.Nil(t, set.compiled)
+	assert.Len(t, set.compiledPaths, 1)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithCorsOptions(rkmidcors.WithEntryNameAndType("ut-cors-name", "ut-cors-type")))
+	assert.Equal(t, "ut-name", set.corsSet.GetEntryName())
+}
+
+func TestOptionSet_PolicyOf(t *testing.T) {
+	set := newOptionSet(
+		WithAllowOrigins("https://www.example.com"),
+		WithPolicyByPath("/v1", &Policy{AllowOrigins: []string{"https://v1.example.com"}}),
+		WithPolicyByPath("/v1/public", &Policy{AllowOrigins: []string{"*"}}))
+
+	newCtx := func(path string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(http.MethodGet, path, nil), httptest.NewRecorder())
+	}
+
+	assert.True(t, set.policyOf(newCtx("/v1/public/ut")).isOriginAllowed(nil, "https://ut.evil.com"))
+	assert.True(t, set.policyOf(newCtx("/v1/ut")).isOriginAllowed(nil, "https://v1.example.com"))
+	assert.False(t, set.policyOf(newCtx("/v1/ut")).isOriginAllowed(nil, "https://www.example.com"))
+	assert.True(t, set.policyOf(newCtx("/v2")).isOriginAllowed(nil, "https://www.example.com"))
+}
+
+func TestOptionSet_ShouldIgnore(t *testing.T) {
+	set := newOptionSet(WithPathToIgnore("/ut-ignore"))
+
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ut-ignore", nil), httptest.NewRecorder())
+	assert.True(t, set.ShouldIgnore(ctx))
+
+	ctx = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ut-path", nil), httptest.NewRecorder())
+	assert.False(t, set.ShouldIgnore(ctx))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		BootConfig: rkmidcors.BootConfig{
+			Enabled:      true,
+			AllowOrigins: []string{"https://*.example.com"},
+		},
+		AllowPrivateNetwork: true,
+		Paths: []PolicyConfig{
+			{
+				Path:         "/v1",
+				AllowOrigins: []string{"https://v1.example.com"},
+				MaxAge:       10,
+			},
+		},
+	}
+
+	// with disabled
+	config.Enabled = false
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-name", "ut-type")...)
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.True(t, set.compiled.allowPrivateNetwork)
+	assert.True(t, set.compiled.isOriginAllowed(nil, "https://ut.example.com"))
+	assert.Len(t, set.compiledPaths, 1)
+	assert.Equal(t, "10", set.compiledPaths[0].policy.maxAge)
+}
@@ -7,42 +7,174 @@
 package rkechocsrf
 
 import (
+	"crypto/rand"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/csrf"
+	"math/big"
 	"net/http"
+	"time"
 )
 
+// SessionTokenKey is the key of csrf token in session
+const SessionTokenKey = "_csrf"
+
 // Middleware Add csrf interceptors.
 //
 // Mainly copied from bellow.
 // https://github.com/labstack/echo/blob/master/middleware/csrf.go
+//
+// If session middleware enabled, csrf token would be bound to session and take precedence over token in cookie.
 func Middleware(opts ...rkmidcsrf.Option) echo.MiddlewareFunc {
-	set := rkmidcsrf.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithCsrfOptions(opts...))
+}
+
+// MiddlewareWithOptions validate csrf token same as Middleware with bellow extensions.
+//
+// 1: Origin mode validates Sec-Fetch-Site, Origin or Referer header against origin of request and trusted origins,
+// token would be validated only if browser sent none of them, which fits JSON APIs.
+// 2: Token bound to session would be rotated after configured interval.
+// 3: Routes could be exempted by route pattern like /v1/hook/:id or glob like /v1/hook/*.
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			if set.Skipper(ctx) || set.isExempt(ctx) || set.csrfSet.ShouldIgnore(ctx.Request().URL.Path) {
+				return next(ctx)
+			}
 
-			if beforeCtx.Output.ErrResp != nil {
-				return ctx.JSON(beforeCtx.Output.ErrResp.Code(), beforeCtx.Output.ErrResp)
+			beforeCtx := set.csrfSet.BeforeCtx(ctx.Request())
+
+			// csrf token bound to session
+			sess := rkechoctx.GetSession(ctx)
+			bindSession(sess, beforeCtx)
+
+			if set.Mode == ModeOrigin && !isSafeMethod(ctx.Request().Method) {
+				trusted, sent := set.verifyOrigin(ctx)
+
+				// case 1: request initiated by untrusted origin
+				if sent && !trusted {
+					errResp := rkmid.GetErrorBuilder().New(http.StatusForbidden, "Untrusted origin")
+					return ctx.JSON(errResp.Code(), errResp)
+				}
+
+				// case 2: request initiated by trusted origin, token would only be issued without validation
+				if trusted {
+					beforeCtx.Input.Method = http.MethodGet
+				}
 			}
 
-			for _, v := range beforeCtx.Output.VaryHeaders {
-				ctx.Response().Header().Add(rkmid.HeaderVary, v)
+			set.csrfSet.Before(beforeCtx)
+
+			if beforeCtx.Output.ErrResp != nil {
+				return ctx.JSON(beforeCtx.Output.ErrResp.Code(), beforeCtx.Output.ErrResp)
 			}
 
-			if beforeCtx.Output.Cookie != nil {
-				http.SetCookie(ctx.Response(), beforeCtx.Output.Cookie)
+			// rotate token bound to session after validation passed
+			if beforeCtx.Output.Cookie != nil && set.shouldRotate(sess) {
+				beforeCtx.Input.Token = newToken(set.tokenLength)
+				beforeCtx.Output.Cookie.Value = beforeCtx.Input.Token
 			}
 
-			// store token in the context
-			ctx.Set(rkmid.CsrfTokenKey.String(), beforeCtx.Input.Token)
+			writeOutput(ctx, sess, beforeCtx)
 
 			return next(ctx)
 		}
 	}
 }
+
+// verifyOrigin returns whether request was initiated by trusted origin and whether browser sent any of related headers
+func (set *optionSet) verifyOrigin(ctx echo.Context) (trusted, sent bool) {
+	fetchSite := ctx.Request().Header.Get(HeaderSecFetchSite)
+
+	// same origin request or user initiated navigation
+	if fetchSite == "same-origin" || fetchSite == "none" {
+		return true, true
+	}
+
+	if origin := originOf(ctx); len(origin) > 0 {
+		return set.isTrustedOrigin(ctx, origin), true
+	}
+
+	return false, len(fetchSite) > 0
+}
+
+// shouldRotate returns true if token bound to session was issued before rotate interval
+func (set *optionSet) shouldRotate(sess rkechoctx.Session) bool {
+	if set.RotateInterval <= 0 || sess == nil {
+		return false
+	}
+
+	if sess.Get(SessionTokenKey) == nil {
+		return false
+	}
+
+	// token bound without issued time would be rotated
+	issuedAt, ok := sess.Get(SessionTokenIssuedAtKey).(string)
+	if !ok {
+		return true
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, issuedAt)
+	return err != nil || time.Since(t) >= set.RotateInterval
+}
+
+// bindSession overrides token in cookie with token bound to session
+func bindSession(sess rkechoctx.Session, beforeCtx *rkmidcsrf.BeforeCtx) {
+	if sess == nil {
+		return
+	}
+
+	if token, ok := sess.Get(SessionTokenKey).(string); ok && len(token) > 0 {
+		beforeCtx.Input.Token = token
+	}
+}
+
+// writeOutput writes vary headers and cookie, then stores token in session and context
+func writeOutput(ctx echo.Context, sess rkechoctx.Session, beforeCtx *rkmidcsrf.BeforeCtx) {
+	for _, v := range beforeCtx.Output.VaryHeaders {
+		ctx.Response().Header().Add(rkmid.HeaderVary, v)
+	}
+
+	if beforeCtx.Output.Cookie != nil {
+		http.SetCookie(ctx.Response(), beforeCtx.Output.Cookie)
+
+		if sess != nil && sess.Get(SessionTokenKey) != beforeCtx.Input.Token {
+			sess.Set(SessionTokenKey, beforeCtx.Input.Token)
+			sess.Set(SessionTokenIssuedAtKey, time.Now().Format(time.RFC3339Nano))
+		}
+	}
+
+	// store token in the context
+	ctx.Set(rkmid.CsrfTokenKey.String(), beforeCtx.Input.Token)
+}
+
+// isSafeMethod returns true if method would not be validated
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+// newToken returns random alphanumeric token
+func newToken(length int) string {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+	res := make([]byte, length)
+	for i := range res {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			panic(err)
+		}
+		res[i] = charset[n.Int64()]
+	}
+
+	return string(res)
+}
@@ -8,6 +8,8 @@ package rkechocsrf
 import (
 	"bytes"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-echo/middleware/session"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/csrf"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +17,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 var userHandler = func(ctx echo.Context) error {
@@ -47,6 +50,175 @@ func TestMiddleware(t *testing.T) {
 	assert.NotNil(t, w.Header().Get("Set-Cookie"))
 }
 
+func TestMiddleware_WithSession(t *testing.T) {
+	e := echo.New()
+	e.Use(rkechosession.Middleware(), Middleware())
+	e.Any("/ut-path", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, rkechoctx.GetCsrfToken(ctx))
+	})
+
+	// case 1: token issued and bound to session
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := w.Body.String()
+	assert.NotEmpty(t, token)
+
+	var sessionCookie *http.Cookie
+	for _, v := range w.Result().Cookies() {
+		if v.Name == "rk_session" {
+			sessionCookie = v
+		}
+	}
+	assert.NotNil(t, sessionCookie)
+
+	// case 2: token validated against session without csrf cookie
+	req = httptest.NewRequest(http.MethodPost, "/ut-path", nil)
+	req.AddCookie(sessionCookie)
+	req.Header.Set(echo.HeaderXCSRFToken, token)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, token, w.Body.String())
+
+	// case 3: token of csrf cookie is ignored if token bound to session
+	req = httptest.NewRequest(http.MethodPost, "/ut-path", nil)
+	req.AddCookie(sessionCookie)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "ut-token"})
+	req.Header.Set(echo.HeaderXCSRFToken, "ut-token")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestMiddlewareWithOptions(t *testing.T) {
+	e := echo.New()
+	e.Use(MiddlewareWithOptions(
+		WithMode(ModeOrigin),
+		WithTrustedOrigins("https://*.example.com"),
+		WithExempt("/v1/hook/:id", "/v2/hook/*")))
+	e.Any("/ut-path", userHandler)
+	e.Any("/v1/hook/:id", userHandler)
+	e.Any("/v2/hook/:id", userHandler)
+
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// case 1: same origin with Sec-Fetch-Site
+	w := serve("/ut-path", map[string]string{HeaderSecFetchSite: "same-origin"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Result().Cookies())
+
+	// case 2: same origin with Origin
+	w = serve("/ut-path", map[string]string{rkmid.HeaderOrigin: "http://example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 3: trusted origin
+	w = serve("/ut-path", map[string]string{
+		HeaderSecFetchSite: "cross-site",
+		rkmid.HeaderOrigin: "https://web.example.com",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 4: trusted origin from Referer
+	w = serve("/ut-path", map[string]string{HeaderReferer: "https://web.example.com/ut-page"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 5: untrusted origin
+	w = serve("/ut-path", map[string]string{rkmid.HeaderOrigin: "https://evil.com"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Untrusted origin")
+
+	// case 6: cross site without origin
+	w = serve("/ut-path", map[string]string{HeaderSecFetchSite: "cross-site"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// case 7: fallback to token without any of headers
+	w = serve("/ut-path", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/ut-path", nil)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "ut-token"})
+	req.Header.Set(echo.HeaderXCSRFToken, "ut-token")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 8: exempt by route and glob
+	w = serve("/v1/hook/1", map[string]string{rkmid.HeaderOrigin: "https://evil.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve("/v2/hook/1", map[string]string{rkmid.HeaderOrigin: "https://evil.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// case 9: token mode ignores origin
+	e = echo.New()
+	e.Use(MiddlewareWithOptions())
+	e.Any("/ut-path", userHandler)
+	w = serve("/ut-path", map[string]string{HeaderSecFetchSite: "same-origin"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMiddlewareWithOptions_Rotate(t *testing.T) {
+	e := echo.New()
+	e.Use(rkechosession.Middleware(), MiddlewareWithOptions(WithRotateInterval(time.Hour)))
+	e.Any("/ut-path", func(ctx echo.Context) error {
+		sess := rkechoctx.GetSession(ctx)
+		if ctx.QueryParam("expire") == "true" {
+			sess.Set(SessionTokenIssuedAtKey, time.Now().Add(-2*time.Hour).Format(time.RFC3339Nano))
+		}
+		return ctx.String(http.StatusOK, rkechoctx.GetCsrfToken(ctx))
+	})
+
+	serve := func(method, path, token string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		req.Header.Set(echo.HeaderXCSRFToken, token)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// case 1: token issued
+	w := serve(http.MethodGet, "/ut-path", "", nil)
+	token := w.Body.String()
+	var sessionCookie *http.Cookie
+	for _, v := range w.Result().Cookies() {
+		if v.Name == "rk_session" {
+			sessionCookie = v
+		}
+	}
+	assert.NotNil(t, sessionCookie)
+
+	// case 2: token not rotated within interval, mark it as expired in handler
+	w = serve(http.MethodPost, "/ut-path?expire=true", token, sessionCookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, token, w.Body.String())
+
+	// case 3: token rotated after interval
+	w = serve(http.MethodPost, "/ut-path", token, sessionCookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	rotated := w.Body.String()
+	assert.NotEqual(t, token, rotated)
+	assert.Len(t, rotated, 32)
+
+	// case 4: previous token is rejected
+	w = serve(http.MethodPost, "/ut-path", token, sessionCookie)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(http.MethodPost, "/ut-path", rotated, sessionCookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
 	var buf bytes.Buffer
 	req := httptest.NewRequest(http.MethodGet, "/ut-path", &buf)
@@ -0,0 +1,272 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechocsrf
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/csrf"
+	"github.com/rs/xid"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// ModeToken validates double submitted token only
+	ModeToken = "token"
+	// ModeOrigin validates Sec-Fetch-Site, Origin or Referer header against trusted origins,
+	// token would be validated if none of them was sent
+	ModeOrigin = "origin"
+
+	// HeaderSecFetchSite is sent by browser which describes relationship between request initiator and target
+	HeaderSecFetchSite = "Sec-Fetch-Site"
+	// HeaderReferer is sent by browser which contains address of page making request
+	HeaderReferer = "Referer"
+
+	// SessionTokenIssuedAtKey is the key of time when csrf token in session was issued
+	SessionTokenIssuedAtKey = "_csrfIssuedAt"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultTokenLength = 32
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:   "",
+		EntryType:   "",
+		Skipper:     defaultSkipper,
+		Mode:        ModeToken,
+		tokenLength: defaultTokenLength,
+		csrfOpts:    make([]rkmidcsrf.Option, 0),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	for i := range set.trustedOrigins {
+		matcher, err := rkechointernal.NewOriginMatcher(set.trustedOrigins[i])
+		if err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+		set.originMatchers = append(set.originMatchers, matcher)
+	}
+
+	csrfOpts := append([]rkmidcsrf.Option{rkmidcsrf.WithTokenLength(set.tokenLength)}, set.csrfOpts...)
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidcsrf
+	if len(set.EntryName) > 0 {
+		set.csrfSet = rkmidcsrf.NewOptionSet(append(csrfOpts,
+			rkmidcsrf.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	} else {
+		set.csrfSet = rkmidcsrf.NewOptionSet(append([]rkmidcsrf.Option{
+			rkmidcsrf.WithEntryNameAndType(xid.New().String(), "")}, csrfOpts...)...)
+		set.EntryName = set.csrfSet.GetEntryName()
+		set.EntryType = set.csrfSet.GetEntryType()
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName      string
+	EntryType      string
+	Skipper        Skipper
+	Mode           string
+	RotateInterval time.Duration
+	trustedOrigins []string
+	originMatchers []rkechointernal.OriginMatcher
+	exempt         []string
+	tokenLength    int
+	csrfOpts       []rkmidcsrf.Option
+	csrfSet        rkmidcsrf.OptionSetInterface
+}
+
+// isExempt returns true if route pattern or request path matches any of exempt patterns.
+//
+// Pattern could be route registered in echo like /v1/hook/:id or glob like /v1/hook/*
+func (set *optionSet) isExempt(ctx echo.Context) bool {
+	if ctx == nil || ctx.Request().URL == nil {
+		return false
+	}
+
+	for _, pattern := range set.exempt {
+		if pattern == ctx.Path() {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, ctx.Request().URL.Path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTrustedOrigin returns true if origin is same as request or matches any of trusted origins
+func (set *optionSet) isTrustedOrigin(ctx echo.Context, origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+
+	if origin == strings.ToLower(ctx.Scheme()+"://"+ctx.Request().Host) {
+		return true
+	}
+
+	for i := range set.originMatchers {
+		if set.originMatchers[i].Match(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// originOf returns origin of request initiator from Origin or Referer header, empty if none of them sent
+func originOf(ctx echo.Context) string {
+	if origin := ctx.Request().Header.Get(rkmid.HeaderOrigin); len(origin) > 0 && origin != "null" {
+		return origin
+	}
+
+	if referer := ctx.Request().Header.Get(HeaderReferer); len(referer) > 0 {
+		if u, err := url.Parse(referer); err == nil && len(u.Scheme) > 0 && len(u.Host) > 0 {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+
+	return ""
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidcsrf.BootConfig with origin mode, token rotation and exempt routes
+type BootConfig struct {
+	rkmidcsrf.BootConfig `yaml:",inline" mapstructure:",squash"`
+	Mode                 string   `yaml:"mode" json:"mode"`
+	TrustedOrigins       []string `yaml:"trustedOrigins" json:"trustedOrigins"`
+	RotateIntervalMs     int      `yaml:"rotateIntervalMs" json:"rotateIntervalMs"`
+	Exempt               []string `yaml:"exempt" json:"exempt"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithCsrfOptions(rkmidcsrf.ToOptions(&config.BootConfig, entryName, entryType)...),
+			WithTokenLength(config.TokenLength),
+			WithMode(config.Mode),
+			WithTrustedOrigins(config.TrustedOrigins...),
+			WithRotateInterval(time.Duration(config.RotateIntervalMs)*time.Millisecond),
+			WithExempt(config.Exempt...))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithCsrfOptions provide options of double submitted token like token lookup and cookie attributes.
+func WithCsrfOptions(opts ...rkmidcsrf.Option) Option {
+	return func(opt *optionSet) {
+		opt.csrfOpts = append(opt.csrfOpts, opts...)
+	}
+}
+
+// WithTokenLength provide length of csrf token, default: 32.
+func WithTokenLength(length int) Option {
+	return func(opt *optionSet) {
+		if length > 0 {
+			opt.tokenLength = length
+		}
+	}
+}
+
+// WithMode provide validation mode, one of token and origin, default: token.
+func WithMode(mode string) Option {
+	return func(opt *optionSet) {
+		switch strings.ToLower(mode) {
+		case ModeOrigin:
+			opt.Mode = ModeOrigin
+		case ModeToken:
+			opt.Mode = ModeToken
+		}
+	}
+}
+
+// WithTrustedOrigins provide origins trusted in origin mode besides origin of request itself,
+// wildcard like https://*.example.com and regular expression with regex: prefix are supported.
+func WithTrustedOrigins(origins ...string) Option {
+	return func(opt *optionSet) {
+		for i := range origins {
+			if len(origins[i]) > 0 {
+				opt.trustedOrigins = append(opt.trustedOrigins, strings.TrimSuffix(origins[i], "/"))
+			}
+		}
+	}
+}
+
+// WithRotateInterval rotate csrf token bound to session after interval, session middleware should be enabled.
+func WithRotateInterval(interval time.Duration) Option {
+	return func(opt *optionSet) {
+		if interval > 0 {
+			opt.RotateInterval = interval
+		}
+	}
+}
+
+// WithExempt provide route patterns exempt from csrf validation,
+// pattern could be route registered in echo like /v1/hook/:id or glob like /v1/hook/*
+func WithExempt(patterns ...string) Option {
+	return func(opt *optionSet) {
+		for i := range patterns {
+			if len(patterns[i]) > 0 {
+				opt.exempt = append(opt.exempt, patterns[i])
+			}
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return WithCsrfOptions(rkmidcsrf.WithPathToIgnore(prefix...))
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
@@ -0,0 +1,168 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechoidem is a middleware for echo framework which replays responses of requests with Idempotency-Key
+package rkechoidem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Middleware honors Idempotency-Key header of requests.
+//
+// 1: The first response (status, headers, body) of a key would be stored and replayed on retries.
+// 2: A retry while the first request is still in-flight would be rejected with 409.
+// 3: A retry with different method, path or body would be rejected with 422.
+//
+// Responses with status code >= 500, handler errors or panics would not be stored, so that client could retry with the same key.
+// Only headers assigned by handler are stored, headers like X-Request-Id assigned before it belong to each request.
+//
+// Request body larger than max body bytes would be rejected with 413.
+//
+// Keys are scoped by authenticated principal, or client ip if request was not authenticated,
+// so that response of a caller would never be replayed to another one.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			key := ctx.Request().Header.Get(set.Header)
+			if len(key) < 1 {
+				return next(ctx)
+			}
+
+			ttl, ok := set.ttlFor(ctx.Request())
+			if !ok {
+				return next(ctx)
+			}
+
+			// read body and assign it back to request
+			body, err := ioutil.ReadAll(io.LimitReader(ctx.Request().Body, int64(set.MaxBodyBytes)+1))
+			if err != nil {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusInternalServerError, "Failed to read request body", err)
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+			if len(body) > set.MaxBodyBytes {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusRequestEntityTooLarge, "Request body too large")
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+			ctx.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			fingerprint := fingerprintOf(ctx.Request(), body)
+			key = scopeOf(ctx) + "\n" + key
+
+			record, reserved, err := set.Store.Reserve(key, fingerprint, ttl)
+			if err != nil {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusInternalServerError, "Failed to access idempotency store", err)
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			// case 1: key exists
+			if !reserved {
+				switch {
+				case record.Fingerprint != fingerprint:
+					errResp := rkmid.GetErrorBuilder().New(http.StatusUnprocessableEntity,
+						"Idempotency-Key was used with a different request")
+					return ctx.JSON(errResp.Code(), errResp)
+				case !record.Completed:
+					errResp := rkmid.GetErrorBuilder().New(http.StatusConflict,
+						"Request with the same Idempotency-Key is being processed")
+					return ctx.JSON(errResp.Code(), errResp)
+				default:
+					return replay(ctx, record)
+				}
+			}
+
+			// case 2: key reserved, record response
+			headerBefore := ctx.Response().Header().Clone()
+			originalWriter := ctx.Response().Writer
+			recorder := newRecordWriter(originalWriter)
+			ctx.Response().Writer = recorder
+
+			// release key if handler failed or panicked, panic would be propagated to panic middleware
+			saved := false
+			defer func() {
+				ctx.Response().Writer = originalWriter
+				if !saved {
+					if err := set.Store.Delete(key); err != nil {
+						rkechoctx.GetLogger(ctx).Error("Failed to release idempotency key", zap.Error(err))
+					}
+				}
+			}()
+
+			err = next(ctx)
+
+			if err != nil || !ctx.Response().Committed || ctx.Response().Status >= http.StatusInternalServerError {
+				return err
+			}
+
+			// response was sent already, key would be released if failed to save, so that client could retry
+			if err := set.Store.Save(key, &Record{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				Status:      ctx.Response().Status,
+				Header:      rkechointernal.DiffHeader(headerBefore, ctx.Response().Header()),
+				Body:        recorder.body.Bytes(),
+			}, ttl); err != nil {
+				rkechoctx.GetLogger(ctx).Error("Failed to save idempotency record", zap.Error(err))
+				return nil
+			}
+			saved = true
+
+			return nil
+		}
+	}
+}
+
+// replay writes stored record to response
+func replay(ctx echo.Context, record *Record) error {
+	header := ctx.Response().Header()
+	for k, v := range record.Header {
+		header[k] = v
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+
+	ctx.Response().WriteHeader(record.Status)
+	_, err := ctx.Response().Write(record.Body)
+
+	return err
+}
+
+// scopeOf returns authenticated principal of request, client ip if not authenticated
+func scopeOf(ctx echo.Context) string {
+	if id, principalType := rkechoctx.GetPrincipal(ctx); len(id) > 0 {
+		return "principal:" + principalType + ":" + id
+	}
+
+	return "ip:" + rkechoctx.GetClientIP(ctx)
+}
+
+// fingerprintOf returns sha256 digest of method, path and body of request
+func fingerprintOf(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(req.URL.RequestURI()))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoidem
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func newCtx(method, path, key, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if len(key) > 0 {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	resp := httptest.NewRecorder()
+	return echo.New().NewContext(req, resp), resp
+}
+
+func TestMiddleware(t *testing.T) {
+	var counter int32
+	handler := func(ctx echo.Context) error {
+		n := atomic.AddInt32(&counter, 1)
+		ctx.Response().Header().Set("X-Counter", fmt.Sprintf("%d", n))
+		return ctx.String(http.StatusCreated, fmt.Sprintf("order-%d", n))
+	}
+
+	inter := Middleware()
+
+	// case 1: without key
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "", "ut-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "order-1", w.Body.String())
+
+	// case 2: first request with key
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "order-2", w.Body.String())
+	assert.Empty(t, w.Header().Get(HeaderIdempotentReplayed))
+
+	// case 3: replay
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "order-2", w.Body.String())
+	assert.Equal(t, "2", w.Header().Get("X-Counter"))
+	assert.Equal(t, "true", w.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+
+	// case 4: same key with different body
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-other-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// case 5: headers assigned before handler belong to each request
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-other-key", "ut-body")
+	ctx.Response().Header().Set(rkmid.HeaderRequestId, "ut-request-1")
+	assert.Nil(t, inter(handler)(ctx))
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-other-key", "ut-body")
+	ctx.Response().Header().Set(rkmid.HeaderRequestId, "ut-request-2")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "true", w.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, []string{"ut-request-2"}, w.Header().Values(rkmid.HeaderRequestId))
+	assert.Equal(t, "3", w.Header().Get("X-Counter"))
+
+	// case 6: GET is not honored by default
+	ctx, w = newCtx(http.MethodGet, "/ut-path", "ut-key", "")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "order-4", w.Body.String())
+}
+
+func TestMiddleware_InFlight(t *testing.T) {
+	store := NewMemoryStore()
+	inter := Middleware(WithStore(store))
+
+	// reserve key as if first request is still running
+	_, ok, _ := store.Reserve("ip:192.0.2.1\nut-key", fingerprintOf(httptest.NewRequest(http.MethodPost, "/ut-path", nil), []byte("ut-body")), defaultTtl)
+	assert.True(t, ok)
+
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestMiddleware_WithError(t *testing.T) {
+	var counter int32
+	inter := Middleware()
+
+	// case 1: handler returns error, key would be released
+	ctx, _ := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.NotNil(t, inter(func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return fmt.Errorf("ut-error")
+	})(ctx))
+
+	// case 2: 5xx response, key would be released
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return ctx.NoContent(http.StatusServiceUnavailable)
+	})(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// case 3: retry would reach handler
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&counter))
+}
+
+func TestMiddleware_WithPanic(t *testing.T) {
+	var counter int32
+	inter := Middleware()
+
+	// case 1: handler panics, key would be released
+	ctx, _ := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Panics(t, func() {
+		inter(func(ctx echo.Context) error {
+			atomic.AddInt32(&counter, 1)
+			panic("ut-panic")
+		})(ctx)
+	})
+
+	// case 2: retry would reach handler
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+}
+
+func TestMiddleware_WithScope(t *testing.T) {
+	var counter int32
+	handler := func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, fmt.Sprintf("%d", atomic.AddInt32(&counter, 1)))
+	}
+
+	inter := Middleware()
+
+	// same key from different clients
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	ctx.Request().RemoteAddr = "1.1.1.1:1234"
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "1", w.Body.String())
+
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	ctx.Request().RemoteAddr = "2.2.2.2:1234"
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "2", w.Body.String())
+
+	// same key from different principals behind the same client ip
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Type: "Basic", Id: "ut-user"})
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "3", w.Body.String())
+
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Type: "Basic", Id: "ut-user"})
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "3", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(HeaderIdempotentReplayed))
+}
+
+func TestMiddleware_WithInvalidBody(t *testing.T) {
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "")
+	ctx.Request().Body = io.NopCloser(iotest.ErrReader(fmt.Errorf("ut-error")))
+
+	assert.Nil(t, Middleware()(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// with body too large
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, Middleware(WithMaxBodyBytes(4))(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestMiddleware_WithStoreError(t *testing.T) {
+	store := &errStore{Store: NewMemoryStore()}
+	inter := Middleware(WithStore(store))
+	handler := func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusCreated)
+	}
+
+	// key is released if failed to save
+	ctx, w := newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, store.deleted)
+
+	// retry is not rejected as in-flight
+	ctx, w = newCtx(http.MethodPost, "/ut-path", "ut-key", "ut-body")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+// errStore fails to save records
+type errStore struct {
+	Store
+	deleted int
+}
+
+func (s *errStore) Save(string, *Record, time.Duration) error {
+	return fmt.Errorf("ut-error")
+}
+
+func (s *errStore) Delete(key string) error {
+	s.deleted++
+	return s.Store.Delete(key)
+}
+
+func TestMiddleware_WithPaths(t *testing.T) {
+	var counter int32
+	handler := func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, fmt.Sprintf("%d", atomic.AddInt32(&counter, 1)))
+	}
+
+	inter := Middleware(WithTtlByPath("/v1/orders", 0))
+
+	// path not configured
+	for i := 0; i < 2; i++ {
+		ctx, _ := newCtx(http.MethodPost, "/v1/users", "ut-key", "")
+		assert.Nil(t, inter(handler)(ctx))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter))
+
+	// path configured
+	for i := 0; i < 2; i++ {
+		ctx, w := newCtx(http.MethodPost, "/v1/orders", "ut-key-2", "")
+		assert.Nil(t, inter(handler)(ctx))
+		assert.Equal(t, "3", w.Body.String())
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoidem
+
+import (
+	"github.com/labstack/echo/v4"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderIdempotencyKey is the default request header carrying idempotency key
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed would be set to response if response was replayed from store
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultTtl = 24 * time.Hour
+	// defaultMaxBodyBytes is max size of request body which would be read for fingerprint
+	defaultMaxBodyBytes = 1024 * 1024
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:    xid.New().String(),
+		EntryType:    "",
+		Skipper:      defaultSkipper,
+		Header:       HeaderIdempotencyKey,
+		Ttl:          defaultTtl,
+		MaxBodyBytes: defaultMaxBodyBytes,
+		methods: map[string]bool{
+			http.MethodPost: true,
+		},
+		paths: make(map[string]time.Duration),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if set.Store == nil {
+		set.Store = NewMemoryStore()
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	Header       string
+	Ttl          time.Duration
+	MaxBodyBytes int
+	Store        Store
+	methods      map[string]bool
+	paths        map[string]time.Duration
+	ignorePrefix []string
+}
+
+// ShouldIgnore determine whether idempotency should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ttlFor returns TTL of request and whether request should be handled.
+//
+// If no path was configured, all paths would be handled with default TTL,
+// otherwise, TTL of the longest matching path prefix would be returned.
+func (set *optionSet) ttlFor(req *http.Request) (time.Duration, bool) {
+	if !set.methods[req.Method] {
+		return 0, false
+	}
+
+	if len(set.paths) < 1 {
+		return set.Ttl, true
+	}
+
+	matched, ttl := "", time.Duration(0)
+	for prefix, v := range set.paths {
+		if strings.HasPrefix(req.URL.Path, prefix) && len(prefix) > len(matched) {
+			matched, ttl = prefix, v
+		}
+	}
+
+	if len(matched) < 1 {
+		return 0, false
+	}
+
+	if ttl <= 0 {
+		ttl = set.Ttl
+	}
+
+	return ttl, true
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Ignore       []string `yaml:"ignore" json:"ignore"`
+	Header       string   `yaml:"header" json:"header"`
+	Methods      []string `yaml:"methods" json:"methods"`
+	TtlMs        int      `yaml:"ttlMs" json:"ttlMs"`
+	MaxBodyBytes int      `yaml:"maxBodyBytes" json:"maxBodyBytes"`
+	Paths        []struct {
+		Path  string `yaml:"path" json:"path"`
+		TtlMs int    `yaml:"ttlMs" json:"ttlMs"`
+	} `yaml:"paths" json:"paths"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithHeader(config.Header),
+			WithTtl(time.Duration(config.TtlMs)*time.Millisecond),
+			WithMaxBodyBytes(config.MaxBodyBytes),
+			WithPathToIgnore(config.Ignore...))
+
+		if len(config.Methods) > 0 {
+			opts = append(opts, WithMethods(config.Methods...))
+		}
+
+		for i := range config.Paths {
+			e := config.Paths[i]
+			opts = append(opts, WithTtlByPath(e.Path, time.Duration(e.TtlMs)*time.Millisecond))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithHeader provide request header name of idempotency key, default: Idempotency-Key.
+func WithHeader(header string) Option {
+	return func(opt *optionSet) {
+		if len(header) > 0 {
+			opt.Header = header
+		}
+	}
+}
+
+// WithMethods provide http methods which idempotency key would be honored, default: POST.
+func WithMethods(methods ...string) Option {
+	return func(opt *optionSet) {
+		opt.methods = make(map[string]bool)
+		for i := range methods {
+			opt.methods[strings.ToUpper(methods[i])] = true
+		}
+	}
+}
+
+// WithTtl provide default TTL of stored response, default: 24 hours.
+func WithTtl(ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if ttl > 0 {
+			opt.Ttl = ttl
+		}
+	}
+}
+
+// WithMaxBodyBytes provide max size of request body, larger request would be rejected with 413, default: 1MB.
+func WithMaxBodyBytes(maxBytes int) Option {
+	return func(opt *optionSet) {
+		if maxBytes > 0 {
+			opt.MaxBodyBytes = maxBytes
+		}
+	}
+}
+
+// WithTtlByPath enable idempotency on path prefix with TTL.
+// Default TTL would be used if ttl is zero.
+//
+// Once any of path was provided, only requests matching provided paths would be handled.
+func WithTtlByPath(path string, ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if len(path) < 1 {
+			return
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		opt.paths[path] = ttl
+	}
+}
+
+// WithStore provide Store, default: in-memory store.
+func WithStore(store Store) Option {
+	return func(opt *optionSet) {
+		opt.Store = store
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
@@ -0,0 +1,117 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoidem
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Equal(t, HeaderIdempotencyKey, set.Header)
+	assert.Equal(t, defaultTtl, set.Ttl)
+	assert.Equal(t, defaultMaxBodyBytes, set.MaxBodyBytes)
+	assert.NotNil(t, set.Store)
+	assert.True(t, set.methods[http.MethodPost])
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithHeader("X-Ut-Key"),
+		WithMethods("post", "put"),
+		WithTtl(time.Minute),
+		WithMaxBodyBytes(16),
+		WithTtlByPath("ut-path", time.Second),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "X-Ut-Key", set.Header)
+	assert.True(t, set.methods[http.MethodPut])
+	assert.Equal(t, time.Minute, set.Ttl)
+	assert.Equal(t, 16, set.MaxBodyBytes)
+	assert.Equal(t, time.Second, set.paths["/ut-path"])
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+}
+
+func TestOptionSet_TtlFor(t *testing.T) {
+	set := newOptionSet(
+		WithTtl(time.Minute),
+		WithTtlByPath("/v1", 0),
+		WithTtlByPath("/v1/orders", time.Second))
+
+	ttl, ok := set.ttlFor(httptest.NewRequest(http.MethodPost, "/v1/orders/1", nil))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, ttl)
+
+	ttl, ok = set.ttlFor(httptest.NewRequest(http.MethodPost, "/v1/users", nil))
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, ttl)
+
+	_, ok = set.ttlFor(httptest.NewRequest(http.MethodPost, "/v2", nil))
+	assert.False(t, ok)
+
+	_, ok = set.ttlFor(httptest.NewRequest(http.MethodDelete, "/v1", nil))
+	assert.False(t, ok)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.Methods = []string{"PUT"}
+	config.TtlMs = 1000
+	assert.NotEmpty(t, ToOptions(config, "", ""))
+
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, time.Second, set.Ttl)
+	assert.True(t, set.methods[http.MethodPut])
+	assert.False(t, set.methods[http.MethodPost])
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	// reserve
+	record, ok, err := store.Reserve("ut-key", "ut-fp", time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, record)
+	assert.True(t, ok)
+
+	// reserve again
+	record, ok, err = store.Reserve("ut-key", "ut-fp", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.False(t, record.Completed)
+
+	// save
+	assert.Nil(t, store.Save("ut-key", &Record{Fingerprint: "ut-fp", Completed: true}, time.Minute))
+	record, ok, _ = store.Reserve("ut-key", "ut-fp", time.Minute)
+	assert.False(t, ok)
+	assert.True(t, record.Completed)
+
+	// expired
+	assert.Nil(t, store.Save("ut-key", &Record{Fingerprint: "ut-fp", Completed: true}, -time.Second))
+	_, ok, _ = store.Reserve("ut-key", "ut-fp", time.Minute)
+	assert.True(t, ok)
+
+	// delete
+	assert.Nil(t, store.Delete("ut-key"))
+	_, ok, _ = store.Reserve("ut-key", "ut-fp", time.Minute)
+	assert.True(t, ok)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoidem
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record stores first response of an idempotency key.
+//
+// Record with Completed as false means the first request is still in-flight.
+type Record struct {
+	Fingerprint string      `json:"fingerprint" yaml:"fingerprint"`
+	Completed   bool        `json:"completed" yaml:"completed"`
+	Status      int         `json:"status" yaml:"status"`
+	Header      http.Header `json:"header" yaml:"header"`
+	Body        []byte      `json:"body" yaml:"body"`
+}
+
+// Store is a pluggable storage of idempotency records.
+//
+// Implementation should be thread safe and should expire records after TTL.
+type Store interface {
+	// Reserve marks key as in-flight with fingerprint if key does not exist or has expired.
+	// If key exists, the existing record would be returned with false.
+	Reserve(key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+
+	// Save stores completed record of key with TTL.
+	Save(key string, record *Record, ttl time.Duration) error
+
+	// Delete removes key from store, so that client could retry with the same key.
+	Delete(key string) error
+}
+
+// NewMemoryStore create an in-memory Store.
+//
+// Expired records would be purged lazily while reserving new keys.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items: make(map[string]*memoryItem),
+	}
+}
+
+type memoryItem struct {
+	record   *Record
+	expireAt time.Time
+}
+
+// memoryStore implements Store with a map guarded by mutex
+type memoryStore struct {
+	lock      sync.Mutex
+	items     map[string]*memoryItem
+	lastPurge time.Time
+}
+
+// Reserve marks key as in-flight if key does not exist or has expired
+func (s *memoryStore) Reserve(key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	if item, ok := s.items[key]; ok && now.Before(item.expireAt) {
+		return item.record, false, nil
+	}
+
+	s.items[key] = &memoryItem{
+		record: &Record{
+			Fingerprint: fingerprint,
+		},
+		expireAt: now.Add(ttl),
+	}
+
+	return nil, true, nil
+}
+
+// Save stores completed record of key
+func (s *memoryStore) Save(key string, record *Record, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.items[key] = &memoryItem{
+		record:   record,
+		expireAt: time.Now().Add(ttl),
+	}
+
+	return nil
+}
+
+// Delete removes key from store
+func (s *memoryStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.items, key)
+
+	return nil
+}
+
+// purge removes expired items at most once per minute, caller should hold the lock
+func (s *memoryStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+
+	for k, v := range s.items {
+		if !now.Before(v.expireAt) {
+			delete(s.items, k)
+		}
+	}
+
+	s.lastPurge = now
+}
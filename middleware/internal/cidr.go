@@ -0,0 +1,37 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseCidr parses CIDR or single IP address, single IP address is parsed as /32 or /128
+func ParseCidr(raw string) (*net.IPNet, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "/") {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address %s", raw)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, cidr, err := net.ParseCIDR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %s", raw)
+	}
+
+	return cidr, nil
+}
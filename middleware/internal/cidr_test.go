@@ -0,0 +1,31 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCidr(t *testing.T) {
+	cidr, err := ParseCidr(" 1.2.3.4 ")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.2.3.4/32", cidr.String())
+
+	cidr, err = ParseCidr("::1")
+	assert.Nil(t, err)
+	assert.Equal(t, "::1/128", cidr.String())
+
+	cidr, err = ParseCidr("10.1.0.0/8")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.0/8", cidr.String())
+
+	_, err = ParseCidr("10.0.0.0/33")
+	assert.NotNil(t, err)
+
+	_, err = ParseCidr("ut-invalid")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechointernal provides helpers shared by middlewares which are not part of public API.
+package rkechointernal
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the interval file is checked for modification by default
+var DefaultReloadInterval = 10 * time.Second
+
+// NewFileWatcher create FileWatcher which loads file with load function
+func NewFileWatcher(path string, reloadInterval time.Duration, load func([]byte) error) *FileWatcher {
+	if reloadInterval <= 0 {
+		reloadInterval = DefaultReloadInterval
+	}
+
+	return &FileWatcher{
+		path:           path,
+		reloadInterval: reloadInterval,
+		load:           load,
+	}
+}
+
+// FileWatcher reloads file if modification time changed, which is checked at most once per reload interval
+type FileWatcher struct {
+	path           string
+	reloadInterval time.Duration
+	load           func([]byte) error
+	lock           sync.Mutex
+	modTime        time.Time
+	lastCheck      time.Time
+}
+
+// Reload loads file with load function
+func (w *FileWatcher) Reload() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	if err := w.load(bytes); err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	w.modTime = info.ModTime()
+	w.lastCheck = time.Now()
+	w.lock.Unlock()
+
+	return nil
+}
+
+// ReloadIfChanged reloads file if modification time changed since last load, previous content would be kept if failed
+func (w *FileWatcher) ReloadIfChanged() {
+	w.lock.Lock()
+	now := time.Now()
+	if now.Sub(w.lastCheck) < w.reloadInterval {
+		w.lock.Unlock()
+		return
+	}
+	w.lastCheck = now
+	modTime := w.modTime
+	w.lock.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err := w.Reload(); err != nil {
+		rkentry.LoggerEntryStdout.Warn("Failed to reload file, previous content is kept",
+			zap.String("path", w.path), zap.Error(err))
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestNewFileWatcher(t *testing.T) {
+	w := NewFileWatcher("ut-path", 0, nil)
+	assert.Equal(t, DefaultReloadInterval, w.reloadInterval)
+}
+
+func TestFileWatcher(t *testing.T) {
+	file := path.Join(t.TempDir(), "ut-file")
+	content := ""
+	w := NewFileWatcher(file, time.Millisecond, func(raw []byte) error {
+		if string(raw) == "ut-invalid" {
+			return errors.New("ut-error")
+		}
+		content = string(raw)
+		return nil
+	})
+
+	// with missing file
+	assert.NotNil(t, w.Reload())
+
+	// happy case
+	assert.Nil(t, os.WriteFile(file, []byte("ut-v1"), 0644))
+	assert.Nil(t, w.Reload())
+	assert.Equal(t, "ut-v1", content)
+
+	// reload on change
+	assert.Nil(t, os.WriteFile(file, []byte("ut-v2"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(2 * time.Millisecond)
+	w.ReloadIfChanged()
+	assert.Equal(t, "ut-v2", content)
+
+	// keep previous content if reload failed
+	assert.Nil(t, os.WriteFile(file, []byte("ut-invalid"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(2 * time.Millisecond)
+	w.ReloadIfChanged()
+	assert.Equal(t, "ut-v2", content)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DiffHeader returns headers which were added or changed after snapshot, headers in ignore are excluded.
+//
+// Headers assigned before snapshot, like X-Request-Id set by meta middleware, are not part of result,
+// so that they would not be replayed to other requests.
+func DiffHeader(before, after http.Header, ignore ...string) http.Header {
+	res := make(http.Header)
+
+	for k, v := range after {
+		if containsHeader(ignore, k) {
+			continue
+		}
+
+		if old, ok := before[k]; ok && strings.Join(old, ",") == strings.Join(v, ",") {
+			continue
+		}
+
+		res[k] = append([]string(nil), v...)
+	}
+
+	return res
+}
+
+// containsHeader returns true if key is in headers, case-insensitive
+func containsHeader(headers []string, key string) bool {
+	for i := range headers {
+		if strings.EqualFold(headers[i], key) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestDiffHeader(t *testing.T) {
+	before := http.Header{
+		"X-Request-Id": []string{"ut-id"},
+		"X-Changed":    []string{"ut-old"},
+	}
+	after := http.Header{
+		"X-Request-Id": []string{"ut-id"},
+		"X-Changed":    []string{"ut-new"},
+		"X-New":        []string{"ut"},
+		"Set-Cookie":   []string{"ut=cookie"},
+	}
+
+	assert.Equal(t, http.Header{
+		"X-Changed": []string{"ut-new"},
+		"X-New":     []string{"ut"},
+	}, DiffHeader(before, after, "set-cookie"))
+
+	// result is not shared with after
+	res := DiffHeader(http.Header{}, after)
+	res["X-New"][0] = "ut-modified"
+	assert.Equal(t, "ut", after.Get("X-New"))
+}
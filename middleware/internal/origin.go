@@ -0,0 +1,85 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointernal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// OriginAny allows any of origins
+	OriginAny = "*"
+	// OriginRegexPrefix marks origin as regular expression, for example regex:^https://[a-z]+\.example\.com$
+	OriginRegexPrefix = "regex:"
+)
+
+// wildcard in origin matches one or more characters of host or port, never path, user info or query
+const wildcardPattern = `[A-Za-z0-9._~-]+`
+
+// OriginMatcher matches Origin header
+type OriginMatcher interface {
+	Match(origin string) bool
+}
+
+// NewOriginMatcher create matcher from exact origin, wildcard origin like https://*.example.com or regex:<expr>
+func NewOriginMatcher(raw string) (OriginMatcher, error) {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case raw == OriginAny:
+		return anyMatcher{}, nil
+	case strings.HasPrefix(raw, OriginRegexPrefix):
+		expr := strings.TrimPrefix(raw, OriginRegexPrefix)
+		// make sure the whole origin is matched
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid origin regex %q: %v", expr, err)
+		}
+		return &regexMatcher{re: re}, nil
+	case strings.Contains(raw, "*"):
+		var builder strings.Builder
+		builder.WriteString("^")
+		for i, literal := range strings.Split(strings.ToLower(raw), "*") {
+			if i > 0 {
+				builder.WriteString(wildcardPattern)
+			}
+			builder.WriteString(regexp.QuoteMeta(literal))
+		}
+		builder.WriteString("$")
+		return &regexMatcher{re: regexp.MustCompile(builder.String()), lower: true}, nil
+	}
+
+	return exactMatcher(strings.ToLower(raw)), nil
+}
+
+// anyMatcher matches any of origins
+type anyMatcher struct{}
+
+func (anyMatcher) Match(string) bool {
+	return true
+}
+
+// exactMatcher matches origin case-insensitively
+type exactMatcher string
+
+func (m exactMatcher) Match(origin string) bool {
+	return string(m) == strings.ToLower(origin)
+}
+
+// regexMatcher matches origin with compiled expression, origin is lower cased for wildcard origins
+type regexMatcher struct {
+	re    *regexp.Regexp
+	lower bool
+}
+
+func (m *regexMatcher) Match(origin string) bool {
+	if m.lower {
+		origin = strings.ToLower(origin)
+	}
+	return m.re.MatchString(origin)
+}
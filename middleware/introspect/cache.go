@@ -0,0 +1,101 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointrospect
+
+import (
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"sync"
+	"time"
+)
+
+// newCache create cache with max entries, maxTtl limits TTL of entries if positive,
+// inactive results would be cached for negativeTtl if positive
+func newCache(maxEntries int, maxTtl, negativeTtl time.Duration) *cache {
+	return &cache{
+		maxEntries:  maxEntries,
+		maxTtl:      maxTtl,
+		negativeTtl: negativeTtl,
+		items:       make(map[string]*cacheItem),
+	}
+}
+
+type cacheItem struct {
+	result   *rkechoctx.Introspection
+	expireAt time.Time
+}
+
+// cache stores active introspection results until exp of token and inactive results for a short while
+type cache struct {
+	lock        sync.Mutex
+	maxEntries  int
+	maxTtl      time.Duration
+	negativeTtl time.Duration
+	items       map[string]*cacheItem
+}
+
+// get returns cached result which has not expired
+func (c *cache) get(key string) *rkechoctx.Introspection {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	if !time.Now().Before(item.expireAt) {
+		delete(c.items, key)
+		return nil
+	}
+
+	return item.result
+}
+
+// put caches active result until exp, result without exp would be cached only if maxTtl is positive,
+// inactive result would be cached for negativeTtl, so that replayed invalid tokens would not flood endpoint
+func (c *cache) put(key string, result *rkechoctx.Introspection) {
+	now := time.Now()
+
+	var expireAt time.Time
+	if result.Active {
+		if result.Exp > 0 {
+			expireAt = time.Unix(result.Exp, 0)
+		}
+		if c.maxTtl > 0 && (expireAt.IsZero() || expireAt.After(now.Add(c.maxTtl))) {
+			expireAt = now.Add(c.maxTtl)
+		}
+	} else if c.negativeTtl > 0 {
+		expireAt = now.Add(c.negativeTtl)
+	}
+
+	if !now.Before(expireAt) {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.items) >= c.maxEntries {
+		for k, v := range c.items {
+			if !now.Before(v.expireAt) {
+				delete(c.items, k)
+			}
+		}
+	}
+
+	// evict arbitrary entry if still full
+	for k := range c.items {
+		if len(c.items) < c.maxEntries {
+			break
+		}
+		delete(c.items, k)
+	}
+
+	c.items[key] = &cacheItem{
+		result:   result,
+		expireAt: expireAt,
+	}
+}
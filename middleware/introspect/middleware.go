@@ -0,0 +1,128 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechointrospect is a middleware for echo framework which validates opaque access token with OAuth2 token introspection
+package rkechointrospect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Middleware validates access token in Authorization header with introspection endpoint, see RFC 7662.
+//
+// Active introspection results would be cached until exp of token, and could be read with rkechoctx.GetIntrospection().
+// Inactive results would be cached for a short while, see WithNegativeCacheTtl().
+// Scopes would also be stored into context which could be checked with rkechoctx.HasScope().
+//
+// 1: Request without access token or with inactive token would be rejected with 401.
+// 2: Request would be rejected with 503 if introspection endpoint is unavailable.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			token := set.extractToken(ctx.Request())
+			if len(token) < 1 {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Missing or malformed access token")
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			res, err := set.introspect(ctx.Request().Context(), token)
+			if err != nil {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusServiceUnavailable, "Token introspection unavailable", err.Error())
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			if !res.Active {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid or expired access token")
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			ctx.Set(rkechoctx.IntrospectionKey, res)
+			ctx.Set(rkechoctx.ScopesKey, res.Scopes())
+
+			return next(ctx)
+		}
+	}
+}
+
+// extractToken returns token in Authorization header with auth scheme
+func (set *optionSet) extractToken(req *http.Request) string {
+	auth := req.Header.Get(rkmid.HeaderAuthorization)
+	prefix := set.AuthScheme + " "
+
+	if len(auth) > len(prefix) && strings.EqualFold(auth[:len(prefix)], prefix) {
+		return strings.TrimSpace(auth[len(prefix):])
+	}
+
+	return ""
+}
+
+// introspect returns cached result or calls introspection endpoint
+func (set *optionSet) introspect(ctx context.Context, token string) (*rkechoctx.Introspection, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	if res := set.cache.get(key); res != nil {
+		return res, nil
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", set.TokenTypeHint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, set.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if len(set.ClientId) > 0 {
+		// client credentials should be form encoded, see RFC 6749 section 2.3.1
+		req.SetBasicAuth(url.QueryEscape(set.ClientId), url.QueryEscape(set.ClientSecret))
+	}
+
+	resp, err := set.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from introspection endpoint", resp.StatusCode)
+	}
+
+	res := &rkechoctx.Introspection{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, err
+	}
+
+	// double check time based claims
+	now := time.Now().Unix()
+	if (res.Exp > 0 && now >= res.Exp) || (res.Nbf > 0 && now < res.Nbf) {
+		res.Active = false
+	}
+
+	set.cache.put(key, res)
+
+	return res, nil
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointrospect
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newIntrospectionServer returns introspection endpoint stub which treats token active-* as active
+func newIntrospectionServer(t *testing.T, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "ut-client" || secret != "ut-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assert.Equal(t, DefaultTokenTypeHint, r.PostFormValue("token_type_hint"))
+
+		switch r.PostFormValue("token") {
+		case "active-token":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":    true,
+				"scope":     "orders:read orders:write",
+				"sub":       "ut-sub",
+				"client_id": "ut-app",
+				"exp":       time.Now().Add(time.Minute).Unix(),
+			})
+		case "active-expired":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active": true,
+				"exp":    time.Now().Add(-time.Minute).Unix(),
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active": false,
+			})
+		}
+	}))
+}
+
+func serve(inter echo.MiddlewareFunc, token string) (*httptest.ResponseRecorder, echo.Context) {
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	if len(token) > 0 {
+		req.Header.Set(rkmid.HeaderAuthorization, "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, w)
+
+	inter(func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "")
+	})(ctx)
+
+	return w, ctx
+}
+
+func TestMiddleware(t *testing.T) {
+	var hits int32
+	server := newIntrospectionServer(t, &hits)
+	defer server.Close()
+
+	inter := Middleware(
+		WithEndpoint(server.URL),
+		WithClientCredentials("ut-client", "ut-secret"))
+
+	// case 1: without token
+	w, _ := serve(inter, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	// case 2: active token
+	w, ctx := serve(inter, "active-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	res := rkechoctx.GetIntrospection(ctx)
+	assert.NotNil(t, res)
+	assert.Equal(t, "ut-sub", res.Sub)
+	assert.Equal(t, "ut-app", res.ClientId)
+	assert.True(t, rkechoctx.HasScope(ctx, "orders:read", "orders:write"))
+
+	// case 3: active token cached until exp
+	w, _ = serve(inter, "active-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// case 4: inactive token is cached for negative TTL
+	w, _ = serve(inter, "inactive-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = serve(inter, "inactive-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// case 5: expired token reported as active
+	w, _ = serve(inter, "active-expired")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// case 6: invalid client credentials
+	inter = Middleware(
+		WithEndpoint(server.URL),
+		WithClientCredentials("ut-client", "invalid"))
+	w, _ = serve(inter, "active-token")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// case 7: unreachable endpoint
+	inter = Middleware(WithEndpoint("http://127.0.0.1:0"))
+	w, _ = serve(inter, "active-token")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// case 8: negative cache disabled
+	atomic.StoreInt32(&hits, 0)
+	inter = Middleware(
+		WithEndpoint(server.URL),
+		WithClientCredentials("ut-client", "ut-secret"),
+		WithNegativeCacheTtl(-1))
+	serve(inter, "inactive-token")
+	w, _ = serve(inter, "inactive-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2, 0, 0)
+
+	// without exp
+	c.put("no-exp", &rkechoctx.Introspection{Active: true})
+	assert.Nil(t, c.get("no-exp"))
+
+	// inactive
+	c.put("inactive", &rkechoctx.Introspection{Active: false, Exp: time.Now().Add(time.Minute).Unix()})
+	assert.Nil(t, c.get("inactive"))
+
+	// evict when full
+	exp := time.Now().Add(time.Minute).Unix()
+	c.put("a", &rkechoctx.Introspection{Active: true, Exp: exp})
+	c.put("b", &rkechoctx.Introspection{Active: true, Exp: exp})
+	c.put("c", &rkechoctx.Introspection{Active: true, Exp: exp})
+	assert.Len(t, c.items, 2)
+	assert.NotNil(t, c.get("c"))
+
+	// with max ttl
+	c = newCache(2, time.Millisecond, 0)
+	c.put("no-exp", &rkechoctx.Introspection{Active: true})
+	assert.NotNil(t, c.get("no-exp"))
+	time.Sleep(2 * time.Millisecond)
+	assert.Nil(t, c.get("no-exp"))
+
+	// with negative ttl, inactive result cached regardless of exp
+	c = newCache(2, 0, time.Millisecond)
+	c.put("inactive", &rkechoctx.Introspection{Active: false, Exp: time.Now().Add(-time.Minute).Unix()})
+	assert.False(t, c.get("inactive").Active)
+	time.Sleep(2 * time.Millisecond)
+	assert.Nil(t, c.get("inactive"))
+}
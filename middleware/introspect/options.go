@@ -0,0 +1,243 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointrospect
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTokenTypeHint is the default token_type_hint sent to introspection endpoint
+	DefaultTokenTypeHint = "access_token"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultTimeout          = 5 * time.Second
+	defaultMaxEntries       = 10000
+	defaultNegativeCacheTtl = 5 * time.Second
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:        xid.New().String(),
+		EntryType:        "",
+		Skipper:          defaultSkipper,
+		AuthScheme:       "Bearer",
+		TokenTypeHint:    DefaultTokenTypeHint,
+		Client:           &http.Client{Timeout: defaultTimeout},
+		MaxEntries:       defaultMaxEntries,
+		NegativeCacheTtl: defaultNegativeCacheTtl,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if len(set.Endpoint) < 1 {
+		rkentry.ShutdownWithError(errors.New("introspection endpoint is required"))
+	}
+
+	set.cache = newCache(set.MaxEntries, set.MaxCacheTtl, set.NegativeCacheTtl)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName        string
+	EntryType        string
+	Skipper          Skipper
+	Endpoint         string
+	ClientId         string
+	ClientSecret     string
+	AuthScheme       string
+	TokenTypeHint    string
+	Client           *http.Client
+	MaxEntries       int
+	MaxCacheTtl      time.Duration
+	NegativeCacheTtl time.Duration
+	cache            *cache
+	ignorePrefix     []string
+}
+
+// ShouldIgnore determine whether introspection should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled            bool     `yaml:"enabled" json:"enabled"`
+	Ignore             []string `yaml:"ignore" json:"ignore"`
+	Endpoint           string   `yaml:"endpoint" json:"endpoint"`
+	ClientId           string   `yaml:"clientId" json:"clientId"`
+	ClientSecret       string   `yaml:"clientSecret" json:"clientSecret"`
+	AuthScheme         string   `yaml:"authScheme" json:"authScheme"`
+	TokenTypeHint      string   `yaml:"tokenTypeHint" json:"tokenTypeHint"`
+	TimeoutMs          int      `yaml:"timeoutMs" json:"timeoutMs"`
+	MaxEntries         int      `yaml:"maxEntries" json:"maxEntries"`
+	MaxCacheTtlMs      int      `yaml:"maxCacheTtlMs" json:"maxCacheTtlMs"`
+	NegativeCacheTtlMs int      `yaml:"negativeCacheTtlMs" json:"negativeCacheTtlMs"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithEndpoint(config.Endpoint),
+			WithClientCredentials(config.ClientId, config.ClientSecret),
+			WithAuthScheme(config.AuthScheme),
+			WithTokenTypeHint(config.TokenTypeHint),
+			WithMaxEntries(config.MaxEntries),
+			WithMaxCacheTtl(time.Duration(config.MaxCacheTtlMs)*time.Millisecond),
+			WithNegativeCacheTtl(time.Duration(config.NegativeCacheTtlMs)*time.Millisecond),
+			WithPathToIgnore(config.Ignore...))
+
+		if config.TimeoutMs > 0 {
+			opts = append(opts, WithClient(&http.Client{
+				Timeout: time.Duration(config.TimeoutMs) * time.Millisecond,
+			}))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithEndpoint provide url of introspection endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(opt *optionSet) {
+		opt.Endpoint = endpoint
+	}
+}
+
+// WithClientCredentials provide client id and secret which authenticate to introspection endpoint with basic auth.
+func WithClientCredentials(clientId, clientSecret string) Option {
+	return func(opt *optionSet) {
+		opt.ClientId = clientId
+		opt.ClientSecret = clientSecret
+	}
+}
+
+// WithAuthScheme provide auth scheme of Authorization header, default: Bearer.
+func WithAuthScheme(scheme string) Option {
+	return func(opt *optionSet) {
+		if len(scheme) > 0 {
+			opt.AuthScheme = scheme
+		}
+	}
+}
+
+// WithTokenTypeHint provide token_type_hint, default: access_token.
+func WithTokenTypeHint(hint string) Option {
+	return func(opt *optionSet) {
+		if len(hint) > 0 {
+			opt.TokenTypeHint = hint
+		}
+	}
+}
+
+// WithClient provide http client used for calling introspection endpoint, default timeout is 5 seconds.
+func WithClient(client *http.Client) Option {
+	return func(opt *optionSet) {
+		if client != nil {
+			opt.Client = client
+		}
+	}
+}
+
+// WithMaxEntries provide max number of cached introspection results, default: 10000.
+func WithMaxEntries(max int) Option {
+	return func(opt *optionSet) {
+		if max > 0 {
+			opt.MaxEntries = max
+		}
+	}
+}
+
+// WithMaxCacheTtl provide max TTL of cached introspection results.
+// Results are cached until exp by default, zero means no limit.
+func WithMaxCacheTtl(ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if ttl > 0 {
+			opt.MaxCacheTtl = ttl
+		}
+	}
+}
+
+// WithNegativeCacheTtl provide TTL of cached inactive introspection results, default: 5 seconds.
+// Negative value disables caching of inactive results.
+func WithNegativeCacheTtl(ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if ttl != 0 {
+			opt.NegativeCacheTtl = ttl
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
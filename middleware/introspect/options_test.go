@@ -0,0 +1,89 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechointrospect
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without endpoint
+	assert.Panics(t, func() {
+		newOptionSet()
+	})
+
+	// with endpoint only
+	set := newOptionSet(WithEndpoint("ut-endpoint"))
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Equal(t, "Bearer", set.AuthScheme)
+	assert.Equal(t, DefaultTokenTypeHint, set.TokenTypeHint)
+	assert.Equal(t, defaultTimeout, set.Client.Timeout)
+	assert.Equal(t, defaultMaxEntries, set.MaxEntries)
+	assert.Equal(t, defaultNegativeCacheTtl, set.NegativeCacheTtl)
+	assert.NotNil(t, set.cache)
+
+	// with options
+	client := &http.Client{}
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithEndpoint("ut-endpoint"),
+		WithClientCredentials("ut-id", "ut-secret"),
+		WithAuthScheme("Token"),
+		WithTokenTypeHint("refresh_token"),
+		WithClient(client),
+		WithMaxEntries(1),
+		WithMaxCacheTtl(time.Second),
+		WithNegativeCacheTtl(-1),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-endpoint", set.Endpoint)
+	assert.Equal(t, "ut-id", set.ClientId)
+	assert.Equal(t, "ut-secret", set.ClientSecret)
+	assert.Equal(t, "Token", set.AuthScheme)
+	assert.Equal(t, "refresh_token", set.TokenTypeHint)
+	assert.Equal(t, client, set.Client)
+	assert.Equal(t, 1, set.MaxEntries)
+	assert.Equal(t, time.Second, set.MaxCacheTtl)
+	assert.Equal(t, time.Duration(-1), set.NegativeCacheTtl)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+}
+
+func TestOptionSet_ExtractToken(t *testing.T) {
+	set := newOptionSet(WithEndpoint("ut-endpoint"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Empty(t, set.extractToken(req))
+
+	req.Header.Set("Authorization", "Basic xxx")
+	assert.Empty(t, set.extractToken(req))
+
+	req.Header.Set("Authorization", "bearer ut-token")
+	assert.Equal(t, "ut-token", set.extractToken(req))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.Endpoint = "ut-endpoint"
+	config.TimeoutMs = 1000
+	config.NegativeCacheTtlMs = 1000
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, "ut-endpoint", set.Endpoint)
+	assert.Equal(t, time.Second, set.Client.Timeout)
+	assert.Equal(t, time.Second, set.NegativeCacheTtl)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newIpList create empty ipList
+func newIpList() *ipList {
+	return &ipList{
+		static:   make([]*net.IPNet, 0),
+		fromFile: make(map[string][]*net.IPNet),
+		tree:     newRadixTree(),
+		watchers: make([]*rkechointernal.FileWatcher, 0),
+	}
+}
+
+// ipList is a list of CIDRs from config and files, files are reloaded if changed
+type ipList struct {
+	lock     sync.RWMutex
+	static   []*net.IPNet
+	fromFile map[string][]*net.IPNet
+	tree     *radixTree
+	watchers []*rkechointernal.FileWatcher
+}
+
+// addCidrs adds CIDRs or IP addresses
+func (l *ipList) addCidrs(raw ...string) error {
+	for i := range raw {
+		if len(strings.TrimSpace(raw[i])) < 1 {
+			continue
+		}
+
+		cidr, err := rkechointernal.ParseCidr(raw[i])
+		if err != nil {
+			return err
+		}
+		l.static = append(l.static, cidr)
+	}
+
+	l.rebuild()
+	return nil
+}
+
+// addFile loads CIDRs from file, file would be reloaded at most once per reload interval if modified
+func (l *ipList) addFile(path string, reloadInterval time.Duration) error {
+	watcher := rkechointernal.NewFileWatcher(path, reloadInterval, func(raw []byte) error {
+		cidrs, err := parseList(raw)
+		if err != nil {
+			return err
+		}
+
+		l.lock.Lock()
+		l.fromFile[path] = cidrs
+		l.lock.Unlock()
+
+		l.rebuild()
+		return nil
+	})
+
+	if err := watcher.Reload(); err != nil {
+		return err
+	}
+
+	l.watchers = append(l.watchers, watcher)
+	return nil
+}
+
+// rebuild builds new tree and replaces current one
+func (l *ipList) rebuild() {
+	tree := newRadixTree()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, cidr := range l.static {
+		tree.insert(cidr)
+	}
+
+	for _, cidrs := range l.fromFile {
+		for _, cidr := range cidrs {
+			tree.insert(cidr)
+		}
+	}
+
+	l.tree = tree
+}
+
+// isEmpty returns true if no CIDR configured nor file watched
+func (l *ipList) isEmpty() bool {
+	return len(l.static) < 1 && len(l.watchers) < 1
+}
+
+// contains returns true if ip is in list
+func (l *ipList) contains(ip net.IP) bool {
+	for _, w := range l.watchers {
+		w.ReloadIfChanged()
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.tree.contains(ip)
+}
+
+// parseList parses CIDRs or IP addresses in lines, empty lines and comments start with # are skipped
+func parseList(raw []byte) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(line)
+		if len(line) < 1 {
+			continue
+		}
+
+		cidr, err := rkechointernal.ParseCidr(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		res = append(res, cidr)
+	}
+
+	return res, scanner.Err()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestParseList(t *testing.T) {
+	// with comments and empty lines
+	cidrs, err := parseList([]byte("# ut comment\n\n10.0.0.0/8\n 1.2.3.4 # ut host\n2001:db8::/32\n"))
+	assert.Nil(t, err)
+	assert.Len(t, cidrs, 3)
+	assert.Equal(t, "10.0.0.0/8", cidrs[0].String())
+	assert.Equal(t, "1.2.3.4/32", cidrs[1].String())
+	assert.Equal(t, "2001:db8::/32", cidrs[2].String())
+
+	// with invalid line
+	_, err = parseList([]byte("10.0.0.0/8\nut-invalid\n"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestIpList(t *testing.T) {
+	file := path.Join(t.TempDir(), "deny.txt")
+	list := newIpList()
+	assert.True(t, list.isEmpty())
+
+	// with invalid CIDR
+	assert.NotNil(t, list.addCidrs("ut-invalid"))
+
+	// with missing file
+	assert.NotNil(t, list.addFile(file, time.Millisecond))
+
+	// with invalid file
+	assert.Nil(t, os.WriteFile(file, []byte("ut-invalid"), 0644))
+	assert.NotNil(t, list.addFile(file, time.Millisecond))
+
+	// happy case
+	assert.Nil(t, list.addCidrs("", "10.0.0.0/8"))
+	assert.Nil(t, os.WriteFile(file, []byte("1.1.1.1\n"), 0644))
+	assert.Nil(t, list.addFile(file, time.Millisecond))
+	assert.False(t, list.isEmpty())
+	assert.True(t, list.contains(net.ParseIP("10.0.0.1")))
+	assert.True(t, list.contains(net.ParseIP("1.1.1.1")))
+	assert.False(t, list.contains(net.ParseIP("2.2.2.2")))
+
+	// reload on change
+	assert.Nil(t, os.WriteFile(file, []byte("2.2.2.0/24\n"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(2 * time.Millisecond)
+
+	assert.True(t, list.contains(net.ParseIP("2.2.2.2")))
+	assert.False(t, list.contains(net.ParseIP("1.1.1.1")))
+	assert.True(t, list.contains(net.ParseIP("10.0.0.1")))
+
+	// keep previous content if reload failed
+	assert.Nil(t, os.WriteFile(file, []byte("ut-invalid"), 0644))
+	assert.Nil(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(2 * time.Millisecond)
+
+	assert.True(t, list.contains(net.ParseIP("2.2.2.2")))
+}
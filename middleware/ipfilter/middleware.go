@@ -0,0 +1,47 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechoipfilter is ip allow/deny list middleware for echo framework
+package rkechoipfilter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net"
+	"net/http"
+)
+
+// Middleware rejects requests with 403 if client ip is in deny list or not in allow list.
+//
+// Client ip is resolved with rkechoctx.GetClientIP(), which honors trusted proxies if proxy middleware enabled.
+// Global lists apply to all requests, lists of the longest matched path prefix apply in addition.
+// Deny list takes precedence over allow list.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			ip := net.ParseIP(rkechoctx.GetClientIP(ctx))
+			if path, reason := set.check(ctx.Request().URL.Path, ip); len(reason) > 0 {
+				if len(path) < 1 {
+					path = "/"
+				}
+				set.blocked(path, reason)
+
+				errResp := rkmid.GetErrorBuilder().New(http.StatusForbidden, "Access denied for client ip")
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+var userHandler = func(ctx echo.Context) error {
+	return ctx.NoContent(http.StatusOK)
+}
+
+func newCtx(remoteAddr, path string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	return echo.New().NewContext(req, w), w
+}
+
+func TestMiddleware(t *testing.T) {
+	defer assertNotPanic(t)
+
+	registry := prometheus.NewRegistry()
+	inter := Middleware(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithRegisterer(registry),
+		WithAllow("10.0.0.0/8"),
+		WithDenyByPath("/ut-admin", "10.1.0.0/16"),
+		WithPathToIgnore("/ut-ignore"))
+
+	// with allowed ip
+	ctx, w := newCtx("10.0.0.1:1234", "/ut-path")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// with ip not allowed
+	ctx, w = newCtx("1.1.1.1:1234", "/ut-path")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Access denied")
+
+	// with ip denied by path
+	ctx, w = newCtx("10.1.0.1:1234", "/ut-admin/users")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// with ignored path
+	ctx, w = newCtx("1.1.1.1:1234", "/ut-ignore")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// with client ip resolved by proxy middleware
+	ctx, w = newCtx("1.1.1.1:1234", "/ut-path")
+	ctx.Set(rkechoctx.ClientIPKey, "10.0.0.2")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	set := optionsMap["ut-entry"]
+	vec := set.metricsSet.GetCounter(MetricsNameBlocked)
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/", reasonNotAllowed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-admin", reasonDenied)))
+}
+
+func TestMiddleware_WithoutRules(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := Middleware(WithRegisterer(prometheus.NewRegistry()))
+
+	ctx, w := newCtx("1.1.1.1:1234", "/ut-path")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func assertNotPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// Expect panic to be called with non nil error
+		assert.True(t, false)
+	} else {
+		// This should never be called in case of a bug
+		assert.True(t, true)
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
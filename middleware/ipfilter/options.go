@@ -0,0 +1,405 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rs/xid"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MetricsNameBlocked records requests blocked by ip filter
+	MetricsNameBlocked = "blocked"
+
+	// reasonDenied means client ip is in deny list
+	reasonDenied = "denied"
+	// reasonNotAllowed means allow list configured and client ip is not in it
+	reasonNotAllowed = "notAllowed"
+	// reasonInvalidIp means client ip could not be parsed
+	reasonInvalidIp = "invalidIp"
+
+	// globalPath is path of rule applied to all requests
+	globalPath = ""
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	labelKeys = []string{"entryName", "entryType", "path", "reason"}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:      xid.New().String(),
+		EntryType:      "",
+		Skipper:        defaultSkipper,
+		reloadInterval: rkechointernal.DefaultReloadInterval,
+		rawRules:       make(map[string]*rawRule),
+		rules:          make([]*rule, 0),
+		registerer:     prometheus.DefaultRegisterer,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	for path, raw := range set.rawRules {
+		r, err := raw.build(path, set.reloadInterval)
+		if err != nil {
+			rkentry.ShutdownWithError(err)
+		}
+
+		if !r.isEmpty() {
+			set.rules = append(set.rules, r)
+		}
+	}
+
+	// longest path first, global rule would be the last one
+	sort.Slice(set.rules, func(i, j int) bool {
+		return len(set.rules[i].path) > len(set.rules[j].path)
+	})
+
+	set.metricsSet = rkmidprom.NewMetricsSet("rk", "ipfilter", set.registerer)
+	set.metricsSet.RegisterCounter(MetricsNameBlocked, labelKeys...)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName      string
+	EntryType      string
+	Skipper        Skipper
+	reloadInterval time.Duration
+	rawRules       map[string]*rawRule
+	rules          []*rule
+	ignorePrefix   []string
+	registerer     prometheus.Registerer
+	metricsSet     *rkmidprom.MetricsSet
+}
+
+// ShouldIgnore determine whether ip filter should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if len(set.rules) < 1 {
+		return true
+	}
+
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// check returns path of rule and reason if ip blocked, both empty if allowed.
+//
+// Global rule applies to all requests, rule of the longest matched path prefix applies in addition.
+func (set *optionSet) check(path string, ip net.IP) (string, string) {
+	if ip == nil {
+		return globalPath, reasonInvalidIp
+	}
+
+	var pathRule, globalRule *rule
+	for _, r := range set.rules {
+		if r.path == globalPath {
+			globalRule = r
+		} else if pathRule == nil && hasPathPrefix(path, r.path) {
+			pathRule = r
+		}
+	}
+
+	for _, r := range []*rule{globalRule, pathRule} {
+		if r == nil {
+			continue
+		}
+
+		if reason := r.check(ip); len(reason) > 0 {
+			return r.path, reason
+		}
+	}
+
+	return "", ""
+}
+
+// blocked increases blocked request counter
+func (set *optionSet) blocked(path, reason string) {
+	if vec := set.metricsSet.GetCounter(MetricsNameBlocked); vec != nil {
+		vec.WithLabelValues(set.EntryName, set.EntryType, path, reason).Inc()
+	}
+}
+
+// hasPathPrefix returns true if path equals to prefix or is under prefix on segment boundary,
+// /admin matches /admin and /admin/users but not /administrator
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return len(prefix) < 1 || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// rawRule is CIDRs and files of path prefix collected from options
+type rawRule struct {
+	allow      []string
+	deny       []string
+	allowFiles []string
+	denyFiles  []string
+}
+
+// build creates rule with CIDRs parsed and files loaded
+func (raw *rawRule) build(path string, reloadInterval time.Duration) (*rule, error) {
+	r := &rule{
+		path:  path,
+		allow: newIpList(),
+		deny:  newIpList(),
+	}
+
+	if err := r.allow.addCidrs(raw.allow...); err != nil {
+		return nil, err
+	}
+
+	if err := r.deny.addCidrs(raw.deny...); err != nil {
+		return nil, err
+	}
+
+	for _, f := range raw.allowFiles {
+		if err := r.allow.addFile(f, reloadInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range raw.denyFiles {
+		if err := r.deny.addFile(f, reloadInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// rule is allow and deny lists of path prefix
+type rule struct {
+	path  string
+	allow *ipList
+	deny  *ipList
+}
+
+// isEmpty returns true if neither allow nor deny list configured
+func (r *rule) isEmpty() bool {
+	return r.allow.isEmpty() && r.deny.isEmpty()
+}
+
+// check returns reason if ip blocked, deny list takes precedence over allow list
+func (r *rule) check(ip net.IP) string {
+	if r.deny.contains(ip) {
+		return reasonDenied
+	}
+
+	if !r.allow.isEmpty() && !r.allow.contains(ip) {
+		return reasonNotAllowed
+	}
+
+	return ""
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled          bool         `yaml:"enabled" json:"enabled"`
+	Ignore           []string     `yaml:"ignore" json:"ignore"`
+	Allow            []string     `yaml:"allow" json:"allow"`
+	Deny             []string     `yaml:"deny" json:"deny"`
+	AllowFiles       []string     `yaml:"allowFiles" json:"allowFiles"`
+	DenyFiles        []string     `yaml:"denyFiles" json:"denyFiles"`
+	ReloadIntervalMs int          `yaml:"reloadIntervalMs" json:"reloadIntervalMs"`
+	Paths            []PathConfig `yaml:"paths" json:"paths"`
+}
+
+// PathConfig for YAML, lists apply to requests with path prefix in addition to global lists
+type PathConfig struct {
+	Path       string   `yaml:"path" json:"path"`
+	Allow      []string `yaml:"allow" json:"allow"`
+	Deny       []string `yaml:"deny" json:"deny"`
+	AllowFiles []string `yaml:"allowFiles" json:"allowFiles"`
+	DenyFiles  []string `yaml:"denyFiles" json:"denyFiles"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string, registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithRegisterer(registerer),
+			WithReloadInterval(time.Duration(config.ReloadIntervalMs)*time.Millisecond),
+			WithAllow(config.Allow...),
+			WithDeny(config.Deny...),
+			WithAllowFile(config.AllowFiles...),
+			WithDenyFile(config.DenyFiles...),
+			WithPathToIgnore(config.Ignore...))
+
+		for _, p := range config.Paths {
+			opts = append(opts,
+				WithAllowByPath(p.Path, p.Allow...),
+				WithDenyByPath(p.Path, p.Deny...),
+				WithAllowFileByPath(p.Path, p.AllowFiles...),
+				WithDenyFileByPath(p.Path, p.DenyFiles...))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithAllow provide CIDRs or IP addresses allowed for all paths, requests from other clients would be rejected.
+func WithAllow(cidr ...string) Option {
+	return WithAllowByPath(globalPath, cidr...)
+}
+
+// WithDeny provide CIDRs or IP addresses denied for all paths.
+func WithDeny(cidr ...string) Option {
+	return WithDenyByPath(globalPath, cidr...)
+}
+
+// WithAllowFile provide files of allowed CIDRs for all paths, one CIDR or IP address per line.
+func WithAllowFile(path ...string) Option {
+	return WithAllowFileByPath(globalPath, path...)
+}
+
+// WithDenyFile provide files of denied CIDRs for all paths, one CIDR or IP address per line.
+func WithDenyFile(path ...string) Option {
+	return WithDenyFileByPath(globalPath, path...)
+}
+
+// WithAllowByPath provide CIDRs or IP addresses allowed for requests with path prefix.
+func WithAllowByPath(path string, cidr ...string) Option {
+	return func(opt *optionSet) {
+		r := opt.rawRuleOf(path)
+		r.allow = append(r.allow, cidr...)
+	}
+}
+
+// WithDenyByPath provide CIDRs or IP addresses denied for requests with path prefix.
+func WithDenyByPath(path string, cidr ...string) Option {
+	return func(opt *optionSet) {
+		r := opt.rawRuleOf(path)
+		r.deny = append(r.deny, cidr...)
+	}
+}
+
+// WithAllowFileByPath provide files of allowed CIDRs for requests with path prefix.
+func WithAllowFileByPath(path string, file ...string) Option {
+	return func(opt *optionSet) {
+		r := opt.rawRuleOf(path)
+		r.allowFiles = append(r.allowFiles, nonEmpty(file)...)
+	}
+}
+
+// WithDenyFileByPath provide files of denied CIDRs for requests with path prefix.
+func WithDenyFileByPath(path string, file ...string) Option {
+	return func(opt *optionSet) {
+		r := opt.rawRuleOf(path)
+		r.denyFiles = append(r.denyFiles, nonEmpty(file)...)
+	}
+}
+
+// WithReloadInterval provide interval of checking modification of files, default: 10 seconds.
+func WithReloadInterval(interval time.Duration) Option {
+	return func(opt *optionSet) {
+		if interval > 0 {
+			opt.reloadInterval = interval
+		}
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for blocked request metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// rawRuleOf returns rawRule of path, created if missing
+func (set *optionSet) rawRuleOf(path string) *rawRule {
+	path = strings.TrimSpace(path)
+	if path == "/" {
+		path = globalPath
+	}
+
+	if _, ok := set.rawRules[path]; !ok {
+		set.rawRules[path] = &rawRule{}
+	}
+
+	return set.rawRules[path]
+}
+
+// nonEmpty returns elements which are not empty
+func nonEmpty(in []string) []string {
+	res := make([]string, 0)
+	for i := range in {
+		if len(strings.TrimSpace(in[i])) > 0 {
+			res = append(res, strings.TrimSpace(in[i]))
+		}
+	}
+
+	return res
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
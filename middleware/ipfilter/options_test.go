@@ -0,0 +1,144 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet(WithRegisterer(prometheus.NewRegistry()))
+	assert.NotEmpty(t, set.EntryName)
+	assert.Empty(t, set.rules)
+	assert.Equal(t, rkechointernal.DefaultReloadInterval, set.reloadInterval)
+	assert.True(t, set.ShouldIgnore(nil))
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithReloadInterval(time.Second),
+		WithAllow("10.0.0.0/8"),
+		WithDenyByPath("/ut-admin", "10.1.0.0/16"),
+		WithAllowByPath("/", "192.168.0.0/16"),
+		WithAllowFileByPath("/ut-empty", ""),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Equal(t, time.Second, set.reloadInterval)
+	assert.Len(t, set.rules, 2)
+	assert.Equal(t, "/ut-admin", set.rules[0].path)
+	assert.Equal(t, globalPath, set.rules[1].path)
+
+	ctx := echo.New().NewContext(httptest.NewRequest("GET", "/ut-ignore", nil), httptest.NewRecorder())
+	assert.True(t, set.ShouldIgnore(ctx))
+	ctx = echo.New().NewContext(httptest.NewRequest("GET", "/ut-path", nil), httptest.NewRecorder())
+	assert.False(t, set.ShouldIgnore(ctx))
+
+	// with invalid CIDR
+	assert.Panics(t, func() {
+		newOptionSet(WithRegisterer(prometheus.NewRegistry()), WithDeny("ut-invalid"))
+	})
+
+	// with missing file
+	assert.Panics(t, func() {
+		newOptionSet(WithRegisterer(prometheus.NewRegistry()), WithDenyFile(path.Join(t.TempDir(), "ut-missing")))
+	})
+}
+
+func TestOptionSet_Check(t *testing.T) {
+	set := newOptionSet(
+		WithRegisterer(prometheus.NewRegistry()),
+		WithAllow("10.0.0.0/8", "192.168.0.0/16"),
+		WithDeny("10.9.0.0/16"),
+		WithAllowByPath("/admin", "10.1.0.0/16"),
+		WithDenyByPath("/admin/users", "10.1.1.0/24"))
+
+	cases := []struct {
+		path, ip, rulePath, reason string
+	}{
+		{"/", "10.2.0.1", "", ""},
+		{"/", "8.8.8.8", globalPath, reasonNotAllowed},
+		{"/", "10.9.0.1", globalPath, reasonDenied},
+		{"/admin", "10.1.0.1", "", ""},
+		{"/admin", "10.2.0.1", "/admin", reasonNotAllowed},
+		{"/admin", "8.8.8.8", globalPath, reasonNotAllowed},
+		// path prefix matches on segment boundary
+		{"/administrator", "10.2.0.1", "", ""},
+		{"/admin/", "10.2.0.1", "/admin", reasonNotAllowed},
+		// only rule of the longest path prefix applies in addition to global rule
+		{"/admin/users", "10.1.1.1", "/admin/users", reasonDenied},
+		{"/admin/users", "10.2.0.1", "", ""},
+	}
+
+	for _, c := range cases {
+		rulePath, reason := set.check(c.path, net.ParseIP(c.ip))
+		assert.Equal(t, c.rulePath, rulePath, c.path+" "+c.ip)
+		assert.Equal(t, c.reason, reason, c.path+" "+c.ip)
+	}
+
+	// with invalid ip
+	_, reason := set.check("/", nil)
+	assert.Equal(t, reasonInvalidIp, reason)
+}
+
+func TestToOptions(t *testing.T) {
+	file := path.Join(t.TempDir(), "deny.txt")
+	assert.Nil(t, os.WriteFile(file, []byte("1.1.1.1\n"), 0644))
+
+	config := &BootConfig{
+		Enabled:          false,
+		Ignore:           []string{"/ut-ignore"},
+		Allow:            []string{"10.0.0.0/8"},
+		DenyFiles:        []string{file},
+		ReloadIntervalMs: 1000,
+		Paths: []PathConfig{
+			{
+				Path:  "/ut-admin",
+				Allow: []string{"10.1.0.0/16"},
+			},
+		},
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "ut-entry", "ut-type", prometheus.NewRegistry()))
+
+	// with enabled
+	config.Enabled = true
+	opts := ToOptions(config, "ut-entry", "ut-type", prometheus.NewRegistry())
+	assert.NotEmpty(t, opts)
+
+	set := newOptionSet(opts...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Equal(t, time.Second, set.reloadInterval)
+	assert.Equal(t, []string{"/ut-ignore"}, set.ignorePrefix)
+	assert.Len(t, set.rules, 2)
+
+	_, reason := set.check("/", net.ParseIP("1.1.1.1"))
+	assert.Equal(t, reasonDenied, reason)
+	_, reason = set.check("/ut-admin", net.ParseIP("10.2.0.1"))
+	assert.Equal(t, reasonNotAllowed, reason)
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	assert.True(t, hasPathPrefix("/admin", "/admin"))
+	assert.True(t, hasPathPrefix("/admin/users", "/admin"))
+	assert.True(t, hasPathPrefix("/admin/users", "/admin/"))
+	assert.True(t, hasPathPrefix("/admin", "/admin/"))
+	assert.True(t, hasPathPrefix("/any", "/"))
+	assert.False(t, hasPathPrefix("/administrator", "/admin"))
+	assert.False(t, hasPathPrefix("/ad", "/admin"))
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import "net"
+
+// newRadixTree create empty radixTree
+func newRadixTree() *radixTree {
+	return &radixTree{
+		v4: &radixNode{},
+		v6: &radixNode{},
+	}
+}
+
+// radixTree is a binary radix tree of CIDRs, IPv4 and IPv6 are stored in separated trees.
+//
+// Lookup walks at most 32 or 128 nodes regardless of number of CIDRs, and stops at the first CIDR contains ip.
+type radixTree struct {
+	v4   *radixNode
+	v6   *radixNode
+	size int
+}
+
+// radixNode is a node of radixTree, terminal node means a CIDR ends at it
+type radixNode struct {
+	children [2]*radixNode
+	terminal bool
+}
+
+// insert adds CIDR into tree, CIDR covered by inserted one would be skipped
+func (t *radixTree) insert(cidr *net.IPNet) {
+	ip, root := t.rootOf(cidr.IP)
+	if ip == nil {
+		return
+	}
+
+	ones, bits := cidr.Mask.Size()
+	if bits != len(ip)*8 {
+		return
+	}
+
+	node := root
+	for i := 0; i < ones; i++ {
+		if node.terminal {
+			return
+		}
+
+		bit := bitAt(ip, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &radixNode{}
+		}
+		node = node.children[bit]
+	}
+
+	if !node.terminal {
+		node.terminal = true
+		// children are covered by this CIDR
+		node.children = [2]*radixNode{}
+		t.size++
+	}
+}
+
+// contains returns true if ip is in any CIDR of tree
+func (t *radixTree) contains(ip net.IP) bool {
+	ip, node := t.rootOf(ip)
+	if ip == nil {
+		return false
+	}
+
+	for i := 0; node != nil; i++ {
+		if node.terminal {
+			return true
+		}
+
+		if i >= len(ip)*8 {
+			return false
+		}
+
+		node = node.children[bitAt(ip, i)]
+	}
+
+	return false
+}
+
+// len returns number of CIDRs in tree, CIDRs covered by others are not counted
+func (t *radixTree) len() int {
+	return t.size
+}
+
+// rootOf returns ip in 4 or 16 bytes with root of tree
+func (t *radixTree) rootOf(ip net.IP) (net.IP, *radixNode) {
+	if v4 := ip.To4(); v4 != nil {
+		return v4, t.v4
+	}
+
+	if v6 := ip.To16(); v6 != nil {
+		return v6, t.v6
+	}
+
+	return nil, nil
+}
+
+// bitAt returns bit at index i of ip from the most significant bit
+func bitAt(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
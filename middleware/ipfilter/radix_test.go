@@ -0,0 +1,57 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoipfilter
+
+import (
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestRadixTree(t *testing.T) {
+	tree := newRadixTree()
+
+	// with empty tree
+	assert.False(t, tree.contains(net.ParseIP("10.0.0.1")))
+	assert.False(t, tree.contains(nil))
+
+	for _, raw := range []string{"10.0.0.0/8", "192.168.1.0/24", "1.2.3.4", "2001:db8::/32"} {
+		cidr, err := rkechointernal.ParseCidr(raw)
+		assert.Nil(t, err)
+		tree.insert(cidr)
+	}
+	assert.Equal(t, 4, tree.len())
+
+	assert.True(t, tree.contains(net.ParseIP("10.255.0.1")))
+	assert.True(t, tree.contains(net.ParseIP("192.168.1.200")))
+	assert.True(t, tree.contains(net.ParseIP("1.2.3.4")))
+	assert.True(t, tree.contains(net.ParseIP("2001:db8::1")))
+	// IPv4-mapped IPv6 address
+	assert.True(t, tree.contains(net.ParseIP("::ffff:10.0.0.1")))
+
+	assert.False(t, tree.contains(net.ParseIP("11.0.0.1")))
+	assert.False(t, tree.contains(net.ParseIP("192.168.2.1")))
+	assert.False(t, tree.contains(net.ParseIP("1.2.3.5")))
+	assert.False(t, tree.contains(net.ParseIP("2001:db9::1")))
+
+	// with CIDR covered by existing one
+	cidr, _ := rkechointernal.ParseCidr("10.1.0.0/16")
+	tree.insert(cidr)
+	assert.Equal(t, 4, tree.len())
+
+	// with CIDR covers existing ones
+	cidr, _ = rkechointernal.ParseCidr("192.168.0.0/16")
+	tree.insert(cidr)
+	assert.Equal(t, 5, tree.len())
+	assert.True(t, tree.contains(net.ParseIP("192.168.2.1")))
+
+	// with match all
+	cidr, _ = rkechointernal.ParseCidr("0.0.0.0/0")
+	tree.insert(cidr)
+	assert.True(t, tree.contains(net.ParseIP("8.8.8.8")))
+	assert.False(t, tree.contains(net.ParseIP("2001:db9::1")))
+}
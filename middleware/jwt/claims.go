@@ -0,0 +1,159 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"time"
+)
+
+// ClaimsFactory creates empty claims which verified token would be parsed into, for example:
+//
+//	func() jwt.Claims { return &MyClaims{} }
+type ClaimsFactory func() jwt.Claims
+
+// NewClaimsSigner wraps rkentry.SignerJwt, claims of verified token would be validated against
+// required claims, checked with RevocationChecker and parsed into claims created by ClaimsFactory.
+//
+// ClaimsSigner could be passed to rkmidjwt.WithSigner(), parsed claims could be read with rkechoctx.GetJwtClaims().
+func NewClaimsSigner(signer rkentry.SignerJwt, opts ...ClaimsOption) *ClaimsSigner {
+	res := &ClaimsSigner{
+		SignerJwt:      signer,
+		requiredClaims: make([]string, 0),
+	}
+
+	for i := range opts {
+		opts[i](res)
+	}
+
+	return res
+}
+
+// ClaimsSigner is a rkentry.SignerJwt which parses claims into user defined struct after signature verified
+type ClaimsSigner struct {
+	rkentry.SignerJwt
+	factory        ClaimsFactory
+	requiredClaims []string
+	revocation     RevocationChecker
+}
+
+// VerifyJwt verify jwt with wrapped signer, validate required claims and parse claims with ClaimsFactory
+func (s *ClaimsSigner) VerifyJwt(raw string) (*jwt.Token, error) {
+	token, err := s.SignerJwt.VerifyJwt(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := claimsToMap(token.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// refresh token could only be exchanged by TokenRefresher
+	if claims[ClaimTokenUse] == TokenUseRefresh {
+		return nil, errors.New("refresh token is not accepted")
+	}
+
+	for _, name := range s.requiredClaims {
+		if v, ok := claims[name]; !ok || v == nil || v == "" {
+			return nil, fmt.Errorf("missing required jwt claim %s", name)
+		}
+	}
+
+	if s.revocation != nil {
+		jti, sub, iat := revocationKeys(claims)
+		revoked, err := s.revocation.IsRevoked(jti, sub, iat)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, errors.New("revoked jwt")
+		}
+	}
+
+	if s.factory != nil {
+		custom := s.factory()
+
+		bytes, _ := json.Marshal(claims)
+		if err := json.Unmarshal(bytes, custom); err != nil {
+			return nil, err
+		}
+
+		if err := custom.Valid(); err != nil {
+			return nil, err
+		}
+
+		token.Claims = custom
+	}
+
+	return token, nil
+}
+
+// revocationKeys returns jti, sub and iat claims
+func revocationKeys(claims jwt.MapClaims) (string, string, time.Time) {
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+
+	var iat time.Time
+	if v, ok := claims["iat"].(float64); ok {
+		iat = time.Unix(int64(v), 0)
+	} else if v, ok := claims["iat"].(json.Number); ok {
+		if i, err := v.Int64(); err == nil {
+			iat = time.Unix(i, 0)
+		}
+	}
+
+	return jti, sub, iat
+}
+
+// claimsToMap converts jwt.Claims into jwt.MapClaims
+func claimsToMap(claims jwt.Claims) (jwt.MapClaims, error) {
+	if v, ok := claims.(jwt.MapClaims); ok {
+		return v, nil
+	}
+
+	res := jwt.MapClaims{}
+	bytes, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, json.Unmarshal(bytes, &res)
+}
+
+// ***************** Option *****************
+
+// ClaimsOption is for ClaimsSigner options while creating signer
+type ClaimsOption func(*ClaimsSigner)
+
+// WithClaimsFactory provide ClaimsFactory, claims would be kept as jwt.MapClaims if not provided.
+func WithClaimsFactory(factory ClaimsFactory) ClaimsOption {
+	return func(s *ClaimsSigner) {
+		s.factory = factory
+	}
+}
+
+// WithRequiredClaims provide names of claims which must present with non-empty value.
+func WithRequiredClaims(names ...string) ClaimsOption {
+	return func(s *ClaimsSigner) {
+		for i := range names {
+			if len(names[i]) > 0 {
+				s.requiredClaims = append(s.requiredClaims, names[i])
+			}
+		}
+	}
+}
+
+// WithRevocationChecker provide RevocationChecker which is consulted after signature verified.
+func WithRevocationChecker(checker RevocationChecker) ClaimsOption {
+	return func(s *ClaimsSigner) {
+		s.revocation = checker
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type utClaims struct {
+	jwt.RegisteredClaims
+	Tenant string `json:"tenant"`
+}
+
+func (c *utClaims) Valid() error {
+	if c.Tenant == "invalid" {
+		return errors.New("invalid tenant")
+	}
+	return c.RegisteredClaims.Valid()
+}
+
+func TestClaimsSigner_VerifyJwt(t *testing.T) {
+	base := rkentry.RegisterSymmetricJwtSigner("ut-claims", jwt.SigningMethodHS256.Name, []byte("ut-key"))
+	signer := NewClaimsSigner(base,
+		WithClaimsFactory(func() jwt.Claims { return &utClaims{} }),
+		WithRequiredClaims("sub", "tenant", ""))
+	assert.Len(t, signer.requiredClaims, 2)
+
+	// case 1: happy case
+	raw, _ := base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "tenant": "ut-tenant"})
+	token, err := signer.VerifyJwt(raw)
+	assert.Nil(t, err)
+	claims, ok := token.Claims.(*utClaims)
+	assert.True(t, ok)
+	assert.Equal(t, "ut-sub", claims.Subject)
+	assert.Equal(t, "ut-tenant", claims.Tenant)
+
+	// case 2: missing required claim
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "tenant": ""})
+	_, err = signer.VerifyJwt(raw)
+	assert.NotNil(t, err)
+
+	// case 3: claims validation failed
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "tenant": "invalid"})
+	_, err = signer.VerifyJwt(raw)
+	assert.NotNil(t, err)
+
+	// case 4: claims could not be parsed
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "tenant": 1})
+	_, err = signer.VerifyJwt(raw)
+	assert.NotNil(t, err)
+
+	// case 5: invalid signature
+	_, err = signer.VerifyJwt(raw + "x")
+	assert.NotNil(t, err)
+
+	// case 6: without factory
+	signer = NewClaimsSigner(base)
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub"})
+	token, err = signer.VerifyJwt(raw)
+	assert.Nil(t, err)
+	assert.IsType(t, jwt.MapClaims{}, token.Claims)
+
+	// case 7: with revocation
+	store := NewMemoryRevocationStore()
+	signer = NewClaimsSigner(base, WithRevocationChecker(store))
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "jti": "ut-jti", "iat": time.Now().Unix()})
+	_, err = signer.VerifyJwt(raw)
+	assert.Nil(t, err)
+
+	store.RevokeId("ut-jti", time.Now().Add(time.Minute))
+	_, err = signer.VerifyJwt(raw)
+	assert.NotNil(t, err)
+
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub", "iat": time.Now().Add(-time.Minute).Unix()})
+	_, err = signer.VerifyJwt(raw)
+	assert.Nil(t, err)
+	store.RevokeSubject("ut-sub", time.Now())
+	_, err = signer.VerifyJwt(raw)
+	assert.NotNil(t, err)
+}
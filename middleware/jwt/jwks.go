@@ -0,0 +1,469 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJwksRefreshInterval    = time.Hour
+	defaultJwksMinRefreshInterval = time.Minute
+	defaultJwksTimeout            = 5 * time.Second
+)
+
+var (
+	// defaultJwksAlgorithms are asymmetric algorithms accepted while verifying jwt with JWKS
+	defaultJwksAlgorithms = []string{
+		"RS256", "RS384", "RS512",
+		"PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512",
+		"EdDSA",
+	}
+
+	errUntrustedIssuer = errors.New("untrusted jwt issuer")
+	errUnknownKid      = errors.New("unknown jwt kid")
+	errInvalidAudience = errors.New("invalid jwt audience")
+)
+
+// RegisterJwksSigner create JwksSigner and register it into rkentry.GlobalAppCtx.
+//
+// JwksSigner implements rkentry.SignerJwt and could be passed to rkmidjwt.WithSigner().
+func RegisterJwksSigner(entryName string, opts ...JwksOption) *JwksSigner {
+	signer := &JwksSigner{
+		entryName:          entryName,
+		issuers:            make(map[string]*trustedIssuer),
+		algorithms:         defaultJwksAlgorithms,
+		refreshInterval:    defaultJwksRefreshInterval,
+		minRefreshInterval: defaultJwksMinRefreshInterval,
+		client:             &http.Client{Timeout: defaultJwksTimeout},
+	}
+
+	for i := range opts {
+		opts[i](signer)
+	}
+
+	// key sets are shared by issuers with the same JWKS url
+	keySets := make(map[string]*keySet)
+	for _, issuer := range signer.issuers {
+		ks, ok := keySets[issuer.jwksUrl]
+		if !ok {
+			ks = &keySet{
+				url:                issuer.jwksUrl,
+				client:             signer.client,
+				refreshInterval:    signer.refreshInterval,
+				minRefreshInterval: signer.minRefreshInterval,
+				keys:               make(map[string]*jsonWebKey),
+			}
+			keySets[issuer.jwksUrl] = ks
+		}
+		issuer.keys = ks
+	}
+
+	rkentry.GlobalAppCtx.AddEntry(signer)
+
+	return signer
+}
+
+// JwksSigner verifies jwt with public keys fetched from JWKS urls of trusted issuers.
+//
+// Keys are cached and refreshed every refresh interval. Token signed with unknown kid
+// triggers a refresh which is limited to once per min refresh interval.
+type JwksSigner struct {
+	entryName          string
+	issuers            map[string]*trustedIssuer
+	algorithms         []string
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	client             *http.Client
+}
+
+// trustedIssuer is an issuer whose tokens would be accepted
+type trustedIssuer struct {
+	issuer    string
+	jwksUrl   string
+	audiences []string
+	keys      *keySet
+}
+
+// Bootstrap fetch JWKS of trusted issuers in advance, failures would be retried while verifying tokens.
+func (s *JwksSigner) Bootstrap(ctx context.Context) {
+	for _, issuer := range s.issuers {
+		issuer.keys.refresh()
+	}
+}
+
+// Interrupt noop
+func (s *JwksSigner) Interrupt(ctx context.Context) {}
+
+// GetName returns entry name
+func (s *JwksSigner) GetName() string {
+	return s.entryName
+}
+
+// GetType returns entry type
+func (s *JwksSigner) GetType() string {
+	return rkentry.SignerJwtEntryType
+}
+
+// GetDescription returns entry description
+func (s *JwksSigner) GetDescription() string {
+	return "JWKS jwt signer"
+}
+
+// String print entry as string
+func (s *JwksSigner) String() string {
+	issuers := make([]string, 0)
+	for k := range s.issuers {
+		issuers = append(issuers, k)
+	}
+	sort.Strings(issuers)
+
+	m := map[string]string{
+		"name":                s.entryName,
+		"issuers":             strings.Join(issuers, ","),
+		"supportedAlgorithms": strings.Join(s.Algorithms(), ","),
+	}
+
+	bytes, _ := json.Marshal(m)
+	return string(bytes)
+}
+
+// SignJwt is not supported since JWKS only contains public keys
+func (s *JwksSigner) SignJwt(jwt.Claims) (string, error) {
+	return "", errors.New("jwks signer does not support signing")
+}
+
+// VerifyJwt verify jwt with key of issuer and kid, audience would be validated if configured
+func (s *JwksSigner) VerifyJwt(raw string) (*jwt.Token, error) {
+	var issuer *trustedIssuer
+
+	parser := jwt.NewParser(jwt.WithValidMethods(s.algorithms))
+	token, err := parser.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		claims, _ := t.Claims.(jwt.MapClaims)
+		iss, _ := claims["iss"].(string)
+
+		if issuer = s.issuers[iss]; issuer == nil {
+			return nil, errUntrustedIssuer
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		return issuer.keys.get(kid, t.Method)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if len(issuer.audiences) > 0 {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		matched := false
+		for i := range issuer.audiences {
+			if claims.VerifyAudience(issuer.audiences[i], true) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, errInvalidAudience
+		}
+	}
+
+	return token, nil
+}
+
+// PubKey returns nil since there could be multiple keys
+func (s *JwksSigner) PubKey() []byte {
+	return nil
+}
+
+// Algorithms returns accepted algorithms
+func (s *JwksSigner) Algorithms() []string {
+	return s.algorithms
+}
+
+// ***************** Key Set *****************
+
+// keySet caches keys fetched from JWKS url.
+//
+// Lock only guards cached keys, JWKS document is fetched without lock held,
+// concurrent refreshes share the same in-flight fetch.
+type keySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	lock               sync.Mutex
+	keys               map[string]*jsonWebKey
+	fetchedAt          time.Time
+	lastAttempt        time.Time
+	inflight           *refreshCall
+}
+
+// refreshCall is an in-flight fetch of JWKS document, done would be closed once finished
+type refreshCall struct {
+	done chan struct{}
+	ok   bool
+}
+
+// get returns key matches kid and signing method, keys would be refreshed if expired or kid is unknown.
+//
+// Expired keys would be refreshed in background if any key was cached, so that verification is not blocked by JWKS url.
+func (ks *keySet) get(kid string, method jwt.SigningMethod) (interface{}, error) {
+	ks.lock.Lock()
+	expired := ks.refreshInterval > 0 && time.Since(ks.fetchedAt) > ks.refreshInterval
+	cached := len(ks.keys) > 0
+	ks.lock.Unlock()
+
+	if expired {
+		if cached {
+			go ks.refresh()
+		} else {
+			ks.refresh()
+		}
+	}
+
+	key := ks.lookup(kid, method)
+	if key == nil && ks.refresh() {
+		key = ks.lookup(kid, method)
+	}
+
+	if key == nil {
+		return nil, errUnknownKid
+	}
+
+	if len(key.alg) > 0 && key.alg != method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing algorithm=%s for kid=%s", method.Alg(), kid)
+	}
+
+	return key.key, nil
+}
+
+// lookup returns key by kid, the only compatible key would be returned if kid is empty
+func (ks *keySet) lookup(kid string, method jwt.SigningMethod) *jsonWebKey {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+
+	if len(kid) > 0 {
+		if key, ok := ks.keys[kid]; ok && key.compatible(method) {
+			return key
+		}
+		return nil
+	}
+
+	var res *jsonWebKey
+	for _, key := range ks.keys {
+		if key.compatible(method) {
+			if res != nil {
+				return nil
+			}
+			res = key
+		}
+	}
+
+	return res
+}
+
+// refresh fetch keys from url, returns false if rate limited or failed.
+// Caller would wait for in-flight fetch if exists and share the result of it.
+func (ks *keySet) refresh() bool {
+	ks.lock.Lock()
+	if call := ks.inflight; call != nil {
+		ks.lock.Unlock()
+		<-call.done
+		return call.ok
+	}
+
+	if !ks.lastAttempt.IsZero() && time.Since(ks.lastAttempt) < ks.minRefreshInterval {
+		ks.lock.Unlock()
+		return false
+	}
+	ks.lastAttempt = time.Now()
+
+	call := &refreshCall{done: make(chan struct{})}
+	ks.inflight = call
+	ks.lock.Unlock()
+
+	keys, err := fetchJwks(ks.client, ks.url)
+
+	ks.lock.Lock()
+	if err == nil {
+		ks.keys = keys
+		ks.fetchedAt = time.Now()
+	}
+	call.ok = err == nil
+	ks.inflight = nil
+	ks.lock.Unlock()
+
+	close(call.done)
+	return call.ok
+}
+
+// fetchJwks fetch and parse JWKS document, keys with unsupported type or usage are skipped
+func fetchJwks(client *http.Client, url string) (map[string]*jsonWebKey, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+
+	doc := struct {
+		Keys []rawJsonWebKey `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*jsonWebKey)
+	for i := range doc.Keys {
+		raw := doc.Keys[i]
+		if len(raw.Use) > 0 && raw.Use != "sig" {
+			continue
+		}
+
+		key, err := raw.parse()
+		if err != nil {
+			continue
+		}
+
+		res[raw.Kid] = &jsonWebKey{
+			alg: raw.Alg,
+			key: key,
+		}
+	}
+
+	return res, nil
+}
+
+// ***************** JSON Web Key *****************
+
+// rawJsonWebKey is a public key in JWKS document, see RFC 7517
+type rawJsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parse converts json web key into *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+func (raw *rawJsonWebKey) parse() (interface{}, error) {
+	switch raw.Kty {
+	case "RSA":
+		n, err := decodeBigInt(raw.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(raw.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch raw.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", raw.Crv)
+		}
+
+		x, err := decodeBigInt(raw.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(raw.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if raw.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", raw.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(raw.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", raw.Kty)
+}
+
+// jsonWebKey is a parsed public key
+type jsonWebKey struct {
+	alg string
+	key interface{}
+}
+
+// compatible returns true if key could be used to verify signing method
+func (k *jsonWebKey) compatible(method jwt.SigningMethod) bool {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := k.key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := k.key.(*ecdsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodEd25519:
+		_, ok := k.key.(ed25519.PublicKey)
+		return ok
+	}
+
+	return false
+}
+
+// decodeBigInt decodes base64url encoded big-endian integer
+func decodeBigInt(str string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes) < 1 {
+		return nil, errors.New("empty integer")
+	}
+
+	return new(big.Int).SetBytes(bytes), nil
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksStub serves JWKS document with keys which could be rotated
+type jwksStub struct {
+	lock   sync.Mutex
+	keys   []map[string]string
+	hits   int32
+	server *httptest.Server
+}
+
+func newJwksStub() *jwksStub {
+	stub := &jwksStub{}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stub.hits, 1)
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": stub.keys})
+	}))
+	return stub
+}
+
+func (stub *jwksStub) setKeys(keys ...map[string]string) {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	stub.keys = keys
+}
+
+func rsaJwk(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if len(kid) > 0 {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return raw
+}
+
+func TestJwksSigner_VerifyJwt(t *testing.T) {
+	stub := newJwksStub()
+	defer stub.server.Close()
+
+	key1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	stub.setKeys(rsaJwk("kid-1", key1))
+
+	signer := RegisterJwksSigner("ut-jwks",
+		WithTrustedIssuer("https://idp-a", stub.server.URL, "api"),
+		WithTrustedIssuer("https://idp-b", stub.server.URL))
+	exp := time.Now().Add(time.Minute).Unix()
+
+	// case 1: happy case
+	token, err := signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key1,
+		jwt.MapClaims{"iss": "https://idp-a", "aud": []string{"web", "api"}, "exp": exp}))
+	assert.Nil(t, err)
+	assert.True(t, token.Valid)
+
+	// case 2: second issuer without audience restriction
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key1,
+		jwt.MapClaims{"iss": "https://idp-b", "exp": exp}))
+	assert.Nil(t, err)
+
+	// case 3: untrusted issuer
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key1,
+		jwt.MapClaims{"iss": "https://idp-c", "exp": exp}))
+	assert.NotNil(t, err)
+
+	// case 4: invalid audience
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key1,
+		jwt.MapClaims{"iss": "https://idp-a", "aud": "web", "exp": exp}))
+	assert.Equal(t, errInvalidAudience, err)
+
+	// case 5: expired
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key1,
+		jwt.MapClaims{"iss": "https://idp-b", "exp": time.Now().Add(-time.Minute).Unix()}))
+	assert.NotNil(t, err)
+
+	// case 6: signed by another key with known kid
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-1", key2,
+		jwt.MapClaims{"iss": "https://idp-b", "exp": exp}))
+	assert.NotNil(t, err)
+
+	// case 7: symmetric algorithm is not accepted
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodHS256, "kid-1", []byte("ut-key"),
+		jwt.MapClaims{"iss": "https://idp-b", "exp": exp}))
+	assert.NotNil(t, err)
+
+	// issuers share the same key set
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stub.hits))
+}
+
+func TestJwksSigner_KeyRotation(t *testing.T) {
+	stub := newJwksStub()
+	defer stub.server.Close()
+
+	key1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	stub.setKeys(rsaJwk("kid-1", key1))
+
+	signer := RegisterJwksSigner("ut-jwks",
+		WithTrustedIssuer("https://idp", stub.server.URL),
+		WithJwksMinRefreshInterval(time.Hour))
+	signer.Bootstrap(nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stub.hits))
+
+	claims := jwt.MapClaims{"iss": "https://idp"}
+
+	// case 1: without kid, the only key would be used
+	_, err := signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "", key1, claims))
+	assert.Nil(t, err)
+
+	// case 2: rotated key could not be fetched within min refresh interval
+	stub.setKeys(rsaJwk("kid-1", key1), rsaJwk("kid-2", key2))
+	raw := sign(t, jwt.SigningMethodRS256, "kid-2", key2, claims)
+	_, err = signer.VerifyJwt(raw)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stub.hits))
+	assert.NotNil(t, err)
+
+	// case 3: unknown kid triggers refresh once min refresh interval elapsed
+	ks := signer.issuers["https://idp"].keys
+	ks.lastAttempt = time.Now().Add(-2 * time.Hour)
+	_, err = signer.VerifyJwt(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&stub.hits))
+
+	// case 4: unknown kid again, rate limited
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "kid-3", key2, claims))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&stub.hits))
+
+	// case 5: without kid and multiple keys
+	_, err = signer.VerifyJwt(sign(t, jwt.SigningMethodRS256, "", key1, claims))
+	assert.NotNil(t, err)
+}
+
+func TestKeySet_Refresh(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJwk("kid-1", key)}})
+	}))
+	defer server.Close()
+
+	ks := &keySet{
+		url:                server.URL,
+		client:             server.Client(),
+		refreshInterval:    time.Hour,
+		minRefreshInterval: time.Hour,
+	}
+	assert.True(t, ks.refresh())
+
+	// expired keys are refreshed in background, cached key is returned without waiting for slow JWKS url
+	ks.lock.Lock()
+	ks.fetchedAt = time.Now().Add(-2 * time.Hour)
+	ks.lastAttempt = ks.fetchedAt
+	ks.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := ks.get("kid-1", jwt.SigningMethodRS256)
+		assert.Nil(t, err)
+		assert.NotNil(t, res)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "verification blocked by JWKS url")
+	}
+
+	// concurrent refreshes share the in-flight fetch
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&hits) == 2
+	}, time.Second, time.Millisecond)
+
+	results := make(chan bool, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			results <- ks.refresh()
+		}()
+	}
+
+	// callers arrive while fetch is in-flight, later callers would be rate limited
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 5; i++ {
+		assert.True(t, <-results)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestRawJsonWebKey_Parse(t *testing.T) {
+	// ecdsa
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	raw := &rawJsonWebKey{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+	}
+	key, err := raw.parse()
+	assert.Nil(t, err)
+	assert.True(t, (&jsonWebKey{key: key}).compatible(jwt.SigningMethodES256))
+	assert.False(t, (&jsonWebKey{key: key}).compatible(jwt.SigningMethodRS256))
+
+	// ed25519
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	raw = &rawJsonWebKey{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+	}
+	key, err = raw.parse()
+	assert.Nil(t, err)
+	assert.True(t, (&jsonWebKey{key: key}).compatible(jwt.SigningMethodEdDSA))
+
+	// unsupported
+	_, err = (&rawJsonWebKey{Kty: "oct"}).parse()
+	assert.NotNil(t, err)
+	_, err = (&rawJsonWebKey{Kty: "EC", Crv: "P-192"}).parse()
+	assert.NotNil(t, err)
+	_, err = (&rawJsonWebKey{Kty: "RSA", N: "!", E: "AQAB"}).parse()
+	assert.NotNil(t, err)
+}
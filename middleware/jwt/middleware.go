@@ -16,6 +16,8 @@ import (
 //
 // Mainly copied from bellow.
 // https://github.com/labstack/echo/blob/master/middleware/jwt.go
+//
+// Tokens issued by IdP could be verified with JwksSigner, see RegisterJwksSigner() and ToOptions().
 func Middleware(opts ...rkmidjwt.Option) echo.MiddlewareFunc {
 	set := rkmidjwt.NewOptionSet(opts...)
 
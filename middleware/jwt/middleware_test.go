@@ -7,7 +7,12 @@ package rkechojwt
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
 	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/jwt"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +20,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 var userHandler = func(ctx echo.Context) error {
@@ -41,6 +47,104 @@ func TestMiddleware(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestMiddleware_WithJwks(t *testing.T) {
+	stub := newJwksStub()
+	defer stub.server.Close()
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	stub.setKeys(rsaJwk("kid-1", key))
+
+	config := &BootConfig{}
+	config.Enabled = true
+	config.Jwks.Issuers = []JwksIssuer{
+		{Issuer: "https://idp", JwksUrl: stub.server.URL, Audiences: []string{"api"}},
+	}
+	inter := Middleware(ToOptions(config, "ut-jwks-entry", "ut-type")...)
+
+	// case 1: without token
+	ctx, w := newCtx()
+	inter(userHandler)(ctx)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// case 2: with token signed by key in JWKS
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderAuthorization, "Bearer "+sign(t, jwt.SigningMethodRS256, "kid-1", key,
+		jwt.MapClaims{"iss": "https://idp", "aud": "api", "exp": time.Now().Add(time.Minute).Unix()}))
+	inter(userHandler)(ctx)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, ctx.Get(rkmid.JwtTokenKey.String()))
+
+	// case 3: with token of another audience
+	ctx, w = newCtx()
+	ctx.Request().Header.Set(rkmid.HeaderAuthorization, "Bearer "+sign(t, jwt.SigningMethodRS256, "kid-1", key,
+		jwt.MapClaims{"iss": "https://idp", "aud": "web", "exp": time.Now().Add(time.Minute).Unix()}))
+	inter(userHandler)(ctx)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMiddleware_WithRevocation(t *testing.T) {
+	config := &BootConfig{}
+	config.Enabled = true
+	config.Symmetric = &rkmidjwt.SymmetricConfig{
+		Algorithm: jwt.SigningMethodHS256.Name,
+		Token:     "ut-key",
+	}
+	config.Revocation.Enabled = true
+	inter := Middleware(ToOptions(config, "ut-revocation-entry", "ut-type")...)
+
+	raw, err := rkentry.GlobalAppCtx.GetSignerJwtEntry("ut-revocation-entry").SignJwt(jwt.MapClaims{
+		"jti": "ut-jti",
+		"sub": "ut-sub",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	assert.Nil(t, err)
+
+	serve := func() int {
+		ctx, w := newCtx()
+		ctx.Request().Header.Set(rkmid.HeaderAuthorization, "Bearer "+raw)
+		inter(userHandler)(ctx)
+		return w.Code
+	}
+
+	// case 1: before revoked
+	assert.Equal(t, http.StatusOK, serve())
+
+	// case 2: revoked with store of entry
+	store := GetRevocationStore("ut-revocation-entry")
+	assert.NotNil(t, store)
+	assert.Nil(t, store.RevokeId("ut-jti", time.Now().Add(time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, serve())
+}
+
+func TestMiddleware_WithClaimsFactory(t *testing.T) {
+	base := rkentry.RegisterSymmetricJwtSigner("ut-claims-entry", jwt.SigningMethodHS256.Name, []byte("ut-key"))
+	inter := Middleware(rkmidjwt.WithSigner(NewClaimsSigner(base,
+		WithClaimsFactory(func() jwt.Claims { return &utClaims{} }),
+		WithRequiredClaims("tenant"))))
+
+	handler := func(ctx echo.Context) error {
+		claims, err := rkechoctx.GetJwtClaims[*utClaims](ctx)
+		if err != nil {
+			return err
+		}
+		return ctx.String(http.StatusOK, claims.Tenant)
+	}
+
+	// case 1: with required claim
+	ctx, w := newCtx()
+	raw, _ := base.SignJwt(jwt.MapClaims{"tenant": "ut-tenant"})
+	ctx.Request().Header.Set(rkmid.HeaderAuthorization, "Bearer "+raw)
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "ut-tenant", w.Body.String())
+
+	// case 2: without required claim
+	ctx, w = newCtx()
+	raw, _ = base.SignJwt(jwt.MapClaims{"sub": "ut-sub"})
+	ctx.Request().Header.Set(rkmid.HeaderAuthorization, "Bearer "+raw)
+	inter(handler)(ctx)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
 	var buf bytes.Buffer
 	req := httptest.NewRequest(http.MethodGet, "/ut-path", &buf)
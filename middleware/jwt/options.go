@@ -0,0 +1,210 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/jwt"
+	"net/http"
+	"time"
+)
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidjwt.BootConfig with JWKS
+type BootConfig struct {
+	rkmidjwt.BootConfig `yaml:",inline" mapstructure:",squash"`
+	Jwks                JwksConfig       `yaml:"jwks" json:"jwks"`
+	RequiredClaims      []string         `yaml:"requiredClaims" json:"requiredClaims"`
+	Revocation          RevocationConfig `yaml:"revocation" json:"revocation"`
+	Refresh             RefreshConfig    `yaml:"refresh" json:"refresh"`
+}
+
+// RevocationConfig for YAML, tokens would be checked with in-memory RevocationStore if enabled
+type RevocationConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// RefreshConfig for YAML, refresh endpoint would be registered into EchoEntry if enabled
+type RefreshConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	Path         string `yaml:"path" json:"path"`
+	AccessTtlMs  int    `yaml:"accessTtlMs" json:"accessTtlMs"`
+	RefreshTtlMs int    `yaml:"refreshTtlMs" json:"refreshTtlMs"`
+}
+
+// JwksConfig for YAML
+type JwksConfig struct {
+	Issuers              []JwksIssuer `yaml:"issuers" json:"issuers"`
+	Algorithms           []string     `yaml:"algorithms" json:"algorithms"`
+	RefreshIntervalMs    int          `yaml:"refreshIntervalMs" json:"refreshIntervalMs"`
+	MinRefreshIntervalMs int          `yaml:"minRefreshIntervalMs" json:"minRefreshIntervalMs"`
+	TimeoutMs            int          `yaml:"timeoutMs" json:"timeoutMs"`
+}
+
+// JwksIssuer is a trusted issuer and url of its JWKS document
+type JwksIssuer struct {
+	Issuer    string   `yaml:"issuer" json:"issuer"`
+	JwksUrl   string   `yaml:"jwksUrl" json:"jwksUrl"`
+	Audiences []string `yaml:"audiences" json:"audiences"`
+}
+
+// ToOptions convert BootConfig into rkmidjwt.Option list.
+//
+// JwksSigner would be registered and used instead of static keys if any of JWKS issuers configured.
+// Signer would be wrapped with ClaimsSigner if required claims, revocation or refresh configured,
+// TokenRefresher registered with refresh enabled could be retrieved with GetTokenRefresher(),
+// RevocationStore registered with revocation or refresh enabled could be retrieved with GetRevocationStore().
+//
+// Refresh requires signing keys configured with signerEntry, symmetric or asymmetric,
+// the default key of rkmidjwt is publicly known and would never be used to issue tokens.
+func ToOptions(config *BootConfig, entryName, entryType string) []rkmidjwt.Option {
+	opts := rkmidjwt.ToOptions(&config.BootConfig, entryName, entryType)
+
+	if !config.Enabled {
+		return opts
+	}
+
+	var signer rkentry.SignerJwt
+	if len(config.Jwks.Issuers) > 0 {
+		jwksOpts := []JwksOption{
+			WithJwksAlgorithms(config.Jwks.Algorithms...),
+			WithJwksRefreshInterval(time.Duration(config.Jwks.RefreshIntervalMs) * time.Millisecond),
+			WithJwksMinRefreshInterval(time.Duration(config.Jwks.MinRefreshIntervalMs) * time.Millisecond),
+		}
+
+		if config.Jwks.TimeoutMs > 0 {
+			jwksOpts = append(jwksOpts, WithJwksHttpClient(&http.Client{
+				Timeout: time.Duration(config.Jwks.TimeoutMs) * time.Millisecond,
+			}))
+		}
+
+		for _, issuer := range config.Jwks.Issuers {
+			jwksOpts = append(jwksOpts, WithTrustedIssuer(issuer.Issuer, issuer.JwksUrl, issuer.Audiences...))
+		}
+
+		signer = RegisterJwksSigner(entryName, jwksOpts...)
+		opts = append(opts, rkmidjwt.WithSigner(signer))
+	}
+
+	if config.SkipVerify {
+		return opts
+	}
+
+	claimsOpts := []ClaimsOption{
+		WithRequiredClaims(config.RequiredClaims...),
+	}
+
+	var store RevocationStore
+	if config.Revocation.Enabled || config.Refresh.Enabled {
+		store = NewMemoryRevocationStore()
+		claimsOpts = append(claimsOpts, WithRevocationChecker(store))
+
+		revocationStoresLock.Lock()
+		revocationStores[entryName] = store
+		revocationStoresLock.Unlock()
+	}
+
+	if store == nil && len(config.RequiredClaims) < 1 {
+		return opts
+	}
+
+	// signer registered by rkmidjwt.ToOptions()
+	if signer == nil {
+		signer = rkentry.GlobalAppCtx.GetSignerJwtEntry(config.SignerEntry)
+	}
+	if signer == nil && (config.Asymmetric != nil || config.Symmetric != nil) {
+		signer = rkentry.GlobalAppCtx.GetSignerJwtEntry(entryName)
+	}
+	if config.Refresh.Enabled && signer == nil {
+		rkentry.ShutdownWithError(errors.New("jwt refresh requires signing keys, configure signerEntry, symmetric or asymmetric"))
+	}
+	// same as default signer of rkmidjwt
+	if signer == nil {
+		signer = rkentry.RegisterSymmetricJwtSigner(entryName, jwt.SigningMethodHS256.Name, []byte("rk jwt key"))
+	}
+
+	if config.Refresh.Enabled {
+		if _, ok := signer.(*JwksSigner); ok {
+			rkentry.ShutdownWithError(errors.New("jwt refresh requires signing keys, JWKS is not supported"))
+		}
+
+		refresher := NewTokenRefresher(signer,
+			WithRefreshPath(config.Refresh.Path),
+			WithAccessTtl(time.Duration(config.Refresh.AccessTtlMs)*time.Millisecond),
+			WithRefreshTtl(time.Duration(config.Refresh.RefreshTtlMs)*time.Millisecond),
+			WithRevocationStore(store))
+
+		refreshersLock.Lock()
+		refreshers[entryName] = refresher
+		refreshersLock.Unlock()
+
+		// refresh endpoint verifies refresh token by itself
+		opts = append(opts, rkmidjwt.WithPathToIgnore(refresher.Path()))
+	}
+
+	opts = append(opts, rkmidjwt.WithSigner(NewClaimsSigner(signer, claimsOpts...)))
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// JwksOption is for JwksSigner options while creating signer
+type JwksOption func(*JwksSigner)
+
+// WithTrustedIssuer provide issuer whose tokens would be verified with keys from jwksUrl.
+// Audience claim should contain any of audiences if provided.
+func WithTrustedIssuer(issuer, jwksUrl string, audiences ...string) JwksOption {
+	return func(s *JwksSigner) {
+		if len(issuer) > 0 && len(jwksUrl) > 0 {
+			s.issuers[issuer] = &trustedIssuer{
+				issuer:    issuer,
+				jwksUrl:   jwksUrl,
+				audiences: audiences,
+			}
+		}
+	}
+}
+
+// WithJwksAlgorithms provide accepted signing algorithms, default: RS*, PS*, ES* and EdDSA.
+func WithJwksAlgorithms(algorithms ...string) JwksOption {
+	return func(s *JwksSigner) {
+		if len(algorithms) > 0 {
+			s.algorithms = algorithms
+		}
+	}
+}
+
+// WithJwksRefreshInterval provide interval of refreshing cached keys, default: 1 hour.
+func WithJwksRefreshInterval(interval time.Duration) JwksOption {
+	return func(s *JwksSigner) {
+		if interval > 0 {
+			s.refreshInterval = interval
+		}
+	}
+}
+
+// WithJwksMinRefreshInterval provide min interval between two fetches of the same JWKS url,
+// which limits refreshes triggered by unknown kid, default: 1 minute.
+func WithJwksMinRefreshInterval(interval time.Duration) JwksOption {
+	return func(s *JwksSigner) {
+		if interval > 0 {
+			s.minRefreshInterval = interval
+		}
+	}
+}
+
+// WithJwksHttpClient provide http client used for fetching JWKS, default timeout is 5 seconds.
+func WithJwksHttpClient(client *http.Client) JwksOption {
+	return func(s *JwksSigner) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalBootConfig(t *testing.T) {
+	raw := `
+enabled: true
+ignore: ["/ut-ignore"]
+jwks:
+  refreshIntervalMs: 1000
+  issuers:
+    - issuer: "https://idp"
+      jwksUrl: "https://idp/jwks"
+      audiences: ["api"]
+`
+	config := &BootConfig{}
+	rkentry.UnmarshalBootYAML([]byte(raw), config)
+
+	assert.True(t, config.Enabled)
+	assert.Equal(t, []string{"/ut-ignore"}, config.Ignore)
+	assert.Equal(t, 1000, config.Jwks.RefreshIntervalMs)
+	assert.Len(t, config.Jwks.Issuers, 1)
+	assert.Equal(t, "https://idp/jwks", config.Jwks.Issuers[0].JwksUrl)
+	assert.Equal(t, []string{"api"}, config.Jwks.Issuers[0].Audiences)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	opts := ToOptions(config, "ut-entry", "ut-type")
+	assert.NotEmpty(t, opts)
+
+	// with jwks
+	config.Jwks = JwksConfig{
+		Issuers: []JwksIssuer{
+			{Issuer: "https://idp", JwksUrl: "https://idp/jwks"},
+		},
+		TimeoutMs: 1000,
+	}
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type"), len(opts)+1)
+	signer, ok := rkentry.GlobalAppCtx.GetSignerJwtEntry("ut-entry").(*JwksSigner)
+	assert.True(t, ok)
+	assert.Len(t, signer.issuers, 1)
+	assert.Equal(t, time.Second, signer.client.Timeout)
+
+	// with required claims
+	config.Jwks = JwksConfig{}
+	config.RequiredClaims = []string{"sub"}
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type"), len(opts)+1)
+
+	// with refresh without signing keys
+	config.Refresh = RefreshConfig{
+		Enabled:     true,
+		Path:        "/ut-refresh",
+		AccessTtlMs: 1000,
+	}
+	assert.Panics(t, func() {
+		ToOptions(config, "ut-entry", "ut-type")
+	})
+
+	// with refresh
+	config.Symmetric = &rkmidjwt.SymmetricConfig{
+		Algorithm: jwt.SigningMethodHS256.Name,
+		Token:     "ut-key",
+	}
+	assert.Len(t, ToOptions(config, "ut-entry", "ut-type"), len(opts)+2)
+	refresher := GetTokenRefresher("ut-entry")
+	assert.NotNil(t, refresher)
+	assert.Equal(t, "/ut-refresh", refresher.Path())
+	assert.Equal(t, time.Second, refresher.accessTtl)
+	assert.Equal(t, defaultRefreshTtl, refresher.refreshTtl)
+	assert.Equal(t, refresher.Store(), GetRevocationStore("ut-entry"))
+}
+
+func TestRegisterJwksSigner(t *testing.T) {
+	// without options
+	signer := RegisterJwksSigner("ut-jwks")
+	assert.Empty(t, signer.issuers)
+	assert.Equal(t, defaultJwksAlgorithms, signer.Algorithms())
+	assert.Equal(t, defaultJwksRefreshInterval, signer.refreshInterval)
+	assert.Equal(t, defaultJwksMinRefreshInterval, signer.minRefreshInterval)
+	assert.Nil(t, signer.PubKey())
+	assert.NotEmpty(t, signer.String())
+	_, err := signer.SignJwt(nil)
+	assert.NotNil(t, err)
+
+	// with options
+	client := &http.Client{}
+	signer = RegisterJwksSigner("ut-jwks",
+		WithTrustedIssuer("https://idp-a", "https://idp/jwks", "api"),
+		WithTrustedIssuer("https://idp-b", "https://idp/jwks"),
+		WithTrustedIssuer("", "https://idp/jwks"),
+		WithJwksAlgorithms("RS256"),
+		WithJwksRefreshInterval(time.Second),
+		WithJwksMinRefreshInterval(time.Millisecond),
+		WithJwksHttpClient(client))
+	assert.Len(t, signer.issuers, 2)
+	assert.Equal(t, signer.issuers["https://idp-a"].keys, signer.issuers["https://idp-b"].keys)
+	assert.Equal(t, []string{"RS256"}, signer.Algorithms())
+	assert.Equal(t, time.Second, signer.refreshInterval)
+	assert.Equal(t, time.Millisecond, signer.minRefreshInterval)
+	assert.Equal(t, client, signer.client)
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// ClaimTokenUse is the claim which distinguishes refresh token from access token
+	ClaimTokenUse = "token_use"
+	// TokenUseAccess is the value of ClaimTokenUse in access token
+	TokenUseAccess = "access"
+	// TokenUseRefresh is the value of ClaimTokenUse in refresh token
+	TokenUseRefresh = "refresh"
+
+	defaultRefreshPath = "/rk/v1/token/refresh"
+	defaultAccessTtl   = 15 * time.Minute
+	defaultRefreshTtl  = 7 * 24 * time.Hour
+)
+
+var (
+	refreshersLock sync.Mutex
+	refreshers     = make(map[string]*TokenRefresher)
+
+	// registered claims which would be regenerated while issuing tokens
+	registeredClaims = []string{"exp", "iat", "nbf", "jti", ClaimTokenUse}
+)
+
+// GetTokenRefresher returns TokenRefresher registered by ToOptions() with entry name
+func GetTokenRefresher(entryName string) *TokenRefresher {
+	refreshersLock.Lock()
+	defer refreshersLock.Unlock()
+
+	return refreshers[entryName]
+}
+
+// NewTokenRefresher create TokenRefresher which issues and exchanges tokens signed by signer.
+//
+// Refresh token is rotated on every exchange, previous refresh token would be revoked in RevocationStore.
+func NewTokenRefresher(signer rkentry.SignerJwt, opts ...RefreshOption) *TokenRefresher {
+	res := &TokenRefresher{
+		signer:     signer,
+		store:      NewMemoryRevocationStore(),
+		path:       defaultRefreshPath,
+		accessTtl:  defaultAccessTtl,
+		refreshTtl: defaultRefreshTtl,
+	}
+
+	for i := range opts {
+		opts[i](res)
+	}
+
+	return res
+}
+
+// TokenRefresher issues access and refresh token pair, and exchanges refresh token for a new pair
+type TokenRefresher struct {
+	signer     rkentry.SignerJwt
+	store      RevocationStore
+	path       string
+	accessTtl  time.Duration
+	refreshTtl time.Duration
+}
+
+// TokenResponse is the response of token exchange
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" yaml:"access_token"`
+	TokenType    string `json:"token_type" yaml:"token_type"`
+	ExpiresIn    int64  `json:"expires_in" yaml:"expires_in"`
+	RefreshToken string `json:"refresh_token" yaml:"refresh_token"`
+}
+
+// Path returns path of refresh endpoint
+func (r *TokenRefresher) Path() string {
+	return r.path
+}
+
+// Store returns RevocationStore
+func (r *TokenRefresher) Store() RevocationStore {
+	return r.store
+}
+
+// Issue sign access and refresh token with claims, registered claims like exp, iat and jti would be overridden
+func (r *TokenRefresher) Issue(claims jwt.MapClaims) (*TokenResponse, error) {
+	now := time.Now()
+
+	access := copyClaims(claims)
+	access["iat"] = now.Unix()
+	access["exp"] = now.Add(r.accessTtl).Unix()
+	access["jti"] = xid.New().String()
+	access[ClaimTokenUse] = TokenUseAccess
+
+	refresh := copyClaims(claims)
+	refresh["iat"] = now.Unix()
+	refresh["exp"] = now.Add(r.refreshTtl).Unix()
+	refresh["jti"] = xid.New().String()
+	refresh[ClaimTokenUse] = TokenUseRefresh
+
+	accessToken, err := r.signer.SignJwt(access)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := r.signer.SignJwt(refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(r.accessTtl.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Refresh verify refresh token and exchange it for a new token pair.
+//
+// Refresh token is revoked with RevocationStore.RevokeIdIfAbsent(), so only one of concurrent exchanges would succeed.
+func (r *TokenRefresher) Refresh(raw string) (*TokenResponse, error) {
+	token, err := r.signer.VerifyJwt(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := claimsToMap(token.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims[ClaimTokenUse] != TokenUseRefresh {
+		return nil, errors.New("not a refresh token")
+	}
+
+	jti, sub, iat := revocationKeys(claims)
+	if len(jti) < 1 {
+		return nil, errors.New("missing jti in refresh token")
+	}
+
+	revoked, err := r.store.IsRevoked(jti, sub, iat)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("revoked refresh token")
+	}
+
+	// rotate refresh token
+	expireAt := time.Now().Add(r.refreshTtl)
+	if exp, ok := claims["exp"].(float64); ok {
+		expireAt = time.Unix(int64(exp), 0)
+	}
+	rotated, err := r.store.RevokeIdIfAbsent(jti, expireAt)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, errors.New("revoked refresh token")
+	}
+
+	return r.Issue(claims)
+}
+
+// Handler returns echo.HandlerFunc which exchanges refresh token in form or json body field refresh_token
+func (r *TokenRefresher) Handler() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := struct {
+			RefreshToken string `json:"refresh_token" form:"refresh_token"`
+		}{}
+
+		if err := ctx.Bind(&req); err != nil || len(req.RefreshToken) < 1 {
+			errResp := rkmid.GetErrorBuilder().New(http.StatusBadRequest, "Missing refresh_token")
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+
+		resp, err := r.Refresh(req.RefreshToken)
+		if err != nil {
+			errResp := rkmid.GetErrorBuilder().New(http.StatusUnauthorized, "Invalid or expired refresh token")
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+
+		ctx.Response().Header().Set("Cache-Control", "no-store")
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// copyClaims copy claims without registered claims which would be regenerated
+func copyClaims(claims jwt.MapClaims) jwt.MapClaims {
+	res := jwt.MapClaims{}
+	for k, v := range claims {
+		res[k] = v
+	}
+
+	for _, k := range registeredClaims {
+		delete(res, k)
+	}
+
+	return res
+}
+
+// ***************** Option *****************
+
+// RefreshOption is for TokenRefresher options while creating refresher
+type RefreshOption func(*TokenRefresher)
+
+// WithRefreshPath provide path of refresh endpoint, default: /rk/v1/token/refresh.
+func WithRefreshPath(path string) RefreshOption {
+	return func(r *TokenRefresher) {
+		if len(path) > 0 {
+			r.path = path
+		}
+	}
+}
+
+// WithAccessTtl provide ttl of access token, default: 15 minutes.
+func WithAccessTtl(ttl time.Duration) RefreshOption {
+	return func(r *TokenRefresher) {
+		if ttl > 0 {
+			r.accessTtl = ttl
+		}
+	}
+}
+
+// WithRefreshTtl provide ttl of refresh token, default: 7 days.
+func WithRefreshTtl(ttl time.Duration) RefreshOption {
+	return func(r *TokenRefresher) {
+		if ttl > 0 {
+			r.refreshTtl = ttl
+		}
+	}
+}
+
+// WithRevocationStore provide RevocationStore which records rotated refresh tokens, default: in-memory store.
+func WithRevocationStore(store RevocationStore) RefreshOption {
+	return func(r *TokenRefresher) {
+		if store != nil {
+			r.store = store
+		}
+	}
+}
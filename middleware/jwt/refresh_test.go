@@ -0,0 +1,115 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenRefresher_Refresh(t *testing.T) {
+	signer := rkentry.RegisterSymmetricJwtSigner("ut-refresh", jwt.SigningMethodHS256.Name, []byte("ut-key"))
+	refresher := NewTokenRefresher(signer,
+		WithRefreshPath("/ut-refresh"),
+		WithAccessTtl(time.Minute),
+		WithRefreshTtl(time.Hour))
+	assert.Equal(t, "/ut-refresh", refresher.Path())
+	assert.NotNil(t, refresher.Store())
+
+	// issue token pair
+	pair, err := refresher.Issue(jwt.MapClaims{"sub": "ut-sub", "tenant": "ut-tenant", "exp": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(60), pair.ExpiresIn)
+
+	// access token could not be exchanged
+	_, err = refresher.Refresh(pair.AccessToken)
+	assert.NotNil(t, err)
+
+	// refresh token is rejected by ClaimsSigner
+	_, err = NewClaimsSigner(signer).VerifyJwt(pair.RefreshToken)
+	assert.NotNil(t, err)
+
+	// exchange refresh token
+	next, err := refresher.Refresh(pair.RefreshToken)
+	assert.Nil(t, err)
+	token, err := NewClaimsSigner(signer).VerifyJwt(next.AccessToken)
+	assert.Nil(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "ut-tenant", claims["tenant"])
+	assert.Equal(t, TokenUseAccess, claims[ClaimTokenUse])
+
+	// rotated refresh token could not be reused
+	_, err = refresher.Refresh(pair.RefreshToken)
+	assert.NotNil(t, err)
+
+	// only one of concurrent exchanges succeeds
+	pair, err = refresher.Issue(jwt.MapClaims{"sub": "ut-sub"})
+	assert.Nil(t, err)
+	var succeeded int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := refresher.Refresh(pair.RefreshToken); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded)
+
+	// refresh token of revoked subject
+	assert.Nil(t, refresher.Store().RevokeSubject("ut-sub", time.Now().Add(time.Second)))
+	_, err = refresher.Refresh(next.RefreshToken)
+	assert.NotNil(t, err)
+
+	// invalid token
+	_, err = refresher.Refresh("invalid")
+	assert.NotNil(t, err)
+}
+
+func TestTokenRefresher_Handler(t *testing.T) {
+	signer := rkentry.RegisterSymmetricJwtSigner("ut-refresh", jwt.SigningMethodHS256.Name, []byte("ut-key"))
+	refresher := NewTokenRefresher(signer)
+	pair, _ := refresher.Issue(jwt.MapClaims{"sub": "ut-sub"})
+
+	e := echo.New()
+	e.POST(refresher.Path(), refresher.Handler())
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, refresher.Path(), strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// case 1: without refresh token
+	assert.Equal(t, http.StatusBadRequest, serve(`{}`).Code)
+
+	// case 2: happy case
+	w := serve(`{"refresh_token":"` + pair.RefreshToken + `"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	resp := &TokenResponse{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), resp))
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.Equal(t, "Bearer", resp.TokenType)
+
+	// case 3: reused refresh token
+	assert.Equal(t, http.StatusUnauthorized, serve(`{"refresh_token":"`+pair.RefreshToken+`"}`).Code)
+}
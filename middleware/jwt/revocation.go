@@ -0,0 +1,143 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	revocationStoresLock sync.Mutex
+	revocationStores     = make(map[string]RevocationStore)
+)
+
+// GetRevocationStore returns RevocationStore registered by ToOptions() with entry name,
+// tokens could be revoked at runtime with RevokeId() and RevokeSubject() of it.
+func GetRevocationStore(entryName string) RevocationStore {
+	revocationStoresLock.Lock()
+	defer revocationStoresLock.Unlock()
+
+	return revocationStores[entryName]
+}
+
+// RevocationChecker checks whether a verified token is revoked.
+//
+// Token could be revoked by jti or by subject with issued-before time.
+type RevocationChecker interface {
+	// IsRevoked returns true if token with jti, subject and issuedAt is revoked
+	IsRevoked(jti, subject string, issuedAt time.Time) (bool, error)
+}
+
+// RevocationStore persists revoked tokens.
+type RevocationStore interface {
+	RevocationChecker
+
+	// RevokeId revokes token with jti, record could be purged after expireAt
+	RevokeId(jti string, expireAt time.Time) error
+
+	// RevokeIdIfAbsent atomically revokes token with jti, returns false if jti was already revoked
+	RevokeIdIfAbsent(jti string, expireAt time.Time) (bool, error)
+
+	// RevokeSubject revokes tokens of subject issued before issuedBefore
+	RevokeSubject(subject string, issuedBefore time.Time) error
+}
+
+// NewMemoryRevocationStore create in-memory RevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		ids:      make(map[string]time.Time),
+		subjects: make(map[string]time.Time),
+	}
+}
+
+// MemoryRevocationStore is an in-memory RevocationStore, revoked ids are purged lazily after expiration.
+type MemoryRevocationStore struct {
+	lock      sync.Mutex
+	ids       map[string]time.Time
+	subjects  map[string]time.Time
+	lastPurge time.Time
+}
+
+// IsRevoked returns true if jti is revoked or token of subject is issued before revocation
+func (s *MemoryRevocationStore) IsRevoked(jti, subject string, issuedAt time.Time) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	if len(jti) > 0 {
+		if expireAt, ok := s.ids[jti]; ok && now.Before(expireAt) {
+			return true, nil
+		}
+	}
+
+	if len(subject) > 0 {
+		if issuedBefore, ok := s.subjects[subject]; ok && issuedAt.Before(issuedBefore) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RevokeId revokes token with jti until expireAt
+func (s *MemoryRevocationStore) RevokeId(jti string, expireAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(jti) > 0 {
+		s.ids[jti] = expireAt
+	}
+
+	return nil
+}
+
+// RevokeIdIfAbsent revokes token with jti until expireAt, returns false if jti was already revoked
+func (s *MemoryRevocationStore) RevokeIdIfAbsent(jti string, expireAt time.Time) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(jti) < 1 {
+		return false, nil
+	}
+
+	if prev, ok := s.ids[jti]; ok && time.Now().Before(prev) {
+		return false, nil
+	}
+
+	s.ids[jti] = expireAt
+	return true, nil
+}
+
+// RevokeSubject revokes tokens of subject issued before issuedBefore
+func (s *MemoryRevocationStore) RevokeSubject(subject string, issuedBefore time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(subject) > 0 {
+		if prev, ok := s.subjects[subject]; !ok || issuedBefore.After(prev) {
+			s.subjects[subject] = issuedBefore
+		}
+	}
+
+	return nil
+}
+
+// purge removes expired ids at most once per minute, lock should be held by caller
+func (s *MemoryRevocationStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+
+	for jti, expireAt := range s.ids {
+		if !now.Before(expireAt) {
+			delete(s.ids, jti)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechojwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	now := time.Now()
+
+	// without revocation
+	revoked, err := store.IsRevoked("ut-jti", "ut-sub", now)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	// revoke by jti
+	assert.Nil(t, store.RevokeId("ut-jti", now.Add(time.Minute)))
+	revoked, _ = store.IsRevoked("ut-jti", "", now)
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked("ut-jti-2", "", now)
+	assert.False(t, revoked)
+
+	// expired jti would be purged
+	assert.Nil(t, store.RevokeId("ut-expired", now.Add(-time.Minute)))
+	revoked, _ = store.IsRevoked("ut-expired", "", now)
+	assert.False(t, revoked)
+	store.lastPurge = time.Time{}
+	store.IsRevoked("", "", now)
+	assert.NotContains(t, store.ids, "ut-expired")
+	assert.Contains(t, store.ids, "ut-jti")
+
+	// revoke if absent
+	ok, err := store.RevokeIdIfAbsent("ut-jti-3", now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = store.RevokeIdIfAbsent("ut-jti-3", now.Add(time.Minute))
+	assert.False(t, ok)
+	ok, _ = store.RevokeIdIfAbsent("ut-expired", now.Add(time.Minute))
+	assert.True(t, ok)
+	ok, _ = store.RevokeIdIfAbsent("", now.Add(time.Minute))
+	assert.False(t, ok)
+
+	// revoke by subject
+	assert.Nil(t, store.RevokeSubject("ut-sub", now))
+	revoked, _ = store.IsRevoked("", "ut-sub", now.Add(-time.Second))
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked("", "ut-sub", now.Add(time.Second))
+	assert.False(t, revoked)
+
+	// earlier cutoff would not override
+	assert.Nil(t, store.RevokeSubject("ut-sub", now.Add(-time.Hour)))
+	revoked, _ = store.IsRevoked("", "ut-sub", now.Add(-time.Second))
+	assert.True(t, revoked)
+}
@@ -0,0 +1,544 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AccessLogFormatCommon is NCSA Common Log Format
+	AccessLogFormatCommon = "common"
+	// AccessLogFormatCombined is Apache Combined Log Format, which is Common Log Format with referer and user agent
+	AccessLogFormatCombined = "combined"
+	// AccessLogFormatTemplate is user defined template with tags like ${method}
+	AccessLogFormatTemplate = "template"
+	// AccessLogFormatEcs is JSON in Elastic Common Schema
+	AccessLogFormatEcs = "ecs"
+	// AccessLogFormatOtel is JSON in OpenTelemetry log data model
+	AccessLogFormatOtel = "otel"
+
+	// ecsVersion is version of Elastic Common Schema used in ECS format
+	ecsVersion = "8.11.0"
+	// clfTimeLayout is time layout used in Common Log Format
+	clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+	// otelSeverityInfo is severity number of INFO in OpenTelemetry log data model
+	otelSeverityInfo = 9
+	// otelSeverityError is severity number of ERROR in OpenTelemetry log data model
+	otelSeverityError = 17
+)
+
+// accessLogFormats maps alias of formats
+var accessLogFormats = map[string]string{
+	"clf":                   AccessLogFormatCommon,
+	AccessLogFormatCommon:   AccessLogFormatCommon,
+	AccessLogFormatCombined: AccessLogFormatCombined,
+	AccessLogFormatTemplate: AccessLogFormatTemplate,
+	AccessLogFormatEcs:      AccessLogFormatEcs,
+	AccessLogFormatOtel:     AccessLogFormatOtel,
+}
+
+// accessRecord is fields of one request written in access log
+type accessRecord struct {
+	startTime time.Time
+	latency   time.Duration
+	remoteIp  string
+	user      string
+	host      string
+	method    string
+	uri       string
+	path      string
+	query     string
+	protocol  string
+	referer   string
+	userAgent string
+	status    int
+	bytesIn   int64
+	bytesOut  int64
+	requestId string
+	traceId   string
+	spanId    string
+	err       error
+	header    http.Header
+}
+
+// newAccessRecord collects fields of request after handler returned
+func newAccessRecord(ctx echo.Context, startTime time.Time, err error) *accessRecord {
+	req := ctx.Request()
+	record := &accessRecord{
+		startTime: startTime,
+		latency:   time.Since(startTime),
+		remoteIp:  rkechoctx.GetClientIP(ctx),
+		host:      req.Host,
+		method:    req.Method,
+		uri:       req.RequestURI,
+		protocol:  req.Proto,
+		referer:   req.Referer(),
+		userAgent: req.UserAgent(),
+		status:    ctx.Response().Status,
+		bytesIn:   req.ContentLength,
+		bytesOut:  ctx.Response().Size,
+		requestId: rkechoctx.GetRequestId(ctx),
+		err:       err,
+		header:    req.Header,
+	}
+
+	if req.URL != nil {
+		record.path = req.URL.Path
+		record.query = req.URL.RawQuery
+		if len(record.uri) < 1 {
+			record.uri = req.URL.RequestURI()
+		}
+	}
+
+	if record.bytesIn < 0 {
+		record.bytesIn = 0
+	}
+
+	// response is written by echo error handler after middleware returned
+	if err != nil && !ctx.Response().Committed {
+		record.status = http.StatusInternalServerError
+		httpErr := &echo.HTTPError{}
+		if errors.As(err, &httpErr) {
+			record.status = httpErr.Code
+		}
+	}
+
+	if principal := rkechoctx.GetAuthPrincipal(ctx); principal != nil {
+		record.user = principal.Id
+	}
+
+	if spanCtx := rkechoctx.GetTraceSpan(ctx).SpanContext(); spanCtx.IsValid() {
+		record.traceId = spanCtx.TraceID().String()
+		record.spanId = spanCtx.SpanID().String()
+	} else {
+		record.traceId = rkechoctx.GetTraceId(ctx)
+	}
+
+	return record
+}
+
+// accessLogOptions describes format and output of access log
+type accessLogOptions struct {
+	enabled     bool
+	format      string
+	templateRaw string
+	template    *accessTemplate
+	outputPaths []string
+	maxSizeMb   int
+	maxBackups  int
+	maxAgeDays  int
+	eventOff    bool
+	logger      *zap.Logger
+	appName     string
+	appVersion  string
+}
+
+// build compiles template and creates logger writes to dedicated files
+func (a *accessLogOptions) build() error {
+	if !a.enabled {
+		return nil
+	}
+
+	if a.format == AccessLogFormatTemplate {
+		tpl, err := newAccessTemplate(a.templateRaw)
+		if err != nil {
+			return err
+		}
+		a.template = tpl
+	}
+
+	appInfo := rkentry.GlobalAppCtx.GetAppInfoEntry()
+	a.appName, a.appVersion = appInfo.AppName, appInfo.Version
+
+	if len(a.outputPaths) < 1 {
+		return nil
+	}
+
+	// message only, each line is one access log
+	config := rklogger.NewZapStdoutConfig()
+	config.Development = false
+	config.EncoderConfig = zapcore.EncoderConfig{
+		MessageKey: "msg",
+		LineEnding: zapcore.DefaultLineEnding,
+	}
+	config.OutputPaths = toAbsPath(a.outputPaths...)
+
+	// files are rotated by lumberjack
+	lumber := rklogger.NewLumberjackConfigDefault()
+	if a.maxSizeMb > 0 {
+		lumber.MaxSize = a.maxSizeMb
+	}
+	if a.maxBackups > 0 {
+		lumber.MaxBackups = a.maxBackups
+	}
+	if a.maxAgeDays > 0 {
+		lumber.MaxAge = a.maxAgeDays
+	}
+
+	logger, err := rklogger.NewZapLoggerWithConf(config, lumber)
+	if err != nil {
+		return err
+	}
+	a.logger = logger
+
+	return nil
+}
+
+// write formats record and writes it into dedicated files or logger of request
+func (a *accessLogOptions) write(record *accessRecord, requestLogger *zap.Logger) {
+	line := a.format2Line(record)
+
+	logger := a.logger
+	if logger == nil {
+		logger = requestLogger
+	}
+
+	if logger != nil {
+		logger.Info(line)
+	}
+}
+
+// format2Line formats record based on format
+func (a *accessLogOptions) format2Line(r *accessRecord) string {
+	switch a.format {
+	case AccessLogFormatCombined:
+		return formatCommon(r) + ` "` + escapeQuoted(r.referer) + `" "` + escapeQuoted(r.userAgent) + `"`
+	case AccessLogFormatTemplate:
+		return a.template.execute(r)
+	case AccessLogFormatEcs:
+		return a.formatEcs(r)
+	case AccessLogFormatOtel:
+		return a.formatOtel(r)
+	}
+
+	return formatCommon(r)
+}
+
+// formatCommon formats record in Common Log Format
+func formatCommon(r *accessRecord) string {
+	bytesOut := "-"
+	if r.bytesOut > 0 {
+		bytesOut = strconv.FormatInt(r.bytesOut, 10)
+	}
+
+	return orDash(r.remoteIp) + " - " + orDash(r.user) +
+		" [" + r.startTime.Format(clfTimeLayout) + "] \"" +
+		escapeQuoted(r.method+" "+r.uri+" "+r.protocol) + "\" " +
+		strconv.Itoa(r.status) + " " + bytesOut
+}
+
+// formatEcs formats record as JSON in Elastic Common Schema
+func (a *accessLogOptions) formatEcs(r *accessRecord) string {
+	outcome := "success"
+	if r.err != nil || r.status >= http.StatusInternalServerError {
+		outcome = "failure"
+	}
+
+	doc := map[string]interface{}{
+		"@timestamp": r.startTime.UTC().Format(time.RFC3339Nano),
+		"message":    formatCommon(r),
+		"ecs":        map[string]interface{}{"version": ecsVersion},
+		"log":        map[string]interface{}{"level": "info", "logger": "access"},
+		"event": map[string]interface{}{
+			"kind":     "event",
+			"category": []string{"web"},
+			"type":     []string{"access"},
+			"outcome":  outcome,
+			"duration": r.latency.Nanoseconds(),
+		},
+		"http": map[string]interface{}{
+			"version": strings.TrimPrefix(r.protocol, "HTTP/"),
+			"request": withoutEmpty(map[string]interface{}{
+				"id":       r.requestId,
+				"method":   r.method,
+				"referrer": r.referer,
+				"body":     map[string]interface{}{"bytes": r.bytesIn},
+			}),
+			"response": map[string]interface{}{
+				"status_code": r.status,
+				"body":        map[string]interface{}{"bytes": r.bytesOut},
+			},
+		},
+		"url": withoutEmpty(map[string]interface{}{
+			"original": r.uri,
+			"path":     r.path,
+			"query":    r.query,
+			"domain":   r.host,
+		}),
+		"client":     map[string]interface{}{"ip": r.remoteIp},
+		"user_agent": map[string]interface{}{"original": r.userAgent},
+		"service":    withoutEmpty(map[string]interface{}{"name": a.appName, "version": a.appVersion}),
+	}
+
+	if len(r.user) > 0 {
+		doc["user"] = map[string]interface{}{"name": r.user}
+	}
+
+	if len(r.traceId) > 0 {
+		doc["trace"] = map[string]interface{}{"id": r.traceId}
+	}
+
+	if len(r.spanId) > 0 {
+		doc["span"] = map[string]interface{}{"id": r.spanId}
+	}
+
+	if r.err != nil {
+		doc["error"] = map[string]interface{}{"message": r.err.Error()}
+	}
+
+	return marshalLine(doc)
+}
+
+// formatOtel formats record as JSON in OpenTelemetry log data model with semantic conventions of HTTP server
+func (a *accessLogOptions) formatOtel(r *accessRecord) string {
+	severityText, severityNumber := "INFO", otelSeverityInfo
+	if r.err != nil || r.status >= http.StatusInternalServerError {
+		severityText, severityNumber = "ERROR", otelSeverityError
+	}
+
+	attrs := withoutEmpty(map[string]interface{}{
+		"http.request.method":       r.method,
+		"http.response.status_code": r.status,
+		"http.request.body.size":    r.bytesIn,
+		"http.response.body.size":   r.bytesOut,
+		"http.request.id":           r.requestId,
+		"url.path":                  r.path,
+		"url.query":                 r.query,
+		"server.address":            r.host,
+		"client.address":            r.remoteIp,
+		"user_agent.original":       r.userAgent,
+		"network.protocol.version":  strings.TrimPrefix(r.protocol, "HTTP/"),
+		"enduser.id":                r.user,
+		"duration_ns":               r.latency.Nanoseconds(),
+	})
+
+	if r.err != nil {
+		attrs["exception.message"] = r.err.Error()
+	}
+
+	doc := map[string]interface{}{
+		"Timestamp":         strconv.FormatInt(r.startTime.UnixNano(), 10),
+		"ObservedTimestamp": strconv.FormatInt(time.Now().UnixNano(), 10),
+		"SeverityText":      severityText,
+		"SeverityNumber":    severityNumber,
+		"Body":              formatCommon(r),
+		"Resource":          withoutEmpty(map[string]interface{}{"service.name": a.appName, "service.version": a.appVersion}),
+		"Attributes":        attrs,
+	}
+
+	if len(r.traceId) > 0 {
+		doc["TraceId"] = r.traceId
+	}
+
+	if len(r.spanId) > 0 {
+		doc["SpanId"] = r.spanId
+	}
+
+	return marshalLine(doc)
+}
+
+// accessTemplate is compiled template with tags like ${remote_ip}, ${header:X-Foo} and ${query:foo}
+type accessTemplate struct {
+	segments []func(*accessRecord) string
+}
+
+// newAccessTemplate compiles template, returns error if template is empty or tag is unknown
+func newAccessTemplate(raw string) (*accessTemplate, error) {
+	if len(raw) < 1 {
+		return nil, errors.New("access log template is empty")
+	}
+
+	res := &accessTemplate{segments: make([]func(*accessRecord) string, 0)}
+
+	for len(raw) > 0 {
+		start := strings.Index(raw, "${")
+		if start < 0 {
+			res.addText(raw)
+			break
+		}
+
+		end := strings.Index(raw[start:], "}")
+		if end < 0 {
+			return nil, errors.New("access log template has unclosed tag " + raw[start:])
+		}
+
+		res.addText(raw[:start])
+
+		tag := raw[start+2 : start+end]
+		segment, err := accessTag(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		res.segments = append(res.segments, segment)
+		raw = raw[start+end+1:]
+	}
+
+	return res, nil
+}
+
+// addText adds literal text as segment
+func (t *accessTemplate) addText(text string) {
+	if len(text) > 0 {
+		t.segments = append(t.segments, func(*accessRecord) string {
+			return text
+		})
+	}
+}
+
+// execute renders record with template
+func (t *accessTemplate) execute(r *accessRecord) string {
+	buf := strings.Builder{}
+	for _, segment := range t.segments {
+		buf.WriteString(segment(r))
+	}
+
+	return buf.String()
+}
+
+// accessTag returns function renders value of tag, tags are same as echo logger middleware
+func accessTag(tag string) (func(*accessRecord) string, error) {
+	switch {
+	case strings.HasPrefix(tag, "header:"):
+		key := strings.TrimPrefix(tag, "header:")
+		return func(r *accessRecord) string {
+			return r.header.Get(key)
+		}, nil
+	case strings.HasPrefix(tag, "query:"):
+		key := strings.TrimPrefix(tag, "query:")
+		return func(r *accessRecord) string {
+			values, _ := url.ParseQuery(r.query)
+			return values.Get(key)
+		}, nil
+	}
+
+	switch tag {
+	case "time_rfc3339":
+		return func(r *accessRecord) string { return r.startTime.Format(time.RFC3339) }, nil
+	case "time_rfc3339_nano":
+		return func(r *accessRecord) string { return r.startTime.Format(time.RFC3339Nano) }, nil
+	case "time_clf":
+		return func(r *accessRecord) string { return r.startTime.Format(clfTimeLayout) }, nil
+	case "time_unix":
+		return func(r *accessRecord) string { return strconv.FormatInt(r.startTime.Unix(), 10) }, nil
+	case "id":
+		return func(r *accessRecord) string { return r.requestId }, nil
+	case "trace_id":
+		return func(r *accessRecord) string { return r.traceId }, nil
+	case "span_id":
+		return func(r *accessRecord) string { return r.spanId }, nil
+	case "remote_ip":
+		return func(r *accessRecord) string { return r.remoteIp }, nil
+	case "user":
+		return func(r *accessRecord) string { return r.user }, nil
+	case "host":
+		return func(r *accessRecord) string { return r.host }, nil
+	case "method":
+		return func(r *accessRecord) string { return r.method }, nil
+	case "uri":
+		return func(r *accessRecord) string { return r.uri }, nil
+	case "path":
+		return func(r *accessRecord) string { return r.path }, nil
+	case "protocol":
+		return func(r *accessRecord) string { return r.protocol }, nil
+	case "referer":
+		return func(r *accessRecord) string { return r.referer }, nil
+	case "user_agent":
+		return func(r *accessRecord) string { return r.userAgent }, nil
+	case "status":
+		return func(r *accessRecord) string { return strconv.Itoa(r.status) }, nil
+	case "error":
+		return func(r *accessRecord) string {
+			if r.err != nil {
+				return r.err.Error()
+			}
+			return ""
+		}, nil
+	case "latency":
+		return func(r *accessRecord) string { return strconv.FormatInt(r.latency.Nanoseconds(), 10) }, nil
+	case "latency_human":
+		return func(r *accessRecord) string { return r.latency.String() }, nil
+	case "bytes_in":
+		return func(r *accessRecord) string { return strconv.FormatInt(r.bytesIn, 10) }, nil
+	case "bytes_out":
+		return func(r *accessRecord) string { return strconv.FormatInt(r.bytesOut, 10) }, nil
+	}
+
+	return nil, errors.New("unknown access log tag " + tag)
+}
+
+// orDash returns - if value is empty, used in Common Log Format
+func orDash(v string) string {
+	if len(v) < 1 {
+		return "-"
+	}
+
+	return v
+}
+
+// escapeQuoted escapes quote and backslash in values which are wrapped with quotes
+func escapeQuoted(v string) string {
+	if !strings.ContainsAny(v, `"\`) {
+		return v
+	}
+
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v)
+}
+
+// withoutEmpty removes empty strings from map
+func withoutEmpty(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		if s, ok := v.(string); ok && len(s) < 1 {
+			delete(m, k)
+		}
+	}
+
+	return m
+}
+
+// marshalLine marshals doc as one line of JSON
+func marshalLine(doc map[string]interface{}) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// toAbsPath converts relative paths to absolute paths based on working directory
+func toAbsPath(p ...string) []string {
+	res := make([]string, 0)
+
+	for i := range p {
+		if filepath.IsAbs(p[i]) || p[i] == "stdout" || p[i] == "stderr" {
+			res = append(res, p[i])
+			continue
+		}
+
+		abs, _ := filepath.Abs(p[i])
+		res = append(res, abs)
+	}
+
+	return res
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAccessRecordForTest() *accessRecord {
+	header := http.Header{}
+	header.Set("X-Ut-Key", "ut-value")
+
+	return &accessRecord{
+		startTime: time.Date(2021, 12, 27, 22, 18, 31, 0, time.FixedZone("CST", 8*3600)),
+		latency:   1500 * time.Microsecond,
+		remoteIp:  "1.2.3.4",
+		user:      "ut-user",
+		host:      "ut.example.com",
+		method:    http.MethodGet,
+		uri:       "/ut-path?k=v",
+		path:      "/ut-path",
+		query:     "k=v",
+		protocol:  "HTTP/1.1",
+		referer:   "https://ut.example.com/",
+		userAgent: `ut-agent "quoted"`,
+		status:    http.StatusOK,
+		bytesIn:   0,
+		bytesOut:  12,
+		requestId: "ut-request-id",
+		traceId:   "ut-trace-id",
+		header:    header,
+	}
+}
+
+func TestAccessLogOptions_Format2Line(t *testing.T) {
+	r := newAccessRecordForTest()
+
+	// common
+	a := &accessLogOptions{format: AccessLogFormatCommon}
+	assert.Equal(t, `1.2.3.4 - ut-user [27/Dec/2021:22:18:31 +0800] "GET /ut-path?k=v HTTP/1.1" 200 12`, a.format2Line(r))
+
+	// combined
+	a = &accessLogOptions{format: AccessLogFormatCombined}
+	assert.Equal(t,
+		`1.2.3.4 - ut-user [27/Dec/2021:22:18:31 +0800] "GET /ut-path?k=v HTTP/1.1" 200 12 "https://ut.example.com/" "ut-agent \"quoted\""`,
+		a.format2Line(r))
+
+	// empty fields
+	empty := &accessRecord{startTime: r.startTime, method: http.MethodGet, uri: "/", protocol: "HTTP/1.1", status: http.StatusNoContent}
+	assert.Equal(t, `- - - [27/Dec/2021:22:18:31 +0800] "GET / HTTP/1.1" 204 -`, formatCommon(empty))
+
+	// template
+	tpl, err := newAccessTemplate(`${remote_ip} ${method} ${path} ${status} ${latency_human} ${header:X-Ut-Key} ${query:k} ${id}`)
+	assert.Nil(t, err)
+	a = &accessLogOptions{format: AccessLogFormatTemplate, template: tpl}
+	assert.Equal(t, "1.2.3.4 GET /ut-path 200 1.5ms ut-value v ut-request-id", a.format2Line(r))
+}
+
+func TestAccessLogOptions_FormatEcs(t *testing.T) {
+	r := newAccessRecordForTest()
+	a := &accessLogOptions{format: AccessLogFormatEcs, appName: "ut-app"}
+
+	doc := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(a.format2Line(r)), &doc))
+	assert.Equal(t, "2021-12-27T14:18:31Z", doc["@timestamp"])
+	assert.Equal(t, ecsVersion, doc["ecs"].(map[string]interface{})["version"])
+	assert.Equal(t, "success", doc["event"].(map[string]interface{})["outcome"])
+	assert.Equal(t, float64(1500000), doc["event"].(map[string]interface{})["duration"])
+	assert.Equal(t, "GET", doc["http"].(map[string]interface{})["request"].(map[string]interface{})["method"])
+	assert.Equal(t, "ut-request-id", doc["http"].(map[string]interface{})["request"].(map[string]interface{})["id"])
+	assert.Equal(t, float64(200), doc["http"].(map[string]interface{})["response"].(map[string]interface{})["status_code"])
+	assert.Equal(t, "/ut-path", doc["url"].(map[string]interface{})["path"])
+	assert.Equal(t, "1.2.3.4", doc["client"].(map[string]interface{})["ip"])
+	assert.Equal(t, "ut-user", doc["user"].(map[string]interface{})["name"])
+	assert.Equal(t, "ut-trace-id", doc["trace"].(map[string]interface{})["id"])
+	assert.Equal(t, "ut-app", doc["service"].(map[string]interface{})["name"])
+	assert.NotContains(t, doc, "error")
+
+	// with error
+	r.err = errors.New("ut-error")
+	r.status = http.StatusInternalServerError
+	doc = make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(a.format2Line(r)), &doc))
+	assert.Equal(t, "failure", doc["event"].(map[string]interface{})["outcome"])
+	assert.Equal(t, "ut-error", doc["error"].(map[string]interface{})["message"])
+}
+
+func TestAccessLogOptions_FormatOtel(t *testing.T) {
+	r := newAccessRecordForTest()
+	r.spanId = "ut-span-id"
+	a := &accessLogOptions{format: AccessLogFormatOtel, appName: "ut-app"}
+
+	doc := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(a.format2Line(r)), &doc))
+	assert.Equal(t, "1640614711000000000", doc["Timestamp"])
+	assert.Equal(t, "INFO", doc["SeverityText"])
+	assert.Equal(t, float64(otelSeverityInfo), doc["SeverityNumber"])
+	assert.Equal(t, "ut-trace-id", doc["TraceId"])
+	assert.Equal(t, "ut-span-id", doc["SpanId"])
+	assert.Equal(t, "ut-app", doc["Resource"].(map[string]interface{})["service.name"])
+
+	attrs := doc["Attributes"].(map[string]interface{})
+	assert.Equal(t, "GET", attrs["http.request.method"])
+	assert.Equal(t, float64(200), attrs["http.response.status_code"])
+	assert.Equal(t, "1.1", attrs["network.protocol.version"])
+	assert.Equal(t, "ut-user", attrs["enduser.id"])
+
+	// with error
+	r.status = http.StatusBadGateway
+	doc = make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(a.format2Line(r)), &doc))
+	assert.Equal(t, "ERROR", doc["SeverityText"])
+	assert.Equal(t, float64(otelSeverityError), doc["SeverityNumber"])
+}
+
+func TestNewAccessTemplate(t *testing.T) {
+	// empty
+	_, err := newAccessTemplate("")
+	assert.NotNil(t, err)
+
+	// unknown tag
+	_, err = newAccessTemplate("${ut-tag}")
+	assert.NotNil(t, err)
+
+	// unclosed tag
+	_, err = newAccessTemplate("${method")
+	assert.NotNil(t, err)
+
+	// literal only
+	tpl, err := newAccessTemplate("ut-text")
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-text", tpl.execute(newAccessRecordForTest()))
+
+	// error tag
+	tpl, err = newAccessTemplate("[${error}]")
+	assert.Nil(t, err)
+	r := newAccessRecordForTest()
+	assert.Equal(t, "[]", tpl.execute(r))
+	r.err = errors.New("ut-error")
+	assert.Equal(t, "[ut-error]", tpl.execute(r))
+}
+
+func TestNewAccessRecord(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/ut-path?k=v", strings.NewReader("ut-body"))
+	req.Header.Set("User-Agent", "ut-agent")
+	ctx := echo.New().NewContext(req, httptest.NewRecorder())
+	ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Id: "ut-principal"})
+
+	// with http error not written yet
+	r := newAccessRecord(ctx, time.Now(), echo.ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, r.status)
+	assert.Equal(t, "/ut-path", r.path)
+	assert.Equal(t, "k=v", r.query)
+	assert.Equal(t, "/ut-path?k=v", r.uri)
+	assert.Equal(t, int64(7), r.bytesIn)
+	assert.Equal(t, "ut-principal", r.user)
+	assert.Equal(t, "ut-agent", r.userAgent)
+
+	// with other error
+	r = newAccessRecord(ctx, time.Now(), errors.New("ut-error"))
+	assert.Equal(t, http.StatusInternalServerError, r.status)
+
+	// without error
+	ctx.String(http.StatusAccepted, "ut-resp")
+	r = newAccessRecord(ctx, time.Now(), nil)
+	assert.Equal(t, http.StatusAccepted, r.status)
+	assert.Equal(t, int64(7), r.bytesOut)
+}
+
+func TestAccessLogOptions_Build(t *testing.T) {
+	// disabled
+	a := &accessLogOptions{}
+	assert.Nil(t, a.build())
+
+	// invalid template
+	a = &accessLogOptions{enabled: true, format: AccessLogFormatTemplate}
+	assert.NotNil(t, a.build())
+
+	// with output paths
+	filePath := path.Join(t.TempDir(), "access.log")
+	a = &accessLogOptions{enabled: true, format: AccessLogFormatCommon, outputPaths: []string{filePath}, maxSizeMb: 1}
+	assert.Nil(t, a.build())
+	assert.NotNil(t, a.logger)
+
+	a.write(newAccessRecordForTest(), nil)
+	a.logger.Sync()
+
+	raw, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `1.2.3.4 - ut-user [27/Dec/2021:22:18:31 +0800] "GET /ut-path?k=v HTTP/1.1" 200 12`+"\n", string(raw))
+}
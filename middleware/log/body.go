@@ -0,0 +1,312 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-query"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RedactedValue replaces values of redacted fields and headers
+const RedactedValue = "******"
+
+// bodyOptions describes how body would be captured and redacted
+type bodyOptions struct {
+	enabled       bool
+	maxBytes      int
+	contentTypes  []string
+	paths         []string
+	redactFields  []string
+	redactHeaders map[string]bool
+	// compiled from redactFields
+	fieldPaths [][]string
+}
+
+// compile split redact fields into paths
+func (b *bodyOptions) compile() {
+	b.fieldPaths = make([][]string, 0)
+
+	for _, field := range b.redactFields {
+		b.fieldPaths = append(b.fieldPaths, strings.Split(strings.ToLower(field), "."))
+	}
+}
+
+// shouldCapture returns true if media type of content type matches any of configured types
+func (b *bodyOptions) shouldCapture(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range b.contentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headers returns headers as map with redacted values masked
+func (b *bodyOptions) headers(header http.Header) map[string]string {
+	res := make(map[string]string, len(header))
+
+	for k, v := range header {
+		if b.redactHeaders[http.CanonicalHeaderKey(k)] {
+			res[k] = RedactedValue
+			continue
+		}
+		res[k] = strings.Join(v, ", ")
+	}
+
+	return res
+}
+
+// redact masks configured fields in JSON or form body, body which can not be parsed, mostly truncated,
+// is dropped as a whole since fields in it can not be located reliably.
+// Bodies of other media types are returned as they are.
+func (b *bodyOptions) redact(contentType string, body []byte) []byte {
+	if len(b.fieldPaths) < 1 || len(body) < 1 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == echo.MIMEApplicationForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(RedactedValue)
+		}
+
+		for k := range values {
+			for _, field := range b.fieldPaths {
+				if len(field) == 1 && strings.EqualFold(k, field[0]) {
+					values[k] = []string{RedactedValue}
+				}
+			}
+		}
+
+		return []byte(values.Encode())
+	case strings.HasSuffix(mediaType, "json"):
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&doc); err != nil {
+			return []byte(RedactedValue)
+		}
+
+		for _, field := range b.fieldPaths {
+			redactValue(doc, field, true)
+		}
+
+		buf := &bytes.Buffer{}
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(doc); err != nil {
+			return []byte(RedactedValue)
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+
+	return body
+}
+
+// redactValue masks value of field path in objects at any depth if anywhere is true,
+// otherwise field path should start from v, arrays are traversed element by element
+func redactValue(v interface{}, field []string, anywhere bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if strings.EqualFold(k, field[0]) {
+				if len(field) == 1 {
+					t[k] = RedactedValue
+					continue
+				}
+				redactValue(child, field[1:], false)
+			}
+
+			if anywhere {
+				redactValue(child, field, true)
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			redactValue(t[i], field, anywhere)
+		}
+	}
+}
+
+// bodyCapture captures request and response of one request
+type bodyCapture struct {
+	opts        *bodyOptions
+	reqBody     *limitedBuffer
+	reqSkipped  bool
+	resWriter   *bodyWriter
+	originalRes http.ResponseWriter
+}
+
+// newBodyCapture wraps request body and response writer of context
+func newBodyCapture(ctx echo.Context, opts *bodyOptions) *bodyCapture {
+	req := ctx.Request()
+	capture := &bodyCapture{
+		opts:        opts,
+		reqBody:     &limitedBuffer{limit: opts.maxBytes},
+		originalRes: ctx.Response().Writer,
+	}
+
+	// encoded body like gzip could not be logged
+	if req.Body == nil || req.Body == http.NoBody ||
+		len(req.Header.Get(echo.HeaderContentEncoding)) > 0 ||
+		!opts.shouldCapture(req.Header.Get(echo.HeaderContentType)) {
+		capture.reqSkipped = true
+	} else {
+		req.Body = &bodyReader{ReadCloser: req.Body, buf: capture.reqBody}
+	}
+
+	capture.resWriter = &bodyWriter{
+		ResponseWriter: ctx.Response().Writer,
+		opts:           opts,
+		buf:            &limitedBuffer{limit: opts.maxBytes},
+	}
+	ctx.Response().Writer = capture.resWriter
+
+	return capture
+}
+
+// restore restores response writer, writer may have been swapped by inner middleware, restore only if it is still ours
+func (c *bodyCapture) restore(ctx echo.Context) {
+	if ctx.Response().Writer == c.resWriter {
+		ctx.Response().Writer = c.originalRes
+	}
+}
+
+// finish restores response writer and adds captured headers and body into event
+func (c *bodyCapture) finish(ctx echo.Context, event rkquery.Event) {
+	c.restore(ctx)
+
+	req := ctx.Request()
+	fields := []zap.Field{
+		zap.Any("reqHeaders", c.opts.headers(req.Header)),
+		zap.Any("resHeaders", c.opts.headers(ctx.Response().Header())),
+	}
+
+	if !c.reqSkipped {
+		fields = append(fields,
+			zap.ByteString("reqBody", c.opts.redact(req.Header.Get(echo.HeaderContentType), c.reqBody.Bytes())),
+			zap.Bool("reqBodyTruncated", c.reqBody.truncated))
+	}
+
+	if c.resWriter.capturing {
+		fields = append(fields,
+			zap.ByteString("resBody", c.opts.redact(ctx.Response().Header().Get(echo.HeaderContentType), c.resWriter.buf.Bytes())),
+			zap.Bool("resBodyTruncated", c.resWriter.buf.truncated))
+	}
+
+	event.AddPayloads(fields...)
+}
+
+// limitedBuffer keeps first limit bytes written and drops the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// keep copies bytes into buffer till limit reached
+func (b *limitedBuffer) keep(p []byte) {
+	if remain := b.limit - b.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.Buffer.Write(p[:remain])
+		}
+		return
+	}
+
+	b.Buffer.Write(p)
+}
+
+// bodyReader copies bytes read by handler into buffer, request body is never read ahead
+type bodyReader struct {
+	io.ReadCloser
+	buf *limitedBuffer
+}
+
+// Read reads from original body and keeps bytes read
+func (r *bodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.buf.keep(p[:n])
+	}
+	return n, err
+}
+
+// bodyWriter copies bytes written into buffer while passing them through to original writer
+type bodyWriter struct {
+	http.ResponseWriter
+	opts      *bodyOptions
+	buf       *limitedBuffer
+	decided   bool
+	capturing bool
+}
+
+// decide checks headers once before first byte sent, encoded body like gzip would not be captured
+func (w *bodyWriter) decide() {
+	if w.decided {
+		return
+	}
+
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	w.capturing = len(header.Get(echo.HeaderContentEncoding)) < 1 &&
+		w.opts.shouldCapture(header.Get(echo.HeaderContentType))
+}
+
+// WriteHeader writes header into http.ResponseWriter
+func (w *bodyWriter) WriteHeader(code int) {
+	w.decide()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes bytes into http.ResponseWriter and keeps copy of them
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.decide()
+	n, err := w.ResponseWriter.Write(b)
+	if w.capturing && n > 0 {
+		w.buf.keep(b[:n])
+	}
+	return n, err
+}
+
+// Flush flushes contents in http.ResponseWriter.
+func (w *bodyWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijack http.ResponseWriter
+func (w *bodyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap returns original http.ResponseWriter, used by http.ResponseController
+func (w *bodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
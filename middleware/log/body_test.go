@@ -0,0 +1,125 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBodyOptions_ShouldCapture(t *testing.T) {
+	opts := newOptionSet(WithBodyContentTypes(echo.MIMEApplicationJSON, "text/*", "application/*+json")).body
+
+	assert.True(t, opts.shouldCapture(echo.MIMEApplicationJSONCharsetUTF8))
+	assert.True(t, opts.shouldCapture("text/csv"))
+	assert.True(t, opts.shouldCapture("application/problem+json"))
+	assert.False(t, opts.shouldCapture("image/png"))
+	assert.False(t, opts.shouldCapture("text/event-stream/invalid"))
+	assert.False(t, opts.shouldCapture(""))
+
+	// XML and plain text are not captured by default since they can not be redacted
+	opts = newOptionSet().body
+	assert.True(t, opts.shouldCapture(echo.MIMEApplicationForm))
+	assert.False(t, opts.shouldCapture(echo.MIMETextPlain))
+	assert.False(t, opts.shouldCapture(echo.MIMEApplicationXML))
+}
+
+func TestBodyOptions_Headers(t *testing.T) {
+	opts := newOptionSet(WithRedactHeaders("X-Ut-Secret")).body
+
+	header := http.Header{}
+	header.Set(echo.HeaderAuthorization, "Bearer ut-token")
+	header.Set("X-Ut-Secret", "ut-secret")
+	header.Add("X-Ut-Value", "a")
+	header.Add("X-Ut-Value", "b")
+
+	assert.Equal(t, map[string]string{
+		echo.HeaderAuthorization: RedactedValue,
+		"X-Ut-Secret":            RedactedValue,
+		"X-Ut-Value":             "a, b",
+	}, opts.headers(header))
+}
+
+func TestBodyOptions_Redact(t *testing.T) {
+	opts := newOptionSet(WithRedactFields("password", "card.number")).body
+
+	// JSON with nested field and arrays
+	body := `{"user":"ut","password":"ut-pwd","card":{"number":"4111","exp":"12/30"},"items":[{"card":{"number":"4222"}}]}`
+	assert.JSONEq(t,
+		`{"user":"ut","password":"******","card":{"number":"******","exp":"12/30"},"items":[{"card":{"number":"******"}}]}`,
+		string(opts.redact(echo.MIMEApplicationJSONCharsetUTF8, []byte(body))))
+
+	// array at top level
+	assert.JSONEq(t,
+		`[{"password":"******"},{"user":"ut"}]`,
+		string(opts.redact(echo.MIMEApplicationJSON, []byte(`[{"password":"ut-pwd"},{"user":"ut"}]`))))
+
+	// numbers keep precision
+	assert.Equal(t, `{"amount":12345678901234567890}`,
+		string(opts.redact(echo.MIMEApplicationJSON, []byte(`{"amount":12345678901234567890}`))))
+
+	// truncated JSON dropped as a whole
+	truncated := opts.redact(echo.MIMEApplicationJSON, []byte(`{"password":"ut-pwd","card":{"number":{"value":41111111},"exp":"12/3`))
+	assert.Equal(t, RedactedValue, string(truncated))
+	truncated = opts.redact(echo.MIMEApplicationJSON, []byte(`{"user":"ut","password":"ut-p`))
+	assert.Equal(t, RedactedValue, string(truncated))
+
+	// form
+	assert.Equal(t, "password=%2A%2A%2A%2A%2A%2A&user=ut",
+		string(opts.redact(echo.MIMEApplicationForm, []byte("user=ut&password=ut-pwd"))))
+
+	// other content type
+	assert.Equal(t, "password=ut-pwd", string(opts.redact(echo.MIMETextPlain, []byte("password=ut-pwd"))))
+
+	// without fields
+	opts = newOptionSet().body
+	assert.Equal(t, body, string(opts.redact(echo.MIMEApplicationJSON, []byte(body))))
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 4}
+
+	buf.keep([]byte("ab"))
+	assert.Equal(t, "ab", buf.String())
+	assert.False(t, buf.truncated)
+
+	buf.keep([]byte("cdef"))
+	assert.Equal(t, "abcd", buf.String())
+	assert.True(t, buf.truncated)
+
+	buf.keep([]byte("g"))
+	assert.Equal(t, "abcd", buf.String())
+}
+
+func TestBodyWriter(t *testing.T) {
+	opts := newOptionSet(WithBodyCapture(4), WithBodyContentTypes(echo.MIMETextPlain)).body
+
+	// captured
+	rec := httptest.NewRecorder()
+	w := &bodyWriter{ResponseWriter: rec, opts: opts, buf: &limitedBuffer{limit: opts.maxBytes}}
+	w.Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ut-body"))
+	w.Flush()
+	assert.True(t, w.capturing)
+	assert.Equal(t, "ut-b", w.buf.String())
+	assert.Equal(t, "ut-body", rec.Body.String())
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, rec, w.Unwrap())
+
+	// encoded
+	rec = httptest.NewRecorder()
+	w = &bodyWriter{ResponseWriter: rec, opts: opts, buf: &limitedBuffer{limit: opts.maxBytes}}
+	w.Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+	w.Header().Set(echo.HeaderContentEncoding, "gzip")
+	w.Write([]byte("ut-body"))
+	assert.False(t, w.capturing)
+	assert.Empty(t, w.buf.String())
+	assert.Equal(t, "ut-body", rec.Body.String())
+}
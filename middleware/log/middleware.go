@@ -11,34 +11,92 @@ import (
 	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/log"
+	"net"
 	"strconv"
+	"time"
 )
 
 // Middleware returns a echo.MiddlewareFunc (middleware) that logs requests using uber-go/zap.
 func Middleware(opts ...rkmidlog.Option) echo.MiddlewareFunc {
-	set := rkmidlog.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithLogOptions(opts...))
+}
+
+// MiddlewareWithOptions logs requests same as Middleware with bellow extensions.
+//
+// 1: Request and response body could be captured in event with size limit, content type filter and path prefixes.
+// Body is copied while handler reads or writes it, so streaming response and writer swapped by gzip middleware work as usual,
+// body encoded by gzip would not be captured.
+// 2: Fields of JSON or form body like password or card.number, and headers like Authorization would be masked.
+// 3: Logs could be sampled by rate per path prefix, errors and slow requests are always logged.
+// Decision of every request is recorded in metrics, sampled out requests still go through prom and trace middlewares.
+// 4: Access log in format of Common, Combined, custom template, ECS or OpenTelemetry could be written into LoggerEntry
+// or dedicated files with rotation, alongside or instead of event log.
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
 			// call before
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			beforeCtx := set.logSet.BeforeCtx(ctx.Request())
+			beforeCtx.Input.RemoteAddr = remoteAddrOf(ctx)
+			set.logSet.Before(beforeCtx)
 
 			ctx.Set(rkmid.EventKey.String(), beforeCtx.Output.Event)
 			ctx.Set(rkmid.LoggerKey.String(), beforeCtx.Output.Logger)
 
+			var capture *bodyCapture
+			if set.shouldCaptureBody(ctx) {
+				capture = newBodyCapture(ctx, set.body)
+			}
+
+			startTime := time.Now()
 			err := next(ctx)
 
+			if !set.shouldLog(ctx, err, time.Since(startTime)) {
+				if capture != nil {
+					capture.restore(ctx)
+				}
+				return err
+			}
+
+			if capture != nil {
+				capture.finish(ctx, beforeCtx.Output.Event)
+			}
+
+			if set.accessLog.enabled && !set.logSet.ShouldIgnore(ctx.Request().URL.Path) {
+				set.accessLog.write(newAccessRecord(ctx, startTime, err), beforeCtx.Output.Logger)
+			}
+
+			if set.eventLogDisabled() {
+				return err
+			}
+
+			// identity matched by auth middleware
+			if principal := rkechoctx.GetAuthPrincipal(ctx); principal != nil {
+				beforeCtx.Output.Event.AddPair("authPrincipal", principal.Id)
+			}
+
 			// call after
-			afterCtx := set.AfterCtx(
+			afterCtx := set.logSet.AfterCtx(
 				rkechoctx.GetRequestId(ctx),
 				rkechoctx.GetTraceId(ctx),
 				strconv.Itoa(ctx.Response().Status))
-			set.After(beforeCtx, afterCtx)
+			set.logSet.After(beforeCtx, afterCtx)
 
 			return err
 		}
 	}
 }
+
+// remoteAddrOf returns client ip with port of connection,
+// which replaces address from X-Forwarded-For trusted for any peer by rkmidlog
+func remoteAddrOf(ctx echo.Context) string {
+	_, port, err := net.SplitHostPort(ctx.Request().RemoteAddr)
+	if err != nil {
+		port = "0"
+	}
+
+	return rkechoctx.GetClientIP(ctx) + ":" + port
+}
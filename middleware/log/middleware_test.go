@@ -7,16 +7,24 @@ package rkecholog
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-echo/middleware/gzip"
 	rkentry "github.com/rookie-ninja/rk-entry/v2/entry"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/log"
 	"github.com/rookie-ninja/rk-query"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -47,6 +55,226 @@ func TestMiddleware(t *testing.T) {
 	assert.Equal(t, logger, loggerFromCtx.(*zap.Logger))
 
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	// with auth principal
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, _ = newCtx()
+	inter(func(ctx echo.Context) error {
+		ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Id: "ut-principal"})
+		return userHandler(ctx)
+	})(ctx)
+	assert.Equal(t, "ut-principal", event.GetValueFromPair("authPrincipal"))
+}
+
+func TestMiddlewareWithOptions(t *testing.T) {
+	defer assertNotPanic(t)
+
+	beforeCtx := rkmidlog.NewBeforeCtx()
+	afterCtx := rkmidlog.NewAfterCtx()
+	mock := rkmidlog.NewOptionSetMock(beforeCtx, afterCtx)
+	beforeCtx.Output.Logger = rkentry.LoggerEntryNoop.Logger
+
+	handler := func(ctx echo.Context) error {
+		req := struct {
+			User string `json:"user"`
+		}{}
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+		ctx.Response().Header().Set("X-Ut-Secret", "ut-secret")
+		return ctx.JSON(http.StatusOK, map[string]string{"user": req.User, "password": "ut-pwd"})
+	}
+
+	newJsonCtx := func(p string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader(`{"user":"ut","password":"ut-pwd"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer ut-token")
+		resp := httptest.NewRecorder()
+		return echo.New().NewContext(req, resp), resp
+	}
+
+	inter := MiddlewareWithOptions(
+		WithLogOptions(rkmidlog.WithMockOptionSet(mock)),
+		WithBodyCapture(0),
+		WithBodyPaths("/ut-partner"),
+		WithBodyContentTypes(echo.MIMEApplicationJSON, echo.MIMETextPlain),
+		WithRedactFields("password"),
+		WithRedactHeaders("X-Ut-Secret"))
+
+	// happy case
+	event := rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, w := newJsonCtx("/ut-partner")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user":"ut","password":"ut-pwd"}`, w.Body.String())
+	assert.Equal(t, w, ctx.Response().Writer)
+
+	payloads := payloadsOf(event)
+	assert.JSONEq(t, `{"user":"ut","password":"******"}`, payloads["reqBody"])
+	assert.JSONEq(t, `{"user":"ut","password":"******"}`, payloads["resBody"])
+	assert.Equal(t, "false", payloads["resBodyTruncated"])
+	assert.Contains(t, payloads["reqHeaders"], "Authorization:******")
+	assert.Contains(t, payloads["resHeaders"], "X-Ut-Secret:******")
+
+	// path not matched
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, w = newJsonCtx("/ut-path")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, payloadsOf(event), "reqBody")
+
+	// with gzip middleware, compressed response would not be captured
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, w = newJsonCtx("/ut-partner")
+	ctx.Request().Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	assert.Nil(t, inter(rkechogzip.Middleware()(handler))(ctx))
+	assert.Equal(t, "gzip", w.Header().Get(echo.HeaderContentEncoding))
+	payloads = payloadsOf(event)
+	assert.JSONEq(t, `{"user":"ut","password":"******"}`, payloads["reqBody"])
+	assert.NotContains(t, payloads, "resBody")
+
+	// streaming response is flushed through
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, w = newJsonCtx("/ut-partner")
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+		ctx.Response().WriteHeader(http.StatusOK)
+		for i := 0; i < 3; i++ {
+			ctx.Response().Write([]byte("ut-chunk;"))
+			ctx.Response().Flush()
+		}
+		return nil
+	})(ctx))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "ut-chunk;ut-chunk;ut-chunk;", w.Body.String())
+	payloads = payloadsOf(event)
+	assert.Equal(t, "ut-chunk;ut-chunk;ut-chunk;", payloads["resBody"])
+	assert.Empty(t, payloads["reqBody"])
+}
+
+func TestMiddlewareWithOptions_Sampling(t *testing.T) {
+	defer assertNotPanic(t)
+
+	beforeCtx := rkmidlog.NewBeforeCtx()
+	afterCtx := rkmidlog.NewAfterCtx()
+	mock := rkmidlog.NewOptionSetMock(beforeCtx, afterCtx)
+	beforeCtx.Output.Logger = rkentry.LoggerEntryNoop.Logger
+
+	registry := prometheus.NewRegistry()
+	inter := MiddlewareWithOptions(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithLogOptions(rkmidlog.WithMockOptionSet(mock)),
+		WithRegisterer(registry),
+		WithBodyCapture(0),
+		WithSampling(0))
+
+	// dropped, response writer restored and event not filled
+	event := rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, w := newCtx()
+	ctx.SetPath("/ut-path")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, w, ctx.Response().Writer)
+	assert.Empty(t, event.ListPayloads())
+
+	// error is logged
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, _ = newCtx()
+	ctx.SetPath("/ut-path")
+	assert.NotNil(t, inter(func(ctx echo.Context) error {
+		return echo.ErrInternalServerError
+	})(ctx))
+	assert.NotEmpty(t, event.ListPayloads())
+
+	vec := optionsMap["ut-entry"].metricsSet.GetCounter(MetricsNameLogSampling)
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-path", DecisionDropped)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-path", DecisionError)))
+}
+
+func TestMiddlewareWithOptions_AccessLog(t *testing.T) {
+	defer assertNotPanic(t)
+
+	beforeCtx := rkmidlog.NewBeforeCtx()
+	afterCtx := rkmidlog.NewAfterCtx()
+	mock := rkmidlog.NewOptionSetMock(beforeCtx, afterCtx)
+
+	core, logs := observer.New(zap.InfoLevel)
+	beforeCtx.Output.Logger = zap.New(core)
+
+	// alongside event log
+	inter := MiddlewareWithOptions(
+		WithLogOptions(rkmidlog.WithMockOptionSet(mock)),
+		WithAccessLogTemplate("${method} ${path} ${status} ${user}"))
+
+	event := rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, _ := newCtx()
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Id: "ut-principal"})
+		return userHandler(ctx)
+	})(ctx))
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "GET /ut-path 200 ut-principal", logs.TakeAll()[0].Message)
+	assert.Equal(t, "ut-principal", event.GetValueFromPair("authPrincipal"))
+
+	// instead of event log
+	inter = MiddlewareWithOptions(
+		WithLogOptions(rkmidlog.WithMockOptionSet(mock)),
+		WithAccessLogTemplate("${method} ${path} ${status}"),
+		WithEventLogDisabled())
+
+	event = rkquery.NewEventFactory().CreateEvent()
+	beforeCtx.Output.Event = event
+	ctx, _ = newCtx()
+	assert.NotNil(t, inter(func(ctx echo.Context) error {
+		ctx.Set(rkechoctx.AuthPrincipalKey, &rkechoctx.AuthPrincipal{Id: "ut-principal"})
+		return echo.ErrForbidden
+	})(ctx))
+	assert.Equal(t, "GET /ut-path 403", logs.TakeAll()[0].Message)
+	assert.Empty(t, event.GetValueFromPair("authPrincipal"))
+
+	// client ip, X-Forwarded-For is not trusted without proxy
+	inter = MiddlewareWithOptions(
+		WithLogOptions(rkmidlog.WithMockOptionSet(mock)),
+		WithAccessLogTemplate("${remote_ip}"))
+
+	beforeCtx.Output.Event = rkquery.NewEventFactory().CreateEvent()
+	ctx, _ = newCtx()
+	ctx.Request().RemoteAddr = "1.1.1.1:1234"
+	ctx.Request().Header.Set(echo.HeaderXForwardedFor, "2.2.2.2")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, "1.1.1.1", logs.TakeAll()[0].Message)
+	assert.Equal(t, "1.1.1.1:1234", beforeCtx.Input.RemoteAddr)
+
+	// client ip resolved by proxy
+	ctx, _ = newCtx()
+	ctx.Request().RemoteAddr = "10.0.0.1:1234"
+	ctx.Set(rkechoctx.ClientIPKey, "2.2.2.2")
+	assert.Nil(t, inter(userHandler)(ctx))
+	assert.Equal(t, "2.2.2.2", logs.TakeAll()[0].Message)
+	assert.Equal(t, "2.2.2.2:1234", beforeCtx.Input.RemoteAddr)
+}
+
+// payloadsOf returns payloads of event as strings
+func payloadsOf(event rkquery.Event) map[string]string {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range event.ListPayloads() {
+		f.AddTo(enc)
+	}
+
+	res := make(map[string]string)
+	for k, v := range enc.Fields {
+		res[k] = fmt.Sprintf("%v", v)
+	}
+
+	return res
 }
 
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
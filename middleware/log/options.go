@@ -0,0 +1,437 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/log"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rs/xid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	optionsMap = make(map[string]*optionSet)
+
+	// defaultBodyMaxBytes is size of body captured from request and response
+	defaultBodyMaxBytes = 4096
+	// defaultBodyContentTypes are media types of body which would be captured, wildcard is supported
+	defaultBodyContentTypes = []string{
+		echo.MIMEApplicationJSON,
+		"application/*+json",
+		echo.MIMEApplicationForm,
+	}
+	// defaultRedactHeaders are headers whose values would never be logged
+	defaultRedactHeaders = []string{
+		echo.HeaderAuthorization,
+		"Proxy-Authorization",
+		echo.HeaderCookie,
+		echo.HeaderSetCookie,
+	}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName: "",
+		EntryType: "",
+		logOpts:   make([]rkmidlog.Option, 0),
+		body: &bodyOptions{
+			maxBytes:      defaultBodyMaxBytes,
+			contentTypes:  defaultBodyContentTypes,
+			redactHeaders: make(map[string]bool),
+		},
+		sampling: &samplingOptions{
+			rate:   1,
+			rules:  make([]*samplingRule, 0),
+			random: defaultRandom,
+		},
+		accessLog: &accessLogOptions{
+			format: AccessLogFormatCombined,
+		},
+		registerer: prometheus.DefaultRegisterer,
+	}
+
+	for _, h := range defaultRedactHeaders {
+		set.body.redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidlog
+	if len(set.EntryName) > 0 {
+		set.logSet = rkmidlog.NewOptionSet(append(set.logOpts,
+			rkmidlog.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	} else {
+		set.logSet = rkmidlog.NewOptionSet(append([]rkmidlog.Option{
+			rkmidlog.WithEntryNameAndType(xid.New().String(), "")}, set.logOpts...)...)
+		set.EntryName = set.logSet.GetEntryName()
+		set.EntryType = set.logSet.GetEntryType()
+	}
+
+	set.body.compile()
+
+	if err := set.accessLog.build(); err != nil {
+		rkentry.ShutdownWithError(err)
+	}
+
+	if set.sampling.enabled {
+		set.metricsSet = rkmidprom.NewMetricsSet("rk", "log", set.registerer)
+		set.metricsSet.RegisterCounter(MetricsNameLogSampling, samplingLabelKeys...)
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName  string
+	EntryType  string
+	logOpts    []rkmidlog.Option
+	logSet     rkmidlog.OptionSetInterface
+	body       *bodyOptions
+	sampling   *samplingOptions
+	accessLog  *accessLogOptions
+	registerer prometheus.Registerer
+	metricsSet *rkmidprom.MetricsSet
+}
+
+// shouldCaptureBody returns true if body capture enabled for request path
+func (set *optionSet) shouldCaptureBody(ctx echo.Context) bool {
+	// nothing would be written into event log
+	if !set.body.enabled || set.eventLogDisabled() || ctx.Request().URL == nil {
+		return false
+	}
+
+	urlPath := ctx.Request().URL.Path
+	if set.logSet.ShouldIgnore(urlPath) {
+		return false
+	}
+
+	if len(set.body.paths) < 1 {
+		return true
+	}
+
+	for _, prefix := range set.body.paths {
+		if strings.HasPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventLogDisabled returns true if only access log would be written
+func (set *optionSet) eventLogDisabled() bool {
+	return set.accessLog.enabled && set.accessLog.eventOff
+}
+
+// shouldLog returns true if log of request should be written, decision would be recorded in metrics
+func (set *optionSet) shouldLog(ctx echo.Context, err error, elapsed time.Duration) bool {
+	if !set.sampling.enabled || ctx.Request().URL == nil {
+		return true
+	}
+
+	decision := set.sampling.decide(ctx.Request().URL.Path, ctx.Response().Status, err, elapsed)
+
+	if vec := set.metricsSet.GetCounter(MetricsNameLogSampling); vec != nil {
+		vec.WithLabelValues(set.EntryName, set.EntryType, ctx.Path(), decision).Inc()
+	}
+
+	return decision != DecisionDropped
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidlog.BootConfig with body capture, sampling and access log
+type BootConfig struct {
+	rkmidlog.BootConfig `yaml:",inline" mapstructure:",squash"`
+	Body                BodyConfig      `yaml:"body" json:"body"`
+	Sampling            SamplingConfig  `yaml:"sampling" json:"sampling"`
+	AccessLog           AccessLogConfig `yaml:"accessLog" json:"accessLog"`
+}
+
+// BodyConfig for YAML, describes how request and response body would be captured in event
+type BodyConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	MaxBytes      int      `yaml:"maxBytes" json:"maxBytes"`
+	ContentTypes  []string `yaml:"contentTypes" json:"contentTypes"`
+	Paths         []string `yaml:"paths" json:"paths"`
+	RedactFields  []string `yaml:"redactFields" json:"redactFields"`
+	RedactHeaders []string `yaml:"redactHeaders" json:"redactHeaders"`
+}
+
+// SamplingConfig for YAML, describes how logs of requests would be sampled
+type SamplingConfig struct {
+	Enabled         bool                 `yaml:"enabled" json:"enabled"`
+	Rate            *float64             `yaml:"rate" json:"rate"`
+	SlowThresholdMs int                  `yaml:"slowThresholdMs" json:"slowThresholdMs"`
+	Paths           []SamplingPathConfig `yaml:"paths" json:"paths"`
+}
+
+// SamplingPathConfig for YAML, sampling rate of path prefix
+type SamplingPathConfig struct {
+	Path string  `yaml:"path" json:"path"`
+	Rate float64 `yaml:"rate" json:"rate"`
+}
+
+// AccessLogConfig for YAML, describes format and output of access log
+type AccessLogConfig struct {
+	Enabled         bool     `yaml:"enabled" json:"enabled"`
+	Format          string   `yaml:"format" json:"format"`
+	Template        string   `yaml:"template" json:"template"`
+	OutputPaths     []string `yaml:"outputPaths" json:"outputPaths"`
+	MaxSizeMb       int      `yaml:"maxSizeMb" json:"maxSizeMb"`
+	MaxBackups      int      `yaml:"maxBackups" json:"maxBackups"`
+	MaxAgeDays      int      `yaml:"maxAgeDays" json:"maxAgeDays"`
+	DisableEventLog bool     `yaml:"disableEventLog" json:"disableEventLog"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig,
+	entryName, entryType string,
+	loggerEntry *rkentry.LoggerEntry,
+	eventEntry *rkentry.EventEntry,
+	registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithLogOptions(rkmidlog.ToOptions(&config.BootConfig, entryName, entryType, loggerEntry, eventEntry)...))
+
+		if config.Body.Enabled {
+			opts = append(opts,
+				WithBodyCapture(config.Body.MaxBytes),
+				WithBodyContentTypes(config.Body.ContentTypes...),
+				WithBodyPaths(config.Body.Paths...),
+				WithRedactFields(config.Body.RedactFields...),
+				WithRedactHeaders(config.Body.RedactHeaders...))
+		}
+
+		if config.Sampling.Enabled {
+			rate := 1.0
+			if config.Sampling.Rate != nil {
+				rate = *config.Sampling.Rate
+			}
+
+			opts = append(opts,
+				WithRegisterer(registerer),
+				WithSampling(rate),
+				WithSlowThreshold(time.Duration(config.Sampling.SlowThresholdMs)*time.Millisecond))
+
+			for _, p := range config.Sampling.Paths {
+				opts = append(opts, WithSamplingByPath(p.Path, p.Rate))
+			}
+		}
+
+		if config.AccessLog.Enabled {
+			opts = append(opts,
+				WithAccessLog(config.AccessLog.Format),
+				WithAccessLogOutputPaths(config.AccessLog.OutputPaths...),
+				WithAccessLogRotation(config.AccessLog.MaxSizeMb, config.AccessLog.MaxBackups, config.AccessLog.MaxAgeDays))
+
+			if len(config.AccessLog.Template) > 0 {
+				opts = append(opts, WithAccessLogTemplate(config.AccessLog.Template))
+			}
+
+			if config.AccessLog.DisableEventLog {
+				opts = append(opts, WithEventLogDisabled())
+			}
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithLogOptions provide options of event and zap logger like encoding and output paths.
+func WithLogOptions(opts ...rkmidlog.Option) Option {
+	return func(opt *optionSet) {
+		opt.logOpts = append(opt.logOpts, opts...)
+	}
+}
+
+// WithBodyCapture enable capture of request and response body with max bytes of each, default: 4096.
+//
+// Body is captured while it is read or written, streaming is not blocked.
+func WithBodyCapture(maxBytes int) Option {
+	return func(opt *optionSet) {
+		opt.body.enabled = true
+		if maxBytes > 0 {
+			opt.body.maxBytes = maxBytes
+		}
+	}
+}
+
+// WithBodyContentTypes provide media types of body to capture, wildcard like text/* is supported,
+// default: JSON and form.
+//
+// Redact fields apply to JSON and form only, bodies of other media types like XML or plain text are logged as they are.
+func WithBodyContentTypes(types ...string) Option {
+	return func(opt *optionSet) {
+		res := make([]string, 0)
+		for i := range types {
+			if len(types[i]) > 0 {
+				res = append(res, strings.ToLower(types[i]))
+			}
+		}
+
+		if len(res) > 0 {
+			opt.body.contentTypes = res
+		}
+	}
+}
+
+// WithBodyPaths provide path prefixes where body would be captured, default: all paths.
+func WithBodyPaths(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.body.paths = append(opt.body.paths, prefix[i])
+			}
+		}
+	}
+}
+
+// WithRedactFields provide fields of JSON or form body whose values would be masked,
+// nested field is separated with dot like card.number, fields are matched at any depth and in every element of arrays.
+// JSON body which can not be parsed, like truncated one, is replaced with masked value as a whole.
+func WithRedactFields(fields ...string) Option {
+	return func(opt *optionSet) {
+		for i := range fields {
+			if len(fields[i]) > 0 {
+				opt.body.redactFields = append(opt.body.redactFields, fields[i])
+			}
+		}
+	}
+}
+
+// WithRedactHeaders provide headers whose values would be masked,
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie are masked by default.
+func WithRedactHeaders(headers ...string) Option {
+	return func(opt *optionSet) {
+		for i := range headers {
+			if len(headers[i]) > 0 {
+				opt.body.redactHeaders[http.CanonicalHeaderKey(headers[i])] = true
+			}
+		}
+	}
+}
+
+// WithSampling enable sampling of logs with rate in range of [0, 1] for paths without rule.
+//
+// Requests failed with error or status >= 500 and slow requests are always logged.
+// Sampled out requests are still processed by other middlewares like prom and trace.
+func WithSampling(rate float64) Option {
+	return func(opt *optionSet) {
+		opt.sampling.enabled = true
+		opt.sampling.rate = clampRate(rate)
+	}
+}
+
+// WithSlowThreshold provide threshold of latency, requests slower than it are always logged if sampling enabled.
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(opt *optionSet) {
+		if threshold > 0 {
+			opt.sampling.slowThreshold = threshold
+		}
+	}
+}
+
+// WithSamplingByPath provide sampling rate of path prefix, longest prefix matched takes precedence.
+func WithSamplingByPath(prefix string, rate float64) Option {
+	return func(opt *optionSet) {
+		if len(prefix) > 0 {
+			opt.sampling.addRule(prefix, clampRate(rate))
+		}
+	}
+}
+
+// WithAccessLog enable access log with format of common (clf), combined, template, ecs or otel, default: combined.
+//
+// Access log is written into logger of LoggerEntry, or files provided with WithAccessLogOutputPaths.
+func WithAccessLog(format string) Option {
+	return func(opt *optionSet) {
+		opt.accessLog.enabled = true
+		if f, ok := accessLogFormats[strings.ToLower(format)]; ok {
+			opt.accessLog.format = f
+		}
+	}
+}
+
+// WithAccessLogTemplate enable access log with template, tags are same as echo logger middleware,
+// like ${remote_ip}, ${method}, ${uri}, ${status}, ${latency_human}, ${header:X-Foo} and ${query:foo}.
+func WithAccessLogTemplate(template string) Option {
+	return func(opt *optionSet) {
+		opt.accessLog.enabled = true
+		opt.accessLog.format = AccessLogFormatTemplate
+		opt.accessLog.templateRaw = template
+	}
+}
+
+// WithAccessLogOutputPaths provide files where access log would be written instead of logger of LoggerEntry.
+func WithAccessLogOutputPaths(paths ...string) Option {
+	return func(opt *optionSet) {
+		for i := range paths {
+			if len(paths[i]) > 0 {
+				opt.accessLog.outputPaths = append(opt.accessLog.outputPaths, paths[i])
+			}
+		}
+	}
+}
+
+// WithAccessLogRotation provide rotation policy of access log files, zero value keeps default of lumberjack config in rk-logger.
+func WithAccessLogRotation(maxSizeMb, maxBackups, maxAgeDays int) Option {
+	return func(opt *optionSet) {
+		opt.accessLog.maxSizeMb = maxSizeMb
+		opt.accessLog.maxBackups = maxBackups
+		opt.accessLog.maxAgeDays = maxAgeDays
+	}
+}
+
+// WithEventLogDisabled write access log instead of event log, works only if access log enabled.
+func WithEventLogDisabled() Option {
+	return func(opt *optionSet) {
+		opt.accessLog.eventOff = true
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for sampling metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return WithLogOptions(rkmidlog.WithPathToIgnore(prefix...))
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.NotNil(t, set.logSet)
+	assert.Equal(t, set.EntryName, set.logSet.GetEntryName())
+	assert.False(t, set.body.enabled)
+	assert.Equal(t, defaultBodyMaxBytes, set.body.maxBytes)
+	assert.Equal(t, defaultBodyContentTypes, set.body.contentTypes)
+	assert.True(t, set.body.redactHeaders[echo.HeaderAuthorization])
+	assert.True(t, set.body.redactHeaders[echo.HeaderSetCookie])
+
+	// with entry name of rkmidlog
+	set = newOptionSet(WithLogOptions(rkmidlog.WithEntryNameAndType("ut-log-name", "ut-log-type")))
+	assert.Equal(t, "ut-log-name", set.EntryName)
+	assert.Equal(t, "ut-log-type", set.EntryType)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithLogOptions(rkmidlog.WithEntryNameAndType("ut-log-name", "ut-log-type")))
+	assert.Equal(t, "ut-name", set.logSet.GetEntryName())
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithBodyCapture(16),
+		WithBodyContentTypes("Application/JSON", ""),
+		WithBodyPaths("/ut-partner", ""),
+		WithRedactFields("password", "card.number"),
+		WithRedactHeaders("x-ut-secret"),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Equal(t, "ut-name", set.logSet.GetEntryName())
+	assert.True(t, set.body.enabled)
+	assert.Equal(t, 16, set.body.maxBytes)
+	assert.Equal(t, []string{"application/json"}, set.body.contentTypes)
+	assert.Equal(t, []string{"/ut-partner"}, set.body.paths)
+	assert.Equal(t, [][]string{{"password"}, {"card", "number"}}, set.body.fieldPaths)
+	assert.True(t, set.body.redactHeaders["X-Ut-Secret"])
+	assert.True(t, set.logSet.ShouldIgnore("/ut-ignore"))
+
+	// with sampling
+	registry := prometheus.NewRegistry()
+	set = newOptionSet(
+		WithRegisterer(registry),
+		WithSampling(2),
+		WithSlowThreshold(time.Second),
+		WithSamplingByPath("/ut-path", 0.1),
+		WithSamplingByPath("", 0.2))
+	assert.True(t, set.sampling.enabled)
+	assert.Equal(t, float64(1), set.sampling.rate)
+	assert.Equal(t, time.Second, set.sampling.slowThreshold)
+	assert.Len(t, set.sampling.rules, 1)
+	assert.Equal(t, registry, set.registerer)
+	assert.NotNil(t, set.metricsSet.GetCounter(MetricsNameLogSampling))
+
+	// with access log
+	set = newOptionSet(
+		WithAccessLog("CLF"),
+		WithAccessLogRotation(10, 2, 3),
+		WithEventLogDisabled(),
+		WithBodyCapture(0))
+	assert.True(t, set.accessLog.enabled)
+	assert.Equal(t, AccessLogFormatCommon, set.accessLog.format)
+	assert.Equal(t, 10, set.accessLog.maxSizeMb)
+	assert.True(t, set.eventLogDisabled())
+	assert.False(t, set.shouldCaptureBody(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), nil)))
+
+	// with invalid format
+	set = newOptionSet(WithAccessLog("ut-format"))
+	assert.Equal(t, AccessLogFormatCombined, set.accessLog.format)
+
+	// with template
+	set = newOptionSet(WithAccessLogTemplate("${method} ${uri}"))
+	assert.True(t, set.accessLog.enabled)
+	assert.Equal(t, AccessLogFormatTemplate, set.accessLog.format)
+	assert.NotNil(t, set.accessLog.template)
+
+	// with invalid template
+	assert.Panics(t, func() {
+		newOptionSet(WithAccessLogTemplate("${ut-tag}"))
+	})
+
+	// event log disabled without access log
+	set = newOptionSet(WithEventLogDisabled())
+	assert.False(t, set.eventLogDisabled())
+
+	// with invalid max bytes
+	set = newOptionSet(WithBodyCapture(-1))
+	assert.True(t, set.body.enabled)
+	assert.Equal(t, defaultBodyMaxBytes, set.body.maxBytes)
+}
+
+func TestOptionSet_ShouldCaptureBody(t *testing.T) {
+	newCtxWithPath := func(p string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(http.MethodPost, p, nil), httptest.NewRecorder())
+	}
+
+	// disabled
+	set := newOptionSet()
+	assert.False(t, set.shouldCaptureBody(newCtxWithPath("/ut-path")))
+
+	// all paths
+	set = newOptionSet(WithBodyCapture(0))
+	assert.True(t, set.shouldCaptureBody(newCtxWithPath("/ut-path")))
+
+	// with path prefix and ignored path
+	set = newOptionSet(
+		WithBodyCapture(0),
+		WithBodyPaths("/ut-partner"),
+		WithPathToIgnore("/ut-partner/ignore"))
+	assert.True(t, set.shouldCaptureBody(newCtxWithPath("/ut-partner/order")))
+	assert.False(t, set.shouldCaptureBody(newCtxWithPath("/ut-path")))
+	assert.False(t, set.shouldCaptureBody(newCtxWithPath("/ut-partner/ignore")))
+}
+
+func TestOptionSet_ShouldLog(t *testing.T) {
+	newCtxWithPath := func(p string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, p, nil), httptest.NewRecorder())
+		ctx.SetPath(p)
+		return ctx
+	}
+
+	// sampling disabled
+	set := newOptionSet()
+	assert.True(t, set.shouldLog(newCtxWithPath("/ut-path"), nil, 0))
+
+	// sampling enabled
+	set = newOptionSet(
+		WithEntryNameAndType("ut-entry", "ut-type"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithSampling(0),
+		WithSamplingByPath("/ut-all", 1))
+	assert.False(t, set.shouldLog(newCtxWithPath("/ut-path"), nil, 0))
+	assert.False(t, set.shouldLog(newCtxWithPath("/ut-path"), nil, 0))
+	assert.True(t, set.shouldLog(newCtxWithPath("/ut-path"), errors.New("ut-error"), 0))
+	assert.True(t, set.shouldLog(newCtxWithPath("/ut-all"), nil, 0))
+
+	vec := set.metricsSet.GetCounter(MetricsNameLogSampling)
+	assert.Equal(t, float64(2), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-path", DecisionDropped)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-path", DecisionError)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("ut-entry", "ut-type", "/ut-all", DecisionSampled)))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		BootConfig: rkmidlog.BootConfig{
+			Enabled: false,
+		},
+		Body: BodyConfig{
+			Enabled:       true,
+			MaxBytes:      32,
+			ContentTypes:  []string{"text/*"},
+			Paths:         []string{"/ut-partner"},
+			RedactFields:  []string{"card.number"},
+			RedactHeaders: []string{"X-Ut-Secret"},
+		},
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", "", nil, nil, nil))
+
+	// with enabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type", rkentry.LoggerEntryNoop, rkentry.EventEntryNoop, nil)...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Equal(t, "ut-type", set.logSet.GetEntryType())
+	assert.True(t, set.body.enabled)
+	assert.Equal(t, 32, set.body.maxBytes)
+	assert.Equal(t, []string{"text/*"}, set.body.contentTypes)
+	assert.Equal(t, []string{"/ut-partner"}, set.body.paths)
+	assert.Equal(t, [][]string{{"card", "number"}}, set.body.fieldPaths)
+	assert.True(t, set.body.redactHeaders["X-Ut-Secret"])
+
+	// with body disabled
+	config.Body.Enabled = false
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type", rkentry.LoggerEntryNoop, rkentry.EventEntryNoop, nil)...)
+	assert.False(t, set.body.enabled)
+	assert.False(t, set.sampling.enabled)
+
+	// with sampling
+	rate := 0.5
+	config.Sampling = SamplingConfig{
+		Enabled:         true,
+		Rate:            &rate,
+		SlowThresholdMs: 200,
+		Paths:           []SamplingPathConfig{{Path: "/ut-path", Rate: 0.01}},
+	}
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type",
+		rkentry.LoggerEntryNoop, rkentry.EventEntryNoop, prometheus.NewRegistry())...)
+	assert.True(t, set.sampling.enabled)
+	assert.Equal(t, 0.5, set.sampling.rate)
+	assert.Equal(t, 200*time.Millisecond, set.sampling.slowThreshold)
+	assert.Equal(t, 0.01, set.sampling.rateOf("/ut-path"))
+
+	assert.False(t, set.accessLog.enabled)
+
+	// with access log
+	config.AccessLog = AccessLogConfig{
+		Enabled:         true,
+		Format:          "ut-format",
+		Template:        "${status}",
+		OutputPaths:     []string{path.Join(t.TempDir(), "access.log")},
+		MaxSizeMb:       1,
+		DisableEventLog: true,
+	}
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type",
+		rkentry.LoggerEntryNoop, rkentry.EventEntryNoop, prometheus.NewRegistry())...)
+	assert.True(t, set.accessLog.enabled)
+	assert.Equal(t, AccessLogFormatTemplate, set.accessLog.format)
+	assert.NotNil(t, set.accessLog.logger)
+	assert.Equal(t, 1, set.accessLog.maxSizeMb)
+	assert.True(t, set.eventLogDisabled())
+
+	// with sampling rate missing
+	config.Sampling.Rate = nil
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type",
+		rkentry.LoggerEntryNoop, rkentry.EventEntryNoop, prometheus.NewRegistry())...)
+	assert.Equal(t, float64(1), set.sampling.rate)
+}
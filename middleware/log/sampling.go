@@ -0,0 +1,107 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MetricsNameLogSampling records sampling decision of every request, including routes without sampling rule
+	MetricsNameLogSampling = "logSampling"
+
+	// DecisionSampled means request was logged because it was picked by sampling rate
+	DecisionSampled = "sampled"
+	// DecisionError means request was logged because handler returned error or status >= 500
+	DecisionError = "error"
+	// DecisionSlow means request was logged because it was slower than threshold
+	DecisionSlow = "slow"
+	// DecisionDropped means log of request was dropped by sampling
+	DecisionDropped = "dropped"
+)
+
+var samplingLabelKeys = []string{"entryName", "entryType", "route", "decision"}
+
+// samplingRule is sampling rate of path prefix
+type samplingRule struct {
+	prefix string
+	rate   float64
+}
+
+// samplingOptions describes how logs of requests would be sampled
+type samplingOptions struct {
+	enabled       bool
+	rate          float64
+	slowThreshold time.Duration
+	rules         []*samplingRule
+	random        func() float64
+}
+
+// addRule adds or replaces rate of path prefix, rules are sorted by longest prefix
+func (s *samplingOptions) addRule(prefix string, rate float64) {
+	for _, rule := range s.rules {
+		if rule.prefix == prefix {
+			rule.rate = rate
+			return
+		}
+	}
+
+	s.rules = append(s.rules, &samplingRule{prefix: prefix, rate: rate})
+	sort.SliceStable(s.rules, func(i, j int) bool {
+		return len(s.rules[i].prefix) > len(s.rules[j].prefix)
+	})
+}
+
+// rateOf returns rate of longest path prefix matched, default rate if none matched
+func (s *samplingOptions) rateOf(urlPath string) float64 {
+	for _, rule := range s.rules {
+		if strings.HasPrefix(urlPath, rule.prefix) {
+			return rule.rate
+		}
+	}
+
+	return s.rate
+}
+
+// decide returns decision of whether request should be logged.
+//
+// Errors and slow requests are always logged, the others are picked by rate.
+func (s *samplingOptions) decide(urlPath string, status int, err error, elapsed time.Duration) string {
+	if err != nil || status >= http.StatusInternalServerError {
+		return DecisionError
+	}
+
+	if s.slowThreshold > 0 && elapsed >= s.slowThreshold {
+		return DecisionSlow
+	}
+
+	rate := s.rateOf(urlPath)
+	if rate >= 1 || (rate > 0 && s.random() < rate) {
+		return DecisionSampled
+	}
+
+	return DecisionDropped
+}
+
+// clampRate keeps rate in range of [0, 1]
+func clampRate(rate float64) float64 {
+	if rate < 0 {
+		return 0
+	}
+
+	if rate > 1 {
+		return 1
+	}
+
+	return rate
+}
+
+// defaultRandom returns random number in [0, 1), math/rand is safe for concurrent use
+var defaultRandom = rand.Float64
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholog
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSamplingOptions_RateOf(t *testing.T) {
+	s := &samplingOptions{rate: 0.5}
+	s.addRule("/v1", 0.1)
+	s.addRule("/v1/greeter", 0.01)
+	s.addRule("/v1", 0.2)
+
+	assert.Len(t, s.rules, 2)
+	assert.Equal(t, 0.01, s.rateOf("/v1/greeter/ut"))
+	assert.Equal(t, 0.2, s.rateOf("/v1/ut"))
+	assert.Equal(t, 0.5, s.rateOf("/v2/ut"))
+}
+
+func TestSamplingOptions_Decide(t *testing.T) {
+	random := 0.5
+	s := &samplingOptions{
+		rate:          0.4,
+		slowThreshold: time.Second,
+		random: func() float64 {
+			return random
+		},
+	}
+	s.addRule("/ut-all", 1)
+	s.addRule("/ut-none", 0)
+
+	// errors are always logged
+	assert.Equal(t, DecisionError, s.decide("/ut-none", http.StatusOK, errors.New("ut-error"), 0))
+	assert.Equal(t, DecisionError, s.decide("/ut-none", http.StatusServiceUnavailable, nil, 0))
+
+	// slow requests are always logged
+	assert.Equal(t, DecisionSlow, s.decide("/ut-none", http.StatusOK, nil, time.Second))
+
+	// sampled by rate
+	assert.Equal(t, DecisionSampled, s.decide("/ut-all", http.StatusOK, nil, 0))
+	assert.Equal(t, DecisionDropped, s.decide("/ut-none", http.StatusOK, nil, 0))
+	assert.Equal(t, DecisionDropped, s.decide("/ut-path", http.StatusBadRequest, nil, 0))
+	random = 0.1
+	assert.Equal(t, DecisionSampled, s.decide("/ut-path", http.StatusOK, nil, 0))
+	assert.Equal(t, DecisionDropped, s.decide("/ut-none", http.StatusOK, nil, 0))
+
+	// slow threshold disabled
+	s.slowThreshold = 0
+	assert.Equal(t, DecisionDropped, s.decide("/ut-none", http.StatusOK, nil, time.Hour))
+}
+
+func TestClampRate(t *testing.T) {
+	assert.Equal(t, float64(0), clampRate(-1))
+	assert.Equal(t, 0.3, clampRate(0.3))
+	assert.Equal(t, float64(1), clampRate(2))
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechometa
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"strings"
+	"time"
+)
+
+const (
+	// GeneratorUuid generates random UUID (version 4), same as meta middleware of rk-entry
+	GeneratorUuid = "uuid"
+	// GeneratorUuidV7 generates time ordered UUID (version 7)
+	GeneratorUuidV7 = "uuidv7"
+	// GeneratorXid generates 20 chars sortable id with github.com/rs/xid
+	GeneratorXid = "xid"
+	// GeneratorUlid generates 26 chars time ordered id, see https://github.com/ulid/spec
+	GeneratorUlid = "ulid"
+)
+
+// crockford is the base32 alphabet used by ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Generator generates new request id
+type Generator func() string
+
+// NewGenerator returns Generator with name, one of uuid, uuidv7, xid and ulid.
+func NewGenerator(name string) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "", GeneratorUuid:
+		return generateUuid, nil
+	case GeneratorUuidV7:
+		return generateUuidV7, nil
+	case GeneratorXid:
+		return generateXid, nil
+	case GeneratorUlid:
+		return generateUlid, nil
+	}
+
+	return nil, fmt.Errorf("unknown request id generator %s", name)
+}
+
+// generateUuid generates UUID version 4
+func generateUuid() string {
+	return rkmid.GenerateRequestIdWithPrefix("")
+}
+
+// generateXid generates xid
+func generateXid() string {
+	return xid.New().String()
+}
+
+// generateUuidV7 generates UUID version 7 with 48 bits of unix milliseconds followed by random bits, see RFC 9562
+func generateUuidV7() string {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return ""
+	}
+
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = (b[6] & 0x0f) | 0x70 // version 7
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+
+	dst := make([]byte, 36)
+	hex.Encode(dst[0:8], b[0:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:13], b[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:18], b[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:23], b[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:], b[10:])
+
+	return string(dst)
+}
+
+// generateUlid generates ULID with 48 bits of unix milliseconds followed by 80 random bits,
+// encoded into 26 chars of Crockford's base32
+func generateUlid() string {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return ""
+	}
+
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+
+	// 128 bits are encoded from the lowest 5 bits, the first char holds the highest 3 bits
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	dst := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		dst[i] = crockford[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+
+	return string(dst)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechometa
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewGenerator(t *testing.T) {
+	// with unknown generator
+	_, err := NewGenerator("ut-unknown")
+	assert.NotNil(t, err)
+
+	cases := map[string]*regexp.Regexp{
+		"":              regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		GeneratorUuid:   regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		"UUIDv7":        regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		GeneratorXid:    regexp.MustCompile(`^[0-9a-v]{20}$`),
+		GeneratorUlid:   regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+		GeneratorUuidV7: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+	}
+
+	for name, pattern := range cases {
+		generator, err := NewGenerator(name)
+		assert.Nil(t, err)
+
+		first, second := generator(), generator()
+		assert.Regexp(t, pattern, first, name)
+		assert.NotEqual(t, first, second, name)
+	}
+}
+
+func TestGenerator_Ordered(t *testing.T) {
+	// time ordered generators keep order across milliseconds
+	for _, generator := range []Generator{generateUuidV7, generateUlid} {
+		first := generator()
+		waitNextMilli()
+		second := generator()
+		assert.Less(t, first, second)
+	}
+}
+
+func TestGenerateUlid_Timestamp(t *testing.T) {
+	// first 10 chars encode 48 bits of unix milliseconds
+	id := generateUlid()
+
+	var ms uint64
+	for _, c := range id[:10] {
+		ms = ms<<5 | uint64(indexOfCrockford(byte(c)))
+	}
+
+	assert.InDelta(t, float64(nowMilli()), float64(ms), 1000)
+}
+
+func indexOfCrockford(c byte) int {
+	for i := range crockford {
+		if crockford[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func nowMilli() int64 {
+	return time.Now().UnixMilli()
+}
+
+func waitNextMilli() {
+	start := nowMilli()
+	for nowMilli() == start {
+		time.Sleep(100 * time.Microsecond)
+	}
+}
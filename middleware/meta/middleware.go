@@ -14,17 +14,47 @@ import (
 )
 
 // Middleware will add common headers as extension style in http response.
+//
+// Incoming request id would be honored only if sent from trusted CIDRs, see MiddlewareWithOptions.
 func Middleware(opts ...rkmidmeta.Option) echo.MiddlewareFunc {
-	set := rkmidmeta.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithMetaOptions(opts...))
+}
+
+// MiddlewareWithOptions adds common headers same as Middleware with request id extensions.
+//
+// 1: Incoming request id in X-Request-Id or configured header is honored only if request was sent from trusted CIDRs,
+// and its length and format is valid, otherwise a new one would be generated.
+// 2: Generator of request id is selectable, one of uuid, uuidv7, xid and ulid.
+// 3: Request id is stored in context.Context of request, which could be read by rkechoctx.GetRequestIdFromContext().
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
-			beforeCtx := set.BeforeCtx(ctx.Request(), rkechoctx.GetEvent(ctx))
-			set.Before(beforeCtx)
+			req := ctx.Request()
+			if req.URL != nil && set.metaSet.ShouldIgnore(req.URL.Path) {
+				return next(ctx)
+			}
+
+			event := rkechoctx.GetEvent(ctx)
+			beforeCtx := set.metaSet.BeforeCtx(req, event)
+			set.metaSet.Before(beforeCtx)
+
+			// override request id assigned by rkmidmeta which trusts any incoming one
+			requestId := set.requestId.of(req)
+			event.SetRequestId(requestId)
+			event.SetEventId(requestId)
+
+			beforeCtx.Output.RequestId = requestId
+			beforeCtx.Output.HeadersToReturn[rkmid.HeaderRequestId] = requestId
+			if set.requestId.header != rkmid.HeaderRequestId {
+				beforeCtx.Output.HeadersToReturn[set.requestId.header] = requestId
+			}
 
-			ctx.Set(rkmid.HeaderRequestId, beforeCtx.Output.RequestId)
+			ctx.Set(rkmid.HeaderRequestId, requestId)
+			ctx.SetRequest(req.WithContext(rkechoctx.WithRequestId(req.Context(), requestId)))
 
 			for k, v := range beforeCtx.Output.HeadersToReturn {
 				ctx.Response().Header().Set(k, v)
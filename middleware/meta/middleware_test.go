@@ -8,7 +8,9 @@ package rkechometa
 import (
 	"bytes"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/meta"
 	"github.com/stretchr/testify/assert"
 	"net/http"
@@ -32,10 +34,59 @@ func TestMiddleware(t *testing.T) {
 
 	beforeCtx.Input.Event = rkentry.EventEntryNoop.CreateEventNoop()
 	beforeCtx.Output.HeadersToReturn["key"] = "value"
+	beforeCtx.Output.RequestId = "ut-request-id"
 
 	inter(userHandler)(ctx)
 
 	assert.Equal(t, "value", w.Header().Get("key"))
+	// request id assigned by rkmidmeta is overridden since it trusts any incoming one
+	assert.NotEqual(t, "ut-request-id", w.Header().Get(rkmid.HeaderRequestId))
+	assert.Equal(t, w.Header().Get(rkmid.HeaderRequestId), rkechoctx.GetRequestIdFromContext(ctx.Request().Context()))
+}
+
+func TestMiddlewareWithOptions(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := MiddlewareWithOptions(
+		WithMetaOptions(rkmidmeta.WithPrefix("ut"), rkmidmeta.WithPathToIgnore("/ut-ignore")),
+		WithRequestIdHeader("X-Correlation-Id"),
+		WithTrustedCidrs("10.0.0.0/8"),
+		WithGenerator(GeneratorUlid))
+
+	var fromContext string
+	handler := func(ctx echo.Context) error {
+		fromContext = rkechoctx.GetRequestIdFromContext(ctx.Request().Context())
+		return ctx.String(http.StatusOK, "")
+	}
+
+	// with trusted incoming request id
+	ctx, w := newCtx()
+	ctx.Request().RemoteAddr = "10.1.2.3:1234"
+	ctx.Request().Header.Set("X-Correlation-Id", "ut-incoming")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "ut-incoming", w.Header().Get(rkmid.HeaderRequestId))
+	assert.Equal(t, "ut-incoming", w.Header().Get("X-Correlation-Id"))
+	assert.Equal(t, "ut-incoming", fromContext)
+	assert.Equal(t, "ut-incoming", ctx.Get(rkmid.HeaderRequestId))
+	assert.NotEmpty(t, w.Header().Get("X-Ut-App-Name"))
+
+	// with untrusted incoming request id
+	ctx, w = newCtx()
+	ctx.Request().RemoteAddr = "1.2.3.4:1234"
+	ctx.Request().Header.Set("X-Correlation-Id", "ut-incoming")
+	ctx.Request().Header.Set(rkmid.HeaderRequestId, "ut-incoming")
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Len(t, w.Header().Get(rkmid.HeaderRequestId), 26)
+	assert.Equal(t, w.Header().Get(rkmid.HeaderRequestId), fromContext)
+	assert.Equal(t, w.Header().Get(rkmid.HeaderRequestId), rkechoctx.GetRequestId(ctx))
+
+	// with ignored path
+	ctx, w = newCtx()
+	ctx.Request().URL.Path = "/ut-ignore"
+	fromContext = ""
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Empty(t, w.Header().Get(rkmid.HeaderRequestId))
+	assert.Empty(t, fromContext)
 }
 
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
@@ -0,0 +1,279 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechometa
+
+import (
+	"fmt"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/meta"
+	"github.com/rs/xid"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	optionsMap = make(map[string]*optionSet)
+
+	// defaultRequestIdMaxLength is max length of incoming request id
+	defaultRequestIdMaxLength = 128
+	// defaultRequestIdPattern is format of incoming request id, which prevents injection into logs and headers
+	defaultRequestIdPattern = `^[A-Za-z0-9._:\-]+$`
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName: "",
+		EntryType: "",
+		metaOpts:  make([]rkmidmeta.Option, 0),
+		requestId: &requestIdOptions{
+			header:        rkmid.HeaderRequestId,
+			trustedCidrs:  make([]*net.IPNet, 0),
+			maxLength:     defaultRequestIdMaxLength,
+			patternRaw:    defaultRequestIdPattern,
+			generatorName: GeneratorUuid,
+		},
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidmeta
+	if len(set.EntryName) > 0 {
+		set.metaSet = rkmidmeta.NewOptionSet(append(set.metaOpts,
+			rkmidmeta.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	} else {
+		set.metaSet = rkmidmeta.NewOptionSet(append([]rkmidmeta.Option{
+			rkmidmeta.WithEntryNameAndType(xid.New().String(), "")}, set.metaOpts...)...)
+		set.EntryName = set.metaSet.GetEntryName()
+		set.EntryType = set.metaSet.GetEntryType()
+	}
+
+	if err := set.requestId.compile(); err != nil {
+		rkentry.ShutdownWithError(err)
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName string
+	EntryType string
+	metaOpts  []rkmidmeta.Option
+	metaSet   rkmidmeta.OptionSetInterface
+	requestId *requestIdOptions
+}
+
+// requestIdOptions decides whether incoming request id would be honored or a new one generated
+type requestIdOptions struct {
+	header        string
+	trustedCidrs  []*net.IPNet
+	maxLength     int
+	patternRaw    string
+	pattern       *regexp.Regexp
+	generatorName string
+	generator     Generator
+}
+
+// compile pattern and generator
+func (opts *requestIdOptions) compile() error {
+	if len(opts.header) < 1 {
+		opts.header = rkmid.HeaderRequestId
+	}
+	opts.header = http.CanonicalHeaderKey(opts.header)
+
+	if opts.maxLength < 1 {
+		opts.maxLength = defaultRequestIdMaxLength
+	}
+
+	if len(opts.patternRaw) < 1 {
+		opts.patternRaw = defaultRequestIdPattern
+	}
+
+	pattern, err := regexp.Compile(opts.patternRaw)
+	if err != nil {
+		return fmt.Errorf("invalid request id pattern, %v", err)
+	}
+	opts.pattern = pattern
+
+	if opts.generator == nil {
+		if opts.generator, err = NewGenerator(opts.generatorName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isTrusted returns true if request was sent from trusted address, address of connection is used instead of forwarded headers
+func (opts *requestIdOptions) isTrusted(req *http.Request) bool {
+	if len(opts.trustedCidrs) < 1 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range opts.trustedCidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isValid returns true if incoming request id matches length and pattern
+func (opts *requestIdOptions) isValid(requestId string) bool {
+	return len(requestId) > 0 && len(requestId) <= opts.maxLength && opts.pattern.MatchString(requestId)
+}
+
+// of returns incoming request id if trusted and valid, otherwise a new generated one
+func (opts *requestIdOptions) of(req *http.Request) string {
+	if incoming := req.Header.Get(opts.header); len(incoming) > 0 && opts.isTrusted(req) && opts.isValid(incoming) {
+		return incoming
+	}
+
+	return opts.generator()
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	rkmidmeta.BootConfig `yaml:",inline" mapstructure:",squash"`
+	RequestId            RequestIdConfig `yaml:"requestId" json:"requestId"`
+}
+
+// RequestIdConfig for YAML
+type RequestIdConfig struct {
+	Header       string   `yaml:"header" json:"header"`
+	TrustedCidrs []string `yaml:"trustedCidrs" json:"trustedCidrs"`
+	MaxLength    int      `yaml:"maxLength" json:"maxLength"`
+	Pattern      string   `yaml:"pattern" json:"pattern"`
+	Generator    string   `yaml:"generator" json:"generator"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithMetaOptions(rkmidmeta.ToOptions(&config.BootConfig, entryName, entryType)...),
+			WithRequestIdHeader(config.RequestId.Header),
+			WithTrustedCidrs(config.RequestId.TrustedCidrs...),
+			WithRequestIdMaxLength(config.RequestId.MaxLength),
+			WithRequestIdPattern(config.RequestId.Pattern),
+			WithGenerator(config.RequestId.Generator))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithMetaOptions provide options of rkmidmeta, like prefix of headers and paths to ignore.
+func WithMetaOptions(opts ...rkmidmeta.Option) Option {
+	return func(opt *optionSet) {
+		opt.metaOpts = append(opt.metaOpts, opts...)
+	}
+}
+
+// WithRequestIdHeader provide header of incoming request id, default: X-Request-Id.
+//
+// Request id would be returned with both X-Request-Id and the header.
+func WithRequestIdHeader(header string) Option {
+	return func(opt *optionSet) {
+		if len(header) > 0 {
+			opt.requestId.header = header
+		}
+	}
+}
+
+// WithTrustedCidrs provide CIDRs or IP addresses of sources, like load balancer or gateway,
+// whose incoming request id would be honored, default: none.
+func WithTrustedCidrs(cidrs ...string) Option {
+	return func(opt *optionSet) {
+		for i := range cidrs {
+			if len(strings.TrimSpace(cidrs[i])) < 1 {
+				continue
+			}
+
+			cidr, err := rkechointernal.ParseCidr(cidrs[i])
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opt.requestId.trustedCidrs = append(opt.requestId.trustedCidrs, cidr)
+		}
+	}
+}
+
+// WithRequestIdMaxLength provide max length of incoming request id, default: 128.
+func WithRequestIdMaxLength(maxLength int) Option {
+	return func(opt *optionSet) {
+		if maxLength > 0 {
+			opt.requestId.maxLength = maxLength
+		}
+	}
+}
+
+// WithRequestIdPattern provide regular expression incoming request id should match, default: ^[A-Za-z0-9._:\-]+$.
+func WithRequestIdPattern(pattern string) Option {
+	return func(opt *optionSet) {
+		if len(pattern) > 0 {
+			opt.requestId.patternRaw = pattern
+		}
+	}
+}
+
+// WithGenerator provide name of request id generator, one of uuid, uuidv7, xid and ulid, default: uuid.
+func WithGenerator(name string) Option {
+	return func(opt *optionSet) {
+		if len(name) > 0 {
+			opt.requestId.generatorName = name
+			opt.requestId.generator = nil
+		}
+	}
+}
+
+// WithCustomGenerator provide Generator of request id.
+func WithCustomGenerator(generator Generator) Option {
+	return func(opt *optionSet) {
+		if generator != nil {
+			opt.requestId.generator = generator
+		}
+	}
+}
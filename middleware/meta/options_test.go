@@ -0,0 +1,143 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechometa
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/meta"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.NotNil(t, set.metaSet)
+	assert.Equal(t, rkmid.HeaderRequestId, set.requestId.header)
+	assert.Empty(t, set.requestId.trustedCidrs)
+	assert.Equal(t, defaultRequestIdMaxLength, set.requestId.maxLength)
+	assert.NotNil(t, set.requestId.pattern)
+	assert.NotNil(t, set.requestId.generator)
+
+	// with entry name of rkmidmeta
+	set = newOptionSet(WithMetaOptions(rkmidmeta.WithEntryNameAndType("ut-meta-name", "ut-meta-type")))
+	assert.Equal(t, "ut-meta-name", set.EntryName)
+	assert.Equal(t, "ut-meta-type", set.EntryType)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithMetaOptions(rkmidmeta.WithEntryNameAndType("ut-meta-name", "ut-meta-type")))
+	assert.Equal(t, "ut-name", set.metaSet.GetEntryName())
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithMetaOptions(rkmidmeta.WithPrefix("ut")),
+		WithRequestIdHeader("x-correlation-id"),
+		WithTrustedCidrs("10.0.0.0/8", "", "192.168.1.1", "::1"),
+		WithRequestIdMaxLength(16),
+		WithRequestIdPattern("^[a-z]+$"),
+		WithGenerator(GeneratorXid))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Len(t, set.metaOpts, 1)
+	assert.Equal(t, "X-Correlation-Id", set.requestId.header)
+	assert.Len(t, set.requestId.trustedCidrs, 3)
+	assert.Equal(t, 16, set.requestId.maxLength)
+	assert.Equal(t, "^[a-z]+$", set.requestId.pattern.String())
+	assert.Len(t, set.requestId.generator(), 20)
+
+	// with custom generator
+	set = newOptionSet(WithCustomGenerator(func() string {
+		return "ut-id"
+	}))
+	assert.Equal(t, "ut-id", set.requestId.generator())
+
+	// with invalid options
+	assert.Panics(t, func() {
+		newOptionSet(WithTrustedCidrs("ut-invalid"))
+	})
+	assert.Panics(t, func() {
+		newOptionSet(WithTrustedCidrs("10.0.0.0/99"))
+	})
+	assert.Panics(t, func() {
+		newOptionSet(WithRequestIdPattern("(ut-invalid"))
+	})
+	assert.Panics(t, func() {
+		newOptionSet(WithGenerator("ut-invalid"))
+	})
+}
+
+func TestRequestIdOptions_Of(t *testing.T) {
+	set := newOptionSet(
+		WithTrustedCidrs("10.0.0.0/8", "::1"),
+		WithRequestIdMaxLength(16),
+		WithCustomGenerator(func() string {
+			return "ut-generated"
+		}))
+
+	newReq := func(remoteAddr, requestId string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+		req.RemoteAddr = remoteAddr
+		if len(requestId) > 0 {
+			req.Header.Set(rkmid.HeaderRequestId, requestId)
+		}
+		return req
+	}
+
+	// without incoming request id
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("10.1.2.3:1234", "")))
+
+	// with trusted source
+	assert.Equal(t, "ut-incoming", set.requestId.of(newReq("10.1.2.3:1234", "ut-incoming")))
+	assert.Equal(t, "ut-incoming", set.requestId.of(newReq("[::1]:1234", "ut-incoming")))
+
+	// with untrusted source
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("1.2.3.4:1234", "ut-incoming")))
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("ut-invalid", "ut-incoming")))
+
+	// with too long request id
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("10.1.2.3:1234", strings.Repeat("a", 17))))
+
+	// with invalid format
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("10.1.2.3:1234", "ut\r\ninjected")))
+	assert.Equal(t, "ut-generated", set.requestId.of(newReq("10.1.2.3:1234", "ut id")))
+
+	// with untrusted default
+	set = newOptionSet()
+	assert.NotEqual(t, "ut-incoming", set.requestId.of(newReq("10.1.2.3:1234", "ut-incoming")))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		RequestId: RequestIdConfig{
+			Header:       "X-Correlation-Id",
+			TrustedCidrs: []string{"10.0.0.0/8"},
+			MaxLength:    32,
+			Pattern:      "^[a-z0-9]+$",
+			Generator:    GeneratorUlid,
+		},
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.Prefix = "ut"
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Equal(t, "X-Correlation-Id", set.requestId.header)
+	assert.Len(t, set.requestId.trustedCidrs, 1)
+	assert.Equal(t, 32, set.requestId.maxLength)
+	assert.Len(t, set.requestId.generator(), 26)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoproxy
+
+import (
+	"github.com/labstack/echo/v4"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPExtractor returns echo.IPExtractor which could be assigned to echo.Echo, so that echo.Context.RealIP()
+// returns same client ip as rkechoctx.GetClientIP().
+func IPExtractor(opts ...Option) echo.IPExtractor {
+	return newOptionSet(opts...).clientIp
+}
+
+// clientIp returns ip of client.
+//
+// Address of connection is returned if peer is not trusted. Otherwise, addresses in header are walked from right to left,
+// the first one which is not trusted proxy is returned.
+func (set *optionSet) clientIp(req *http.Request) string {
+	peer := peerOf(req)
+	if !set.isTrusted(net.ParseIP(peer)) {
+		return peer
+	}
+
+	var chain []string
+	switch set.header {
+	case HeaderXRealIp:
+		if ip := net.ParseIP(strings.TrimSpace(req.Header.Get(echo.HeaderXRealIP))); ip != nil {
+			return ip.String()
+		}
+		return peer
+	case HeaderForwarded:
+		for _, elem := range forwardedElements(req.Header) {
+			if v, ok := elem["for"]; ok {
+				chain = append(chain, v)
+			}
+		}
+	default:
+		for _, line := range req.Header.Values(echo.HeaderXForwardedFor) {
+			chain = append(chain, strings.Split(line, ",")...)
+		}
+	}
+
+	res := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(chain[i]))
+		if ip == nil {
+			// obfuscated or unknown identifier, stop walking since addresses before it could not be trusted
+			break
+		}
+
+		res = ip.String()
+		if !set.isTrusted(ip) {
+			break
+		}
+	}
+
+	return res
+}
+
+// peerOf returns ip of connection
+func peerOf(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// stripPort removes quotes, brackets and port of address in header, like "[2001:db8::1]:4711" or 192.0.2.1:80
+func stripPort(raw string) string {
+	raw = strings.Trim(strings.TrimSpace(raw), `"`)
+
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return host
+	}
+
+	return strings.Trim(raw, "[]")
+}
+
+// forwardedElements parses Forwarded headers into elements of lowercased key and value, see RFC 7239
+func forwardedElements(header http.Header) []map[string]string {
+	res := make([]map[string]string, 0)
+
+	for _, line := range header.Values("Forwarded") {
+		for _, elem := range strings.Split(line, ",") {
+			pairs := make(map[string]string)
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				pairs[strings.ToLower(k)] = strings.Trim(v, `"`)
+			}
+
+			if len(pairs) > 0 {
+				res = append(res, pairs)
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoproxy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newReq(remoteAddr string, headers ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+	return req
+}
+
+func TestIPExtractor_XForwardedFor(t *testing.T) {
+	extract := IPExtractor(WithTrustedCidrs("10.0.0.0/8"))
+
+	// untrusted peer
+	assert.Equal(t, "1.1.1.1", extract(newReq("1.1.1.1:1234", "X-Forwarded-For", "2.2.2.2")))
+
+	// trusted peer without header
+	assert.Equal(t, "10.0.0.1", extract(newReq("10.0.0.1:1234")))
+
+	// trusted peer, rightmost untrusted address is client
+	assert.Equal(t, "3.3.3.3", extract(newReq("10.0.0.1:1234", "X-Forwarded-For", "2.2.2.2, 3.3.3.3, 10.0.0.2")))
+
+	// multiple headers
+	assert.Equal(t, "3.3.3.3", extract(newReq("10.0.0.1:1234",
+		"X-Forwarded-For", "2.2.2.2", "X-Forwarded-For", "3.3.3.3,10.0.0.2")))
+
+	// all trusted
+	assert.Equal(t, "10.0.0.3", extract(newReq("10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2")))
+
+	// invalid address stops walking
+	assert.Equal(t, "10.0.0.2", extract(newReq("10.0.0.1:1234", "X-Forwarded-For", "2.2.2.2, ut-invalid, 10.0.0.2")))
+
+	// ipv6 with port
+	assert.Equal(t, "2001:db8::1", extract(newReq("10.0.0.1:1234", "X-Forwarded-For", "[2001:db8::1]:4711")))
+
+	// remote address without port
+	assert.Equal(t, "ut-addr", extract(newReq("ut-addr")))
+}
+
+func TestIPExtractor_XRealIp(t *testing.T) {
+	extract := IPExtractor(WithTrustedCidrs("10.0.0.0/8"), WithHeader(HeaderXRealIp))
+
+	// untrusted peer
+	assert.Equal(t, "1.1.1.1", extract(newReq("1.1.1.1:1234", "X-Real-Ip", "2.2.2.2")))
+
+	// trusted peer
+	assert.Equal(t, "2.2.2.2", extract(newReq("10.0.0.1:1234", "X-Real-Ip", "2.2.2.2")))
+
+	// invalid header
+	assert.Equal(t, "10.0.0.1", extract(newReq("10.0.0.1:1234", "X-Real-Ip", "ut-invalid")))
+}
+
+func TestIPExtractor_Forwarded(t *testing.T) {
+	extract := IPExtractor(WithTrustedCidrs("10.0.0.0/8"), WithHeader(HeaderForwarded))
+
+	// untrusted peer
+	assert.Equal(t, "1.1.1.1", extract(newReq("1.1.1.1:1234", "Forwarded", "for=2.2.2.2")))
+
+	// trusted peer
+	assert.Equal(t, "3.3.3.3", extract(newReq("10.0.0.1:1234",
+		"Forwarded", `for=2.2.2.2;proto=http, For="3.3.3.3:80";by=10.0.0.1`,
+		"Forwarded", "for=10.0.0.2;host=example.com")))
+
+	// ipv6
+	assert.Equal(t, "2001:db8:cafe::17", extract(newReq("10.0.0.1:1234", "Forwarded", `for="[2001:db8:cafe::17]:4711"`)))
+
+	// obfuscated identifier
+	assert.Equal(t, "10.0.0.2", extract(newReq("10.0.0.1:1234", "Forwarded", "for=_hidden, for=10.0.0.2")))
+
+	// element without for
+	assert.Equal(t, "10.0.0.1", extract(newReq("10.0.0.1:1234", "Forwarded", "proto=https;host=example.com")))
+}
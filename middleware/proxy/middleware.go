@@ -0,0 +1,87 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechoproxy is a middleware for echo framework which resolves client ip, scheme and host behind trusted proxies
+package rkechoproxy
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Middleware resolves client ip behind trusted proxies and assigns it to context, which could be read by rkechoctx.GetClientIP().
+//
+// 1: Client ip is selected from X-Forwarded-For, X-Real-Ip or Forwarded sent by trusted proxies.
+// 2: Scheme and host could be rewritten with X-Forwarded-Proto, X-Forwarded-Host or Forwarded.
+// 3: Forwarding headers sent by untrusted peers are removed, so that they could not be spoofed by clients.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			req := ctx.Request()
+			if !set.isTrusted(net.ParseIP(peerOf(req))) {
+				for _, h := range forwardingHeaders {
+					req.Header.Del(h)
+				}
+				ctx.Set(rkechoctx.ClientIPKey, peerOf(req))
+				return next(ctx)
+			}
+
+			ctx.Set(rkechoctx.ClientIPKey, set.clientIp(req))
+
+			if set.rewriteScheme {
+				// echo.Context.Scheme() reads X-Forwarded-Proto
+				if scheme := set.forwardedValue(req.Header, "proto", echo.HeaderXForwardedProto); scheme == "http" || scheme == "https" {
+					req.URL.Scheme = scheme
+					req.Header.Set(echo.HeaderXForwardedProto, scheme)
+				} else {
+					req.Header.Del(echo.HeaderXForwardedProto)
+				}
+			}
+
+			if set.rewriteHost {
+				if host := set.forwardedValue(req.Header, "host", "X-Forwarded-Host"); len(host) > 0 {
+					req.Host = host
+				}
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// forwardedValue returns value of key in nearest element of Forwarded if selected, otherwise the rightmost value in header.
+//
+// Values on the left are sent by client or farther proxies and could be spoofed, the rightmost one is appended by nearest proxy.
+func (set *optionSet) forwardedValue(header http.Header, key, xHeader string) string {
+	if set.header == HeaderForwarded {
+		elems := forwardedElements(header)
+		for i := len(elems) - 1; i >= 0; i-- {
+			if v, ok := elems[i][key]; ok {
+				return strings.ToLower(strings.TrimSpace(v))
+			}
+		}
+		return ""
+	}
+
+	values := header.Values(xHeader)
+	for i := len(values) - 1; i >= 0; i-- {
+		elems := strings.Split(values[i], ",")
+		for j := len(elems) - 1; j >= 0; j-- {
+			if v := strings.TrimSpace(elems[j]); len(v) > 0 {
+				return strings.ToLower(v)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoproxy
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer assertNotPanic(t)
+
+	var clientIp, scheme, host, xff string
+	handler := func(ctx echo.Context) error {
+		clientIp = rkechoctx.GetClientIP(ctx)
+		scheme = ctx.Scheme()
+		host = ctx.Request().Host
+		xff = ctx.Request().Header.Get(echo.HeaderXForwardedFor)
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	inter := Middleware(
+		WithTrustedCidrs("10.0.0.0/8"),
+		WithRewriteScheme(true),
+		WithRewriteHost(true))
+
+	// with untrusted peer, forwarding headers are removed
+	ctx := echo.New().NewContext(newReq("1.1.1.1:1234",
+		"X-Forwarded-For", "2.2.2.2",
+		"X-Forwarded-Proto", "https",
+		"X-Forwarded-Host", "ut-evil.com"), httptest.NewRecorder())
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "1.1.1.1", clientIp)
+	assert.Equal(t, "http", scheme)
+	assert.Equal(t, "example.com", host)
+	assert.Empty(t, xff)
+
+	// with trusted peer
+	ctx = echo.New().NewContext(newReq("10.0.0.1:1234",
+		"X-Forwarded-For", "2.2.2.2",
+		"X-Forwarded-Proto", "HTTPS",
+		"X-Forwarded-Host", "ut-host.com"), httptest.NewRecorder())
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "2.2.2.2", clientIp)
+	assert.Equal(t, "https", scheme)
+	assert.Equal(t, "https", ctx.Request().URL.Scheme)
+	assert.Equal(t, "ut-host.com", host)
+	assert.Equal(t, "2.2.2.2", xff)
+
+	// with values spoofed by client, the one appended by nearest proxy is used
+	req := newReq("10.0.0.1:1234",
+		"X-Forwarded-Proto", "http, https",
+		"X-Forwarded-Host", "ut-evil.com, ut-host.com")
+	req.Header.Add("X-Forwarded-Host", "ut-evil.com,ut-proxy.com")
+	ctx = echo.New().NewContext(req, httptest.NewRecorder())
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "https", scheme)
+	assert.Equal(t, "ut-proxy.com", host)
+
+	// with invalid scheme
+	ctx = echo.New().NewContext(newReq("10.0.0.1:1234",
+		"X-Forwarded-Proto", "ut-invalid"), httptest.NewRecorder())
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Equal(t, "http", scheme)
+	assert.Equal(t, "example.com", host)
+}
+
+func TestMiddleware_Forwarded(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := Middleware(
+		WithTrustedCidrs("10.0.0.0/8"),
+		WithHeader(HeaderForwarded),
+		WithRewriteScheme(true),
+		WithRewriteHost(true))
+
+	ctx := echo.New().NewContext(newReq("10.0.0.1:1234",
+		"X-Forwarded-Host", "ut-ignored.com",
+		"Forwarded", `for=2.2.2.2;proto=https;host="ut-host.com"`), httptest.NewRecorder())
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+
+	assert.Equal(t, "2.2.2.2", rkechoctx.GetClientIP(ctx))
+	assert.Equal(t, "https", ctx.Scheme())
+	assert.Equal(t, "ut-host.com", ctx.Request().Host)
+
+	// without rewriting
+	inter = Middleware(WithTrustedCidrs("10.0.0.0/8"), WithHeader(HeaderForwarded))
+	ctx = echo.New().NewContext(newReq("10.0.0.1:1234",
+		"Forwarded", `for=2.2.2.2;proto=https;host="ut-host.com"`), httptest.NewRecorder())
+	assert.Nil(t, inter(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})(ctx))
+	assert.Equal(t, "example.com", ctx.Request().Host)
+}
+
+func assertNotPanic(t *testing.T) {
+	if r := recover(); r != nil {
+		// Expect panic to be called with non nil error
+		assert.True(t, false)
+	} else {
+		// This should never be called in case of a bug
+		assert.True(t, true)
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
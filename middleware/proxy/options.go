@@ -0,0 +1,175 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoproxy
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/internal"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rs/xid"
+	"net"
+	"strings"
+)
+
+const (
+	// HeaderXForwardedFor selects client ip from X-Forwarded-For
+	HeaderXForwardedFor = "x-forwarded-for"
+	// HeaderXRealIp selects client ip from X-Real-Ip
+	HeaderXRealIp = "x-real-ip"
+	// HeaderForwarded selects client ip from Forwarded, see RFC 7239
+	HeaderForwarded = "forwarded"
+)
+
+var (
+	optionsMap = make(map[string]*optionSet)
+
+	// forwardingHeaders are removed from requests sent by untrusted peers
+	forwardingHeaders = []string{
+		echo.HeaderXForwardedFor,
+		echo.HeaderXRealIP,
+		echo.HeaderXForwardedProto,
+		echo.HeaderXForwardedProtocol,
+		echo.HeaderXForwardedSsl,
+		echo.HeaderXUrlScheme,
+		"X-Forwarded-Host",
+		"Forwarded",
+	}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:    xid.New().String(),
+		EntryType:    "",
+		trustedCidrs: make([]*net.IPNet, 0),
+		header:       HeaderXForwardedFor,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	switch set.header {
+	case HeaderXForwardedFor, HeaderXRealIp, HeaderForwarded:
+	default:
+		rkentry.ShutdownWithError(fmt.Errorf("unknown proxy header %s", set.header))
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName     string
+	EntryType     string
+	trustedCidrs  []*net.IPNet
+	header        string
+	rewriteScheme bool
+	rewriteHost   bool
+}
+
+// isTrusted returns true if ip is in trusted CIDRs
+func (set *optionSet) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range set.trustedCidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	TrustedCidrs  []string `yaml:"trustedCidrs" json:"trustedCidrs"`
+	Header        string   `yaml:"header" json:"header"`
+	RewriteScheme bool     `yaml:"rewriteScheme" json:"rewriteScheme"`
+	RewriteHost   bool     `yaml:"rewriteHost" json:"rewriteHost"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithTrustedCidrs(config.TrustedCidrs...),
+			WithHeader(config.Header),
+			WithRewriteScheme(config.RewriteScheme),
+			WithRewriteHost(config.RewriteHost))
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithTrustedCidrs provide CIDRs or IP addresses of proxies like load balancer, default: none.
+//
+// Forwarding headers are honored only if request was sent from trusted proxies.
+func WithTrustedCidrs(cidrs ...string) Option {
+	return func(opt *optionSet) {
+		for i := range cidrs {
+			if len(strings.TrimSpace(cidrs[i])) < 1 {
+				continue
+			}
+
+			cidr, err := rkechointernal.ParseCidr(cidrs[i])
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opt.trustedCidrs = append(opt.trustedCidrs, cidr)
+		}
+	}
+}
+
+// WithHeader provide header client ip is selected from, one of x-forwarded-for, x-real-ip and forwarded,
+// default: x-forwarded-for.
+func WithHeader(header string) Option {
+	return func(opt *optionSet) {
+		if len(header) > 0 {
+			opt.header = strings.ToLower(header)
+		}
+	}
+}
+
+// WithRewriteScheme rewrites scheme of request with X-Forwarded-Proto or proto of Forwarded sent by trusted proxies.
+func WithRewriteScheme(enabled bool) Option {
+	return func(opt *optionSet) {
+		opt.rewriteScheme = enabled
+	}
+}
+
+// WithRewriteHost rewrites host of request with X-Forwarded-Host or host of Forwarded sent by trusted proxies.
+func WithRewriteHost(enabled bool) Option {
+	return func(opt *optionSet) {
+		opt.rewriteHost = enabled
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechoproxy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.Empty(t, set.trustedCidrs)
+	assert.Equal(t, HeaderXForwardedFor, set.header)
+	assert.False(t, set.rewriteScheme)
+	assert.False(t, set.rewriteHost)
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithTrustedCidrs("10.0.0.0/8", "", "192.168.1.1", "::1"),
+		WithHeader("Forwarded"),
+		WithRewriteScheme(true),
+		WithRewriteHost(true))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Len(t, set.trustedCidrs, 3)
+	assert.Equal(t, HeaderForwarded, set.header)
+	assert.True(t, set.rewriteScheme)
+	assert.True(t, set.rewriteHost)
+
+	assert.True(t, set.isTrusted(net.ParseIP("10.1.2.3")))
+	assert.True(t, set.isTrusted(net.ParseIP("192.168.1.1")))
+	assert.True(t, set.isTrusted(net.ParseIP("::1")))
+	assert.False(t, set.isTrusted(net.ParseIP("192.168.1.2")))
+	assert.False(t, set.isTrusted(nil))
+
+	// with invalid options
+	assert.Panics(t, func() {
+		newOptionSet(WithTrustedCidrs("ut-invalid"))
+	})
+	assert.Panics(t, func() {
+		newOptionSet(WithTrustedCidrs("10.0.0.0/99"))
+	})
+	assert.Panics(t, func() {
+		newOptionSet(WithHeader("ut-invalid"))
+	})
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		TrustedCidrs:  []string{"10.0.0.0/8"},
+		Header:        HeaderXRealIp,
+		RewriteScheme: true,
+		RewriteHost:   true,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Len(t, set.trustedCidrs, 1)
+	assert.Equal(t, HeaderXRealIp, set.header)
+	assert.True(t, set.rewriteScheme)
+	assert.True(t, set.rewriteHost)
+}
@@ -8,25 +8,41 @@ package rkecholimit
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/ratelimit"
+	"net/http"
 )
 
 // Middleware Add rate limit interceptors.
 func Middleware(opts ...rkmidlimit.Option) echo.MiddlewareFunc {
-	set := rkmidlimit.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithLimitOptions(opts...))
+}
+
+// MiddlewareWithOptions limits rate same as Middleware, and limits requests of each client additionally if enabled.
+//
+// Client is identified by rkechoctx.GetClientIP(), which is resolved behind trusted proxies if proxy is enabled.
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
 
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			beforeCtx := set.limitSet.BeforeCtx(ctx.Request())
+			set.limitSet.Before(beforeCtx)
 
 			if beforeCtx.Output.ErrResp != nil {
 				return ctx.JSON(beforeCtx.Output.ErrResp.Code(), beforeCtx.Output.ErrResp)
 			}
 
+			if set.clientStore != nil && !set.limitSet.ShouldIgnore(beforeCtx.Input.UrlPath) {
+				if allowed, _ := set.clientStore.Allow(rkechoctx.GetClientIP(ctx)); !allowed {
+					errResp := rkmid.GetErrorBuilder().New(http.StatusTooManyRequests, "Too many requests from client")
+					return ctx.JSON(errResp.Code(), errResp)
+				}
+			}
+
 			return next(ctx)
 		}
 	}
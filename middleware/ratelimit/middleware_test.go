@@ -42,6 +42,37 @@ func TestMiddleware(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestMiddlewareWithOptions(t *testing.T) {
+	defer assertNotPanic(t)
+
+	inter := MiddlewareWithOptions(
+		WithLimitOptions(rkmidlimit.WithPathToIgnore("/ut-ignore")),
+		WithReqPerSecByClient(1, 1))
+
+	serve := func(remoteAddr, path string) int {
+		ctx, w := newCtx()
+		ctx.Request().RemoteAddr = remoteAddr
+		ctx.Request().URL.Path = path
+		inter(userHandler)(ctx)
+		return w.Code
+	}
+
+	// client is limited
+	assert.Equal(t, http.StatusOK, serve("1.1.1.1:1234", "/ut-path"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("1.1.1.1:5678", "/ut-path"))
+
+	// other client is not affected
+	assert.Equal(t, http.StatusOK, serve("2.2.2.2:1234", "/ut-path"))
+
+	// ignored path
+	assert.Equal(t, http.StatusOK, serve("1.1.1.1:1234", "/ut-ignore"))
+
+	// with global limit
+	reqPerSec := 0
+	inter = MiddlewareWithOptions(WithLimitOptions(rkmidlimit.WithReqPerSec(&reqPerSec)))
+	assert.Equal(t, http.StatusTooManyRequests, serve("1.1.1.1:1234", "/ut-path"))
+}
+
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
 	var buf bytes.Buffer
 	req := httptest.NewRequest(http.MethodGet, "/ut-path", &buf)
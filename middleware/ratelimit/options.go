@@ -0,0 +1,143 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholimit
+
+import (
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/ratelimit"
+	"github.com/rs/xid"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+var optionsMap = make(map[string]*optionSet)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:        "",
+		EntryType:        "",
+		limitOpts:        make([]rkmidlimit.Option, 0),
+		clientExpiration: middleware.DefaultRateLimiterMemoryStoreConfig.ExpiresIn,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidlimit
+	if len(set.EntryName) > 0 {
+		set.limitSet = rkmidlimit.NewOptionSet(append(set.limitOpts,
+			rkmidlimit.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	} else {
+		set.limitSet = rkmidlimit.NewOptionSet(append([]rkmidlimit.Option{
+			rkmidlimit.WithEntryNameAndType(xid.New().String(), "")}, set.limitOpts...)...)
+		set.EntryName = set.limitSet.GetEntryName()
+		set.EntryType = set.limitSet.GetEntryType()
+	}
+
+	if set.clientReqPerSec > 0 {
+		if set.clientBurst < 1 {
+			set.clientBurst = int(set.clientReqPerSec)
+		}
+
+		set.clientStore = middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(set.clientReqPerSec),
+			Burst:     set.clientBurst,
+			ExpiresIn: set.clientExpiration,
+		})
+	}
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName        string
+	EntryType        string
+	limitOpts        []rkmidlimit.Option
+	limitSet         rkmidlimit.OptionSetInterface
+	clientReqPerSec  float64
+	clientBurst      int
+	clientExpiration time.Duration
+	clientStore      *middleware.RateLimiterMemoryStore
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	rkmidlimit.BootConfig `yaml:",inline" mapstructure:",squash"`
+	PerClient             PerClientConfig `yaml:"perClient" json:"perClient"`
+}
+
+// PerClientConfig for YAML
+type PerClientConfig struct {
+	Enabled     bool    `yaml:"enabled" json:"enabled"`
+	ReqPerSec   float64 `yaml:"reqPerSec" json:"reqPerSec"`
+	Burst       int     `yaml:"burst" json:"burst"`
+	ExpiresInMs int     `yaml:"expiresInMs" json:"expiresInMs"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithLimitOptions(rkmidlimit.ToOptions(&config.BootConfig, entryName, entryType)...))
+
+		if config.PerClient.Enabled {
+			opts = append(opts,
+				WithReqPerSecByClient(config.PerClient.ReqPerSec, config.PerClient.Burst),
+				WithClientExpiration(time.Duration(config.PerClient.ExpiresInMs)*time.Millisecond))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithLimitOptions provide options of rkmidlimit, like global and per path limits.
+func WithLimitOptions(opts ...rkmidlimit.Option) Option {
+	return func(opt *optionSet) {
+		opt.limitOpts = append(opt.limitOpts, opts...)
+	}
+}
+
+// WithReqPerSecByClient limits requests of each client ip, burst equals to reqPerSec if not positive.
+func WithReqPerSecByClient(reqPerSec float64, burst int) Option {
+	return func(opt *optionSet) {
+		opt.clientReqPerSec = reqPerSec
+		opt.clientBurst = burst
+	}
+}
+
+// WithClientExpiration provide duration after which limiter of idle client is removed, default: 3 minutes.
+func WithClientExpiration(expiration time.Duration) Option {
+	return func(opt *optionSet) {
+		if expiration > 0 {
+			opt.clientExpiration = expiration
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkecholimit
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.NotNil(t, set.limitSet)
+	assert.Nil(t, set.clientStore)
+
+	// with entry name of rkmidlimit
+	set = newOptionSet(WithLimitOptions(rkmidlimit.WithEntryNameAndType("ut-limit-name", "ut-limit-type")))
+	assert.Equal(t, "ut-limit-name", set.EntryName)
+	assert.Equal(t, "ut-limit-type", set.EntryType)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithLimitOptions(rkmidlimit.WithEntryNameAndType("ut-limit-name", "ut-limit-type")))
+	assert.Equal(t, "ut-name", set.limitSet.GetEntryName())
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithLimitOptions(rkmidlimit.WithPathToIgnore("/ut-ignore")),
+		WithReqPerSecByClient(5, 0),
+		WithClientExpiration(time.Minute))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.Equal(t, "ut-name", set.limitSet.GetEntryName())
+	assert.True(t, set.limitSet.ShouldIgnore("/ut-ignore"))
+	assert.NotNil(t, set.clientStore)
+	assert.Equal(t, 5, set.clientBurst)
+	assert.Equal(t, time.Minute, set.clientExpiration)
+}
+
+func TestToOptions(t *testing.T) {
+	reqPerSec := 100
+	config := &BootConfig{
+		BootConfig: rkmidlimit.BootConfig{
+			ReqPerSec: &reqPerSec,
+		},
+		PerClient: PerClientConfig{
+			ReqPerSec:   2.5,
+			Burst:       10,
+			ExpiresInMs: 1000,
+		},
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled, per client disabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, "ut-entry", set.EntryName)
+	assert.Nil(t, set.clientStore)
+
+	// with per client enabled
+	config.PerClient.Enabled = true
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.NotNil(t, set.clientStore)
+	assert.Equal(t, 2.5, set.clientReqPerSec)
+	assert.Equal(t, 10, set.clientBurst)
+	assert.Equal(t, time.Second, set.clientExpiration)
+}
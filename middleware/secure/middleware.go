@@ -8,8 +8,10 @@ package rkechosec
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/secure"
+	"strings"
 )
 
 // Middleware Add security interceptors.
@@ -17,20 +19,76 @@ import (
 // Mainly copied from bellow.
 // https://github.com/labstack/echo/blob/master/middleware/secure.go
 func Middleware(opts ...rkmidsec.Option) echo.MiddlewareFunc {
-	set := rkmidsec.NewOptionSet(opts...)
+	return MiddlewareWithOptions(WithSecOptions(opts...))
+}
+
+// MiddlewareWithOptions add security headers same as Middleware with bellow extensions.
+//
+// 1: A cryptographically random nonce would be generated per request if {nonce} exists in policy template,
+// which could be read with rkechoctx.GetCspNonce() while rendering templates.
+// 2: If CSP report enabled, report-uri and report-to directives would be added into policy,
+// violations sent to report endpoint would be logged as events with metrics.
+// 3: Cross-Origin-Opener-Policy, Cross-Origin-Embedder-Policy, Cross-Origin-Resource-Policy and Permissions-Policy
+// could be provided explicitly or with preset of strict, api and spa.
+// 4: Headers could be overridden per path prefix, for example, relaxed for swagger UI while API stays strict.
+func MiddlewareWithOptions(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			ctx.Set(rkmid.EntryNameKey.String(), set.GetEntryName())
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.secSet.ShouldIgnore(ctx.Request().URL.Path) {
+				return next(ctx)
+			}
+
+			beforeCtx := set.secSet.BeforeCtx(ctx.Request())
+			set.secSet.Before(beforeCtx)
+
+			headers := beforeCtx.Output.HeadersToReturn
+			for k, v := range set.headers {
+				headers[k] = v
+			}
 
-			// case 1: return to user if error occur
-			beforeCtx := set.BeforeCtx(ctx.Request())
-			set.Before(beforeCtx)
+			// policy rendered from template takes precedence
+			if len(set.cspTemplate) > 0 {
+				delete(headers, rkmid.HeaderContentSecurityPolicy)
+				delete(headers, rkmid.HeaderContentSecurityPolicyReportOnly)
+
+				if set.cspReportOnly {
+					headers[rkmid.HeaderContentSecurityPolicyReportOnly] = set.cspTemplate
+				} else {
+					headers[rkmid.HeaderContentSecurityPolicy] = set.cspTemplate
+				}
+			}
+
+			// override headers by path, empty value removes header
+			for k, v := range set.overrideOf(ctx) {
+				if len(v) < 1 {
+					delete(headers, k)
+				} else {
+					headers[k] = v
+				}
+			}
+
+			nonce := ""
+			for k, v := range headers {
+				if strings.Contains(v, CspNoncePlaceholder) &&
+					(k == rkmid.HeaderContentSecurityPolicy || k == rkmid.HeaderContentSecurityPolicyReportOnly) {
+					if len(nonce) < 1 {
+						nonce = newCspNonce()
+						ctx.Set(rkechoctx.CspNonceKey, nonce)
+					}
+					v = strings.ReplaceAll(v, CspNoncePlaceholder, nonce)
+				}
 
-			for k, v := range beforeCtx.Output.HeadersToReturn {
 				ctx.Response().Header().Set(k, v)
 			}
 
+			if set.cspReporter != nil {
+				ctx.Response().Header().Set(HeaderReportingEndpoints, cspReportGroup+`="`+set.cspReportPath+`"`)
+			}
+
 			return next(ctx)
 		}
 	}
@@ -8,6 +8,9 @@ package rkechosec
 import (
 	"bytes"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/secure"
 	"github.com/stretchr/testify/assert"
 	"net/http"
@@ -34,6 +37,90 @@ func TestMiddleware(t *testing.T) {
 	inter(userHandler)(ctx)
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "value", w.Header().Get("key"))
+
+	// case 2: policy provided with rkmidsec
+	inter = Middleware(rkmidsec.WithContentSecurityPolicy("default-src 'self'"))
+	ctx, w = newCtx()
+	inter(userHandler)(ctx)
+	assert.Equal(t, "default-src 'self'", w.Header().Get(rkmid.HeaderContentSecurityPolicy))
+}
+
+func TestMiddlewareWithOptions(t *testing.T) {
+	defer assertNotPanic(t)
+
+	var nonce string
+	handler := func(ctx echo.Context) error {
+		nonce = rkechoctx.GetCspNonce(ctx)
+		return ctx.String(http.StatusOK, "")
+	}
+
+	// case 1: nonce generated per request
+	inter := MiddlewareWithOptions(
+		WithContentSecurityPolicy("script-src 'self' 'nonce-{nonce}'", false),
+		WithRegisterer(prometheus.NewRegistry()))
+	ctx, w := newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Len(t, nonce, 24)
+	assert.Equal(t, "script-src 'self' 'nonce-"+nonce+"'", w.Header().Get(rkmid.HeaderContentSecurityPolicy))
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get(rkmid.HeaderXFrameOptions))
+
+	prev := nonce
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.NotEqual(t, prev, nonce)
+
+	// case 2: report only with report endpoint
+	inter = MiddlewareWithOptions(
+		WithEntryNameAndType("ut-csp", "ut-type"),
+		WithContentSecurityPolicy("default-src 'self';", true),
+		WithCspReport("/ut-report"),
+		WithRegisterer(prometheus.NewRegistry()))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Empty(t, nonce)
+	assert.Empty(t, w.Header().Get(rkmid.HeaderContentSecurityPolicy))
+	assert.Equal(t, "default-src 'self'; report-uri /ut-report; report-to csp-endpoint",
+		w.Header().Get(rkmid.HeaderContentSecurityPolicyReportOnly))
+	assert.Equal(t, `csp-endpoint="/ut-report"`, w.Header().Get(HeaderReportingEndpoints))
+	assert.NotNil(t, GetCspReporter("ut-csp"))
+
+	// case 3: preset with relaxed path
+	inter = MiddlewareWithOptions(
+		WithPreset(PresetStrict),
+		WithRelaxedPath("/ut-path"),
+		WithRegisterer(prometheus.NewRegistry()))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Empty(t, nonce)
+	assert.Empty(t, w.Header().Get(rkmid.HeaderContentSecurityPolicy))
+	assert.Empty(t, w.Header().Get(HeaderCrossOriginEmbedderPolicy))
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get(rkmid.HeaderXFrameOptions))
+	assert.Equal(t, "same-origin", w.Header().Get(HeaderCrossOriginOpenerPolicy))
+
+	// case 4: preset with nonce in overridden policy
+	inter = MiddlewareWithOptions(
+		WithPreset(PresetApi),
+		WithHeadersByPath("/ut-path", map[string]string{
+			rkmid.HeaderContentSecurityPolicyReportOnly: "script-src 'nonce-{nonce}'",
+		}),
+		WithRegisterer(prometheus.NewRegistry()))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.NotEmpty(t, nonce)
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get(rkmid.HeaderContentSecurityPolicy))
+	assert.Equal(t, "script-src 'nonce-"+nonce+"'", w.Header().Get(rkmid.HeaderContentSecurityPolicyReportOnly))
+	assert.Equal(t, "DENY", w.Header().Get(rkmid.HeaderXFrameOptions))
+	assert.Equal(t, "same-origin", w.Header().Get(HeaderCrossOriginResourcePolicy))
+	assert.NotEmpty(t, w.Header().Get(HeaderPermissionsPolicy))
+
+	// case 5: ignored path
+	inter = MiddlewareWithOptions(
+		WithContentSecurityPolicy("default-src 'self'", false),
+		WithPathToIgnore("/ut-path"),
+		WithRegisterer(prometheus.NewRegistry()))
+	ctx, w = newCtx()
+	assert.Nil(t, inter(handler)(ctx))
+	assert.Empty(t, w.Header().Get(rkmid.HeaderContentSecurityPolicy))
 }
 
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {
@@ -0,0 +1,417 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/secure"
+	"github.com/rs/xid"
+	"sort"
+	"strings"
+)
+
+const (
+	// CspNoncePlaceholder in Content-Security-Policy template would be replaced with nonce generated per request,
+	// for example: script-src 'self' 'nonce-{nonce}'
+	CspNoncePlaceholder = "{nonce}"
+
+	// HeaderReportingEndpoints declares endpoints of Reporting API
+	HeaderReportingEndpoints = "Reporting-Endpoints"
+
+	// name of Reporting API endpoint which CSP violations would be sent to
+	cspReportGroup = "csp-endpoint"
+	// 128 bits nonce recommended by CSP spec
+	cspNonceSize = 16
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:  "",
+		EntryType:  "",
+		Skipper:    defaultSkipper,
+		secOpts:    make([]rkmidsec.Option, 0),
+		headers:    make(map[string]string),
+		overrides:  make(map[string]map[string]string),
+		registerer: prometheus.DefaultRegisterer,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// entry name provided with WithEntryNameAndType takes precedence over the one in options of rkmidsec
+	if len(set.EntryName) > 0 {
+		set.secSet = rkmidsec.NewOptionSet(append(set.secOpts,
+			rkmidsec.WithEntryNameAndType(set.EntryName, set.EntryType))...)
+	} else {
+		set.secSet = rkmidsec.NewOptionSet(append([]rkmidsec.Option{
+			rkmidsec.WithEntryNameAndType(xid.New().String(), "")}, set.secOpts...)...)
+		set.EntryName = set.secSet.GetEntryName()
+		set.EntryType = set.secSet.GetEntryType()
+	}
+
+	if len(set.cspReportPath) > 0 {
+		set.cspReporter = newCspReporter(set.EntryName, set.EntryType, set.cspReportPath, set.registerer)
+		registerCspReporter(set.cspReporter)
+	}
+
+	set.cspTemplate = set.withReportDirectives(set.cspTemplate)
+
+	for prefix, headers := range set.overrides {
+		for _, k := range []string{rkmid.HeaderContentSecurityPolicy, rkmid.HeaderContentSecurityPolicyReportOnly} {
+			if v, ok := headers[k]; ok && len(v) > 0 {
+				headers[k] = set.withReportDirectives(v)
+			}
+		}
+
+		set.pathOverrides = append(set.pathOverrides, &pathOverride{
+			path:    prefix,
+			headers: headers,
+		})
+	}
+
+	// longest path prefix wins
+	sort.Slice(set.pathOverrides, func(i, j int) bool {
+		return len(set.pathOverrides[i].path) > len(set.pathOverrides[j].path)
+	})
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName     string
+	EntryType     string
+	Skipper       Skipper
+	secOpts       []rkmidsec.Option
+	secSet        rkmidsec.OptionSetInterface
+	cspTemplate   string
+	cspReportOnly bool
+	cspReportPath string
+	cspReporter   *CspReporter
+	headers       map[string]string
+	overrides     map[string]map[string]string
+	pathOverrides []*pathOverride
+	registerer    prometheus.Registerer
+}
+
+// pathOverride overrides headers of requests whose path starts with prefix, empty value removes header
+type pathOverride struct {
+	path    string
+	headers map[string]string
+}
+
+// overrideOf returns override of longest matching path prefix, nil if none matched
+func (set *optionSet) overrideOf(ctx echo.Context) map[string]string {
+	if ctx != nil && ctx.Request().URL != nil {
+		for _, v := range set.pathOverrides {
+			if strings.HasPrefix(ctx.Request().URL.Path, v.path) {
+				return v.headers
+			}
+		}
+	}
+
+	return nil
+}
+
+// withReportDirectives appends report-uri and report-to directives into policy if CSP report endpoint enabled
+func (set *optionSet) withReportDirectives(policy string) string {
+	if len(policy) < 1 || set.cspReporter == nil {
+		return policy
+	}
+
+	policy = strings.TrimSuffix(strings.TrimSpace(policy), ";")
+
+	if !strings.Contains(policy, "report-uri") {
+		policy = fmt.Sprintf("%s; report-uri %s", policy, set.cspReportPath)
+	}
+
+	if !strings.Contains(policy, "report-to") {
+		policy = fmt.Sprintf("%s; report-to %s", policy, cspReportGroup)
+	}
+
+	return policy
+}
+
+// newCspNonce returns base64 encoded random nonce
+func newCspNonce() string {
+	bytes := make([]byte, cspNonceSize)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(bytes)
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML, extends rkmidsec.BootConfig with CSP nonce, violation report endpoint,
+// cross origin isolation headers, presets and per path overrides.
+//
+// ContentSecurityPolicy is treated as template, {nonce} would be replaced with nonce generated per request.
+// Headers of preset would be overridden by headers configured explicitly.
+type BootConfig struct {
+	rkmidsec.BootConfig       `yaml:",inline" mapstructure:",squash"`
+	Preset                    string          `yaml:"preset" json:"preset"`
+	CrossOriginOpenerPolicy   string          `yaml:"crossOriginOpenerPolicy" json:"crossOriginOpenerPolicy"`
+	CrossOriginEmbedderPolicy string          `yaml:"crossOriginEmbedderPolicy" json:"crossOriginEmbedderPolicy"`
+	CrossOriginResourcePolicy string          `yaml:"crossOriginResourcePolicy" json:"crossOriginResourcePolicy"`
+	PermissionsPolicy         string          `yaml:"permissionsPolicy" json:"permissionsPolicy"`
+	Paths                     []PathConfig    `yaml:"paths" json:"paths"`
+	CspReport                 CspReportConfig `yaml:"cspReport" json:"cspReport"`
+}
+
+// PathConfig for YAML, headers of preset and explicit headers would override entry headers for path prefix,
+// header with empty value would be removed
+type PathConfig struct {
+	Path    string            `yaml:"path" json:"path"`
+	Preset  string            `yaml:"preset" json:"preset"`
+	Headers map[string]string `yaml:"headers" json:"headers"`
+}
+
+// CspReportConfig for YAML, report endpoint would be registered into EchoEntry if enabled
+type CspReportConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Path    string `yaml:"path" json:"path"`
+}
+
+// ToOptions convert BootConfig into Option list,
+// CspReporter registered with cspReport enabled could be retrieved with GetCspReporter().
+func ToOptions(config *BootConfig, entryName, entryType string, registerer prometheus.Registerer) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts, WithEntryNameAndType(entryName, entryType))
+
+		// preset goes first, so that explicit headers take precedence
+		if len(config.Preset) > 0 {
+			opts = append(opts, WithPreset(config.Preset))
+		}
+
+		opts = append(opts,
+			WithSecOptions(rkmidsec.ToOptions(&config.BootConfig, entryName, entryType)...),
+			WithContentSecurityPolicy(config.ContentSecurityPolicy, config.CspReportOnly),
+			WithCrossOriginOpenerPolicy(config.CrossOriginOpenerPolicy),
+			WithCrossOriginEmbedderPolicy(config.CrossOriginEmbedderPolicy),
+			WithCrossOriginResourcePolicy(config.CrossOriginResourcePolicy),
+			WithPermissionsPolicy(config.PermissionsPolicy),
+			WithRegisterer(registerer))
+
+		for i := range config.Paths {
+			e := config.Paths[i]
+			if len(e.Preset) > 0 {
+				opts = append(opts, WithPresetByPath(e.Path, e.Preset))
+			}
+			opts = append(opts, WithHeadersByPath(e.Path, e.Headers))
+		}
+
+		if config.CspReport.Enabled {
+			path := config.CspReport.Path
+			if len(path) < 1 {
+				path = defaultCspReportPath
+			}
+			opts = append(opts, WithCspReport(path))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithSecOptions provide options of classic secure headers like X-Frame-Options and HSTS.
+func WithSecOptions(opts ...rkmidsec.Option) Option {
+	return func(opt *optionSet) {
+		opt.secOpts = append(opt.secOpts, opts...)
+	}
+}
+
+// WithPreset provide preset of headers, one of strict, api and spa.
+// Options provided after preset would override headers of preset.
+func WithPreset(name string) Option {
+	return func(opt *optionSet) {
+		preset := getPreset(name)
+		if preset == nil {
+			rkentry.ShutdownWithError(fmt.Errorf("unknown secure preset %s", name))
+			return
+		}
+
+		for k, v := range preset {
+			switch k {
+			case rkmid.HeaderXXSSProtection:
+				opt.secOpts = append(opt.secOpts, rkmidsec.WithXSSProtection(v))
+			case rkmid.HeaderXContentTypeOptions:
+				opt.secOpts = append(opt.secOpts, rkmidsec.WithContentTypeNosniff(v))
+			case rkmid.HeaderXFrameOptions:
+				opt.secOpts = append(opt.secOpts, rkmidsec.WithXFrameOptions(v))
+			case rkmid.HeaderReferrerPolicy:
+				opt.secOpts = append(opt.secOpts, rkmidsec.WithReferrerPolicy(v))
+			case rkmid.HeaderContentSecurityPolicy:
+				opt.cspTemplate = v
+			default:
+				opt.headers[k] = v
+			}
+		}
+	}
+}
+
+// WithContentSecurityPolicy provide Content-Security-Policy template,
+// {nonce} in template would be replaced with nonce generated per request.
+func WithContentSecurityPolicy(template string, reportOnly bool) Option {
+	return func(opt *optionSet) {
+		if len(template) > 0 {
+			opt.cspTemplate = template
+		}
+		opt.cspReportOnly = reportOnly
+	}
+}
+
+// WithCrossOriginOpenerPolicy provide Cross-Origin-Opener-Policy, for example same-origin.
+func WithCrossOriginOpenerPolicy(val string) Option {
+	return withHeader(HeaderCrossOriginOpenerPolicy, val)
+}
+
+// WithCrossOriginEmbedderPolicy provide Cross-Origin-Embedder-Policy, for example require-corp.
+func WithCrossOriginEmbedderPolicy(val string) Option {
+	return withHeader(HeaderCrossOriginEmbedderPolicy, val)
+}
+
+// WithCrossOriginResourcePolicy provide Cross-Origin-Resource-Policy, for example same-origin.
+func WithCrossOriginResourcePolicy(val string) Option {
+	return withHeader(HeaderCrossOriginResourcePolicy, val)
+}
+
+// WithPermissionsPolicy provide Permissions-Policy, for example camera=(), microphone=().
+func WithPermissionsPolicy(val string) Option {
+	return withHeader(HeaderPermissionsPolicy, val)
+}
+
+// withHeader set header if value is not empty
+func withHeader(key, val string) Option {
+	return func(opt *optionSet) {
+		if len(val) > 0 {
+			opt.headers[key] = val
+		}
+	}
+}
+
+// WithPresetByPath override headers of requests whose path starts with prefix with preset.
+func WithPresetByPath(prefix, name string) Option {
+	return func(opt *optionSet) {
+		preset := getPreset(name)
+		if preset == nil {
+			rkentry.ShutdownWithError(fmt.Errorf("unknown secure preset %s", name))
+			return
+		}
+
+		WithHeadersByPath(prefix, preset)(opt)
+	}
+}
+
+// WithHeadersByPath override headers of requests whose path starts with prefix, header with empty value would be removed.
+// Longest prefix wins if multiple prefixes matched.
+func WithHeadersByPath(prefix string, headers map[string]string) Option {
+	return func(opt *optionSet) {
+		if override := opt.overrideFor(prefix); override != nil {
+			for k, v := range copyHeaders(headers) {
+				override[k] = v
+			}
+		}
+	}
+}
+
+// WithRelaxedPath remove Content-Security-Policy and Cross-Origin-Embedder-Policy of requests whose path starts with prefix,
+// which fits pages with inline scripts like swagger UI. Headers overridden explicitly would be kept.
+func WithRelaxedPath(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if override := opt.overrideFor(prefix[i]); override != nil {
+				for k, v := range relaxedHeaders {
+					if _, ok := override[k]; !ok {
+						override[k] = v
+					}
+				}
+			}
+		}
+	}
+}
+
+// overrideFor returns override of prefix, nil if prefix is empty
+func (set *optionSet) overrideFor(prefix string) map[string]string {
+	if len(prefix) < 1 {
+		return nil
+	}
+
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+
+	if _, ok := set.overrides[prefix]; !ok {
+		set.overrides[prefix] = make(map[string]string)
+	}
+
+	return set.overrides[prefix]
+}
+
+// WithCspReport enable CSP violation report endpoint on path, report-uri and report-to would be added into policy.
+func WithCspReport(path string) Option {
+	return func(opt *optionSet) {
+		opt.cspReportPath = path
+	}
+}
+
+// WithRegisterer provide prometheus.Registerer for CSP violation metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(opt *optionSet) {
+		if registerer != nil {
+			opt.registerer = registerer
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return WithSecOptions(rkmidsec.WithPathToIgnore(prefix...))
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
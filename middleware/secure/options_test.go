@@ -0,0 +1,142 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/secure"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.NotNil(t, set.secSet)
+	assert.Empty(t, set.cspTemplate)
+	assert.Nil(t, set.cspReporter)
+
+	// with entry name of rkmidsec
+	set = newOptionSet(WithSecOptions(rkmidsec.WithEntryNameAndType("ut-sec-name", "ut-sec-type")))
+	assert.Equal(t, "ut-sec-name", set.EntryName)
+	assert.Equal(t, "ut-sec-type", set.EntryType)
+
+	// entry name provided explicitly takes precedence
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithSecOptions(rkmidsec.WithEntryNameAndType("ut-sec-name", "ut-sec-type")))
+	assert.Equal(t, "ut-name", set.secSet.GetEntryName())
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithContentSecurityPolicy("script-src 'nonce-{nonce}'", true),
+		WithCspReport("/ut-report"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, "ut-name", set.EntryName)
+	assert.Equal(t, "ut-type", set.EntryType)
+	assert.True(t, set.cspReportOnly)
+	assert.Equal(t, "/ut-report", set.cspReporter.Path())
+	assert.Equal(t, "script-src 'nonce-{nonce}'; report-uri /ut-report; report-to csp-endpoint", set.cspTemplate)
+	assert.True(t, set.secSet.ShouldIgnore("/ut-ignore"))
+}
+
+func TestOptionSet_WithReportDirectives(t *testing.T) {
+	set := newOptionSet(WithCspReport("/ut-report"), WithRegisterer(prometheus.NewRegistry()))
+
+	// without policy
+	assert.Empty(t, set.withReportDirectives(""))
+
+	// with existing directives
+	assert.Equal(t, "default-src 'self'; report-uri /other; report-to other",
+		set.withReportDirectives("default-src 'self'; report-uri /other; report-to other"))
+}
+
+func TestOptionSet_Overrides(t *testing.T) {
+	set := newOptionSet(
+		WithCspReport("/ut-report-override"),
+		WithRegisterer(prometheus.NewRegistry()),
+		WithHeadersByPath("/sw", map[string]string{"content-security-policy": "default-src *"}),
+		WithRelaxedPath("/sw", "docs"),
+		WithPresetByPath("/v1", PresetApi))
+
+	newCtx := func(path string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(http.MethodGet, path, nil), httptest.NewRecorder())
+	}
+
+	// explicit header kept by relaxed path
+	sw := set.overrideOf(newCtx("/sw/index.html"))
+	assert.Equal(t, "default-src *; report-uri /ut-report-override; report-to csp-endpoint", sw[rkmid.HeaderContentSecurityPolicy])
+	assert.Equal(t, "", sw[HeaderCrossOriginEmbedderPolicy])
+
+	// relaxed path
+	docs := set.overrideOf(newCtx("/docs"))
+	assert.Equal(t, "", docs[rkmid.HeaderContentSecurityPolicy])
+	assert.Equal(t, "SAMEORIGIN", docs[rkmid.HeaderXFrameOptions])
+
+	// preset path
+	api := set.overrideOf(newCtx("/v1/ut"))
+	assert.Equal(t, "DENY", api[rkmid.HeaderXFrameOptions])
+
+	// without override
+	assert.Nil(t, set.overrideOf(newCtx("/v2")))
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		BootConfig: rkmidsec.BootConfig{
+			Enabled:               true,
+			ContentSecurityPolicy: "default-src 'self'",
+		},
+		CspReport: CspReportConfig{
+			Enabled: true,
+		},
+	}
+
+	// with disabled
+	config.Enabled = false
+	assert.Empty(t, ToOptions(config, "", "", nil))
+
+	// with enabled
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-boot", "ut-type", prometheus.NewRegistry())...)
+	assert.Equal(t, "ut-boot", set.EntryName)
+	assert.Equal(t, defaultCspReportPath, set.cspReporter.Path())
+	assert.Equal(t, set.cspReporter, GetCspReporter("ut-boot"))
+	assert.Equal(t, "default-src 'self'; report-uri /rk/v1/csp-report; report-to csp-endpoint", set.cspTemplate)
+
+	// with preset and explicit headers
+	config = &BootConfig{
+		BootConfig: rkmidsec.BootConfig{
+			Enabled: true,
+		},
+		Preset:                  PresetStrict,
+		CrossOriginOpenerPolicy: "same-origin-allow-popups",
+		PermissionsPolicy:       "camera=()",
+		Paths: []PathConfig{
+			{
+				Path:    "/ut-path",
+				Preset:  PresetSpa,
+				Headers: map[string]string{"cross-origin-resource-policy": "cross-origin"},
+			},
+		},
+	}
+	set = newOptionSet(ToOptions(config, "ut-preset", "ut-type", prometheus.NewRegistry())...)
+	assert.Equal(t, "same-origin-allow-popups", set.headers[HeaderCrossOriginOpenerPolicy])
+	assert.Equal(t, "require-corp", set.headers[HeaderCrossOriginEmbedderPolicy])
+	assert.Equal(t, "camera=()", set.headers[HeaderPermissionsPolicy])
+	assert.Contains(t, set.cspTemplate, CspNoncePlaceholder)
+	assert.Len(t, set.pathOverrides, 1)
+	assert.Equal(t, "cross-origin", set.pathOverrides[0].headers[HeaderCrossOriginResourcePolicy])
+	assert.Equal(t, "SAMEORIGIN", set.pathOverrides[0].headers[rkmid.HeaderXFrameOptions])
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"net/http"
+	"strings"
+)
+
+const (
+	// HeaderCrossOriginOpenerPolicy isolates browsing context from cross origin documents
+	HeaderCrossOriginOpenerPolicy = "Cross-Origin-Opener-Policy"
+	// HeaderCrossOriginEmbedderPolicy prevents document from loading cross origin resources without permission
+	HeaderCrossOriginEmbedderPolicy = "Cross-Origin-Embedder-Policy"
+	// HeaderCrossOriginResourcePolicy restricts which origins could load resource
+	HeaderCrossOriginResourcePolicy = "Cross-Origin-Resource-Policy"
+	// HeaderPermissionsPolicy controls which browser features could be used
+	HeaderPermissionsPolicy = "Permissions-Policy"
+
+	// PresetStrict fits server side rendered pages without third party resources
+	PresetStrict = "strict"
+	// PresetApi fits JSON APIs which never render documents
+	PresetApi = "api"
+	// PresetSpa fits single page applications, scripts should carry nonce
+	PresetSpa = "spa"
+)
+
+// deny features which are rarely used by web applications
+const restrictivePermissionsPolicy = "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()"
+
+// presets of headers, HSTS is not included since it depends on deployment
+var presets = map[string]map[string]string{
+	PresetStrict: {
+		rkmid.HeaderXXSSProtection:      "0",
+		rkmid.HeaderXContentTypeOptions: "nosniff",
+		rkmid.HeaderXFrameOptions:       "DENY",
+		rkmid.HeaderReferrerPolicy:      "no-referrer",
+		rkmid.HeaderContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-" + CspNoncePlaceholder + "'; " +
+			"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		HeaderCrossOriginOpenerPolicy:   "same-origin",
+		HeaderCrossOriginEmbedderPolicy: "require-corp",
+		HeaderCrossOriginResourcePolicy: "same-origin",
+		HeaderPermissionsPolicy:         restrictivePermissionsPolicy,
+	},
+	PresetApi: {
+		rkmid.HeaderXXSSProtection:        "0",
+		rkmid.HeaderXContentTypeOptions:   "nosniff",
+		rkmid.HeaderXFrameOptions:         "DENY",
+		rkmid.HeaderReferrerPolicy:        "no-referrer",
+		rkmid.HeaderContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		HeaderCrossOriginOpenerPolicy:     "same-origin",
+		HeaderCrossOriginResourcePolicy:   "same-origin",
+		HeaderPermissionsPolicy:           restrictivePermissionsPolicy,
+	},
+	PresetSpa: {
+		rkmid.HeaderXXSSProtection:      "0",
+		rkmid.HeaderXContentTypeOptions: "nosniff",
+		rkmid.HeaderXFrameOptions:       "SAMEORIGIN",
+		rkmid.HeaderReferrerPolicy:      "strict-origin-when-cross-origin",
+		rkmid.HeaderContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-" + CspNoncePlaceholder + "'; " +
+			"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; " +
+			"object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+		HeaderCrossOriginOpenerPolicy:   "same-origin",
+		HeaderCrossOriginResourcePolicy: "same-site",
+		HeaderPermissionsPolicy:         "camera=(), geolocation=(), microphone=(), payment=(), usb=()",
+	},
+}
+
+// relaxedHeaders removes headers which break pages with inline scripts and cross origin assets like swagger UI
+var relaxedHeaders = map[string]string{
+	rkmid.HeaderContentSecurityPolicy:           "",
+	rkmid.HeaderContentSecurityPolicyReportOnly: "",
+	HeaderCrossOriginEmbedderPolicy:             "",
+	rkmid.HeaderXFrameOptions:                   "SAMEORIGIN",
+}
+
+// getPreset returns copy of preset headers, nil if preset not exists
+func getPreset(name string) map[string]string {
+	preset, ok := presets[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+
+	return copyHeaders(preset)
+}
+
+// copyHeaders returns copy of headers with canonical keys
+func copyHeaders(headers map[string]string) map[string]string {
+	res := make(map[string]string, len(headers))
+	for k, v := range headers {
+		res[http.CanonicalHeaderKey(k)] = v
+	}
+
+	return res
+}
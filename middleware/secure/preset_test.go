@@ -0,0 +1,39 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetPreset(t *testing.T) {
+	// with unknown preset
+	assert.Nil(t, getPreset("ut-preset"))
+
+	// with case insensitive name
+	preset := getPreset("STRICT")
+	assert.Equal(t, "DENY", preset[rkmid.HeaderXFrameOptions])
+	assert.Equal(t, "require-corp", preset[HeaderCrossOriginEmbedderPolicy])
+
+	// returns copy
+	preset[rkmid.HeaderXFrameOptions] = "ut-value"
+	assert.Equal(t, "DENY", getPreset(PresetStrict)[rkmid.HeaderXFrameOptions])
+
+	// all presets are isolated from cross origin documents
+	for _, name := range []string{PresetStrict, PresetApi, PresetSpa} {
+		preset = getPreset(name)
+		assert.NotEmpty(t, preset[rkmid.HeaderContentSecurityPolicy])
+		assert.Equal(t, "same-origin", preset[HeaderCrossOriginOpenerPolicy])
+		assert.NotEmpty(t, preset[HeaderPermissionsPolicy])
+	}
+}
+
+func TestCopyHeaders(t *testing.T) {
+	res := copyHeaders(map[string]string{"cross-origin-opener-policy": "same-origin"})
+	assert.Equal(t, "same-origin", res[HeaderCrossOriginOpenerPolicy])
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rookie-ninja/rk-entry/v2/middleware/prom"
+	"github.com/rookie-ninja/rk-query"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// MetricsNameCspViolation records CSP violations reported by browsers
+	MetricsNameCspViolation = "cspViolation"
+
+	// ContentTypeCspReport is content type of report sent with report-uri directive
+	ContentTypeCspReport = "application/csp-report"
+	// ContentTypeReports is content type of report sent with Reporting API
+	ContentTypeReports = "application/reports+json"
+
+	defaultCspReportPath = "/rk/v1/csp-report"
+	// reports larger than this would be rejected
+	maxCspReportSize = 64 * 1024
+)
+
+var (
+	reportersLock sync.Mutex
+	reporters     = make(map[string]*CspReporter)
+
+	reportLabelKeys = []string{"entryName", "entryType", "directive", "disposition"}
+
+	// known directives and dispositions used as metrics labels, others are recorded as labelOther
+	knownCspDirectives = toSet(
+		"default-src", "child-src", "connect-src", "font-src", "frame-src", "img-src", "manifest-src",
+		"media-src", "object-src", "prefetch-src", "script-src", "script-src-elem", "script-src-attr",
+		"style-src", "style-src-elem", "style-src-attr", "worker-src", "base-uri", "sandbox", "form-action",
+		"frame-ancestors", "navigate-to", "require-trusted-types-for", "trusted-types", "plugin-types",
+		"upgrade-insecure-requests", "block-all-mixed-content")
+	knownCspDispositions = toSet("enforce", "report")
+)
+
+// labelOther is metrics label of unknown directive or disposition
+const labelOther = "other"
+
+// GetCspReporter returns CspReporter registered by ToOptions() with entry name
+func GetCspReporter(entryName string) *CspReporter {
+	reportersLock.Lock()
+	defer reportersLock.Unlock()
+
+	return reporters[entryName]
+}
+
+// registerCspReporter register CspReporter with entry name
+func registerCspReporter(reporter *CspReporter) {
+	reportersLock.Lock()
+	defer reportersLock.Unlock()
+
+	reporters[reporter.entryName] = reporter
+}
+
+// newCspReporter create CspReporter with metrics registered in registerer
+func newCspReporter(entryName, entryType, path string, registerer prometheus.Registerer) *CspReporter {
+	res := &CspReporter{
+		entryName:  entryName,
+		entryType:  entryType,
+		path:       path,
+		metricsSet: rkmidprom.NewMetricsSet("rk", "secure", registerer),
+	}
+
+	res.metricsSet.RegisterCounter(MetricsNameCspViolation, reportLabelKeys...)
+
+	return res
+}
+
+// CspReporter receives CSP violation reports sent by browsers, logs them as events and records metrics
+type CspReporter struct {
+	entryName  string
+	entryType  string
+	path       string
+	metricsSet *rkmidprom.MetricsSet
+}
+
+// CspViolation is CSP violation normalized from report-uri and Reporting API payloads
+type CspViolation struct {
+	DocumentUri        string `json:"documentUri" yaml:"documentUri"`
+	Referrer           string `json:"referrer" yaml:"referrer"`
+	BlockedUri         string `json:"blockedUri" yaml:"blockedUri"`
+	EffectiveDirective string `json:"effectiveDirective" yaml:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy" yaml:"originalPolicy"`
+	Disposition        string `json:"disposition" yaml:"disposition"`
+	SourceFile         string `json:"sourceFile" yaml:"sourceFile"`
+	Sample             string `json:"sample" yaml:"sample"`
+	LineNumber         int    `json:"lineNumber" yaml:"lineNumber"`
+	ColumnNumber       int    `json:"columnNumber" yaml:"columnNumber"`
+	StatusCode         int    `json:"statusCode" yaml:"statusCode"`
+}
+
+// Path returns path of report endpoint
+func (r *CspReporter) Path() string {
+	return r.path
+}
+
+// Handler returns echo.HandlerFunc which parses reports and logs each violation as event in eventEntry
+func (r *CspReporter) Handler(eventEntry *rkentry.EventEntry) echo.HandlerFunc {
+	if eventEntry == nil {
+		eventEntry = rkentry.EventEntryStdout
+	}
+
+	return func(ctx echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(ctx.Request().Body, maxCspReportSize+1))
+		if err != nil {
+			errResp := rkmid.GetErrorBuilder().New(http.StatusBadRequest, "Failed to read CSP report", err)
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+
+		if len(body) > maxCspReportSize {
+			errResp := rkmid.GetErrorBuilder().New(http.StatusRequestEntityTooLarge, "CSP report too large")
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+
+		violations, err := parseCspReport(ctx.Request().Header.Get(echo.HeaderContentType), body)
+		if err != nil {
+			errResp := rkmid.GetErrorBuilder().New(http.StatusBadRequest, "Invalid CSP report", err)
+			return ctx.JSON(errResp.Code(), errResp)
+		}
+
+		for _, v := range violations {
+			r.record(eventEntry, v)
+		}
+
+		return ctx.NoContent(http.StatusNoContent)
+	}
+}
+
+// record logs violation as event and increases metrics
+func (r *CspReporter) record(eventEntry *rkentry.EventEntry, v *CspViolation) {
+	event := eventEntry.Start("cspReport",
+		rkquery.WithEntryName(r.entryName),
+		rkquery.WithEntryType(r.entryType))
+
+	event.AddPayloads(
+		zap.String("documentUri", v.DocumentUri),
+		zap.String("referrer", v.Referrer),
+		zap.String("blockedUri", v.BlockedUri),
+		zap.String("effectiveDirective", v.EffectiveDirective),
+		zap.String("originalPolicy", v.OriginalPolicy),
+		zap.String("disposition", v.Disposition),
+		zap.String("sourceFile", v.SourceFile),
+		zap.String("sample", v.Sample),
+		zap.Int("lineNumber", v.LineNumber),
+		zap.Int("columnNumber", v.ColumnNumber),
+		zap.Int("statusCode", v.StatusCode))
+	eventEntry.Finish(event)
+
+	if vec := r.metricsSet.GetCounter(MetricsNameCspViolation); vec != nil {
+		vec.WithLabelValues(r.entryName, r.entryType,
+			knownLabel(knownCspDirectives, v.EffectiveDirective),
+			knownLabel(knownCspDispositions, v.Disposition)).Inc()
+	}
+}
+
+// knownLabel returns value in lower case if it is known, labelOther otherwise,
+// values are sent by clients and should not create unbounded metrics series
+func knownLabel(known map[string]bool, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if known[value] {
+		return value
+	}
+
+	return labelOther
+}
+
+// toSet converts values into set
+func toSet(values ...string) map[string]bool {
+	res := make(map[string]bool, len(values))
+	for i := range values {
+		res[values[i]] = true
+	}
+
+	return res
+}
+
+// parseCspReport parses payload sent with report-uri or Reporting API into violations,
+// reports other than csp-violation in Reporting API payload would be skipped
+func parseCspReport(contentType string, body []byte) ([]*CspViolation, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case ContentTypeCspReport, echo.MIMEApplicationJSON:
+		raw := struct {
+			Report *struct {
+				DocumentUri        string `json:"document-uri"`
+				Referrer           string `json:"referrer"`
+				BlockedUri         string `json:"blocked-uri"`
+				ViolatedDirective  string `json:"violated-directive"`
+				EffectiveDirective string `json:"effective-directive"`
+				OriginalPolicy     string `json:"original-policy"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"source-file"`
+				ScriptSample       string `json:"script-sample"`
+				LineNumber         int    `json:"line-number"`
+				ColumnNumber       int    `json:"column-number"`
+				StatusCode         int    `json:"status-code"`
+			} `json:"csp-report"`
+		}{}
+
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, err
+		}
+		if raw.Report == nil {
+			return nil, errors.New("missing csp-report")
+		}
+
+		v := &CspViolation{
+			DocumentUri:        raw.Report.DocumentUri,
+			Referrer:           raw.Report.Referrer,
+			BlockedUri:         raw.Report.BlockedUri,
+			EffectiveDirective: raw.Report.EffectiveDirective,
+			OriginalPolicy:     raw.Report.OriginalPolicy,
+			Disposition:        raw.Report.Disposition,
+			SourceFile:         raw.Report.SourceFile,
+			Sample:             raw.Report.ScriptSample,
+			LineNumber:         raw.Report.LineNumber,
+			ColumnNumber:       raw.Report.ColumnNumber,
+			StatusCode:         raw.Report.StatusCode,
+		}
+
+		// effective-directive is missing in legacy browsers
+		if len(v.EffectiveDirective) < 1 {
+			v.EffectiveDirective = strings.SplitN(raw.Report.ViolatedDirective, " ", 2)[0]
+		}
+
+		return []*CspViolation{normalizeViolation(v)}, nil
+	case ContentTypeReports:
+		raw := make([]struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				Referrer           string `json:"referrer"`
+				BlockedURL         string `json:"blockedURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				OriginalPolicy     string `json:"originalPolicy"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"sourceFile"`
+				Sample             string `json:"sample"`
+				LineNumber         int    `json:"lineNumber"`
+				ColumnNumber       int    `json:"columnNumber"`
+				StatusCode         int    `json:"statusCode"`
+			} `json:"body"`
+		}, 0)
+
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, err
+		}
+
+		res := make([]*CspViolation, 0)
+		for i := range raw {
+			if raw[i].Type != "csp-violation" {
+				continue
+			}
+
+			res = append(res, normalizeViolation(&CspViolation{
+				DocumentUri:        raw[i].Body.DocumentURL,
+				Referrer:           raw[i].Body.Referrer,
+				BlockedUri:         raw[i].Body.BlockedURL,
+				EffectiveDirective: raw[i].Body.EffectiveDirective,
+				OriginalPolicy:     raw[i].Body.OriginalPolicy,
+				Disposition:        raw[i].Body.Disposition,
+				SourceFile:         raw[i].Body.SourceFile,
+				Sample:             raw[i].Body.Sample,
+				LineNumber:         raw[i].Body.LineNumber,
+				ColumnNumber:       raw[i].Body.ColumnNumber,
+				StatusCode:         raw[i].Body.StatusCode,
+			}))
+		}
+
+		return res, nil
+	}
+
+	return nil, errors.New("unsupported content type " + contentType)
+}
+
+// normalizeViolation fills labels which would be used in metrics
+func normalizeViolation(v *CspViolation) *CspViolation {
+	if len(v.EffectiveDirective) < 1 {
+		v.EffectiveDirective = "unknown"
+	}
+
+	if len(v.Disposition) < 1 {
+		v.Disposition = "enforce"
+	}
+
+	return v
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosec
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCspReport(t *testing.T) {
+	// report-uri payload
+	res, err := parseCspReport(ContentTypeCspReport, []byte(`{"csp-report": {
+		"document-uri": "https://example.com/ut",
+		"blocked-uri": "https://evil.com/x.js",
+		"violated-directive": "script-src-elem 'self'",
+		"original-policy": "script-src 'self'",
+		"line-number": 10
+	}}`))
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "https://example.com/ut", res[0].DocumentUri)
+	assert.Equal(t, "https://evil.com/x.js", res[0].BlockedUri)
+	assert.Equal(t, "script-src-elem", res[0].EffectiveDirective)
+	assert.Equal(t, "enforce", res[0].Disposition)
+	assert.Equal(t, 10, res[0].LineNumber)
+
+	// Reporting API payload
+	res, err = parseCspReport(ContentTypeReports+"; charset=utf-8", []byte(`[
+		{"type": "csp-violation", "body": {
+			"documentURL": "https://example.com/ut",
+			"blockedURL": "inline",
+			"effectiveDirective": "script-src-elem",
+			"disposition": "report",
+			"sample": "alert(1)"
+		}},
+		{"type": "deprecation", "body": {}}
+	]`))
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "inline", res[0].BlockedUri)
+	assert.Equal(t, "report", res[0].Disposition)
+	assert.Equal(t, "alert(1)", res[0].Sample)
+
+	// invalid payloads
+	_, err = parseCspReport(ContentTypeCspReport, []byte(`{}`))
+	assert.NotNil(t, err)
+	_, err = parseCspReport(ContentTypeReports, []byte(`{`))
+	assert.NotNil(t, err)
+	_, err = parseCspReport("text/plain", []byte(`{}`))
+	assert.NotNil(t, err)
+}
+
+func TestCspReporter_Handler(t *testing.T) {
+	reporter := newCspReporter("ut-entry", "ut-type", "/ut-report", prometheus.NewRegistry())
+	handler := reporter.Handler(nil)
+
+	serve := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ut-report", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		w := httptest.NewRecorder()
+		assert.Nil(t, handler(echo.New().NewContext(req, w)))
+		return w
+	}
+
+	// case 1: valid report
+	w := serve(ContentTypeCspReport, `{"csp-report": {"effective-directive": "img-src"}}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		reporter.metricsSet.GetCounter(MetricsNameCspViolation).WithLabelValues("ut-entry", "ut-type", "img-src", "enforce")))
+
+	// case 2: unknown directive and disposition are recorded as other
+	w = serve(ContentTypeCspReport, `{"csp-report": {"effective-directive": "ut-directive", "disposition": "ut-disposition"}}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		reporter.metricsSet.GetCounter(MetricsNameCspViolation).WithLabelValues("ut-entry", "ut-type", "other", "other")))
+
+	// case 3: invalid report
+	w = serve(ContentTypeCspReport, `ut-report`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// case 4: report too large
+	w = serve(ContentTypeCspReport, strings.Repeat(" ", maxCspReportSize+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidCookie  = errors.New("invalid session cookie")
+	errCookieTooLarge = errors.New("session cookie exceeds 4096 bytes, use server side store instead")
+)
+
+// cookiePayload is the content of session cookie, values are only kept in cookie with cookie store
+type cookiePayload struct {
+	Id        string                 `json:"id"`
+	ExpiresAt int64                  `json:"exp"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+}
+
+// newCodec create codec with secrets, the first secret encodes cookie and all of secrets decode cookie.
+func newCodec(secrets []string, encrypt bool) *codec {
+	res := &codec{
+		encrypt: encrypt,
+	}
+
+	for i := range secrets {
+		if len(secrets[i]) < 1 {
+			continue
+		}
+
+		res.signKeys = append(res.signKeys, deriveKey("sign", secrets[i]))
+		res.encryptKeys = append(res.encryptKeys, deriveKey("encrypt", secrets[i]))
+	}
+
+	return res
+}
+
+// codec signs or encrypts session cookie
+type codec struct {
+	encrypt     bool
+	signKeys    [][]byte
+	encryptKeys [][]byte
+}
+
+// deriveKey derives 32 bytes key with purpose from secret
+func deriveKey(purpose, secret string) []byte {
+	sum := sha256.Sum256([]byte("rk-session-" + purpose + ":" + secret))
+	return sum[:]
+}
+
+// encode marshals payload and signs or encrypts it
+func (c *codec) encode(payload *cookiePayload) (string, error) {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case c.encrypt && len(c.encryptKeys) > 0:
+		return seal(c.encryptKeys[0], bytes)
+	case len(c.signKeys) > 0:
+		value := base64.RawURLEncoding.EncodeToString(bytes)
+		return value + "." + base64.RawURLEncoding.EncodeToString(sign(c.signKeys[0], value)), nil
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// decode verifies or decrypts cookie with any of secrets and returns payload
+func (c *codec) decode(value string) (*cookiePayload, error) {
+	var bytes []byte
+	var err error
+
+	switch {
+	case c.encrypt && len(c.encryptKeys) > 0:
+		err = errInvalidCookie
+		for _, key := range c.encryptKeys {
+			if bytes, err = open(key, value); err == nil {
+				break
+			}
+		}
+	case len(c.signKeys) > 0:
+		tokens := strings.SplitN(value, ".", 2)
+		if len(tokens) != 2 {
+			return nil, errInvalidCookie
+		}
+
+		signature, decodeErr := base64.RawURLEncoding.DecodeString(tokens[1])
+		if decodeErr != nil {
+			return nil, errInvalidCookie
+		}
+
+		err = errInvalidCookie
+		for _, key := range c.signKeys {
+			if hmac.Equal(signature, sign(key, tokens[0])) {
+				bytes, err = base64.RawURLEncoding.DecodeString(tokens[0])
+				break
+			}
+		}
+	default:
+		bytes, err = base64.RawURLEncoding.DecodeString(value)
+	}
+
+	if err != nil {
+		return nil, errInvalidCookie
+	}
+
+	payload := &cookiePayload{}
+	if err := json.Unmarshal(bytes, payload); err != nil {
+		return nil, errInvalidCookie
+	}
+
+	if !isValidId(payload.Id) || !time.Now().Before(time.Unix(payload.ExpiresAt, 0)) {
+		return nil, errInvalidCookie
+	}
+
+	return payload, nil
+}
+
+// sign returns HMAC-SHA256 of value
+func sign(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// seal encrypts plaintext with AES-GCM, nonce is prepended to ciphertext
+func seal(key, plaintext []byte) (string, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// open decrypts value encrypted by seal
+func open(key []byte, value string) ([]byte, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) < gcm.NonceSize() {
+		return nil, errInvalidCookie
+	}
+
+	return gcm.Open(nil, bytes[:gcm.NonceSize()], bytes[gcm.NonceSize():], nil)
+}
+
+// newGcm create AES-GCM with 32 bytes key
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
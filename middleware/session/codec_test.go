@@ -0,0 +1,105 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPayload() *cookiePayload {
+	return &cookiePayload{
+		Id:        newId(),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		Values:    map[string]interface{}{"key": "value"},
+	}
+}
+
+func TestCodec_Plain(t *testing.T) {
+	c := newCodec(nil, true)
+	payload := newPayload()
+
+	value, err := c.encode(payload)
+	assert.Nil(t, err)
+
+	res, err := c.decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, res)
+
+	// with invalid value
+	_, err = c.decode("ut-invalid")
+	assert.NotNil(t, err)
+}
+
+func TestCodec_Signed(t *testing.T) {
+	c := newCodec([]string{"ut-secret"}, false)
+	payload := newPayload()
+
+	value, err := c.encode(payload)
+	assert.Nil(t, err)
+	assert.Contains(t, value, ".")
+
+	res, err := c.decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, res)
+
+	// with tampered value
+	tokens := strings.SplitN(value, ".", 2)
+	_, err = c.decode(tokens[0] + "x." + tokens[1])
+	assert.NotNil(t, err)
+	_, err = c.decode(tokens[0])
+	assert.NotNil(t, err)
+	_, err = c.decode(tokens[0] + ".!")
+	assert.NotNil(t, err)
+
+	// with rotated secret
+	rotated := newCodec([]string{"ut-new-secret", "ut-secret"}, false)
+	res, err = rotated.decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, payload.Id, res.Id)
+
+	// with unknown secret
+	_, err = newCodec([]string{"ut-new-secret"}, false).decode(value)
+	assert.NotNil(t, err)
+}
+
+func TestCodec_Encrypted(t *testing.T) {
+	c := newCodec([]string{"ut-secret"}, true)
+	payload := newPayload()
+
+	value, err := c.encode(payload)
+	assert.Nil(t, err)
+	assert.NotContains(t, value, ".")
+
+	res, err := c.decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, res)
+
+	// with rotated secret
+	res, err = newCodec([]string{"ut-new-secret", "ut-secret"}, true).decode(value)
+	assert.Nil(t, err)
+	assert.Equal(t, payload.Id, res.Id)
+
+	// with unknown secret
+	_, err = newCodec([]string{"ut-new-secret"}, true).decode(value)
+	assert.NotNil(t, err)
+
+	// with invalid value
+	_, err = c.decode("ut")
+	assert.NotNil(t, err)
+}
+
+func TestCodec_Expired(t *testing.T) {
+	c := newCodec([]string{"ut-secret"}, false)
+	payload := newPayload()
+	payload.ExpiresAt = time.Now().Add(-time.Second).Unix()
+
+	value, _ := c.encode(payload)
+	_, err := c.decode(value)
+	assert.NotNil(t, err)
+}
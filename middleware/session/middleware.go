@@ -0,0 +1,138 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechosession is a middleware for echo framework which manages sessions in cookie or server side store.
+package rkechosession
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// Middleware loads session from cookie and assigns it into context which could be read with rkechoctx.GetSession().
+//
+// Session would be persisted and its cookie would be refreshed before response written, which slides expiration.
+// Empty session created by current request would not be persisted.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			sess, err := set.load(ctx.Request())
+			if err != nil {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusServiceUnavailable, "Failed to load session", err.Error())
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			ctx.Set(rkechoctx.SessionKey, sess)
+			ctx.Response().Before(func() {
+				if err := set.save(ctx, sess); err != nil {
+					rkechoctx.GetLogger(ctx).Warn("Failed to save session", zap.Error(err))
+				}
+			})
+
+			return next(ctx)
+		}
+	}
+}
+
+// load session from cookie, a new session would be created if cookie missing, invalid or expired
+func (set *optionSet) load(req *http.Request) (*session, error) {
+	cookie, err := req.Cookie(set.CookieName)
+	if err != nil {
+		return newSession("", nil), nil
+	}
+
+	payload, err := set.codec.decode(cookie.Value)
+	if err != nil {
+		return newSession("", nil), nil
+	}
+
+	if set.cookieStore {
+		values := payload.Values
+		if values == nil {
+			values = make(map[string]interface{})
+		}
+		return newSession(payload.Id, values), nil
+	}
+
+	values, err := set.store.Load(payload.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSession(payload.Id, values), nil
+}
+
+// save session into store and refresh cookie
+func (set *optionSet) save(ctx echo.Context, sess *session) error {
+	id, oldId, values, persist, invalidated := sess.snapshot()
+
+	if set.store != nil && len(oldId) > 0 {
+		if err := set.store.Delete(oldId); err != nil {
+			return err
+		}
+	}
+
+	// expire cookie of invalidated session
+	if invalidated && len(values) < 1 {
+		if !sess.IsNew() {
+			http.SetCookie(ctx.Response(), set.newCookie("", -1))
+		}
+		return nil
+	}
+
+	if !persist {
+		return nil
+	}
+
+	payload := &cookiePayload{
+		Id:        id,
+		ExpiresAt: time.Now().Add(set.Ttl).Unix(),
+	}
+
+	if set.cookieStore {
+		payload.Values = values
+	} else if err := set.store.Save(id, values, set.Ttl); err != nil {
+		return err
+	}
+
+	value, err := set.codec.encode(payload)
+	if err != nil {
+		return err
+	}
+
+	cookie := set.newCookie(value, int(set.Ttl.Seconds()))
+	if len(cookie.String()) > maxCookieSize {
+		return errCookieTooLarge
+	}
+
+	http.SetCookie(ctx.Response(), cookie)
+	return nil
+}
+
+// newCookie create session cookie with value and max age
+func (set *optionSet) newCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     set.CookieName,
+		Value:    value,
+		Path:     set.CookiePath,
+		Domain:   set.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   set.CookieSecure || set.CookieSameSite == http.SameSiteNoneMode,
+		HttpOnly: set.CookieHttpOnly,
+		SameSite: set.CookieSameSite,
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newServer(handler echo.HandlerFunc, opts ...Option) *echo.Echo {
+	e := echo.New()
+	e.Use(Middleware(opts...))
+	e.Any("/ut-path", handler)
+	return e
+}
+
+func serve(e *echo.Echo, cookies ...*http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/ut-path", nil)
+	for i := range cookies {
+		req.AddCookie(cookies[i])
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	for _, v := range w.Result().Cookies() {
+		if v.Name == defaultCookieName {
+			return w, v
+		}
+	}
+
+	return w, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+
+	var sess rkechoctx.Session
+	action := ""
+	e := newServer(func(ctx echo.Context) error {
+		sess = rkechoctx.GetSession(ctx)
+		switch action {
+		case "login":
+			sess.Regenerate()
+			sess.Set("user", "ut-user")
+		case "logout":
+			sess.Invalidate()
+		}
+		return ctx.String(http.StatusOK, "")
+	}, WithStore(store), WithSecrets("ut-secret"), WithTtl(time.Minute))
+
+	// case 1: empty session is not persisted
+	w, cookie := serve(e)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, sess.IsNew())
+	assert.Nil(t, cookie)
+
+	// case 2: login
+	action = "login"
+	_, cookie = serve(e)
+	assert.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, 60, cookie.MaxAge)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	loginId := sess.Id()
+
+	// case 3: load session with sliding expiration
+	action = ""
+	_, refreshed := serve(e, cookie)
+	assert.False(t, sess.IsNew())
+	assert.Equal(t, loginId, sess.Id())
+	assert.Equal(t, "ut-user", sess.Get("user"))
+	assert.NotNil(t, refreshed)
+
+	// case 4: regenerate removes previous id from store
+	action = "login"
+	_, cookie = serve(e, cookie)
+	assert.NotEqual(t, loginId, sess.Id())
+	values, _ := store.Load(loginId)
+	assert.Nil(t, values)
+	values, _ = store.Load(sess.Id())
+	assert.Equal(t, "ut-user", values["user"])
+
+	// case 5: logout expires cookie
+	action = "logout"
+	_, expired := serve(e, cookie)
+	assert.NotNil(t, expired)
+	assert.True(t, expired.MaxAge < 0)
+
+	// case 6: cookie of logged out session is rejected
+	action = ""
+	serve(e, cookie)
+	assert.True(t, sess.IsNew())
+	assert.Nil(t, sess.Get("user"))
+
+	// case 7: tampered cookie is rejected
+	cookie.Value = strings.Replace(cookie.Value, ".", "x.", 1)
+	serve(e, cookie)
+	assert.True(t, sess.IsNew())
+}
+
+func TestMiddleware_CookieStore(t *testing.T) {
+	var sess rkechoctx.Session
+	e := newServer(func(ctx echo.Context) error {
+		sess = rkechoctx.GetSession(ctx)
+		if ctx.QueryParam("large") != "" {
+			sess.Set("large", strings.Repeat("a", 4096))
+		} else {
+			sess.Set("count", len(sess.Keys()))
+		}
+		return ctx.String(http.StatusOK, "")
+	}, WithCookieStore(), WithSecrets("ut-secret"), WithEncryption(true))
+
+	_, cookie := serve(e)
+	assert.NotNil(t, cookie)
+	assert.NotContains(t, cookie.Value, "count")
+
+	serve(e, cookie)
+	assert.False(t, sess.IsNew())
+	// count of loaded keys
+	assert.Equal(t, 1, sess.Get("count"))
+
+	// cookie larger than 4096 bytes is not set
+	req := httptest.NewRequest(http.MethodGet, "/ut-path?large=true", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestMiddleware_FailedStore(t *testing.T) {
+	e := newServer(func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "")
+	}, WithStore(&failedStore{}))
+
+	// without cookie
+	w, _ := serve(e)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// with cookie
+	value, _ := newCodec(nil, false).encode(newPayload())
+	w, _ = serve(e, &http.Cookie{Name: defaultCookieName, Value: value})
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestMiddleware_Ignore(t *testing.T) {
+	var sess rkechoctx.Session
+	e := newServer(func(ctx echo.Context) error {
+		sess = rkechoctx.GetSession(ctx)
+		return ctx.String(http.StatusOK, "")
+	}, WithPathToIgnore("/ut-path"))
+
+	serve(e)
+	assert.Nil(t, sess)
+}
+
+type failedStore struct{}
+
+func (s *failedStore) Load(string) (map[string]interface{}, error) {
+	return nil, errors.New("ut-error")
+}
+
+func (s *failedStore) Save(string, map[string]interface{}, time.Duration) error {
+	return errors.New("ut-error")
+}
+
+func (s *failedStore) Delete(string) error {
+	return errors.New("ut-error")
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// StoreTypeMemory keeps sessions in memory
+	StoreTypeMemory = "memory"
+	// StoreTypeFile keeps sessions as files in directory
+	StoreTypeFile = "file"
+	// StoreTypeCookie keeps sessions in signed or encrypted cookie
+	StoreTypeCookie = "cookie"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultTtl        = 30 * time.Minute
+	defaultCookieName = "rk_session"
+	// max size of cookie accepted by most browsers
+	maxCookieSize = 4096
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:      xid.New().String(),
+		EntryType:      "",
+		Skipper:        defaultSkipper,
+		Ttl:            defaultTtl,
+		CookieName:     defaultCookieName,
+		CookiePath:     "/",
+		CookieHttpOnly: true,
+		CookieSameSite: http.SameSiteLaxMode,
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	if set.store == nil && !set.cookieStore {
+		set.store = NewMemoryStore()
+	}
+
+	if set.cookieStore && len(set.secrets) < 1 {
+		rkentry.ShutdownWithError(errors.New("session cookie store requires secrets"))
+	}
+
+	set.codec = newCodec(set.secrets, set.encrypt)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName      string
+	EntryType      string
+	Skipper        Skipper
+	Ttl            time.Duration
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieHttpOnly bool
+	CookieSameSite http.SameSite
+	store          Store
+	cookieStore    bool
+	secrets        []string
+	encrypt        bool
+	codec          *codec
+	ignorePrefix   []string
+}
+
+// ShouldIgnore determine whether session should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled bool         `yaml:"enabled" json:"enabled"`
+	Ignore  []string     `yaml:"ignore" json:"ignore"`
+	Store   string       `yaml:"store" json:"store"`
+	FileDir string       `yaml:"fileDir" json:"fileDir"`
+	Secrets []string     `yaml:"secrets" json:"secrets"`
+	Encrypt bool         `yaml:"encrypt" json:"encrypt"`
+	TtlMs   int          `yaml:"ttlMs" json:"ttlMs"`
+	Cookie  CookieConfig `yaml:"cookie" json:"cookie"`
+}
+
+// CookieConfig for YAML
+type CookieConfig struct {
+	Name     string `yaml:"name" json:"name"`
+	Path     string `yaml:"path" json:"path"`
+	Domain   string `yaml:"domain" json:"domain"`
+	Secure   bool   `yaml:"secure" json:"secure"`
+	HttpOnly *bool  `yaml:"httpOnly" json:"httpOnly"`
+	SameSite string `yaml:"sameSite" json:"sameSite"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithSecrets(config.Secrets...),
+			WithEncryption(config.Encrypt),
+			WithTtl(time.Duration(config.TtlMs)*time.Millisecond),
+			WithCookieName(config.Cookie.Name),
+			WithCookiePath(config.Cookie.Path),
+			WithCookieDomain(config.Cookie.Domain),
+			WithCookieSecure(config.Cookie.Secure),
+			WithPathToIgnore(config.Ignore...))
+
+		if config.Cookie.HttpOnly != nil {
+			opts = append(opts, WithCookieHttpOnly(*config.Cookie.HttpOnly))
+		}
+
+		switch strings.ToLower(config.Cookie.SameSite) {
+		case "strict":
+			opts = append(opts, WithCookieSameSite(http.SameSiteStrictMode))
+		case "none":
+			opts = append(opts, WithCookieSameSite(http.SameSiteNoneMode))
+		case "lax":
+			opts = append(opts, WithCookieSameSite(http.SameSiteLaxMode))
+		}
+
+		switch strings.ToLower(config.Store) {
+		case StoreTypeCookie:
+			opts = append(opts, WithCookieStore())
+		case StoreTypeFile:
+			dir := config.FileDir
+			if len(dir) < 1 {
+				dir = filepath.Join(os.TempDir(), "rk-session")
+			}
+
+			store, err := NewFileStore(dir)
+			if err != nil {
+				rkentry.ShutdownWithError(err)
+			}
+			opts = append(opts, WithStore(store))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithStore provide server side Store, session cookie would only contain session id, default: in-memory store.
+func WithStore(store Store) Option {
+	return func(opt *optionSet) {
+		if store != nil {
+			opt.store = store
+			opt.cookieStore = false
+		}
+	}
+}
+
+// WithCookieStore keep session values in cookie instead of server side Store, secrets should be provided.
+func WithCookieStore() Option {
+	return func(opt *optionSet) {
+		opt.store = nil
+		opt.cookieStore = true
+	}
+}
+
+// WithSecrets provide secrets which sign or encrypt session cookie.
+// The first secret would be used for new cookies, all of them would be used for verifying which enables rotation.
+func WithSecrets(secrets ...string) Option {
+	return func(opt *optionSet) {
+		for i := range secrets {
+			if len(secrets[i]) > 0 {
+				opt.secrets = append(opt.secrets, secrets[i])
+			}
+		}
+	}
+}
+
+// WithEncryption encrypt session cookie with AES-GCM instead of signing it, secrets should be provided.
+func WithEncryption(encrypt bool) Option {
+	return func(opt *optionSet) {
+		opt.encrypt = encrypt
+	}
+}
+
+// WithTtl provide idle timeout of session, expiration slides on every request, default: 30 minutes.
+func WithTtl(ttl time.Duration) Option {
+	return func(opt *optionSet) {
+		if ttl > 0 {
+			opt.Ttl = ttl
+		}
+	}
+}
+
+// WithCookieName provide name of session cookie, default: rk_session.
+func WithCookieName(name string) Option {
+	return func(opt *optionSet) {
+		if len(name) > 0 {
+			opt.CookieName = name
+		}
+	}
+}
+
+// WithCookiePath provide path of session cookie, default: /.
+func WithCookiePath(path string) Option {
+	return func(opt *optionSet) {
+		if len(path) > 0 {
+			opt.CookiePath = path
+		}
+	}
+}
+
+// WithCookieDomain provide domain of session cookie.
+func WithCookieDomain(domain string) Option {
+	return func(opt *optionSet) {
+		opt.CookieDomain = domain
+	}
+}
+
+// WithCookieSecure mark session cookie as secure.
+func WithCookieSecure(secure bool) Option {
+	return func(opt *optionSet) {
+		opt.CookieSecure = secure
+	}
+}
+
+// WithCookieHttpOnly mark session cookie as http only, default: true.
+func WithCookieHttpOnly(httpOnly bool) Option {
+	return func(opt *optionSet) {
+		opt.CookieHttpOnly = httpOnly
+	}
+}
+
+// WithCookieSameSite provide SameSite attribute of session cookie, default: Lax.
+func WithCookieSameSite(sameSite http.SameSite) Option {
+	return func(opt *optionSet) {
+		opt.CookieSameSite = sameSite
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
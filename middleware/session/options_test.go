@@ -0,0 +1,104 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/entry"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalBootConfig(t *testing.T) {
+	raw := `
+enabled: true
+store: file
+fileDir: "ut-dir"
+secrets: ["ut-secret"]
+encrypt: true
+ttlMs: 1000
+cookie:
+  name: "ut-name"
+  domain: "ut-domain"
+  secure: true
+  httpOnly: false
+  sameSite: strict
+`
+	config := &BootConfig{}
+	rkentry.UnmarshalBootYAML([]byte(raw), config)
+
+	assert.True(t, config.Enabled)
+	assert.Equal(t, "file", config.Store)
+	assert.Equal(t, "ut-dir", config.FileDir)
+	assert.Equal(t, []string{"ut-secret"}, config.Secrets)
+	assert.True(t, config.Encrypt)
+	assert.Equal(t, 1000, config.TtlMs)
+	assert.Equal(t, "ut-name", config.Cookie.Name)
+	assert.True(t, config.Cookie.Secure)
+	assert.False(t, *config.Cookie.HttpOnly)
+	assert.Equal(t, "strict", config.Cookie.SameSite)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with default memory store
+	config.Enabled = true
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.IsType(t, &MemoryStore{}, set.store)
+	assert.Equal(t, defaultTtl, set.Ttl)
+	assert.Equal(t, "/", set.CookiePath)
+	assert.True(t, set.CookieHttpOnly)
+
+	// with file store and cookie
+	httpOnly := false
+	config.Store = StoreTypeFile
+	config.FileDir = path.Join(t.TempDir(), "sessions")
+	config.TtlMs = 1000
+	config.Cookie = CookieConfig{
+		Name:     "ut-name",
+		HttpOnly: &httpOnly,
+		SameSite: "none",
+	}
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.IsType(t, &FileStore{}, set.store)
+	assert.Equal(t, time.Second, set.Ttl)
+	assert.Equal(t, "ut-name", set.CookieName)
+	assert.False(t, set.CookieHttpOnly)
+	assert.Equal(t, http.SameSiteNoneMode, set.CookieSameSite)
+	assert.True(t, set.newCookie("", 0).Secure)
+
+	// with cookie store
+	config.Store = StoreTypeCookie
+	config.Secrets = []string{"ut-secret"}
+	config.Cookie.SameSite = "strict"
+	set = newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Nil(t, set.store)
+	assert.True(t, set.cookieStore)
+	assert.Equal(t, http.SameSiteStrictMode, set.CookieSameSite)
+}
+
+func TestOptionSet_ShouldIgnore(t *testing.T) {
+	set := newOptionSet(WithPathToIgnore("/ut-ignore"))
+
+	ctx := newEchoCtx("/ut-ignore/path")
+	assert.True(t, set.ShouldIgnore(ctx))
+
+	ctx = newEchoCtx("/ut-path")
+	assert.False(t, set.ShouldIgnore(ctx))
+}
+
+func newEchoCtx(path string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
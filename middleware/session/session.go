@@ -0,0 +1,157 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const idSize = 32
+
+// ids are base64 url encoded random bytes which are safe as file names
+var idRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// newId returns random session id
+func newId() string {
+	bytes := make([]byte, idSize)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+// isValidId returns true if id is generated by newId
+func isValidId(id string) bool {
+	return idRegex.MatchString(id)
+}
+
+// newSession create session, a new session would be created if values is nil
+func newSession(id string, values map[string]interface{}) *session {
+	res := &session{
+		id:     id,
+		values: values,
+	}
+
+	if values == nil {
+		res.id = newId()
+		res.isNew = true
+		res.values = make(map[string]interface{})
+	}
+
+	return res
+}
+
+// session implements rkechoctx.Session
+type session struct {
+	lock        sync.Mutex
+	id          string
+	oldId       string
+	isNew       bool
+	invalidated bool
+	values      map[string]interface{}
+}
+
+// Id returns id of session
+func (s *session) Id() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.id
+}
+
+// IsNew returns true if session was created by current request
+func (s *session) IsNew() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.isNew
+}
+
+// Get returns value with key, nil if not exists
+func (s *session) Get(key string) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.values[key]
+}
+
+// Set value with key
+func (s *session) Set(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.values[key] = value
+	s.invalidated = false
+}
+
+// Delete value with key
+func (s *session) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.values, key)
+}
+
+// Keys returns sorted keys of values
+func (s *session) Keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := make([]string, 0, len(s.values))
+	for k := range s.values {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// Regenerate assigns new id to session and keeps values, previous id would be removed from store
+func (s *session) Regenerate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.regenerate()
+}
+
+// Invalidate clears values and expires session, session would be persisted with new id if values set afterwards
+func (s *session) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.regenerate()
+	s.values = make(map[string]interface{})
+	s.invalidated = true
+}
+
+// regenerate assigns new id and records the stored one, lock should be held by caller
+func (s *session) regenerate() {
+	if !s.isNew && len(s.oldId) < 1 {
+		s.oldId = s.id
+	}
+	s.id = newId()
+}
+
+// snapshot returns copy of state which would be persisted
+func (s *session) snapshot() (id, oldId string, values map[string]interface{}, persist, invalidated bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	values = make(map[string]interface{}, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+
+	// empty session created by current request would not be persisted
+	persist = !s.isNew || len(values) > 0
+
+	return s.id, s.oldId, values, persist, s.invalidated
+}
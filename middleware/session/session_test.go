@@ -0,0 +1,72 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewId(t *testing.T) {
+	id := newId()
+	assert.True(t, isValidId(id))
+	assert.NotEqual(t, id, newId())
+
+	assert.False(t, isValidId(""))
+	assert.False(t, isValidId("../../etc/passwd"))
+}
+
+func TestSession(t *testing.T) {
+	// new session
+	sess := newSession("", nil)
+	assert.True(t, sess.IsNew())
+	assert.True(t, isValidId(sess.Id()))
+	_, _, _, persist, _ := sess.snapshot()
+	assert.False(t, persist)
+
+	// set and get
+	sess.Set("b", "value")
+	sess.Set("a", 1)
+	assert.Equal(t, "value", sess.Get("b"))
+	assert.Equal(t, []string{"a", "b"}, sess.Keys())
+	sess.Delete("a")
+	assert.Nil(t, sess.Get("a"))
+	_, _, _, persist, _ = sess.snapshot()
+	assert.True(t, persist)
+
+	// regenerate new session without old id
+	id := sess.Id()
+	sess.Regenerate()
+	assert.NotEqual(t, id, sess.Id())
+	_, oldId, _, _, _ := sess.snapshot()
+	assert.Empty(t, oldId)
+
+	// regenerate existing session
+	sess = newSession(id, map[string]interface{}{"key": "value"})
+	assert.False(t, sess.IsNew())
+	sess.Regenerate()
+	sess.Regenerate()
+	newId, oldId, values, persist, invalidated := sess.snapshot()
+	assert.NotEqual(t, id, newId)
+	assert.Equal(t, id, oldId)
+	assert.Equal(t, "value", values["key"])
+	assert.True(t, persist)
+	assert.False(t, invalidated)
+
+	// invalidate
+	sess = newSession(id, map[string]interface{}{"key": "value"})
+	sess.Invalidate()
+	newId, oldId, values, _, invalidated = sess.snapshot()
+	assert.NotEqual(t, id, newId)
+	assert.Equal(t, id, oldId)
+	assert.Empty(t, values)
+	assert.True(t, invalidated)
+
+	// set after invalidate
+	sess.Set("key", "another")
+	_, _, _, _, invalidated = sess.snapshot()
+	assert.False(t, invalidated)
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileSuffix = ".session"
+
+var errInvalidId = errors.New("invalid session id")
+
+// Store persists session values on server side.
+type Store interface {
+	// Load returns values of session, nil if not exists or expired
+	Load(id string) (map[string]interface{}, error)
+
+	// Save stores values of session which expire after ttl
+	Save(id string, values map[string]interface{}, ttl time.Duration) error
+
+	// Delete removes session
+	Delete(id string) error
+}
+
+// NewMemoryStore create in-memory Store, values are kept as JSON same as other stores.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*memoryRecord),
+	}
+}
+
+// memoryRecord of session in MemoryStore
+type memoryRecord struct {
+	values    []byte
+	expiresAt time.Time
+}
+
+// record of session in FileStore
+type record struct {
+	Values    map[string]interface{} `json:"values"`
+	ExpiresAt time.Time              `json:"expiresAt"`
+}
+
+// MemoryStore is an in-memory Store, expired sessions are purged lazily
+type MemoryStore struct {
+	lock      sync.Mutex
+	records   map[string]*memoryRecord
+	lastPurge time.Time
+}
+
+// Load returns values of session, nil if not exists or expired
+func (s *MemoryStore) Load(id string) (map[string]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	if v, ok := s.records[id]; ok && now.Before(v.expiresAt) {
+		values := make(map[string]interface{})
+		return values, json.Unmarshal(v.values, &values)
+	}
+
+	return nil, nil
+}
+
+// Save stores values of session which expire after ttl
+func (s *MemoryStore) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	bytes, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.records[id] = &memoryRecord{
+		values:    bytes,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	return nil
+}
+
+// Delete removes session
+func (s *MemoryStore) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.records, id)
+	return nil
+}
+
+// purge removes expired sessions at most once per minute, lock should be held by caller
+func (s *MemoryStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+
+	for id, v := range s.records {
+		if !now.Before(v.expiresAt) {
+			delete(s.records, id)
+		}
+	}
+}
+
+// NewFileStore create Store which keeps each session as JSON file in dir.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+// FileStore is a Store backed by files, expired sessions are purged lazily
+type FileStore struct {
+	dir       string
+	lock      sync.Mutex
+	lastPurge time.Time
+}
+
+// Load returns values of session, nil if not exists or expired
+func (s *FileStore) Load(id string) (map[string]interface{}, error) {
+	if !isValidId(id) {
+		return nil, errInvalidId
+	}
+
+	s.purge(time.Now())
+
+	bytes, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &record{}
+	if err := json.Unmarshal(bytes, rec); err != nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(rec.ExpiresAt) {
+		return nil, s.Delete(id)
+	}
+
+	return rec.Values, nil
+}
+
+// Save stores values of session which expire after ttl, file is replaced atomically
+func (s *FileStore) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	if !isValidId(id) {
+		return errInvalidId
+	}
+
+	bytes, err := json.Marshal(&record{
+		Values:    values,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(id))
+}
+
+// Delete removes session
+func (s *FileStore) Delete(id string) error {
+	if !isValidId(id) {
+		return errInvalidId
+	}
+
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns file path of session
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+fileSuffix)
+}
+
+// purge removes expired session files at most once per minute
+func (s *FileStore) purge(now time.Time) {
+	s.lock.Lock()
+	if now.Sub(s.lastPurge) < time.Minute {
+		s.lock.Unlock()
+		return
+	}
+	s.lastPurge = now
+	s.lock.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+
+		bytes, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		rec := &record{}
+		if err := json.Unmarshal(bytes, rec); err == nil && !now.Before(rec.ExpiresAt) {
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosession
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store Store) {
+	id := newId()
+
+	// not exists
+	values, err := store.Load(id)
+	assert.Nil(t, err)
+	assert.Nil(t, values)
+
+	// values are serialized as JSON
+	assert.Nil(t, store.Save(id, map[string]interface{}{"key": "value", "num": 1}, time.Minute))
+	values, err = store.Load(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", values["key"])
+	assert.Equal(t, float64(1), values["num"])
+
+	// delete
+	assert.Nil(t, store.Delete(id))
+	values, _ = store.Load(id)
+	assert.Nil(t, values)
+
+	// expired
+	assert.Nil(t, store.Save(id, map[string]interface{}{}, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	values, err = store.Load(id)
+	assert.Nil(t, err)
+	assert.Nil(t, values)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	testStore(t, store)
+
+	// with unsupported value
+	assert.NotNil(t, store.Save(newId(), map[string]interface{}{"key": make(chan int)}, time.Minute))
+
+	// purge expired
+	store.Save(newId(), map[string]interface{}{}, time.Nanosecond)
+	store.lastPurge = time.Time{}
+	store.Load(newId())
+	assert.Empty(t, store.records)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := path.Join(t.TempDir(), "sessions")
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+	testStore(t, store)
+
+	// with invalid id
+	_, err = store.Load("../ut")
+	assert.NotNil(t, err)
+	assert.NotNil(t, store.Save("../ut", nil, time.Minute))
+	assert.NotNil(t, store.Delete("../ut"))
+
+	// with invalid file
+	id := newId()
+	assert.Nil(t, os.WriteFile(store.path(id), []byte("ut"), 0600))
+	_, err = store.Load(id)
+	assert.NotNil(t, err)
+
+	// purge expired
+	store.Save(id, map[string]interface{}{}, time.Nanosecond)
+	store.lastPurge = time.Time{}
+	store.Load(newId())
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 0)
+
+	// with invalid dir
+	file := path.Join(t.TempDir(), "file")
+	os.WriteFile(file, []byte{}, 0600)
+	_, err = NewFileStore(path.Join(file, "dir"))
+	assert.NotNil(t, err)
+}
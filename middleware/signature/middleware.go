@@ -0,0 +1,168 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Package rkechosig is a middleware for echo framework which verifies HMAC signature of requests
+package rkechosig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-entry/v2/middleware"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Middleware verifies HMAC-SHA256 signature of requests.
+//
+// Signature is calculated over method, request URI, timestamp, nonce and SHA256 digest of body, see StringToSign().
+// Body would be restored after verification, so handlers could still read it.
+//
+// 1: Request without valid signature would be rejected with 401.
+// 2: Request with timestamp out of skew window or replayed nonce would be rejected with 401.
+// 3: Request with body larger than max body size would be rejected with 413.
+func Middleware(opts ...Option) echo.MiddlewareFunc {
+	set := newOptionSet(opts...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(rkmid.EntryNameKey.String(), set.EntryName)
+
+			if set.Skipper(ctx) || set.ShouldIgnore(ctx) {
+				return next(ctx)
+			}
+
+			allowed, ok := set.allowedKeys(ctx.Request().URL.Path)
+			if !ok {
+				return next(ctx)
+			}
+
+			req := ctx.Request()
+			keyId := req.Header.Get(HeaderKeyId)
+			timestamp := req.Header.Get(HeaderTimestamp)
+			nonce := req.Header.Get(HeaderNonce)
+			signature := req.Header.Get(HeaderSignature)
+
+			secret, ok := set.keys[keyId]
+			if !ok || (allowed != nil && !allowed[keyId]) || len(timestamp) < 1 || len(nonce) < 1 || len(signature) < 1 {
+				return unauthorized(ctx, "Missing or unknown signature")
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return unauthorized(ctx, "Invalid signature timestamp")
+			}
+			if skew := time.Since(time.Unix(unix, 0)); skew > set.Skew || skew < -set.Skew {
+				return unauthorized(ctx, "Signature timestamp out of skew window")
+			}
+
+			body, err := readBody(req, set.MaxBodySize)
+			if err == errBodyTooLarge {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusRequestEntityTooLarge, "Request body too large")
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+			if err != nil {
+				errResp := rkmid.GetErrorBuilder().New(http.StatusBadRequest, "Failed to read request body", err.Error())
+				return ctx.JSON(errResp.Code(), errResp)
+			}
+
+			expected := sign(secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+			actual, err := hex.DecodeString(signature)
+			if err != nil || !hmac.Equal(expected, actual) {
+				return unauthorized(ctx, "Invalid signature")
+			}
+
+			// only nonce of valid signature would be remembered
+			if !set.nonces.add(keyId + ":" + nonce) {
+				return unauthorized(ctx, "Replayed signature nonce")
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// StringToSign returns canonical string of request which would be signed:
+//
+//	METHOD \n REQUEST_URI \n TIMESTAMP \n NONCE \n HEX(SHA256(BODY))
+func StringToSign(method, requestUri, timestamp, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestUri,
+		timestamp,
+		nonce,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+}
+
+// Sign adds signature headers to request with key id and secret, body of request would be restored.
+func Sign(req *http.Request, keyId, secret string) error {
+	body, err := readBody(req, -1)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(HeaderKeyId, keyId)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderSignature, hex.EncodeToString(
+		sign([]byte(secret), StringToSign(req.Method, req.URL.RequestURI(), timestamp, req.Header.Get(HeaderNonce), body))))
+
+	return nil
+}
+
+// sign returns HMAC-SHA256 of str
+func sign(secret []byte, str string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(str))
+	return mac.Sum(nil)
+}
+
+// readBody reads request body and restores it, returns error if body is larger than max if max is positive
+func readBody(req *http.Request, max int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}, nil
+	}
+
+	reader := io.Reader(req.Body)
+	if max > 0 {
+		reader = io.LimitReader(req.Body, max+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if max > 0 && int64(len(body)) > max {
+		return nil, errBodyTooLarge
+	}
+
+	return body, nil
+}
+
+// unauthorized responds 401 with rk error
+func unauthorized(ctx echo.Context, msg string) error {
+	errResp := rkmid.GetErrorBuilder().New(http.StatusUnauthorized, msg)
+	return ctx.JSON(errResp.Code(), errResp)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosig
+
+import (
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEcho(opts ...Option) *echo.Echo {
+	e := echo.New()
+	e.Use(Middleware(opts...))
+
+	handler := func(ctx echo.Context) error {
+		body, _ := ioutil.ReadAll(ctx.Request().Body)
+		return ctx.String(http.StatusOK, string(body))
+	}
+	e.POST("/webhook/github", handler)
+	e.POST("/partner/order", handler)
+	e.POST("/public", handler)
+
+	return e
+}
+
+func newReq(path, body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+}
+
+func serve(e *echo.Echo, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	e := newEcho(
+		WithKey("github", "github-secret"),
+		WithKey("partner", "partner-secret"),
+		WithPath("/webhook", "github"),
+		WithPath("/partner"),
+		WithMaxBodySize(16))
+
+	// case 1: path without verification
+	assert.Equal(t, http.StatusOK, serve(e, newReq("/public", "")).Code)
+
+	// case 2: without signature
+	assert.Equal(t, http.StatusUnauthorized, serve(e, newReq("/webhook/github", "")).Code)
+
+	// case 3: happy case, body is readable by handler
+	req := newReq("/webhook/github?delivery=1", "ut-body")
+	assert.Nil(t, Sign(req, "github", "github-secret"))
+	w := serve(e, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ut-body", w.Body.String())
+
+	// case 4: replayed request
+	replay := newReq("/webhook/github?delivery=1", "ut-body")
+	replay.Header = req.Header.Clone()
+	assert.Equal(t, http.StatusUnauthorized, serve(e, replay).Code)
+
+	// case 5: key not allowed on path
+	req = newReq("/webhook/github", "ut-body")
+	Sign(req, "partner", "partner-secret")
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// case 6: any key allowed on path
+	req = newReq("/partner/order", "ut-body")
+	Sign(req, "partner", "partner-secret")
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	// case 7: tampered body
+	req = newReq("/partner/order", "ut-body")
+	Sign(req, "partner", "partner-secret")
+	req.Body = ioutil.NopCloser(strings.NewReader("ut-tampered"))
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// case 8: wrong secret
+	req = newReq("/partner/order", "ut-body")
+	Sign(req, "partner", "invalid")
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// case 9: timestamp out of skew window
+	req = newReq("/partner/order", "ut-body")
+	timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	req.Header.Set(HeaderKeyId, "partner")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, "ut-nonce")
+	req.Header.Set(HeaderSignature, hex.EncodeToString(sign([]byte("partner-secret"),
+		StringToSign(http.MethodPost, "/partner/order", timestamp, "ut-nonce", []byte("ut-body")))))
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// case 10: invalid timestamp
+	req.Header.Set(HeaderTimestamp, "invalid")
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// case 11: body too large
+	req = newReq("/partner/order", strings.Repeat("x", 17))
+	Sign(req, "partner", "partner-secret")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(e, req).Code)
+}
+
+func TestStringToSign(t *testing.T) {
+	assert.Equal(t,
+		"POST\n/ut?a=b\n1\nut-nonce\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		StringToSign("post", "/ut?a=b", "1", "ut-nonce", nil))
+}
+
+func TestNonceCache(t *testing.T) {
+	c := newNonceCache(time.Millisecond)
+	assert.True(t, c.add("ut-nonce"))
+	assert.False(t, c.add("ut-nonce"))
+
+	time.Sleep(2 * time.Millisecond)
+	c.lastPurge = time.Time{}
+	assert.True(t, c.add("ut-nonce"))
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosig
+
+import (
+	"sync"
+	"time"
+)
+
+// newNonceCache create nonceCache which remembers nonce for ttl
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{
+		ttl:   ttl,
+		items: make(map[string]time.Time),
+	}
+}
+
+// nonceCache remembers seen nonce in order to reject replayed requests
+type nonceCache struct {
+	lock      sync.Mutex
+	ttl       time.Duration
+	items     map[string]time.Time
+	lastPurge time.Time
+}
+
+// add returns false if nonce was seen and has not expired
+func (c *nonceCache) add(nonce string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	c.purge(now)
+
+	if expireAt, ok := c.items[nonce]; ok && now.Before(expireAt) {
+		return false
+	}
+
+	c.items[nonce] = now.Add(c.ttl)
+	return true
+}
+
+// purge removes expired nonce at most once per second, lock should be held by caller
+func (c *nonceCache) purge(now time.Time) {
+	if now.Sub(c.lastPurge) < time.Second {
+		return
+	}
+	c.lastPurge = now
+
+	for k, expireAt := range c.items {
+		if !now.Before(expireAt) {
+			delete(c.items, k)
+		}
+	}
+}
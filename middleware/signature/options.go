@@ -0,0 +1,239 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosig
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	rkmid "github.com/rookie-ninja/rk-entry/v2/middleware"
+	"github.com/rs/xid"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderKeyId is the request header carrying id of signing key
+	HeaderKeyId = "X-Signature-Key-Id"
+	// HeaderTimestamp is the request header carrying unix seconds while signing
+	HeaderTimestamp = "X-Signature-Timestamp"
+	// HeaderNonce is the request header carrying random nonce which prevents replay
+	HeaderNonce = "X-Signature-Nonce"
+	// HeaderSignature is the request header carrying hex encoded HMAC-SHA256 signature
+	HeaderSignature = "X-Signature"
+)
+
+var (
+	optionsMap     = make(map[string]*optionSet)
+	defaultSkipper = func(echo.Context) bool {
+		return false
+	}
+	defaultSkew        = 5 * time.Minute
+	defaultMaxBodySize = int64(4 << 20)
+	errBodyTooLarge    = errors.New("request body too large")
+)
+
+// Create new optionSet with options.
+func newOptionSet(opts ...Option) *optionSet {
+	set := &optionSet{
+		EntryName:   xid.New().String(),
+		EntryType:   "",
+		Skipper:     defaultSkipper,
+		Skew:        defaultSkew,
+		MaxBodySize: defaultMaxBodySize,
+		keys:        make(map[string][]byte),
+		paths:       make(map[string]map[string]bool),
+	}
+
+	for i := range opts {
+		opts[i](set)
+	}
+
+	// nonce should be remembered while timestamp is within skew window
+	set.nonces = newNonceCache(2 * set.Skew)
+
+	if _, ok := optionsMap[set.EntryName]; !ok {
+		optionsMap[set.EntryName] = set
+	}
+
+	return set
+}
+
+// Options which is used while initializing extension interceptor
+type optionSet struct {
+	EntryName    string
+	EntryType    string
+	Skipper      Skipper
+	Skew         time.Duration
+	MaxBodySize  int64
+	keys         map[string][]byte
+	paths        map[string]map[string]bool
+	nonces       *nonceCache
+	ignorePrefix []string
+}
+
+// ShouldIgnore determine whether signature verification should be ignored based on path
+func (set *optionSet) ShouldIgnore(ctx echo.Context) bool {
+	if ctx != nil && ctx.Request().URL != nil {
+		for i := range set.ignorePrefix {
+			if strings.HasPrefix(ctx.Request().URL.Path, set.ignorePrefix[i]) {
+				return true
+			}
+		}
+
+		return rkmid.ShouldIgnoreGlobal(ctx.Request().URL.Path)
+	}
+
+	return false
+}
+
+// allowedKeys returns key ids allowed on path and whether path should be verified.
+//
+// If no path was configured, all paths would be verified with all keys,
+// otherwise, key ids of the longest matching path prefix would be returned, nil means all keys.
+func (set *optionSet) allowedKeys(urlPath string) (map[string]bool, bool) {
+	if len(set.paths) < 1 {
+		return nil, true
+	}
+
+	matched := ""
+	for prefix := range set.paths {
+		if strings.HasPrefix(urlPath, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+
+	if len(matched) < 1 {
+		return nil, false
+	}
+
+	return set.paths[matched], true
+}
+
+// ***************** BootConfig *****************
+
+// BootConfig for YAML
+type BootConfig struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	Ignore      []string `yaml:"ignore" json:"ignore"`
+	SkewMs      int      `yaml:"skewMs" json:"skewMs"`
+	MaxBodySize int64    `yaml:"maxBodySize" json:"maxBodySize"`
+	Keys        []struct {
+		Id     string `yaml:"id" json:"id"`
+		Secret string `yaml:"secret" json:"secret"`
+	} `yaml:"keys" json:"keys"`
+	Paths []struct {
+		Path   string   `yaml:"path" json:"path"`
+		KeyIds []string `yaml:"keyIds" json:"keyIds"`
+	} `yaml:"paths" json:"paths"`
+}
+
+// ToOptions convert BootConfig into Option list
+func ToOptions(config *BootConfig, entryName, entryType string) []Option {
+	opts := make([]Option, 0)
+
+	if config.Enabled {
+		opts = append(opts,
+			WithEntryNameAndType(entryName, entryType),
+			WithSkew(time.Duration(config.SkewMs)*time.Millisecond),
+			WithMaxBodySize(config.MaxBodySize),
+			WithPathToIgnore(config.Ignore...))
+
+		for i := range config.Keys {
+			opts = append(opts, WithKey(config.Keys[i].Id, config.Keys[i].Secret))
+		}
+
+		for i := range config.Paths {
+			opts = append(opts, WithPath(config.Paths[i].Path, config.Paths[i].KeyIds...))
+		}
+	}
+
+	return opts
+}
+
+// ***************** Option *****************
+
+// Option if for middleware options while creating middleware
+type Option func(*optionSet)
+
+// WithEntryNameAndType provide entry name and entry type.
+func WithEntryNameAndType(entryName, entryType string) Option {
+	return func(opt *optionSet) {
+		opt.EntryName = entryName
+		opt.EntryType = entryType
+	}
+}
+
+// WithKey provide signing key id and secret.
+func WithKey(id, secret string) Option {
+	return func(opt *optionSet) {
+		if len(id) > 0 && len(secret) > 0 {
+			opt.keys[id] = []byte(secret)
+		}
+	}
+}
+
+// WithPath enable signature verification on path prefix, only keyIds are allowed if provided.
+// All paths would be verified with any of keys if no path provided.
+func WithPath(prefix string, keyIds ...string) Option {
+	return func(opt *optionSet) {
+		if len(prefix) < 1 {
+			return
+		}
+
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+
+		var allowed map[string]bool
+		if len(keyIds) > 0 {
+			allowed = make(map[string]bool)
+			for i := range keyIds {
+				allowed[keyIds[i]] = true
+			}
+		}
+
+		opt.paths[prefix] = allowed
+	}
+}
+
+// WithSkew provide max difference between signing timestamp and server time, default: 5 minutes.
+func WithSkew(skew time.Duration) Option {
+	return func(opt *optionSet) {
+		if skew > 0 {
+			opt.Skew = skew
+		}
+	}
+}
+
+// WithMaxBodySize provide max size of request body which would be digested, default: 4MB.
+func WithMaxBodySize(size int64) Option {
+	return func(opt *optionSet) {
+		if size > 0 {
+			opt.MaxBodySize = size
+		}
+	}
+}
+
+// WithSkipper provide skipper.
+func WithSkipper(skip Skipper) Option {
+	return func(opt *optionSet) {
+		opt.Skipper = skip
+	}
+}
+
+// WithPathToIgnore provide path prefix to ignore middleware
+func WithPathToIgnore(prefix ...string) Option {
+	return func(opt *optionSet) {
+		for i := range prefix {
+			if len(prefix[i]) > 0 {
+				opt.ignorePrefix = append(opt.ignorePrefix, prefix[i])
+			}
+		}
+	}
+}
+
+// Skipper default skipper will always return false
+type Skipper func(echo.Context) bool
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkechosig
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewOptionSet(t *testing.T) {
+	// without options
+	set := newOptionSet()
+	assert.NotEmpty(t, set.EntryName)
+	assert.False(t, set.Skipper(echo.New().NewContext(nil, nil)))
+	assert.Equal(t, defaultSkew, set.Skew)
+	assert.Equal(t, defaultMaxBodySize, set.MaxBodySize)
+	assert.Equal(t, 2*defaultSkew, set.nonces.ttl)
+
+	// with options
+	set = newOptionSet(
+		WithEntryNameAndType("ut-name", "ut-type"),
+		WithKey("ut-id", "ut-secret"),
+		WithKey("", "ut-secret"),
+		WithPath("ut-path", "ut-id"),
+		WithPath(""),
+		WithSkew(time.Second),
+		WithMaxBodySize(1),
+		WithPathToIgnore("/ut-ignore"))
+	assert.Equal(t, []byte("ut-secret"), set.keys["ut-id"])
+	assert.Len(t, set.keys, 1)
+	assert.Len(t, set.paths, 1)
+	assert.True(t, set.paths["/ut-path"]["ut-id"])
+	assert.Equal(t, time.Second, set.Skew)
+	assert.Equal(t, int64(1), set.MaxBodySize)
+	assert.Contains(t, set.ignorePrefix, "/ut-ignore")
+}
+
+func TestOptionSet_AllowedKeys(t *testing.T) {
+	// without paths
+	set := newOptionSet()
+	allowed, ok := set.allowedKeys("/any")
+	assert.True(t, ok)
+	assert.Nil(t, allowed)
+
+	// with paths
+	set = newOptionSet(WithPath("/v1", "a"), WithPath("/v1/webhook", "b"))
+	allowed, ok = set.allowedKeys("/v1/webhook/x")
+	assert.True(t, ok)
+	assert.True(t, allowed["b"])
+	_, ok = set.allowedKeys("/v2")
+	assert.False(t, ok)
+}
+
+func TestToOptions(t *testing.T) {
+	config := &BootConfig{
+		Enabled: false,
+	}
+
+	// with disabled
+	assert.Empty(t, ToOptions(config, "", ""))
+
+	// with enabled
+	config.Enabled = true
+	config.SkewMs = 1000
+	config.Keys = append(config.Keys, struct {
+		Id     string `yaml:"id" json:"id"`
+		Secret string `yaml:"secret" json:"secret"`
+	}{Id: "ut-id", Secret: "ut-secret"})
+	config.Paths = append(config.Paths, struct {
+		Path   string   `yaml:"path" json:"path"`
+		KeyIds []string `yaml:"keyIds" json:"keyIds"`
+	}{Path: "/ut-path"})
+
+	set := newOptionSet(ToOptions(config, "ut-entry", "ut-type")...)
+	assert.Equal(t, time.Second, set.Skew)
+	assert.Len(t, set.keys, 1)
+	assert.Len(t, set.paths, 1)
+}
@@ -35,6 +35,7 @@ func Middleware(opts ...rkmidtrace.Option) echo.MiddlewareFunc {
 				traceId := beforeCtx.Output.Span.SpanContext().TraceID().String()
 				rkechoctx.GetEvent(ctx).SetTraceId(traceId)
 				ctx.Response().Header().Set(rkmid.HeaderTraceId, traceId)
+				ctx.SetRequest(ctx.Request().WithContext(rkechoctx.WithTraceId(ctx.Request().Context(), traceId)))
 				ctx.Set(rkmid.SpanKey.String(), beforeCtx.Output.Span)
 			}
 
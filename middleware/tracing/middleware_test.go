@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"github.com/labstack/echo/v4"
+	"github.com/rookie-ninja/rk-echo/middleware/context"
 	"github.com/rookie-ninja/rk-entry/v2/middleware"
 	"github.com/rookie-ninja/rk-entry/v2/middleware/tracing"
 	"github.com/stretchr/testify/assert"
@@ -46,6 +47,9 @@ func TestMiddleware(t *testing.T) {
 
 	spanFromCtx := ctx.Get(rkmid.SpanKey.String())
 	assert.Equal(t, span, spanFromCtx)
+
+	// trace id is stored in context of request
+	assert.Equal(t, span.SpanContext().TraceID().String(), rkechoctx.GetTraceIdFromContext(ctx.Request().Context()))
 }
 
 func newCtx() (echo.Context, *httptest.ResponseRecorder) {